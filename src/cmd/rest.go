@@ -88,6 +88,10 @@ func restServer(_ *cobra.Command, _ []string) {
 
 		app.Use(basicauth.New(basicauth.Config{
 			Users: account,
+			// Flow webhooks are authenticated by their token
+			Next: func(c *fiber.Ctx) bool {
+				return rest.IsFlowWebhookPath(c.Path())
+			},
 		}))
 	}
 
@@ -157,7 +161,17 @@ func restServer(_ *cobra.Command, _ []string) {
 
 	// Initialize Calendar routes
 	if calendarRepository != nil {
-		rest.InitRestCalendar(platformAPI, calendarRepository)
+		rest.InitRestCalendar(platformAPI, calendarRepository, calendarService)
+	}
+
+	// Initialize Notification routes
+	if notificationService != nil {
+		rest.InitRestNotification(platformAPI, notificationService)
+	}
+
+	// Initialize Usage routes
+	if usageService != nil {
+		rest.InitRestUsage(platformAPI, usageService, settingsService)
 	}
 
 	// Device management routes (no device_id required)
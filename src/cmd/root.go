@@ -13,6 +13,7 @@ import (
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	agentRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/delivery"
 	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
 	analyticsRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/analytics"
 	knowledgeRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/knowledge"
@@ -20,6 +21,10 @@ import (
 	broadcastPkg "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/broadcast"
 	followupPkg "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/followup"
 	calendarRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/calendar"
+	notificationRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/notification"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/translation"
+	usageRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/usage"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
 	domainApp "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/app"
 	domainChat "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chat"
 	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
@@ -34,6 +39,7 @@ import (
 	instagramPkg "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/instagram"
 	telegramBot "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/telegram"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/encryption"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/usecase"
 	_ "github.com/lib/pq"
@@ -89,11 +95,29 @@ var (
 	// Follow-up worker for automatic follow-up messages
 	followUpWorker *followupPkg.FollowUpWorker
 	
+	// Flow scheduler for schedule-triggered flows
+	flowScheduler *flowRepo.FlowScheduler
+
+	// Flow resumer for runs paused at delay and wait for reply nodes
+	flowResumer *flowRepo.FlowResumer
+
+	// Flow execution pruner for execution history retention
+	flowExecutionPruner *flowRepo.ExecutionPruner
+	
 	// Health service for system monitoring
 	healthService *usecase.HealthService
 	
 	// Calendar repository
 	calendarRepository *calendarRepo.SQLiteRepository
+	
+	// Calendar service for Google Calendar access
+	calendarService *usecase.CalendarService
+
+	// Notification service for sentiment alerts and escalations
+	notificationService *usecase.NotificationService
+
+	// Usage service for LLM token and cost accounting
+	usageService *usecase.UsageService
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -115,7 +139,7 @@ func init() {
 	initFlags()
 
 	// Then initialize other components
-	cobra.OnInitialize(initEnvConfig, initApp)
+	cobra.OnInitialize(initEnvConfig, initEncryption, initApp)
 }
 
 // initEnvConfig loads configuration from environment variables
@@ -145,6 +169,12 @@ func initEnvConfig() {
 		proxies := strings.Split(envTrustedProxies, ",")
 		config.AppTrustedProxies = proxies
 	}
+	if envMasterKey := viper.GetString("app_master_key"); envMasterKey != "" {
+		config.AppMasterKeys = strings.Split(envMasterKey, ",")
+	}
+	if envMasterKeyFile := viper.GetString("app_master_key_file"); envMasterKeyFile != "" {
+		config.AppMasterKeyFile = envMasterKeyFile
+	}
 
 	// Database settings
 	if envDBURI := viper.GetString("db_uri"); envDBURI != "" {
@@ -195,6 +225,24 @@ func initEnvConfig() {
 	if envAISystemPrompt := viper.GetString("ai_chatbot_system_prompt"); envAISystemPrompt != "" {
 		config.AIChatbotSystemPrompt = envAISystemPrompt
 	}
+
+	// Calendar settings
+	if envCalendarRedirect := viper.GetString("calendar_oauth_redirect_url"); envCalendarRedirect != "" {
+		config.CalendarOAuthRedirectURL = envCalendarRedirect
+	}
+
+	// Flow execution history settings
+	if viper.IsSet("flow_execution_retention_days") {
+		config.FlowExecutionRetentionDays = viper.GetInt("flow_execution_retention_days")
+	}
+	if viper.IsSet("flow_execution_max_per_flow") {
+		config.FlowExecutionMaxPerFlow = viper.GetInt("flow_execution_max_per_flow")
+	}
+
+	// Flow engine settings
+	if viper.IsSet("flow_max_steps") {
+		config.FlowMaxSteps = viper.GetInt("flow_max_steps")
+	}
 }
 
 func initFlags() {
@@ -243,6 +291,18 @@ func initFlags() {
 		config.AppTrustedProxies,
 		`trusted proxy IP ranges for reverse proxy deployments --trusted-proxies <string> | example: --trusted-proxies="0.0.0.0/0" or --trusted-proxies="10.0.0.0/8,172.16.0.0/12"`,
 	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.AppMasterKeys,
+		"master-key", "",
+		config.AppMasterKeys,
+		`base64 master keys that encrypt stored API keys and credentials, the first encrypts and the others only decrypt, see the secrets command --master-key <string> | example: --master-key="<new key>,<old key>"`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.AppMasterKeyFile,
+		"master-key-file", "",
+		config.AppMasterKeyFile,
+		`file with master keys, one per line, used after those of --master-key --master-key-file <string> | example: --master-key-file="/run/secrets/master_key"`,
+	)
 
 	// Database flags
 	rootCmd.PersistentFlags().StringVarP(
@@ -333,6 +393,36 @@ func initFlags() {
 		config.AIChatbotSystemPrompt,
 		`System prompt for AI chatbot --ai-chatbot-system-prompt <string> | example: --ai-chatbot-system-prompt="You are a helpful assistant."`,
 	)
+
+	// Calendar flags
+	rootCmd.PersistentFlags().StringVarP(
+		&config.CalendarOAuthRedirectURL,
+		"calendar-oauth-redirect-url", "",
+		config.CalendarOAuthRedirectURL,
+		`Google OAuth redirect URL for agent calendars --calendar-oauth-redirect-url <string> | example: --calendar-oauth-redirect-url="https://example.com/api/calendar/oauth/callback"`,
+	)
+
+	// Flow execution history flags
+	rootCmd.PersistentFlags().IntVarP(
+		&config.FlowExecutionRetentionDays,
+		"flow-execution-retention-days", "",
+		config.FlowExecutionRetentionDays,
+		`delete flow execution history older than this many days, 0 to keep it --flow-execution-retention-days <int> | example: --flow-execution-retention-days=7`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.FlowExecutionMaxPerFlow,
+		"flow-execution-max-per-flow", "",
+		config.FlowExecutionMaxPerFlow,
+		`maximum flow executions kept per flow, 0 for no limit --flow-execution-max-per-flow <int> | example: --flow-execution-max-per-flow=500`,
+	)
+
+	// Flow engine flags
+	rootCmd.PersistentFlags().IntVarP(
+		&config.FlowMaxSteps,
+		"flow-max-steps", "",
+		config.FlowMaxSteps,
+		`maximum nodes a flow run may execute, loop iterations included, 0 for no limit --flow-max-steps <int> | example: --flow-max-steps=5000`,
+	)
 }
 
 func initChatStorage() (*sql.DB, error) {
@@ -359,12 +449,40 @@ func initChatStorage() (*sql.DB, error) {
 	return db, nil
 }
 
+// initEncryption loads the master keys that encrypt secrets stored in the databases
+func initEncryption() {
+	keys := append([]string{}, config.AppMasterKeys...)
+	if config.AppMasterKeyFile != "" {
+		fileKeys, err := encryption.ReadKeyFile(config.AppMasterKeyFile)
+		if err != nil {
+			logrus.Fatalf("failed to load master keys: %v", err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if strings.TrimSpace(strings.Join(keys, "")) == "" {
+		logrus.Warn("No master key configured: API keys and credentials are stored unencrypted. Set APP_MASTER_KEY or APP_MASTER_KEY_FILE")
+		return
+	}
+
+	keyring, err := encryption.NewKeyring(keys...)
+	if err != nil {
+		logrus.Fatalf("invalid master key: %v", err)
+	}
+	encryption.SetKeyring(keyring)
+	logrus.Infof("Stored secrets are encrypted with master key %s", keyring.KeyID())
+}
+
 func initApp() {
 	if config.AppDebug {
 		config.WhatsappLogLevel = "DEBUG"
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 
+	// Maintenance commands open only the databases they work on
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil && cmd.Annotations[annotationSkipAppInit] == "true" {
+		return
+	}
+
 	//preparing folder if not exist
 	err := utils.CreateFolder(config.PathQrCode, config.PathSendItems, config.PathStorages, config.PathMedia)
 	if err != nil {
@@ -422,6 +540,10 @@ func initApp() {
 		}
 		// Initialize WhatsApp message handler for agents
 		whatsapp.InitAgentHandler(agentRepository)
+		whatsapp.GetAgentHandler().SetResponder(agentService.HandleIncomingMessageStream)
+		// Voice replies are sent as voice notes through SendAudio
+		agentService.SetSendUsecase(sendUsecase)
+		whatsapp.GetAgentHandler().SetVoiceReplier(agentService)
 		logrus.Info("Agent service initialized successfully")
 		
 		// Initialize Telegram bot manager
@@ -443,6 +565,24 @@ func initApp() {
 		logrus.Warnf("failed to initialize flow repository: %v", err)
 	} else {
 		flowService = usecase.NewFlowService(flowRepository)
+		flowExecutor := flowRepo.NewFlowExecutor(flowRepository)
+		flowService.SetExecutor(flowExecutor)
+		if agentService != nil {
+			agentService.SetFlowService(flowService)
+			// Send nodes deliver through the agent's integrations
+			flowExecutor.SetMessageSender(delivery.NewSender(agentRepository, sendUsecase))
+		}
+		// Run schedule-triggered flows in the background
+		flowScheduler = flowRepo.NewFlowScheduler(flowRepository, flowExecutor)
+		go flowScheduler.Start(context.Background())
+
+		// Resume paused runs, including those left by a restart
+		flowResumer = flowRepo.NewFlowResumer(flowExecutor)
+		go flowResumer.Start(context.Background())
+
+		// Enforce execution history retention in the background
+		flowExecutionPruner = flowRepo.NewExecutionPruner(flowRepository, config.FlowExecutionRetentionDays, config.FlowExecutionMaxPerFlow)
+		go flowExecutionPruner.Start(context.Background())
 		logrus.Info("Flow service initialized successfully")
 	}
 	
@@ -463,6 +603,9 @@ func initApp() {
 		logrus.Warnf("failed to initialize knowledge repository: %v", err)
 	} else {
 		knowledgeService = usecase.NewKnowledgeService(knowledgeRepository, agentService)
+		if agentService != nil {
+			agentService.SetKnowledgeService(knowledgeService)
+		}
 		logrus.Info("Knowledge service initialized successfully")
 	}
 	
@@ -473,6 +616,12 @@ func initApp() {
 	} else {
 		settingsService = usecase.NewSettingsService(settingsRepository)
 		logrus.Info("Settings service initialized successfully")
+
+		// Agent service is created before settings, link it now
+		if agentService != nil {
+			agentService.SetSettingsService(settingsService)
+			logrus.Info("Settings service linked to Agent service")
+		}
 		
 		// Initialize Broadcast worker if agent repository is available
 		if agentRepository != nil {
@@ -504,8 +653,46 @@ func initApp() {
 	if err != nil {
 		logrus.Warnf("failed to initialize calendar repository: %v", err)
 	} else {
+		calendarService = usecase.NewCalendarService(calendarRepository)
+		if agentService != nil {
+			agentService.SetCalendarService(calendarService)
+		}
 		logrus.Info("Calendar repository initialized successfully")
 	}
+
+	// Initialize Notification repository
+	notificationRepository, err := notificationRepo.NewSQLiteRepository(config.PathStorages + "/notifications.db")
+	if err != nil {
+		logrus.Warnf("failed to initialize notification repository: %v", err)
+	} else {
+		notificationService = usecase.NewNotificationService(notificationRepository)
+		if agentService != nil {
+			agentService.SetNotificationService(notificationService)
+		}
+		logrus.Info("Notification repository initialized successfully")
+	}
+
+	// Initialize translation cache
+	translationCache, err := translation.NewCache(config.PathStorages+"/translations.db", translation.DefaultCapacity)
+	if err != nil {
+		logrus.Warnf("failed to initialize translation cache: %v", err)
+	} else if agentService != nil {
+		agentService.SetTranslationCache(translationCache)
+		logrus.Info("Translation cache initialized successfully")
+	}
+
+	// Initialize Usage repository
+	usageRepository, err := usageRepo.NewSQLiteRepository(config.PathStorages + "/usage.db")
+	if err != nil {
+		logrus.Warnf("failed to initialize usage repository: %v", err)
+	} else {
+		usageService = usecase.NewUsageService(usageRepository)
+		aiService.SetUsageRecorder(usageService.Record)
+		if agentService != nil {
+			agentService.SetUsageService(usageService)
+		}
+		logrus.Info("Usage repository initialized successfully")
+	}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	agentRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/agent"
+	calendarRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/calendar"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+	settingsRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/settings"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/encryption"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// annotationSkipAppInit marks commands that run without connecting to WhatsApp or starting workers
+const annotationSkipAppInit = "skip_app_init"
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the encryption of stored API keys and credentials",
+	Long: `Agent API keys, integration tokens, flow credentials, calendar credentials and alert webhook URLs
+are encrypted in the databases under storages with the master keys of --master-key, APP_MASTER_KEY or
+APP_MASTER_KEY_FILE.
+
+To encrypt an existing installation:
+  1. Create a key with "secrets generate-key" and set it as APP_MASTER_KEY
+  2. Run "secrets encrypt" while the server is stopped
+
+To rotate the master key:
+  1. Create a new key and list it first: APP_MASTER_KEY=<new key>,<old key>
+  2. Run "secrets encrypt" while the server is stopped
+  3. Remove the old key`,
+	Annotations: map[string]string{annotationSkipAppInit: "true"},
+}
+
+var secretsGenerateKeyCmd = &cobra.Command{
+	Use:         "generate-key",
+	Short:       "Print a new random master key",
+	Annotations: map[string]string{annotationSkipAppInit: "true"},
+	Run: func(_ *cobra.Command, _ []string) {
+		key, err := encryption.GenerateKey()
+		if err != nil {
+			logrus.Fatalf("failed to generate key: %v", err)
+		}
+		fmt.Println(key)
+	},
+}
+
+var secretsEncryptCmd = &cobra.Command{
+	Use:         "encrypt",
+	Short:       "Encrypt stored secrets that are in plaintext or use an older master key",
+	Annotations: map[string]string{annotationSkipAppInit: "true"},
+	Run:         encryptStoredSecrets,
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsGenerateKeyCmd, secretsEncryptCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+// secretStore is a repository that can re-encrypt the secrets it stores
+type secretStore interface {
+	EncryptSecrets(ctx context.Context) (int, error)
+}
+
+func encryptStoredSecrets(_ *cobra.Command, _ []string) {
+	if !encryption.Enabled() {
+		logrus.Fatal("no master key configured: set --master-key, APP_MASTER_KEY or APP_MASTER_KEY_FILE")
+	}
+	ctx := context.Background()
+
+	stores := []struct {
+		file string
+		open func(path string) (secretStore, error)
+	}{
+		{"agents.db", func(path string) (secretStore, error) { return agentRepo.NewSQLiteRepository(path) }},
+		{"flows.db", func(path string) (secretStore, error) { return flowRepo.NewSQLiteRepository(path) }},
+		{"calendar.db", func(path string) (secretStore, error) { return calendarRepo.NewSQLiteRepository(path) }},
+		{"settings.db", func(path string) (secretStore, error) { return settingsRepo.NewSQLiteRepository(path) }},
+	}
+	failed := false
+	for _, store := range stores {
+		path := filepath.Join(config.PathStorages, store.file)
+		// Databases are not created for features that were never used
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		repo, err := store.open(path)
+		if err != nil {
+			logrus.Errorf("%s: %v", store.file, err)
+			failed = true
+			continue
+		}
+		count, err := repo.EncryptSecrets(ctx)
+		if err != nil {
+			logrus.Errorf("%s: %v", store.file, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: %d rows encrypted\n", store.file, count)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -14,6 +14,8 @@ var (
 	AppBasicAuthCredential = []string{"admin:password"} // Default login: admin, password: password
 	AppBasePath            = ""
 	AppTrustedProxies      []string // Trusted proxy IP ranges (e.g., "0.0.0.0/0" for all, or specific CIDRs)
+	AppMasterKeys          []string // Base64 keys that encrypt stored secrets; the first encrypts, the others only decrypt
+	AppMasterKeyFile       = ""     // File with more master keys, one per line
 
 	McpPort = "8080"
 	McpHost = "localhost"
@@ -51,4 +53,14 @@ var (
 	AIChatbotAPIToken  = ""
 	AIChatbotModel     = "gpt-4o-mini"
 	AIChatbotSystemPrompt = "You are a helpful assistant. Respond concisely and helpfully to user messages."
+
+	// Calendar settings
+	CalendarOAuthRedirectURL = "" // Public URL of /api/calendar/oauth/callback registered in Google Cloud
+
+	// Flow execution history settings
+	FlowExecutionRetentionDays = 30   // Delete flow runs older than this many days (0 = keep forever)
+	FlowExecutionMaxPerFlow    = 1000 // Keep at most this many runs per flow (0 = no limit)
+
+	// Flow engine settings
+	FlowMaxSteps = 1000 // Most nodes one flow run may execute, loop iterations included (0 = no limit)
 )
@@ -3,6 +3,8 @@ package agent
 import (
 	"context"
 	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
 )
 
 // Agent represents an AI chatbot agent
@@ -10,12 +12,14 @@ type Agent struct {
 	ID             string    `json:"id"`
 	Name           string    `json:"name"`
 	Description    string    `json:"description,omitempty"`
-	APIKey         string    `json:"api_key"`          // OpenAI API key
+	Provider       string    `json:"provider"`        // openai, azure, anthropic
+	BaseURL        string    `json:"base_url"`        // Custom endpoint for OpenAI-compatible servers (Ollama, vLLM, LM Studio) or Azure
+	APIKey         string    `json:"api_key"`         // LLM provider API key
 	SerpAPIKey     string    `json:"serp_api_key"`    // SerpAPI key for internet access
-	Model          string    `json:"model"`            // gpt-4o-mini, gpt-4o, etc.
-	SystemPrompt   string    `json:"system_prompt"`    // AI behavior instructions
-	WelcomeMessage string    `json:"welcome_message"`  // First response template
-	IsActive       bool      `json:"is_active"`        // Enable/disable agent
+	Model          string    `json:"model"`           // gpt-4o-mini, gpt-4o, etc.
+	SystemPrompt   string    `json:"system_prompt"`   // AI behavior instructions
+	WelcomeMessage string    `json:"welcome_message"` // First response template
+	IsActive       bool      `json:"is_active"`       // Enable/disable agent
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
@@ -29,13 +33,14 @@ const (
 
 // Integration represents a messaging platform integration for an agent
 type Integration struct {
-	ID          string    `json:"id"`
-	AgentID     string    `json:"agent_id"`
-	Type        string    `json:"type"`        // whatsapp, telegram, instagram
-	IsConnected bool      `json:"is_connected"`
-	Config      string    `json:"config"`      // JSON config specific to integration type
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               string    `json:"id"`
+	AgentID          string    `json:"agent_id"`
+	Type             string    `json:"type"` // whatsapp, telegram, instagram
+	IsConnected      bool      `json:"is_connected"`
+	Config           string    `json:"config"`                      // JSON config specific to integration type
+	CustomerLanguage string    `json:"customer_language,omitempty"` // Fixed customer language when translation auto-detect is off
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // WhatsAppConfig holds WhatsApp-specific integration settings
@@ -61,31 +66,63 @@ type InstagramConfig struct {
 
 // Conversation tracks message history for context
 type Conversation struct {
-	ID            string    `json:"id"`
-	AgentID       string    `json:"agent_id"`
-	IntegrationID string    `json:"integration_id"`
-	RemoteJID     string    `json:"remote_jid"`      // User identifier (phone, chat_id, etc.)
-	IsFirstReply  bool      `json:"is_first_reply"`  // Whether welcome message was sent
-	IsManualMode  bool      `json:"is_manual_mode"`  // Whether AI is paused (manager takeover)
-	Notes         string    `json:"notes"`           // Manager notes
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                 string     `json:"id"`
+	AgentID            string     `json:"agent_id"`
+	IntegrationID      string     `json:"integration_id"`
+	RemoteJID          string     `json:"remote_jid"`                    // User identifier (phone, chat_id, etc.)
+	IsFirstReply       bool       `json:"is_first_reply"`                // Whether welcome message was sent
+	IsManualMode       bool       `json:"is_manual_mode"`                // Whether AI is paused (manager takeover)
+	Notes              string     `json:"notes"`                         // Manager notes
+	Summary            string     `json:"summary,omitempty"`             // Rolling summary of turns older than the history window
+	SummarizedUntil    *time.Time `json:"summarized_until,omitempty"`    // Timestamp of the last message folded into Summary
+	Language           string     `json:"language,omitempty"`            // Customer language (ISO 639-1)
+	LanguageConfidence float64    `json:"language_confidence,omitempty"` // Detection confidence (0-1), 1 for manual
+	LanguageManual     bool       `json:"language_manual"`               // Set from the live chat; auto-detection leaves it alone
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 // Message represents a single message in a conversation
 type Message struct {
+	ID                string    `json:"id"`
+	ConversationID    string    `json:"conversation_id"`
+	Role              string    `json:"role"` // user, assistant, system
+	Content           string    `json:"content"`
+	Timestamp         time.Time `json:"timestamp"`
+	KnowledgeChunkIDs []string  `json:"knowledge_chunk_ids,omitempty"` // Knowledge base chunks injected for this reply
+	SentimentScore    *float64  `json:"sentiment_score,omitempty"`     // -1 (very negative) to 1 (very positive), user messages only
+	SentimentLabel    string    `json:"sentiment_label,omitempty"`
+}
+
+// Built-in tool names an agent can enable
+const (
+	ToolKnowledgeSearch      = "knowledge_search"
+	ToolCalendarAvailability = "calendar_availability"
+	ToolCalendarBooking      = "calendar_booking"
+	ToolRunFlow              = "run_flow"
+	ToolWebSearch            = "web_search"
+	ToolHandoff              = "handoff_to_human"
+)
+
+// ToolInvocation is an audit record of a tool called by the agent during a conversation
+type ToolInvocation struct {
 	ID             string    `json:"id"`
 	ConversationID string    `json:"conversation_id"`
-	Role           string    `json:"role"`    // user, assistant, system
-	Content        string    `json:"content"`
-	Timestamp      time.Time `json:"timestamp"`
+	Tool           string    `json:"tool"`
+	Arguments      string    `json:"arguments"` // JSON arguments sent by the model
+	Result         string    `json:"result"`    // Text returned to the model
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 // CreateAgentRequest is the request body for creating an agent
 type CreateAgentRequest struct {
 	Name           string `json:"name" validate:"required,min=1,max=100"`
 	Description    string `json:"description,omitempty"`
-	APIKey         string `json:"api_key" validate:"required"`
+	Provider       string `json:"provider,omitempty"` // Defaults to openai
+	BaseURL        string `json:"base_url,omitempty"`
+	APIKey         string `json:"api_key"` // Required unless base_url points at a keyless server
 	SerpAPIKey     string `json:"serp_api_key,omitempty"` // Optional SerpAPI key
 	Model          string `json:"model" validate:"required"`
 	SystemPrompt   string `json:"system_prompt" validate:"required"`
@@ -96,6 +133,8 @@ type CreateAgentRequest struct {
 type UpdateAgentRequest struct {
 	Name           *string `json:"name,omitempty"`
 	Description    *string `json:"description,omitempty"`
+	Provider       *string `json:"provider,omitempty"`
+	BaseURL        *string `json:"base_url,omitempty"`
 	APIKey         *string `json:"api_key,omitempty"`
 	SerpAPIKey     *string `json:"serp_api_key,omitempty"`
 	Model          *string `json:"model,omitempty"`
@@ -109,6 +148,8 @@ type AgentResponse struct {
 	ID             string                `json:"id"`
 	Name           string                `json:"name"`
 	Description    string                `json:"description"`
+	Provider       string                `json:"provider"`
+	BaseURL        string                `json:"base_url,omitempty"`
 	APIKeyMasked   string                `json:"api_key_masked"` // sk-...xxxx
 	SerpAPIKeyMasked string              `json:"serp_api_key_masked,omitempty"` // SerpAPI key masked
 	Model          string                `json:"model"`
@@ -148,10 +189,16 @@ type IAgentRepository interface {
 	// Conversation management
 	GetOrCreateConversation(ctx context.Context, agentID, integrationID, remoteJID string) (*Conversation, error)
 	UpdateConversation(ctx context.Context, conversation *Conversation) error
+	UpdateConversationSummary(ctx context.Context, conversationID, summary string, summarizedUntil time.Time) error
 
 	// Message history (for AI context)
 	AddMessage(ctx context.Context, message *Message) error
 	GetRecentMessages(ctx context.Context, conversationID string, limit int) ([]*Message, error)
+	GetMessagesInRange(ctx context.Context, conversationID string, after, before time.Time, limit int) ([]*Message, error)
+
+	// Tool audit log
+	AddToolInvocation(ctx context.Context, invocation *ToolInvocation) error
+	GetToolInvocations(ctx context.Context, conversationID string, limit int) ([]*ToolInvocation, error)
 }
 
 // IAgentService defines business logic for agents
@@ -174,3 +221,47 @@ type IAgentService interface {
 	HandleIncomingMessage(ctx context.Context, agentID, integrationID, remoteJID, message string) (response string, err error)
 }
 
+// Attachment types
+const (
+	AttachmentTypeImage    = "image"
+	AttachmentTypeDocument = "document"
+)
+
+// Attachment is a file received with an incoming message. Images are passed to vision models
+// and PDF documents are converted to text, when the agent has vision enabled.
+type Attachment struct {
+	Type     string // image or document
+	MIMEType string
+	FileName string
+	Data     []byte
+}
+
+// Message types of incoming messages, as matched by flow trigger nodes
+const (
+	MessageTypeText     = "text"
+	MessageTypeImage    = "image"
+	MessageTypeAudio    = "audio"
+	MessageTypeDocument = "document"
+)
+
+type messageTypeKey struct{}
+
+// ContextWithMessageType records the type of the incoming message when it is not evident from the
+// text and attachments, e.g. a voice note that the channel already transcribed
+func ContextWithMessageType(ctx context.Context, messageType string) context.Context {
+	return context.WithValue(ctx, messageTypeKey{}, messageType)
+}
+
+// MessageTypeFromContext returns the type set with ContextWithMessageType, or ""
+func MessageTypeFromContext(ctx context.Context) string {
+	messageType, _ := ctx.Value(messageTypeKey{}).(string)
+	return messageType
+}
+
+// ReplyStream lets a channel deliver an agent reply while it is being generated.
+// Begin is only called when streaming is enabled for the agent, before the first Delta.
+type ReplyStream interface {
+	Begin(opts settings.StreamingSettings)
+	Delta(text string)
+}
+
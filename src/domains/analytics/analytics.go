@@ -39,3 +39,14 @@ type AgentStats struct {
 	MessagesReceived   int    `json:"messages_received"`
 	MessagesSent       int    `json:"messages_sent"`
 }
+
+// SentimentStats summarises the sentiment of incoming messages per agent
+type SentimentStats struct {
+	AgentID      string  `json:"agent_id"`
+	AgentName    string  `json:"agent_name"`
+	Analyzed     int     `json:"analyzed"`
+	Positive     int     `json:"positive"`
+	Neutral      int     `json:"neutral"`
+	Negative     int     `json:"negative"`
+	AverageScore float64 `json:"average_score"`
+}
@@ -14,5 +14,8 @@ type IAnalyticsRepository interface {
 	
 	// Agent statistics
 	GetAgentStats(ctx context.Context, period string) ([]AgentStats, error)
+
+	// Sentiment of incoming messages per agent
+	GetSentimentStats(ctx context.Context, period string) ([]SentimentStats, error)
 }
 
@@ -86,7 +86,7 @@ type ICalendarService interface {
 	
 	// OAuth flow
 	GetAuthURL(ctx context.Context, agentID string) (string, error)
-	HandleCallback(ctx context.Context, agentID string, code string) error
+	HandleCallback(ctx context.Context, state string, code string) error // state comes from the URL returned by GetAuthURL
 	
 	// Calendar operations
 	CreateEvent(ctx context.Context, req CreateEventRequest) (*CalendarEvent, error)
@@ -21,6 +21,10 @@ const (
 	NodeTypeDelay     = "delay"
 	NodeTypeCode      = "code"
 
+	NodeTypeWaitForReply = "wait_for_reply"
+	NodeTypeForEach      = "for_each"
+	NodeTypeParallel     = "parallel"
+
 	// Integrations
 	NodeTypeHTTPRequest  = "http_request"
 	NodeTypeDatabase     = "database"
@@ -40,12 +44,20 @@ const (
 const (
 	CredentialTypeDatabase     = "database"
 	CredentialTypeOpenAI       = "openai"
+	CredentialTypeAnthropic    = "anthropic"
 	CredentialTypeGoogleSheets = "google_sheets"
 	CredentialTypeSMTP         = "smtp"
 	CredentialTypeSerpAPI      = "serp_api"
 	CredentialTypeCustomAPI    = "custom_api"
 )
 
+// Database drivers
+const (
+	DatabaseDriverPostgres = "postgres" // Default, also Supabase
+	DatabaseDriverMySQL    = "mysql"
+	DatabaseDriverSQLite   = "sqlite"
+)
+
 // Flow represents a workflow/automation
 type Flow struct {
 	ID          string    `json:"id"`
@@ -56,10 +68,50 @@ type Flow struct {
 	Nodes       []Node    `json:"nodes"`
 	Edges       []Edge    `json:"edges"`
 	Variables   []Variable `json:"variables,omitempty"` // Flow-level variables
+	Version     int       `json:"version"`      // Published version; 0 for drafts being tested
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// FlowVersion is a published definition of a flow. Versions are never changed: publishing and
+// rolling back both add a version.
+type FlowVersion struct {
+	FlowID    string     `json:"flow_id"`
+	Version   int        `json:"version"`
+	Nodes     []Node     `json:"nodes,omitempty"` // Omitted in version lists
+	Edges     []Edge     `json:"edges,omitempty"`
+	Variables []Variable `json:"variables,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// FlowDraft holds the changes to a flow that are not published yet
+type FlowDraft struct {
+	Nodes     []Node     `json:"nodes"`
+	Edges     []Edge     `json:"edges"`
+	Variables []Variable `json:"variables"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// FlowDiff lists the changes between two definitions of a flow
+type FlowDiff struct {
+	From         string       `json:"from"` // Version number, or draft
+	To           string       `json:"to"`
+	AddedNodes   []Node       `json:"added_nodes"`
+	RemovedNodes []Node       `json:"removed_nodes"`
+	ChangedNodes []NodeChange `json:"changed_nodes"`
+	AddedEdges   []Edge       `json:"added_edges"`
+	RemovedEdges []Edge       `json:"removed_edges"`
+	Variables    bool         `json:"variables_changed"`
+}
+
+// NodeChange describes a node present in both definitions of a diff
+type NodeChange struct {
+	NodeID string   `json:"node_id"`
+	Label  string   `json:"label"`
+	Fields []string `json:"fields"` // type, label, position or data.<key>
+}
+
 // Node represents a single node in the flow
 type Node struct {
 	ID       string                 `json:"id"`
@@ -105,19 +157,30 @@ type Credential struct {
 
 // DatabaseCredential holds database connection settings
 type DatabaseCredential struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Database string `json:"database"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	SSLMode  string `json:"ssl_mode"` // disable, require, verify-ca, verify-full
+	Driver   string   `json:"driver,omitempty"` // postgres (default), mysql or sqlite
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Database string   `json:"database"` // For sqlite, a file in the storages/databases directory
+	User     string   `json:"user"`
+	Password string   `json:"password"`
+	SSLMode  string   `json:"ssl_mode"`            // disable, require, verify-ca, verify-full
+	ReadOnly bool     `json:"read_only,omitempty"` // Only select and read-only raw queries
+	Tables   []string `json:"tables,omitempty"`    // Tables nodes may use; any table when empty. Raw queries are refused when set
 }
 
 // OpenAICredential holds OpenAI API settings
 type OpenAICredential struct {
+	Provider     string `json:"provider,omitempty"` // openai (default, any OpenAI-compatible server) or azure
 	APIKey       string `json:"api_key"`
 	Organization string `json:"organization,omitempty"`
-	BaseURL      string `json:"base_url,omitempty"` // For proxies or Azure
+	BaseURL      string `json:"base_url,omitempty"`    // For proxies, self-hosted servers or Azure
+	APIVersion   string `json:"api_version,omitempty"` // Azure only
+}
+
+// AnthropicCredential holds Anthropic API settings
+type AnthropicCredential struct {
+	APIKey  string `json:"api_key"`
+	BaseURL string `json:"base_url,omitempty"` // For proxies
 }
 
 // SMTPCredential holds email server settings
@@ -129,11 +192,21 @@ type SMTPCredential struct {
 	FromName string `json:"from_name"`
 	FromEmail string `json:"from_email"`
 	UseTLS   bool   `json:"use_tls"`
+	Security string `json:"security,omitempty"`    // starttls, tls (implicit) or none; from UseTLS and the port when empty
+	SkipVerify bool `json:"skip_verify,omitempty"` // Accept any server certificate
 }
 
+// SMTP connection security
+const (
+	SMTPSecuritySTARTTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
 // GoogleSheetsCredential holds Google API settings
 type GoogleSheetsCredential struct {
-	ServiceAccountJSON string `json:"service_account_json"`
+	ServiceAccountJSON string `json:"service_account_json"` // Key file of a service account the sheets are shared with
+	BaseURL            string `json:"base_url,omitempty"`   // Sheets API, https://sheets.googleapis.com by default
 }
 
 // CustomAPICredential holds custom API settings
@@ -144,6 +217,64 @@ type CustomAPICredential struct {
 	AuthValue string          `json:"auth_value"`
 }
 
+// Execution statuses
+const (
+	ExecutionStatusRunning = "running"
+	ExecutionStatusWaiting = "waiting" // Paused at a delay or wait for reply node
+	ExecutionStatusSuccess = "success"
+	ExecutionStatusFailed  = "failed"
+)
+
+// Execution records one run of a flow
+type Execution struct {
+	ID            string                 `json:"id"`
+	FlowID        string                 `json:"flow_id"`
+	FlowVersion   int                    `json:"flow_version"` // 0 for draft test runs and runs before versioning
+	AgentID       string                 `json:"agent_id"`
+	TriggerNodeID string                 `json:"trigger_node_id"`
+	TriggerType   string                 `json:"trigger_type"` // Node type of the trigger
+	Status        string                 `json:"status"`       // running, waiting, success or failed
+	Input         map[string]interface{} `json:"input"`
+	Output        map[string]interface{} `json:"output,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	StartedAt     time.Time              `json:"started_at"`
+	FinishedAt    *time.Time             `json:"finished_at,omitempty"`
+	DurationMs    int64                  `json:"duration_ms"`
+	Trace         []NodeTrace            `json:"trace,omitempty"` // Omitted in execution lists
+}
+
+// NodeTrace records the execution of one node within a run
+type NodeTrace struct {
+	NodeID     string                 `json:"node_id"`
+	NodeType   string                 `json:"node_type"`
+	Label      string                 `json:"label"`
+	Input      map[string]interface{} `json:"input"` // Flow variables when the node started
+	Output     map[string]interface{} `json:"output,omitempty"`
+	Handle     string                 `json:"handle,omitempty"` // Branch taken, for nodes with several outputs
+	Error      string                 `json:"error,omitempty"`
+	Stubbed    bool                   `json:"stubbed,omitempty"` // Side effect recorded instead of performed, in dry runs
+	StartedAt  time.Time              `json:"started_at"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// ExecutionFilter selects executions to list, newest first
+type ExecutionFilter struct {
+	FlowID string
+	Status string     // Optional
+	Since  *time.Time // Optional, inclusive
+	Until  *time.Time // Optional, exclusive
+	Limit  int
+	Offset int
+}
+
+// ExecutionList is a page of executions
+type ExecutionList struct {
+	Executions []*Execution `json:"executions"`
+	Total      int          `json:"total"` // Executions matching the filter, across all pages
+	Limit      int          `json:"limit"`
+	Offset     int          `json:"offset"`
+}
+
 // === Node Data Structures ===
 
 // TriggerNodeData for trigger nodes
@@ -151,16 +282,45 @@ type TriggerNodeData struct {
 	IntegrationID string   `json:"integration_id,omitempty"` // WhatsApp/Telegram integration
 	MessageTypes  []string `json:"message_types,omitempty"`  // text, image, audio, etc.
 	FilterKeywords []string `json:"filter_keywords,omitempty"`
+
+	// Webhook triggers
+	WebhookToken     string `json:"webhook_token,omitempty"`     // Generated on save; part of the webhook URL
+	WebhookSecret    string `json:"webhook_secret,omitempty"`    // If set, requests must carry an HMAC-SHA256 signature of the body
+	SignatureHeader  string `json:"signature_header,omitempty"`  // Header holding the signature, default X-Signature
+	ResponseMode     string `json:"response_mode,omitempty"`     // async (default), sync or output
+	ResponseVariable string `json:"response_variable,omitempty"` // Variable returned in output mode instead of the whole output
+
+	// Schedule triggers
+	Cron       string `json:"cron,omitempty"`        // Five-field cron expression or @daily, @hourly, ...
+	Timezone   string `json:"timezone,omitempty"`    // IANA name, default UTC
+	MissedRuns string `json:"missed_runs,omitempty"` // skip (default) or catch_up
 }
 
+// Webhook trigger response modes
+const (
+	WebhookResponseAsync  = "async"  // Respond 202 right away and run in the background
+	WebhookResponseSync   = "sync"   // Wait for the run and respond with its execution ID and output
+	WebhookResponseOutput = "output" // Wait for the run and respond with its output as the body
+)
+
+// What a schedule trigger does about runs missed while the server was down
+const (
+	MissedRunsSkip    = "skip"     // Wait for the next scheduled time
+	MissedRunsCatchUp = "catch_up" // Run once right away for the missed times
+)
+
+// DefaultSignatureHeader carries the webhook signature when a trigger does not name a header
+const DefaultSignatureHeader = "X-Signature"
+
 // AIAgentNodeData for AI agent nodes
 type AIAgentNodeData struct {
-	CredentialID string `json:"credential_id"` // OpenAI credential
-	Model        string `json:"model"`
-	SystemPrompt string `json:"system_prompt"`
-	MaxTokens    int    `json:"max_tokens"`
+	CredentialID string  `json:"credential_id"`      // OpenAI or Anthropic credential
+	Provider     string  `json:"provider,omitempty"` // Overrides the provider implied by the credential
+	Model        string  `json:"model"`
+	SystemPrompt string  `json:"system_prompt"`
+	MaxTokens    int     `json:"max_tokens"`
 	Temperature  float64 `json:"temperature"`
-	UseContext   bool   `json:"use_context"` // Use conversation history
+	UseContext   bool    `json:"use_context"` // Use conversation history
 }
 
 // HTTPRequestNodeData for HTTP request nodes
@@ -175,14 +335,36 @@ type HTTPRequestNodeData struct {
 	CredentialID string           `json:"credential_id,omitempty"` // Optional API credential
 }
 
+// Google Sheets operations
+const (
+	SheetsOperationAppend = "append" // Add a row
+	SheetsOperationLookup = "lookup" // Find the first row with a value in a column
+	SheetsOperationUpdate = "update" // Change the cells of the row found by lookup, or of RowNumber
+	SheetsOperationRead   = "read"   // Read a range
+)
+
+// GoogleSheetsNodeData for Google Sheets nodes. The first row of the sheet holds the column
+// headers that Values and LookupColumn refer to. Text fields can include {{variables}}.
+type GoogleSheetsNodeData struct {
+	CredentialID  string            `json:"credential_id"`
+	SpreadsheetID string            `json:"spreadsheet_id"`
+	Sheet         string            `json:"sheet,omitempty"` // Tab name, Sheet1 by default
+	Operation     string            `json:"operation"`
+	Values        map[string]string `json:"values,omitempty"` // Cells by column header, for append and update
+	LookupColumn  string            `json:"lookup_column,omitempty"`
+	LookupValue   string            `json:"lookup_value,omitempty"`
+	RowNumber     string            `json:"row_number,omitempty"` // For update instead of a lookup
+	Range         string            `json:"range,omitempty"`      // A1 range for read, such as A1:D20; the whole sheet when empty
+}
+
 // DatabaseNodeData for database nodes
 type DatabaseNodeData struct {
 	CredentialID string `json:"credential_id"` // Database credential
 	Operation    string `json:"operation"`     // select, insert, update, delete, raw
 	Table        string `json:"table,omitempty"`
-	Query        string `json:"query,omitempty"`      // For raw SQL
+	Query        string `json:"query,omitempty"`      // For raw SQL; {{ }} values are bound as parameters
 	Columns      []string `json:"columns,omitempty"`  // For select
-	Where        string `json:"where,omitempty"`      // WHERE clause
+	Where        string `json:"where,omitempty"`      // WHERE clause; {{ }} values are bound as parameters
 	Values       map[string]interface{} `json:"values,omitempty"` // For insert/update
 	Limit        int    `json:"limit,omitempty"`
 }
@@ -191,32 +373,157 @@ type DatabaseNodeData struct {
 type ConditionNodeData struct {
 	Conditions []Condition `json:"conditions"`
 	CombineWith string     `json:"combine_with"` // and, or
+	Expression  string     `json:"expression,omitempty"` // Optional expression that must also be truthy, e.g. total * qty >= 100
 }
 
 // Condition represents a single condition
 type Condition struct {
-	Field    string      `json:"field"`    // Variable or path to check
-	Operator string      `json:"operator"` // eq, ne, gt, lt, gte, lte, contains, starts_with, ends_with
+	Field    string      `json:"field"`    // Variable path or expression to check
+	Operator string      `json:"operator"` // eq, ne, gt, lt, gte, lte, contains, starts_with, ends_with, empty, not_empty, matches (regex)
+	Value    interface{} `json:"value"`
+}
+
+// SwitchNodeData for switch nodes, which continue on the handle named after the first case whose
+// value matches the field, or on the default handle when none does
+type SwitchNodeData struct {
+	Field string       `json:"field"` // Variable path or expression to check
+	Cases []SwitchCase `json:"cases"`
+}
+
+// SwitchCase is a named branch of a switch node
+type SwitchCase struct {
+	Name     string      `json:"name"`               // Handle of the branch
+	Operator string      `json:"operator,omitempty"` // As in Condition, default eq
 	Value    interface{} `json:"value"`
 }
 
-// DelayNodeData for delay nodes
+// SwitchHandleDefault is the handle a switch node continues on when no case matches
+const SwitchHandleDefault = "default"
+
+// ForEachNodeData for for each nodes, which run the nodes on the body handle once per item of an
+// array, then continue on the done handle. Edges from the body back to the node only end an
+// iteration; they are the only way a flow may loop.
+type ForEachNodeData struct {
+	Items         string `json:"items"`                    // Variable path or expression giving the array
+	ItemVariable  string `json:"item_variable,omitempty"`  // Variable set to the current item, default item
+	IndexVariable string `json:"index_variable,omitempty"` // Variable set to its zero-based index, default index
+}
+
+// Output handles of for each nodes
+const (
+	ForEachHandleBody = "body"
+	ForEachHandleDone = "done"
+)
+
+// Parallel nodes run the nodes on each of their branch edges at the same time, every branch with
+// its own copy of the variables. Once all branches finished, the variables they changed are merged
+// in edge order and the run continues on the done handle. Branches cannot pause the run.
+const (
+	ParallelHandleBranch = "branch"
+	ParallelHandleDone   = "done"
+)
+
+// DelayNodeData for delay nodes. The run is saved and resumed after the delay, also across restarts.
 type DelayNodeData struct {
 	Duration int    `json:"duration"` // Amount
-	Unit     string `json:"unit"`     // seconds, minutes, hours
+	Unit     string `json:"unit"`     // seconds, minutes, hours, days
 }
 
+// WaitForReplyNodeData for wait for reply nodes, which pause the run until the contact sends their
+// next message and continue on the reply handle, or on the timeout handle when none arrives in time
+type WaitForReplyNodeData struct {
+	IntegrationID string `json:"integration_id,omitempty"` // If neither is set, wait for the sender of the trigger message
+	Recipient     string `json:"recipient,omitempty"`      // Contact to wait for; can include {{variables}}
+	Timeout       int    `json:"timeout"`                  // Amount
+	Unit          string `json:"unit"`                     // minutes, hours, days
+}
+
+// Output handles of wait for reply nodes
+const (
+	WaitHandleReply   = "reply"
+	WaitHandleTimeout = "timeout"
+)
+
 // SendMessageNodeData for send message nodes
 type SendMessageNodeData struct {
 	IntegrationID string `json:"integration_id,omitempty"` // If not set, reply to trigger
+	Recipient     string `json:"recipient,omitempty"`      // Phone/JID, Telegram chat ID or Instagram user ID; can include {{variables}}
 	Message       string `json:"message"`                  // Can include {{variables}}
 	ReplyToTrigger bool  `json:"reply_to_trigger"`
 }
 
-// CodeNodeData for custom code nodes
+// SendMediaNodeData for send image and send file nodes. The media comes from MediaURL, else from
+// the variable named by MediaVariable, else from MediaFile.
+type SendMediaNodeData struct {
+	IntegrationID  string `json:"integration_id,omitempty"` // If not set, reply to trigger
+	Recipient      string `json:"recipient,omitempty"`
+	ReplyToTrigger bool   `json:"reply_to_trigger"`
+	Caption        string `json:"caption,omitempty"`        // Can include {{variables}}
+	MediaURL       string `json:"media_url,omitempty"`      // Can include {{variables}}
+	MediaVariable  string `json:"media_variable,omitempty"` // Variable holding a URL, data URL or base64 data
+	MediaFile      string `json:"media_file,omitempty"`     // ID returned by POST /flows/media
+	FileName       string `json:"file_name,omitempty"`
+}
+
+// Kinds of OutgoingMedia
+const (
+	MediaKindImage = "image"
+	MediaKindFile  = "file"
+)
+
+// OutgoingMedia is an image or file sent by a flow, either by URL or with its content
+type OutgoingMedia struct {
+	Kind     string // image or file
+	URL      string
+	Data     []byte
+	FileName string
+	MIMEType string
+}
+
+// OutgoingMessage is a message delivered by a send_message, send_image or send_file node
+type OutgoingMessage struct {
+	AgentID       string
+	IntegrationID string
+	Recipient     string
+	Text          string         // Message text, or the caption of Media
+	Media         *OutgoingMedia // Nil for text messages
+}
+
+// SentMessage identifies a delivered message
+type SentMessage struct {
+	MessageID string
+	Channel   string // Integration type: whatsapp, telegram or instagram
+}
+
+// EmailNodeData for email nodes. Recipient lists are separated by commas; every text field can
+// include {{variables}}.
+type EmailNodeData struct {
+	CredentialID string            `json:"credential_id"` // SMTP credential
+	To           string            `json:"to"`
+	CC           string            `json:"cc,omitempty"`
+	BCC          string            `json:"bcc,omitempty"`
+	ReplyTo      string            `json:"reply_to,omitempty"`
+	Subject      string            `json:"subject"`
+	Body         string            `json:"body,omitempty"` // Plain text
+	HTML         string            `json:"html,omitempty"` // Sent with Body as the alternative when both are set
+	Attachments  []EmailAttachment `json:"attachments,omitempty"`
+}
+
+// EmailAttachment is a file attached to an email: a variable holding a URL, data URL, base64
+// content or received media (such as {{attachments}} of a WhatsApp trigger), an uploaded file or a URL
+type EmailAttachment struct {
+	Variable  string `json:"variable,omitempty"`
+	MediaFile string `json:"media_file,omitempty"` // ID returned by POST /flows/media
+	URL       string `json:"url,omitempty"`
+	FileName  string `json:"file_name,omitempty"`
+}
+
+// CodeNodeData for custom code nodes. The code runs in a sandbox without network or file access,
+// with the flow variables and input as the globals vars and input; the object it returns is merged
+// into the variables.
 type CodeNodeData struct {
 	Language string `json:"language"` // javascript
-	Code     string `json:"code"`
+	Code     string `json:"code"`     // Function body, e.g. return { total: vars.items.length }
 }
 
 // === Request/Response DTOs ===
@@ -230,7 +537,50 @@ type CreateFlowRequest struct {
 	Edges       []Edge     `json:"edges"`
 }
 
-// UpdateFlowRequest for updating a flow
+// PublishFlowRequest for publishing the draft of a flow
+type PublishFlowRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// RollbackFlowRequest for publishing an earlier version of a flow again
+type RollbackFlowRequest struct {
+	Version int    `json:"version"`
+	Note    string `json:"note,omitempty"`
+}
+
+// DraftRunResponse for test runs of the draft of a flow
+type DraftRunResponse struct {
+	ExecutionID string                 `json:"execution_id,omitempty"` // Live runs only; dry runs are not recorded
+	Output      map[string]interface{} `json:"output"`
+	Waiting     bool                   `json:"waiting"` // Paused at a delay or wait for reply node
+	DryRun      bool                   `json:"dry_run"`
+	Stubbed     []string               `json:"stubbed,omitempty"` // Nodes whose side effects a dry run skipped
+}
+
+// TestFlowRequest for dry runs of a flow
+type TestFlowRequest struct {
+	Input     map[string]interface{} `json:"input"`                // Trigger input, such as message, sender and integration_id
+	TriggerID string                 `json:"trigger_id,omitempty"` // Trigger node to start at; the first trigger when empty
+	Version   string                 `json:"version,omitempty"`    // Version number or draft; the draft when there is one, else the published version
+	Reply     string                 `json:"reply,omitempty"`      // Reply wait for reply nodes receive; they time out without one
+}
+
+// TestFlowResponse is the outcome of a dry run. Nodes with side effects record them in the trace
+// instead of performing them.
+type TestFlowResponse struct {
+	Version    string                 `json:"version"` // Version number or draft
+	Status     string                 `json:"status"`  // success or failed
+	Error      string                 `json:"error,omitempty"`
+	Trace      []NodeTrace            `json:"trace"`
+	Variables  map[string]interface{} `json:"variables"`
+	Output     map[string]interface{} `json:"output"`
+	Replies    []string               `json:"replies"` // Messages the trigger sender would receive
+	Stubbed    []string               `json:"stubbed"` // Nodes whose side effects were recorded instead of performed
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// UpdateFlowRequest for updating a flow. Nodes, edges and variables change the draft; name,
+// description and is_active apply at once.
 type UpdateFlowRequest struct {
 	Name        *string    `json:"name,omitempty"`
 	Description *string    `json:"description,omitempty"`
@@ -250,8 +600,22 @@ type FlowResponse struct {
 	Nodes       []Node     `json:"nodes"`
 	Edges       []Edge     `json:"edges"`
 	Variables   []Variable `json:"variables"`
+	Version     int        `json:"version"`         // Published version that runs; nodes, edges and variables are its own
+	Draft       *FlowDraft `json:"draft,omitempty"` // Unpublished changes, only with single flows
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+
+	NextRunAt *time.Time       `json:"next_run_at,omitempty"` // Earliest next run of the schedule triggers
+	Schedules []ScheduleStatus `json:"schedules,omitempty"`
+}
+
+// ScheduleStatus describes a schedule trigger of a flow
+type ScheduleStatus struct {
+	NodeID    string     `json:"node_id"`
+	Cron      string     `json:"cron"`
+	Timezone  string     `json:"timezone"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	Error     string     `json:"error,omitempty"` // Set when the cron expression or timezone is invalid
 }
 
 // CreateCredentialRequest for creating credentials
@@ -313,6 +677,12 @@ type IFlowService interface {
 	UpdateCredential(ctx context.Context, id string, req UpdateCredentialRequest) (*CredentialResponse, error)
 	DeleteCredential(ctx context.Context, id string) error
 	TestDatabaseConnection(ctx context.Context, config DatabaseCredential) error
+	TestSMTPConnection(ctx context.Context, config SMTPCredential) error
+}
+
+// IMessageSender delivers messages from flows through the agent's integrations
+type IMessageSender interface {
+	Send(ctx context.Context, msg OutgoingMessage) (*SentMessage, error)
 }
 
 // IFlowExecutor defines flow execution
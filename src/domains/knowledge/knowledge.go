@@ -56,6 +56,30 @@ type SearchRequest struct {
 	TopK    int    `json:"top_k,omitempty"` // Default 5
 }
 
+// ContextRequest asks for prompt-ready context for a message
+type ContextRequest struct {
+	AgentID   string
+	Query     string
+	TopK      int     // Chunks to retrieve, default 3
+	MinScore  float64 // Chunks scoring below this are ignored
+	MaxTokens int     // Approximate token budget for the context, 0 = unlimited
+}
+
+// RelevantContext is the knowledge base context injected into a prompt
+type RelevantContext struct {
+	Text    string         `json:"text"`
+	Sources []SearchResult `json:"sources"` // Chunks included in Text
+}
+
+// ChunkIDs returns the IDs of the chunks included in the context
+func (c *RelevantContext) ChunkIDs() []string {
+	ids := make([]string, 0, len(c.Sources))
+	for _, src := range c.Sources {
+		ids = append(ids, src.ChunkID)
+	}
+	return ids
+}
+
 // IKnowledgeRepository defines database operations for knowledge base
 type IKnowledgeRepository interface {
 	CreateDocument(ctx context.Context, doc *Document) error
@@ -77,6 +101,7 @@ type IKnowledgeService interface {
 	DeleteDocument(ctx context.Context, id string) error
 	Search(ctx context.Context, req SearchRequest) ([]SearchResult, error)
 	GetRelevantContext(ctx context.Context, agentID string, query string) (string, error)
+	RetrieveContext(ctx context.Context, req ContextRequest) (*RelevantContext, error)
 }
 
 
@@ -5,6 +5,14 @@ import (
 	"time"
 )
 
+// Notification types
+const (
+	TypeInfo    = "info"
+	TypeWarning = "warning"
+	TypeError   = "error"
+	TypeSuccess = "success"
+)
+
 // Notification represents an alert/notification
 type Notification struct {
 	ID        string    `json:"id"`
@@ -23,11 +23,12 @@ type DaySchedule struct {
 
 // TranslationSettings represents auto-translation configuration
 type TranslationSettings struct {
-	Enabled           bool   `json:"enabled"`
-	SourceLanguage    string `json:"source_language"`    // Agent's language (e.g., "en")
-	AutoDetect        bool   `json:"auto_detect"`        // Auto-detect incoming language
-	TranslateIncoming bool   `json:"translate_incoming"` // Translate user messages to agent language
-	TranslateOutgoing bool   `json:"translate_outgoing"` // Translate agent responses to user language
+	Enabled           bool    `json:"enabled"`
+	SourceLanguage    string  `json:"source_language"`    // Agent's language (e.g., "en")
+	AutoDetect        bool    `json:"auto_detect"`        // Auto-detect incoming language
+	TranslateIncoming bool    `json:"translate_incoming"` // Translate user messages to agent language
+	TranslateOutgoing bool    `json:"translate_outgoing"` // Translate agent responses to user language
+	MinConfidence     float64 `json:"min_confidence"`     // Re-detect the customer language until a detection is this sure (0-1)
 }
 
 // FollowUpSettings represents follow-up automation
@@ -45,8 +46,84 @@ type SentimentSettings struct {
 	AlertOnNegative        bool    `json:"alert_on_negative"`         // Send alert for negative sentiment
 	NegativeThreshold      float64 `json:"negative_threshold"`        // Threshold for negative (0-1)
 	EscalateOnVeryNegative bool    `json:"escalate_on_very_negative"` // Auto-escalate to human
+	EscalationThreshold    float64 `json:"escalation_threshold"`      // Threshold for very negative (0-1)
+	HandoffMessage         string  `json:"handoff_message"`           // Sent to the customer on escalation
+	WebhookURL             string  `json:"webhook_url"`               // Optional URL that also receives the alerts
+}
+
+// HistorySettings controls how much conversation history is sent to the model
+type HistorySettings struct {
+	MaxMessages        int  `json:"max_messages"`        // Max recent messages considered for context
+	MaxContextTokens   int  `json:"max_context_tokens"`  // Token budget for history (approximate)
+	SummarizeEnabled   bool `json:"summarize_enabled"`   // Fold older turns into a rolling summary
+	SummarizeThreshold int  `json:"summarize_threshold"` // Unsummarized overflow messages before summarizing
+}
+
+// ToolSettings controls which built-in tools the agent may call
+type ToolSettings struct {
+	Enabled         []string `json:"enabled"`          // knowledge_search, calendar_availability, calendar_booking, run_flow, web_search, handoff_to_human
+	FlowIDs         []string `json:"flow_ids"`         // Flows the run_flow tool may execute
+	MaxRounds       int      `json:"max_rounds"`       // Max tool-call rounds per reply
+	BookingDuration int      `json:"booking_duration"` // Default appointment length in minutes
+}
+
+// KnowledgeSettings controls automatic knowledge base retrieval for each reply
+type KnowledgeSettings struct {
+	Enabled          bool    `json:"enabled"`            // Inject relevant document chunks into the prompt
+	TopK             int     `json:"top_k"`              // Max chunks retrieved per message
+	Threshold        float64 `json:"threshold"`          // Min similarity score (0-1) for a chunk to be used
+	MaxContextTokens int     `json:"max_context_tokens"` // Token budget for injected chunks (approximate)
+}
+
+// StreamingSettings controls progressive delivery of replies while they are generated
+type StreamingSettings struct {
+	Enabled         bool `json:"enabled"`          // Stream replies instead of waiting for the full answer
+	TypingIndicator bool `json:"typing_indicator"` // Show "typing..." while the reply is generated
+	SplitParagraphs bool `json:"split_paragraphs"` // WhatsApp: send each paragraph as its own message
+	MinChunkChars   int  `json:"min_chunk_chars"`  // WhatsApp: shorter paragraphs are merged with the next one
+	ProgressiveEdit bool `json:"progressive_edit"` // Telegram: edit one message as text arrives
+	EditIntervalMs  int  `json:"edit_interval_ms"` // Telegram: min time between edits
+}
+
+// BudgetSettings caps what an agent may spend on LLM calls per calendar month
+type BudgetSettings struct {
+	Enabled      bool    `json:"enabled"`
+	MonthlyLimit float64 `json:"monthly_limit"` // USD, estimated from the usage pricing table
+	AwayMessage  string  `json:"away_message"`  // Sent instead of AI replies once the cap is reached
+}
+
+// VisionSettings controls how images and documents sent by customers are passed to the model
+type VisionSettings struct {
+	Enabled           bool   `json:"enabled"`
+	Model             string `json:"model"`               // Vision-capable model for messages with images (empty = agent model)
+	MaxImages         int    `json:"max_images"`          // Per message; extra images are dropped
+	MaxDocumentPages  int    `json:"max_document_pages"`  // PDF pages converted to text
+	MaxDocumentTokens int    `json:"max_document_tokens"` // Document text beyond this is truncated (approximate)
+}
+
+// VoiceReplySettings controls spoken replies to customers who send voice notes
+type VoiceReplySettings struct {
+	Enabled  bool   `json:"enabled"`
+	Voice    string `json:"voice"`     // TTS voice, e.g. alloy, echo, nova
+	Model    string `json:"model"`     // TTS model, e.g. tts-1 or tts-1-hd
+	MaxChars int    `json:"max_chars"` // Longer replies are sent as text
+}
+
+// How incoming messages are shared between message-triggered flows and the AI reply
+const (
+	FlowModeFlowThenAI = "flow_then_ai" // Matching flows run first; the AI replies unless a flow did
+	FlowModeFlowOnly   = "flow_only"    // Only flows reply; the AI never does
+	FlowModeAIOnly     = "ai_only"      // Messages do not trigger flows
+)
+
+// FlowSettings controls how messages trigger flows with WhatsApp, Telegram or Instagram triggers
+type FlowSettings struct {
+	Mode string `json:"mode"` // flow_then_ai, flow_only or ai_only
 }
 
+// DefaultHandoffMessage is sent to the customer when a conversation is escalated to a human
+const DefaultHandoffMessage = "Thanks for your patience. I'm handing this conversation over to a member of our team, who will reply shortly."
+
 // AgentSettings represents all configurable settings for an agent
 type AgentSettings struct {
 	ID              string              `json:"id"`
@@ -55,6 +132,14 @@ type AgentSettings struct {
 	Translation     TranslationSettings `json:"translation"`
 	FollowUp        FollowUpSettings    `json:"follow_up"`
 	Sentiment       SentimentSettings   `json:"sentiment"`
+	History         HistorySettings     `json:"history"`
+	Tools           ToolSettings        `json:"tools"`
+	Knowledge       KnowledgeSettings   `json:"knowledge"`
+	Streaming       StreamingSettings   `json:"streaming"`
+	Budget          BudgetSettings      `json:"budget"`
+	Vision          VisionSettings      `json:"vision"`
+	VoiceReply      VoiceReplySettings  `json:"voice_reply"`
+	Flows           FlowSettings        `json:"flows"`
 	MaxTokensPerMsg int                 `json:"max_tokens_per_msg"` // Max response length
 	Temperature     float64             `json:"temperature"`        // AI creativity (0-1)
 	CreatedAt       time.Time           `json:"created_at"`
@@ -136,6 +221,7 @@ func DefaultAgentSettings(agentID string) *AgentSettings {
 			AutoDetect:        true,
 			TranslateIncoming: true,
 			TranslateOutgoing: true,
+			MinConfidence:     0.8,
 		},
 		FollowUp: FollowUpSettings{
 			Enabled:       false,
@@ -152,6 +238,54 @@ func DefaultAgentSettings(agentID string) *AgentSettings {
 			AlertOnNegative:        true,
 			NegativeThreshold:      0.3,
 			EscalateOnVeryNegative: false,
+			EscalationThreshold:    0.7,
+			HandoffMessage:         DefaultHandoffMessage,
+		},
+		History: HistorySettings{
+			MaxMessages:        20,
+			MaxContextTokens:   2000,
+			SummarizeEnabled:   false,
+			SummarizeThreshold: 10,
+		},
+		Tools: ToolSettings{
+			Enabled:         []string{},
+			FlowIDs:         []string{},
+			MaxRounds:       5,
+			BookingDuration: 30,
+		},
+		Knowledge: KnowledgeSettings{
+			Enabled:          true,
+			TopK:             3,
+			Threshold:        0.7,
+			MaxContextTokens: 1000,
+		},
+		Streaming: StreamingSettings{
+			Enabled:         false,
+			TypingIndicator: true,
+			SplitParagraphs: true,
+			MinChunkChars:   200,
+			ProgressiveEdit: false,
+			EditIntervalMs:  1000,
+		},
+		Budget: BudgetSettings{
+			Enabled:      false,
+			MonthlyLimit: 50,
+			AwayMessage:  "We're unable to reply automatically right now. A member of our team will get back to you soon.",
+		},
+		Vision: VisionSettings{
+			Enabled:           false,
+			MaxImages:         4,
+			MaxDocumentPages:  10,
+			MaxDocumentTokens: 4000,
+		},
+		VoiceReply: VoiceReplySettings{
+			Enabled:  false,
+			Voice:    "alloy",
+			Model:    "tts-1",
+			MaxChars: 1000,
+		},
+		Flows: FlowSettings{
+			Mode: FlowModeFlowThenAI,
 		},
 		MaxTokensPerMsg: 500,
 		Temperature:     0.7,
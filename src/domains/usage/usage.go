@@ -0,0 +1,92 @@
+package usage
+
+import (
+	"context"
+	"time"
+)
+
+// Record is one LLM call with its token counts and estimated cost
+type Record struct {
+	ID               string    `json:"id"`
+	AgentID          string    `json:"agent_id,omitempty"`
+	ConversationID   string    `json:"conversation_id,omitempty"`
+	FlowID           string    `json:"flow_id,omitempty"`
+	FlowExecutionID  string    `json:"flow_execution_id,omitempty"`
+	Operation        string    `json:"operation"` // chat, translation, sentiment, embedding, transcription, ...
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	AudioSeconds     float64   `json:"audio_seconds,omitempty"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Cost             float64   `json:"cost"` // USD, estimated from the pricing table at the time of the call
+	Error            string    `json:"error,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ModelPrice is the price of a model in USD. Models match by exact name first,
+// then by the longest price entry that prefixes the model name (gpt-4o-mini-2024-07-18 → gpt-4o-mini).
+type ModelPrice struct {
+	Model                string    `json:"model"`
+	PromptPerMillion     float64   `json:"prompt_per_million"`     // Per 1M input tokens
+	CompletionPerMillion float64   `json:"completion_per_million"` // Per 1M output tokens
+	AudioPerMinute       float64   `json:"audio_per_minute"`       // Speech-to-text
+	UpdatedAt            time.Time `json:"updated_at,omitempty"`
+}
+
+// Group-by values for usage summaries
+const (
+	GroupByAgent        = "agent"
+	GroupByConversation = "conversation"
+	GroupByFlow         = "flow"
+	GroupByModel        = "model"
+	GroupByOperation    = "operation"
+	GroupByDay          = "day"
+)
+
+// Filter selects usage records. Zero values match everything.
+type Filter struct {
+	AgentID         string
+	ConversationID  string
+	FlowID          string
+	FlowExecutionID string
+	Operation       string
+	From            time.Time
+	To              time.Time
+	Limit           int
+}
+
+// Totals aggregates usage for one group (or for everything when Key is empty)
+type Totals struct {
+	Key              string  `json:"key,omitempty"`
+	Calls            int     `json:"calls"`
+	Errors           int     `json:"errors"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	AudioSeconds     float64 `json:"audio_seconds,omitempty"`
+	Cost             float64 `json:"cost"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// Budget is an agent's spending for the current month against its cap
+type Budget struct {
+	AgentID      string    `json:"agent_id"`
+	Enabled      bool      `json:"enabled"`
+	MonthlyLimit float64   `json:"monthly_limit"`
+	Spent        float64   `json:"spent"`
+	Remaining    float64   `json:"remaining"`
+	Exceeded     bool      `json:"exceeded"`
+	PeriodStart  time.Time `json:"period_start"`
+}
+
+// IUsageRepository defines database operations for usage records and prices
+type IUsageRepository interface {
+	AddRecord(ctx context.Context, record *Record) error
+	ListRecords(ctx context.Context, filter Filter) ([]*Record, error)
+	Summarize(ctx context.Context, filter Filter, groupBy string) ([]Totals, error)
+	TotalCost(ctx context.Context, agentID string, since time.Time) (float64, error)
+
+	GetPrices(ctx context.Context) ([]ModelPrice, error)
+	SavePrice(ctx context.Context, price *ModelPrice) error
+	DeletePrice(ctx context.Context, model string) error
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/encryption"
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -36,6 +37,8 @@ func (r *SQLiteRepository) migrate() error {
 			id TEXT PRIMARY KEY,
 			name TEXT NOT NULL,
 			description TEXT DEFAULT '',
+			provider TEXT DEFAULT 'openai',
+			base_url TEXT DEFAULT '',
 			api_key TEXT NOT NULL,
 			serp_api_key TEXT DEFAULT '',
 			model TEXT NOT NULL DEFAULT 'gpt-4o-mini',
@@ -51,6 +54,7 @@ func (r *SQLiteRepository) migrate() error {
 			type TEXT NOT NULL,
 			is_connected INTEGER DEFAULT 0,
 			config TEXT DEFAULT '{}',
+			customer_language TEXT DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
@@ -63,6 +67,11 @@ func (r *SQLiteRepository) migrate() error {
 			is_first_reply INTEGER DEFAULT 0,
 			is_manual_mode INTEGER DEFAULT 0,
 			notes TEXT DEFAULT '',
+			summary TEXT DEFAULT '',
+			summarized_until DATETIME,
+			language TEXT DEFAULT '',
+			language_confidence REAL DEFAULT 0,
+			language_manual INTEGER DEFAULT 0,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE,
@@ -75,11 +84,26 @@ func (r *SQLiteRepository) migrate() error {
 			role TEXT NOT NULL,
 			content TEXT NOT NULL,
 			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+			knowledge_chunk_ids TEXT DEFAULT '',
+			sentiment_score REAL,
+			sentiment_label TEXT DEFAULT '',
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS tool_invocations (
+			id TEXT PRIMARY KEY,
+			conversation_id TEXT NOT NULL,
+			tool TEXT NOT NULL,
+			arguments TEXT DEFAULT '',
+			result TEXT DEFAULT '',
+			error TEXT DEFAULT '',
+			duration_ms INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_integrations_agent_id ON integrations(agent_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_conversations_lookup ON conversations(agent_id, integration_id, remote_jid)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id, timestamp DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_tool_invocations_conversation ON tool_invocations(conversation_id, created_at DESC)`,
 	}
 
 	for _, query := range queries {
@@ -90,8 +114,19 @@ func (r *SQLiteRepository) migrate() error {
 
 	// Safe migrations for existing tables (ignore errors if columns already exist)
 	safeMigrations := []string{
+		`ALTER TABLE agents ADD COLUMN provider TEXT DEFAULT 'openai'`,
+		`ALTER TABLE agents ADD COLUMN base_url TEXT DEFAULT ''`,
 		`ALTER TABLE conversations ADD COLUMN is_manual_mode INTEGER DEFAULT 0`,
 		`ALTER TABLE conversations ADD COLUMN notes TEXT DEFAULT ''`,
+		`ALTER TABLE conversations ADD COLUMN summary TEXT DEFAULT ''`,
+		`ALTER TABLE conversations ADD COLUMN summarized_until DATETIME`,
+		`ALTER TABLE messages ADD COLUMN knowledge_chunk_ids TEXT DEFAULT ''`,
+		`ALTER TABLE messages ADD COLUMN sentiment_score REAL`,
+		`ALTER TABLE messages ADD COLUMN sentiment_label TEXT DEFAULT ''`,
+		`ALTER TABLE integrations ADD COLUMN customer_language TEXT DEFAULT ''`,
+		`ALTER TABLE conversations ADD COLUMN language TEXT DEFAULT ''`,
+		`ALTER TABLE conversations ADD COLUMN language_confidence REAL DEFAULT 0`,
+		`ALTER TABLE conversations ADD COLUMN language_manual INTEGER DEFAULT 0`,
 	}
 	for _, query := range safeMigrations {
 		r.db.Exec(query) // Ignore errors (column may already exist)
@@ -114,30 +149,62 @@ func (r *SQLiteRepository) Create(ctx context.Context, a *agent.Agent) error {
 	a.CreatedAt = now
 	a.UpdatedAt = now
 
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO agents (id, name, description, api_key, serp_api_key, model, system_prompt, welcome_message, is_active, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		a.ID, a.Name, a.Description, a.APIKey, a.SerpAPIKey, a.Model, a.SystemPrompt, a.WelcomeMessage, a.IsActive, a.CreatedAt, a.UpdatedAt,
+	apiKey, serpAPIKey, err := encryptAgentKeys(a)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO agents (id, name, description, provider, base_url, api_key, serp_api_key, model, system_prompt, welcome_message, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.Name, a.Description, a.Provider, a.BaseURL, apiKey, serpAPIKey, a.Model, a.SystemPrompt, a.WelcomeMessage, a.IsActive, a.CreatedAt, a.UpdatedAt,
 	)
 	return err
 }
 
+// encryptAgentKeys returns the API keys of an agent as they are stored
+func encryptAgentKeys(a *agent.Agent) (apiKey, serpAPIKey string, err error) {
+	if apiKey, err = encryption.Encrypt(a.APIKey); err != nil {
+		return "", "", fmt.Errorf("failed to encrypt API key: %w", err)
+	}
+	if serpAPIKey, err = encryption.Encrypt(a.SerpAPIKey); err != nil {
+		return "", "", fmt.Errorf("failed to encrypt SerpAPI key: %w", err)
+	}
+	return apiKey, serpAPIKey, nil
+}
+
+// decryptAgentKeys replaces the stored API keys of an agent with their plaintext
+func decryptAgentKeys(a *agent.Agent) (err error) {
+	if a.APIKey, err = encryption.Decrypt(a.APIKey); err != nil {
+		return fmt.Errorf("agent %s API key: %w", a.ID, err)
+	}
+	if a.SerpAPIKey, err = encryption.Decrypt(a.SerpAPIKey); err != nil {
+		return fmt.Errorf("agent %s SerpAPI key: %w", a.ID, err)
+	}
+	return nil
+}
+
 func (r *SQLiteRepository) GetByID(ctx context.Context, id string) (*agent.Agent, error) {
 	a := &agent.Agent{}
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, name, description, api_key, COALESCE(serp_api_key, ''), model, system_prompt, welcome_message, is_active, created_at, updated_at
+		`SELECT id, name, description, COALESCE(provider, 'openai'), COALESCE(base_url, ''), api_key, COALESCE(serp_api_key, ''), model, system_prompt, welcome_message, is_active, created_at, updated_at
 		FROM agents WHERE id = ?`, id,
-	).Scan(&a.ID, &a.Name, &a.Description, &a.APIKey, &a.SerpAPIKey, &a.Model, &a.SystemPrompt, &a.WelcomeMessage, &a.IsActive, &a.CreatedAt, &a.UpdatedAt)
+	).Scan(&a.ID, &a.Name, &a.Description, &a.Provider, &a.BaseURL, &a.APIKey, &a.SerpAPIKey, &a.Model, &a.SystemPrompt, &a.WelcomeMessage, &a.IsActive, &a.CreatedAt, &a.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("agent not found")
 	}
-	return a, err
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptAgentKeys(a); err != nil {
+		return nil, err
+	}
+	return a, nil
 }
 
 func (r *SQLiteRepository) GetAll(ctx context.Context) ([]*agent.Agent, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, name, description, api_key, COALESCE(serp_api_key, ''), model, system_prompt, welcome_message, is_active, created_at, updated_at
+		`SELECT id, name, description, COALESCE(provider, 'openai'), COALESCE(base_url, ''), api_key, COALESCE(serp_api_key, ''), model, system_prompt, welcome_message, is_active, created_at, updated_at
 		FROM agents ORDER BY created_at DESC`,
 	)
 	if err != nil {
@@ -148,7 +215,10 @@ func (r *SQLiteRepository) GetAll(ctx context.Context) ([]*agent.Agent, error) {
 	var agents []*agent.Agent
 	for rows.Next() {
 		a := &agent.Agent{}
-		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.APIKey, &a.SerpAPIKey, &a.Model, &a.SystemPrompt, &a.WelcomeMessage, &a.IsActive, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.Provider, &a.BaseURL, &a.APIKey, &a.SerpAPIKey, &a.Model, &a.SystemPrompt, &a.WelcomeMessage, &a.IsActive, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := decryptAgentKeys(a); err != nil {
 			return nil, err
 		}
 		agents = append(agents, a)
@@ -158,10 +228,14 @@ func (r *SQLiteRepository) GetAll(ctx context.Context) ([]*agent.Agent, error) {
 
 func (r *SQLiteRepository) Update(ctx context.Context, a *agent.Agent) error {
 	a.UpdatedAt = time.Now()
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE agents SET name=?, description=?, api_key=?, serp_api_key=?, model=?, system_prompt=?, welcome_message=?, is_active=?, updated_at=?
+	apiKey, serpAPIKey, err := encryptAgentKeys(a)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE agents SET name=?, description=?, provider=?, base_url=?, api_key=?, serp_api_key=?, model=?, system_prompt=?, welcome_message=?, is_active=?, updated_at=?
 		WHERE id=?`,
-		a.Name, a.Description, a.APIKey, a.SerpAPIKey, a.Model, a.SystemPrompt, a.WelcomeMessage, a.IsActive, a.UpdatedAt, a.ID,
+		a.Name, a.Description, a.Provider, a.BaseURL, apiKey, serpAPIKey, a.Model, a.SystemPrompt, a.WelcomeMessage, a.IsActive, a.UpdatedAt, a.ID,
 	)
 	return err
 }
@@ -227,17 +301,37 @@ func (r *SQLiteRepository) CreateIntegration(ctx context.Context, i *agent.Integ
 		i.Config = "{}"
 	}
 
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO integrations (id, agent_id, type, is_connected, config, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		i.ID, i.AgentID, i.Type, i.IsConnected, i.Config, i.CreatedAt, i.UpdatedAt,
+	// The config holds bot tokens and access tokens
+	config, err := encryption.Encrypt(i.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt integration config: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO integrations (id, agent_id, type, is_connected, config, customer_language, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		i.ID, i.AgentID, i.Type, i.IsConnected, config, i.CustomerLanguage, i.CreatedAt, i.UpdatedAt,
 	)
 	return err
 }
 
+const integrationColumns = `id, agent_id, type, is_connected, config, COALESCE(customer_language, ''), created_at, updated_at`
+
+func scanIntegration(row rowScanner) (*agent.Integration, error) {
+	i := &agent.Integration{}
+	if err := row.Scan(&i.ID, &i.AgentID, &i.Type, &i.IsConnected, &i.Config, &i.CustomerLanguage, &i.CreatedAt, &i.UpdatedAt); err != nil {
+		return i, err
+	}
+	config, err := encryption.Decrypt(i.Config)
+	if err != nil {
+		return i, fmt.Errorf("integration %s config: %w", i.ID, err)
+	}
+	i.Config = config
+	return i, nil
+}
+
 func (r *SQLiteRepository) GetIntegrationsByAgentID(ctx context.Context, agentID string) ([]*agent.Integration, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, agent_id, type, is_connected, config, created_at, updated_at
+		`SELECT `+integrationColumns+`
 		FROM integrations WHERE agent_id = ?`, agentID,
 	)
 	if err != nil {
@@ -247,8 +341,8 @@ func (r *SQLiteRepository) GetIntegrationsByAgentID(ctx context.Context, agentID
 
 	var integrations []*agent.Integration
 	for rows.Next() {
-		i := &agent.Integration{}
-		if err := rows.Scan(&i.ID, &i.AgentID, &i.Type, &i.IsConnected, &i.Config, &i.CreatedAt, &i.UpdatedAt); err != nil {
+		i, err := scanIntegration(rows)
+		if err != nil {
 			return nil, err
 		}
 		integrations = append(integrations, i)
@@ -257,11 +351,10 @@ func (r *SQLiteRepository) GetIntegrationsByAgentID(ctx context.Context, agentID
 }
 
 func (r *SQLiteRepository) GetIntegrationByID(ctx context.Context, id string) (*agent.Integration, error) {
-	i := &agent.Integration{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, agent_id, type, is_connected, config, created_at, updated_at
+	i, err := scanIntegration(r.db.QueryRowContext(ctx,
+		`SELECT `+integrationColumns+`
 		FROM integrations WHERE id = ?`, id,
-	).Scan(&i.ID, &i.AgentID, &i.Type, &i.IsConnected, &i.Config, &i.CreatedAt, &i.UpdatedAt)
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("integration not found")
@@ -270,11 +363,10 @@ func (r *SQLiteRepository) GetIntegrationByID(ctx context.Context, id string) (*
 }
 
 func (r *SQLiteRepository) GetIntegrationByAgentAndType(ctx context.Context, agentID, integrationType string) (*agent.Integration, error) {
-	i := &agent.Integration{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, agent_id, type, is_connected, config, created_at, updated_at
+	i, err := scanIntegration(r.db.QueryRowContext(ctx,
+		`SELECT `+integrationColumns+`
 		FROM integrations WHERE agent_id = ? AND type = ?`, agentID, integrationType,
-	).Scan(&i.ID, &i.AgentID, &i.Type, &i.IsConnected, &i.Config, &i.CreatedAt, &i.UpdatedAt)
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, nil // Not found is not an error here
@@ -284,9 +376,13 @@ func (r *SQLiteRepository) GetIntegrationByAgentAndType(ctx context.Context, age
 
 func (r *SQLiteRepository) UpdateIntegration(ctx context.Context, i *agent.Integration) error {
 	i.UpdatedAt = time.Now()
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE integrations SET is_connected=?, config=?, updated_at=? WHERE id=?`,
-		i.IsConnected, i.Config, i.UpdatedAt, i.ID,
+	config, err := encryption.Encrypt(i.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt integration config: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE integrations SET is_connected=?, config=?, customer_language=?, updated_at=? WHERE id=?`,
+		i.IsConnected, config, i.CustomerLanguage, i.UpdatedAt, i.ID,
 	)
 	return err
 }
@@ -296,15 +392,46 @@ func (r *SQLiteRepository) DeleteIntegration(ctx context.Context, id string) err
 	return err
 }
 
+// EncryptSecrets encrypts agent API keys and integration configs stored in plaintext or with an
+// older master key, returning how many rows were rewritten
+func (r *SQLiteRepository) EncryptSecrets(ctx context.Context) (int, error) {
+	agents, err := encryption.RewriteColumns(ctx, r.db, "agents", "id", "api_key", "serp_api_key")
+	if err != nil {
+		return agents, err
+	}
+	integrations, err := encryption.RewriteColumns(ctx, r.db, "integrations", "id", "config")
+	return agents + integrations, err
+}
+
 // Conversation management
 
-func (r *SQLiteRepository) GetOrCreateConversation(ctx context.Context, agentID, integrationID, remoteJID string) (*agent.Conversation, error) {
+const conversationColumns = `id, agent_id, integration_id, remote_jid, is_first_reply, COALESCE(is_manual_mode, 0), COALESCE(notes, ''),
+		COALESCE(summary, ''), summarized_until, COALESCE(language, ''), COALESCE(language_confidence, 0), COALESCE(language_manual, 0),
+		created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanConversation(row rowScanner) (*agent.Conversation, error) {
 	c := &agent.Conversation{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, agent_id, integration_id, remote_jid, is_first_reply, COALESCE(is_manual_mode, 0), COALESCE(notes, ''), created_at, updated_at
+	var summarizedUntil sql.NullTime
+	if err := row.Scan(&c.ID, &c.AgentID, &c.IntegrationID, &c.RemoteJID, &c.IsFirstReply, &c.IsManualMode, &c.Notes,
+		&c.Summary, &summarizedUntil, &c.Language, &c.LanguageConfidence, &c.LanguageManual, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if summarizedUntil.Valid {
+		c.SummarizedUntil = &summarizedUntil.Time
+	}
+	return c, nil
+}
+
+func (r *SQLiteRepository) GetOrCreateConversation(ctx context.Context, agentID, integrationID, remoteJID string) (*agent.Conversation, error) {
+	c, err := scanConversation(r.db.QueryRowContext(ctx,
+		`SELECT `+conversationColumns+`
 		FROM conversations WHERE agent_id = ? AND integration_id = ? AND remote_jid = ?`,
 		agentID, integrationID, remoteJID,
-	).Scan(&c.ID, &c.AgentID, &c.IntegrationID, &c.RemoteJID, &c.IsFirstReply, &c.IsManualMode, &c.Notes, &c.CreatedAt, &c.UpdatedAt)
+	))
 
 	if err == sql.ErrNoRows {
 		// Create new conversation
@@ -345,6 +472,26 @@ func (r *SQLiteRepository) UpdateConversation(ctx context.Context, c *agent.Conv
 
 // Message history
 
+const messageColumns = `id, conversation_id, role, content, timestamp, COALESCE(knowledge_chunk_ids, ''),
+		sentiment_score, COALESCE(sentiment_label, '')`
+
+func scanMessage(row rowScanner) (*agent.Message, error) {
+	m := &agent.Message{}
+	var chunkIDs string
+	var sentimentScore sql.NullFloat64
+	if err := row.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.Timestamp, &chunkIDs,
+		&sentimentScore, &m.SentimentLabel); err != nil {
+		return nil, err
+	}
+	if sentimentScore.Valid {
+		m.SentimentScore = &sentimentScore.Float64
+	}
+	if chunkIDs != "" {
+		json.Unmarshal([]byte(chunkIDs), &m.KnowledgeChunkIDs)
+	}
+	return m, nil
+}
+
 func (r *SQLiteRepository) AddMessage(ctx context.Context, m *agent.Message) error {
 	if m.ID == "" {
 		m.ID = uuid.New().String()
@@ -353,17 +500,23 @@ func (r *SQLiteRepository) AddMessage(ctx context.Context, m *agent.Message) err
 		m.Timestamp = time.Now()
 	}
 
+	var chunkIDs string
+	if len(m.KnowledgeChunkIDs) > 0 {
+		data, _ := json.Marshal(m.KnowledgeChunkIDs)
+		chunkIDs = string(data)
+	}
+
 	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO messages (id, conversation_id, role, content, timestamp)
-		VALUES (?, ?, ?, ?, ?)`,
-		m.ID, m.ConversationID, m.Role, m.Content, m.Timestamp,
+		`INSERT INTO messages (id, conversation_id, role, content, timestamp, knowledge_chunk_ids, sentiment_score, sentiment_label)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, m.ConversationID, m.Role, m.Content, m.Timestamp, chunkIDs, m.SentimentScore, m.SentimentLabel,
 	)
 	return err
 }
 
 func (r *SQLiteRepository) GetRecentMessages(ctx context.Context, conversationID string, limit int) ([]*agent.Message, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, conversation_id, role, content, timestamp
+		`SELECT `+messageColumns+`
 		FROM messages WHERE conversation_id = ?
 		ORDER BY timestamp DESC LIMIT ?`, conversationID, limit,
 	)
@@ -374,8 +527,8 @@ func (r *SQLiteRepository) GetRecentMessages(ctx context.Context, conversationID
 
 	var messages []*agent.Message
 	for rows.Next() {
-		m := &agent.Message{}
-		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.Timestamp); err != nil {
+		m, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
@@ -389,10 +542,73 @@ func (r *SQLiteRepository) GetRecentMessages(ctx context.Context, conversationID
 	return messages, rows.Err()
 }
 
+// GetMessagesInRange returns messages with after < timestamp < before in chronological order.
+// A zero after means from the beginning of the conversation.
+func (r *SQLiteRepository) GetMessagesInRange(ctx context.Context, conversationID string, after, before time.Time, limit int) ([]*agent.Message, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+messageColumns+`
+		FROM messages WHERE conversation_id = ? AND timestamp > ? AND timestamp < ?
+		ORDER BY timestamp ASC LIMIT ?`, conversationID, after, before, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*agent.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// AddToolInvocation records a tool call made by the agent
+func (r *SQLiteRepository) AddToolInvocation(ctx context.Context, inv *agent.ToolInvocation) error {
+	if inv.ID == "" {
+		inv.ID = uuid.New().String()
+	}
+	if inv.CreatedAt.IsZero() {
+		inv.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO tool_invocations (id, conversation_id, tool, arguments, result, error, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		inv.ID, inv.ConversationID, inv.Tool, inv.Arguments, inv.Result, inv.Error, inv.DurationMs, inv.CreatedAt,
+	)
+	return err
+}
+
+// GetToolInvocations returns the most recent tool calls of a conversation, newest first
+func (r *SQLiteRepository) GetToolInvocations(ctx context.Context, conversationID string, limit int) ([]*agent.ToolInvocation, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, conversation_id, tool, arguments, result, error, duration_ms, created_at
+		FROM tool_invocations WHERE conversation_id = ?
+		ORDER BY created_at DESC LIMIT ?`, conversationID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invocations []*agent.ToolInvocation
+	for rows.Next() {
+		inv := &agent.ToolInvocation{}
+		if err := rows.Scan(&inv.ID, &inv.ConversationID, &inv.Tool, &inv.Arguments, &inv.Result, &inv.Error, &inv.DurationMs, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		invocations = append(invocations, inv)
+	}
+	return invocations, rows.Err()
+}
+
 // GetAllConversations returns all conversations with optional filtering
 func (r *SQLiteRepository) GetAllConversations(ctx context.Context) ([]*agent.Conversation, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, agent_id, integration_id, remote_jid, is_first_reply, COALESCE(is_manual_mode, 0), COALESCE(notes, ''), created_at, updated_at
+		`SELECT `+conversationColumns+`
 		FROM conversations ORDER BY updated_at DESC`,
 	)
 	if err != nil {
@@ -402,8 +618,8 @@ func (r *SQLiteRepository) GetAllConversations(ctx context.Context) ([]*agent.Co
 
 	var conversations []*agent.Conversation
 	for rows.Next() {
-		c := &agent.Conversation{}
-		if err := rows.Scan(&c.ID, &c.AgentID, &c.IntegrationID, &c.RemoteJID, &c.IsFirstReply, &c.IsManualMode, &c.Notes, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		c, err := scanConversation(rows)
+		if err != nil {
 			return nil, err
 		}
 		conversations = append(conversations, c)
@@ -413,15 +629,10 @@ func (r *SQLiteRepository) GetAllConversations(ctx context.Context) ([]*agent.Co
 
 // GetConversationByID returns a single conversation
 func (r *SQLiteRepository) GetConversationByID(ctx context.Context, id string) (*agent.Conversation, error) {
-	c := &agent.Conversation{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, agent_id, integration_id, remote_jid, is_first_reply, COALESCE(is_manual_mode, 0), COALESCE(notes, ''), created_at, updated_at
+	return scanConversation(r.db.QueryRowContext(ctx,
+		`SELECT `+conversationColumns+`
 		FROM conversations WHERE id = ?`, id,
-	).Scan(&c.ID, &c.AgentID, &c.IntegrationID, &c.RemoteJID, &c.IsFirstReply, &c.IsManualMode, &c.Notes, &c.CreatedAt, &c.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return c, nil
+	))
 }
 
 // SetConversationManualMode sets the manual mode for a conversation
@@ -433,6 +644,25 @@ func (r *SQLiteRepository) SetConversationManualMode(ctx context.Context, conver
 	return err
 }
 
+// UpdateConversationSummary stores the rolling summary and the timestamp of the last summarized message
+func (r *SQLiteRepository) UpdateConversationSummary(ctx context.Context, conversationID, summary string, summarizedUntil time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE conversations SET summary = ?, summarized_until = ? WHERE id = ?`,
+		summary, summarizedUntil, conversationID,
+	)
+	return err
+}
+
+// UpdateConversationLanguage stores the customer's language. Manual languages are set from the
+// live chat and are not replaced by auto-detection.
+func (r *SQLiteRepository) UpdateConversationLanguage(ctx context.Context, conversationID, language string, confidence float64, manual bool) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE conversations SET language = ?, language_confidence = ?, language_manual = ?, updated_at = ? WHERE id = ?`,
+		language, confidence, manual, time.Now(), conversationID,
+	)
+	return err
+}
+
 // UpdateConversationNotes updates the notes for a conversation
 func (r *SQLiteRepository) UpdateConversationNotes(ctx context.Context, conversationID, notes string) error {
 	_, err := r.db.ExecContext(ctx,
@@ -445,7 +675,7 @@ func (r *SQLiteRepository) UpdateConversationNotes(ctx context.Context, conversa
 // GetMessagesForConversation returns all messages for a conversation
 func (r *SQLiteRepository) GetMessagesForConversation(ctx context.Context, conversationID string) ([]*agent.Message, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, conversation_id, role, content, timestamp
+		`SELECT `+messageColumns+`
 		FROM messages WHERE conversation_id = ?
 		ORDER BY timestamp ASC`, conversationID,
 	)
@@ -456,8 +686,8 @@ func (r *SQLiteRepository) GetMessagesForConversation(ctx context.Context, conve
 
 	var messages []*agent.Message
 	for rows.Next() {
-		m := &agent.Message{}
-		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.Timestamp); err != nil {
+		m, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
@@ -467,12 +697,11 @@ func (r *SQLiteRepository) GetMessagesForConversation(ctx context.Context, conve
 
 // GetLastMessageForConversation returns the last message
 func (r *SQLiteRepository) GetLastMessageForConversation(ctx context.Context, conversationID string) (*agent.Message, error) {
-	m := &agent.Message{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, conversation_id, role, content, timestamp
+	m, err := scanMessage(r.db.QueryRowContext(ctx,
+		`SELECT `+messageColumns+`
 		FROM messages WHERE conversation_id = ?
 		ORDER BY timestamp DESC LIMIT 1`, conversationID,
-	).Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.Timestamp)
+	))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Provider types
+const (
+	ProviderOpenAI    = "openai"    // api.openai.com or any OpenAI-compatible endpoint (Ollama, vLLM, LM Studio)
+	ProviderAzure     = "azure"     // Azure OpenAI deployments
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+)
+
+// ErrNotSupported is returned when a provider does not implement a capability
+var ErrNotSupported = errors.New("operation not supported by provider")
+
+// ProviderConfig selects and configures an LLM backend
+type ProviderConfig struct {
+	Provider       string // openai, azure, anthropic (empty = openai)
+	APIKey         string
+	BaseURL        string // Custom endpoint, e.g. http://localhost:11434/v1 for Ollama
+	Organization   string // OpenAI organization (optional)
+	APIVersion     string // Azure API version (optional)
+	Model          string // Default chat model when a call doesn't specify one
+	UtilityModel   string // Model for translation, sentiment and summaries (empty = provider default)
+	EmbeddingModel string // Model for knowledge base embeddings (empty = text-embedding-ada-002)
+}
+
+// StreamHandler receives newly generated reply text while a response is streamed
+type StreamHandler func(delta string)
+
+// Image is a picture passed to a vision-capable model
+type Image struct {
+	MIMEType string // image/jpeg, image/png, image/gif or image/webp
+	Data     []byte
+}
+
+// DataURL encodes the image as a base64 data URL
+func (i Image) DataURL() string {
+	return "data:" + i.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// IsSupportedImageType reports whether vision models accept images of this MIME type
+func IsSupportedImageType(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// ChatRequest is a provider-neutral chat completion request
+type ChatRequest struct {
+	Model        string
+	SystemPrompt string
+	Messages     []ChatMessage
+	MaxTokens    int
+	Temperature  float64
+	Tools        []ToolDefinition // Functions the model may call (optional)
+	Stream       StreamHandler    // Receives content deltas as they are generated (optional)
+}
+
+// ChatResponse is a provider-neutral chat completion result
+type ChatResponse struct {
+	Content          string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	ToolCalls        []ToolCall // Non-empty when the model wants tools executed before answering
+}
+
+// EmbedResponse holds one embedding per input, in order
+type EmbedResponse struct {
+	Embeddings   [][]float64
+	PromptTokens int // 0 when the backend does not report usage
+}
+
+// TranscribeResponse is a speech-to-text result
+type TranscribeResponse struct {
+	Text            string
+	Model           string
+	DurationSeconds float64 // 0 when the backend does not report the audio length
+}
+
+// SpeechRequest is a text-to-speech request
+type SpeechRequest struct {
+	Model string
+	Voice string
+	Text  string
+}
+
+// SpeechResponse holds synthesized audio
+type SpeechResponse struct {
+	Audio  []byte
+	Format string // File extension of the audio, e.g. mp3
+	Model  string
+}
+
+// Provider is implemented by each LLM backend
+type Provider interface {
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	Embed(ctx context.Context, model string, inputs []string) (*EmbedResponse, error)
+	Transcribe(ctx context.Context, audio io.Reader, filename string) (*TranscribeResponse, error)
+	Speak(ctx context.Context, req SpeechRequest) (*SpeechResponse, error)
+}
+
+// NewProvider builds the provider described by cfg
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", ProviderOpenAI:
+		return newOpenAIProvider(cfg, false), nil
+	case ProviderAzure:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("azure provider requires a base URL")
+		}
+		return newOpenAIProvider(cfg, true), nil
+	case ProviderAnthropic:
+		return newAnthropicProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider: %s", cfg.Provider)
+	}
+}
+
+// IsValidProvider reports whether name is a supported provider (empty means openai)
+func IsValidProvider(name string) bool {
+	switch strings.ToLower(name) {
+	case "", ProviderOpenAI, ProviderAzure, ProviderAnthropic:
+		return true
+	}
+	return false
+}
+
+// SupportsEmbeddings reports whether the provider can create embeddings for knowledge bases
+func SupportsEmbeddings(name string) bool {
+	return IsValidProvider(name) && strings.ToLower(name) != ProviderAnthropic
+}
+
+// defaultModels returns the chat and utility model used when none is configured.
+// Self-hosted OpenAI-compatible endpoints have no well-known model names, so they return empty values.
+func defaultModels(cfg ProviderConfig) (chat string, utility string) {
+	switch strings.ToLower(cfg.Provider) {
+	case ProviderAnthropic:
+		return "claude-3-5-haiku-latest", "claude-3-5-haiku-latest"
+	case ProviderAzure:
+		return "", ""
+	default:
+		if cfg.BaseURL != "" {
+			return "", ""
+		}
+		return "gpt-4o-mini", "gpt-4o-mini"
+	}
+}
@@ -0,0 +1,311 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicDefaultBaseURL = "https://api.anthropic.com"
+	anthropicAPIVersion     = "2023-06-01"
+)
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newAnthropicProvider(cfg ProviderConfig) *anthropicProvider {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = anthropicDefaultBaseURL
+	}
+	return &anthropicProvider{
+		apiKey:     cfg.APIKey,
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+// anthropicBlock is a content block: text, tool_use (from the model) or tool_result (from us)
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	Source    *anthropicImage `json:"source,omitempty"`
+}
+
+type anthropicImage struct {
+	Type      string `json:"type"` // base64
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Model   string           `json:"model"`
+	Content []anthropicBlock `json:"content"`
+	Usage   struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	body := anthropicRequest{
+		Model:       req.Model,
+		System:      req.SystemPrompt,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      req.Stream != nil,
+	}
+	for _, m := range req.Messages {
+		// System turns are not allowed in the messages array
+		if m.Role == RoleSystem {
+			body.System = strings.TrimSpace(body.System + "\n\n" + m.Content)
+			continue
+		}
+		body.Messages = appendAnthropicMessage(body.Messages, m)
+	}
+	for _, tool := range req.Tools {
+		body.Tools = append(body.Tools, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if body.Stream && resp.StatusCode == http.StatusOK {
+		return readAnthropicStream(resp.Body, req.Stream)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result anthropicResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("anthropic API error (%s): %s", result.Error.Type, result.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("anthropic API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var text strings.Builder
+	var toolCalls []ToolCall
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+
+	return &ChatResponse{
+		Content:          text.String(),
+		Model:            result.Model,
+		PromptTokens:     result.Usage.InputTokens,
+		CompletionTokens: result.Usage.OutputTokens,
+		ToolCalls:        toolCalls,
+	}, nil
+}
+
+// anthropicStreamEvent is one server-sent event of a streamed Messages response
+type anthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Index   int                `json:"index"`
+	Message *anthropicResponse `json:"message,omitempty"`
+	Block   *anthropicBlock    `json:"content_block,omitempty"`
+	Delta   struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Usage *struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// readAnthropicStream assembles a streamed Messages response, passing text deltas to onDelta
+func readAnthropicStream(body io.Reader, onDelta StreamHandler) (*ChatResponse, error) {
+	result := &ChatResponse{}
+	var text strings.Builder
+	var toolCalls []ToolCall
+	var toolArgs []strings.Builder
+	toolIndex := map[int]int{} // content block index -> toolCalls index
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				result.Model = event.Message.Model
+				result.PromptTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_start":
+			if event.Block != nil && event.Block.Type == "tool_use" {
+				toolIndex[event.Index] = len(toolCalls)
+				toolCalls = append(toolCalls, ToolCall{ID: event.Block.ID, Name: event.Block.Name})
+				toolArgs = append(toolArgs, strings.Builder{})
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				text.WriteString(event.Delta.Text)
+				onDelta(event.Delta.Text)
+			case "input_json_delta":
+				if i, ok := toolIndex[event.Index]; ok {
+					toolArgs[i].WriteString(event.Delta.PartialJSON)
+				}
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				result.CompletionTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("anthropic API error (%s): %s", event.Error.Type, event.Error.Message)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	for i := range toolCalls {
+		toolCalls[i].Arguments = toolArgs[i].String()
+		if toolCalls[i].Arguments == "" {
+			toolCalls[i].Arguments = "{}"
+		}
+	}
+	result.Content = text.String()
+	result.ToolCalls = toolCalls
+	return result, nil
+}
+
+// appendAnthropicMessage converts a turn to content blocks. Tool results are sent as user
+// turns, and consecutive turns with the same role are merged because the API requires alternation.
+func appendAnthropicMessage(messages []anthropicMessage, m ChatMessage) []anthropicMessage {
+	role := m.Role
+	var blocks []anthropicBlock
+	switch m.Role {
+	case RoleTool:
+		role = RoleUser
+		blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+	default:
+		for _, img := range m.Images {
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: &anthropicImage{
+				Type:      "base64",
+				MediaType: img.MIMEType,
+				Data:      base64.StdEncoding.EncodeToString(img.Data),
+			}})
+		}
+		if m.Content != "" {
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+		}
+		for _, call := range m.ToolCalls {
+			input := json.RawMessage(call.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+		}
+	}
+	if len(blocks) == 0 {
+		return messages
+	}
+
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+	return append(messages, anthropicMessage{Role: role, Content: blocks})
+}
+
+func (p *anthropicProvider) Embed(ctx context.Context, model string, inputs []string) (*EmbedResponse, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *anthropicProvider) Transcribe(ctx context.Context, audio io.Reader, filename string) (*TranscribeResponse, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *anthropicProvider) Speak(ctx context.Context, req SpeechRequest) (*SpeechResponse, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,243 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// openAIProvider talks to OpenAI, Azure OpenAI or any OpenAI-compatible endpoint
+type openAIProvider struct {
+	client *openai.Client
+	// streamUsage requests token usage on streamed responses; only api.openai.com is known to support it
+	streamUsage bool
+	// transcriptDuration requests verbose transcripts, which include the billed audio duration
+	transcriptDuration bool
+}
+
+func newOpenAIProvider(cfg ProviderConfig, azure bool) *openAIProvider {
+	var clientConfig openai.ClientConfig
+	if azure {
+		clientConfig = openai.DefaultAzureConfig(cfg.APIKey, cfg.BaseURL)
+		if cfg.APIVersion != "" {
+			clientConfig.APIVersion = cfg.APIVersion
+		}
+	} else {
+		clientConfig = openai.DefaultConfig(cfg.APIKey)
+		if cfg.BaseURL != "" {
+			clientConfig.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+		}
+	}
+	clientConfig.OrgID = cfg.Organization
+
+	return &openAIProvider{
+		client:             openai.NewClientWithConfig(clientConfig),
+		streamUsage:        !azure && cfg.BaseURL == "",
+		transcriptDuration: !azure && cfg.BaseURL == "",
+	}
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages)+1)
+	if req.SystemPrompt != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: req.SystemPrompt,
+		})
+	}
+	for _, m := range req.Messages {
+		msg := openai.ChatCompletionMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCallID: m.ToolCallID,
+		}
+		if len(m.Images) > 0 {
+			msg.Content = ""
+			if m.Content != "" {
+				msg.MultiContent = append(msg.MultiContent, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: m.Content})
+			}
+			for _, img := range m.Images {
+				msg.MultiContent = append(msg.MultiContent, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: img.DataURL(), Detail: openai.ImageURLDetailAuto},
+				})
+			}
+		}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+		messages = append(messages, msg)
+	}
+
+	chatReq := openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: float32(req.Temperature),
+	}
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	if req.Stream != nil {
+		return p.chatStream(ctx, chatReq, req.Stream)
+	}
+
+	resp, err := p.client.CreateChatCompletion(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from AI")
+	}
+
+	result := &ChatResponse{
+		Content:          resp.Choices[0].Message.Content,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result, nil
+}
+
+// chatStream runs a streamed completion, passing content deltas to onDelta and
+// assembling tool calls from their fragments
+func (p *openAIProvider) chatStream(ctx context.Context, chatReq openai.ChatCompletionRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	chatReq.Stream = true
+	if p.streamUsage {
+		chatReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	result := &ChatResponse{}
+	var content strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.PromptTokens = chunk.Usage.PromptTokens
+			result.CompletionTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			onDelta(delta.Content)
+		}
+		for _, call := range delta.ToolCalls {
+			i := len(result.ToolCalls) - 1
+			if call.Index != nil {
+				i = *call.Index
+			}
+			for len(result.ToolCalls) <= i {
+				result.ToolCalls = append(result.ToolCalls, ToolCall{})
+			}
+			if call.ID != "" {
+				result.ToolCalls[i].ID = call.ID
+			}
+			result.ToolCalls[i].Name += call.Function.Name
+			result.ToolCalls[i].Arguments += call.Function.Arguments
+		}
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+func (p *openAIProvider) Embed(ctx context.Context, model string, inputs []string) (*EmbedResponse, error) {
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Model: openai.EmbeddingModel(model),
+		Input: inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float64, len(resp.Data))
+	for i, d := range resp.Data {
+		vec := make([]float64, len(d.Embedding))
+		for j, v := range d.Embedding {
+			vec[j] = float64(v)
+		}
+		embeddings[i] = vec
+	}
+	return &EmbedResponse{Embeddings: embeddings, PromptTokens: resp.Usage.PromptTokens}, nil
+}
+
+func (p *openAIProvider) Transcribe(ctx context.Context, audio io.Reader, filename string) (*TranscribeResponse, error) {
+	format := openai.AudioResponseFormatText
+	if p.transcriptDuration {
+		format = openai.AudioResponseFormatVerboseJSON
+	}
+	resp, err := p.client.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    openai.Whisper1,
+		FilePath: filename,
+		Reader:   audio,
+		Format:   format,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TranscribeResponse{Text: resp.Text, Model: openai.Whisper1, DurationSeconds: resp.Duration}, nil
+}
+
+func (p *openAIProvider) Speak(ctx context.Context, req SpeechRequest) (*SpeechResponse, error) {
+	resp, err := p.client.CreateSpeech(ctx, openai.CreateSpeechRequest{
+		Model:          openai.SpeechModel(req.Model),
+		Input:          req.Text,
+		Voice:          openai.SpeechVoice(req.Voice),
+		ResponseFormat: openai.SpeechResponseFormatMp3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	audio, err := io.ReadAll(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read speech audio: %w", err)
+	}
+	return &SpeechResponse{Audio: audio, Format: string(openai.SpeechResponseFormatMp3), Model: req.Model}, nil
+}
@@ -0,0 +1,338 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAICompatibleProviderChat(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","object":"chat.completion","model":"llama3","choices":[{"index":0,"message":{"role":"assistant","content":" hello "},"finish_reason":"stop"}],"usage":{"prompt_tokens":7,"completion_tokens":2,"total_tokens":9}}`))
+	}))
+	defer server.Close()
+
+	svc := NewServiceWithProvider(ProviderConfig{BaseURL: server.URL + "/v1", Model: "llama3"}, "")
+	if svc == nil {
+		t.Fatalf("NewServiceWithProvider() returned nil for keyless base URL")
+	}
+
+	got, err := svc.GenerateChatResponse(context.Background(), []ChatMessage{
+		{Role: RoleUser, Content: "hi"},
+		{Role: RoleAssistant, Content: "hey"},
+		{Role: RoleUser, Content: "how are you"},
+	}, "be brief", "", 100, 0.5)
+	if err != nil {
+		t.Fatalf("GenerateChatResponse() error = %v", err)
+	}
+	if got != "hello" {
+		t.Fatalf("GenerateChatResponse() = %q, want %q", got, "hello")
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Fatalf("request path = %q", gotPath)
+	}
+	if gotAuth != "" {
+		t.Fatalf("Authorization = %q, want none for keyless server", gotAuth)
+	}
+	if gotBody.Model != "llama3" {
+		t.Fatalf("model = %q, want llama3", gotBody.Model)
+	}
+	if len(gotBody.Messages) != 4 || gotBody.Messages[0].Role != RoleSystem || gotBody.Messages[0].Content != "be brief" {
+		t.Fatalf("messages = %+v", gotBody.Messages)
+	}
+}
+
+func TestOpenAICompatibleProviderEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("request path = %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.5,-1]}],"model":"nomic"}`))
+	}))
+	defer server.Close()
+
+	svc := NewServiceWithProvider(ProviderConfig{BaseURL: server.URL + "/v1", EmbeddingModel: "nomic"}, "")
+	got, err := svc.CreateEmbeddings(context.Background(), []string{"text"})
+	if err != nil {
+		t.Fatalf("CreateEmbeddings() error = %v", err)
+	}
+	if len(got) != 1 || len(got[0]) != 2 || got[0][0] != 0.5 || got[0][1] != -1 {
+		t.Fatalf("CreateEmbeddings() = %v", got)
+	}
+}
+
+func TestCreateEmbeddingsUnsupportedProvider(t *testing.T) {
+	if !SupportsEmbeddings("") || !SupportsEmbeddings(ProviderAzure) || SupportsEmbeddings(ProviderAnthropic) {
+		t.Fatalf("SupportsEmbeddings() reports the wrong providers")
+	}
+
+	svc := NewServiceWithProvider(ProviderConfig{Provider: ProviderAnthropic, APIKey: "key"}, "")
+	if _, err := svc.CreateEmbeddings(context.Background(), []string{"text"}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Anthropic CreateEmbeddings() error = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestAnthropicProviderChat(t *testing.T) {
+	var gotBody anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("request path = %q", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"claude-test","content":[{"type":"text","text":"Bonjour"}],"usage":{"input_tokens":12,"output_tokens":3}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{Provider: ProviderAnthropic, APIKey: "test-key", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	resp, err := provider.Chat(context.Background(), ChatRequest{
+		Model:        "claude-test",
+		SystemPrompt: "translate",
+		Messages:     []ChatMessage{{Role: RoleUser, Content: "Hello"}},
+		MaxTokens:    50,
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "Bonjour" || resp.PromptTokens != 12 || resp.CompletionTokens != 3 {
+		t.Fatalf("Chat() = %+v", resp)
+	}
+	if gotBody.System != "translate" || len(gotBody.Messages) != 1 || gotBody.MaxTokens != 50 {
+		t.Fatalf("request body = %+v", gotBody)
+	}
+}
+
+func TestProvidersSendImages(t *testing.T) {
+	image := Image{MIMEType: "image/png", Data: []byte("png-bytes")}
+	messages := []ChatMessage{{Role: RoleUser, Content: "what is this?", Images: []Image{image}}}
+
+	var openAIBody struct {
+		Messages []struct {
+			Content []struct {
+				Type     string `json:"type"`
+				Text     string `json:"text"`
+				ImageURL struct {
+					URL string `json:"url"`
+				} `json:"image_url"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	openAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"a shoe"}}]}`))
+	}))
+	defer openAIServer.Close()
+
+	provider, _ := NewProvider(ProviderConfig{BaseURL: openAIServer.URL + "/v1"})
+	if _, err := provider.Chat(context.Background(), ChatRequest{Model: "gpt-4o", Messages: messages}); err != nil {
+		t.Fatalf("OpenAI Chat() error = %v", err)
+	}
+	parts := openAIBody.Messages[0].Content
+	if len(parts) != 2 || parts[0].Text != "what is this?" || parts[1].ImageURL.URL != image.DataURL() {
+		t.Fatalf("OpenAI content parts = %+v", parts)
+	}
+
+	var anthropicBody anthropicRequest
+	anthropicServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&anthropicBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"a shoe"}]}`))
+	}))
+	defer anthropicServer.Close()
+
+	provider, _ = NewProvider(ProviderConfig{Provider: ProviderAnthropic, APIKey: "k", BaseURL: anthropicServer.URL})
+	if _, err := provider.Chat(context.Background(), ChatRequest{Model: "claude-test", Messages: messages, MaxTokens: 10}); err != nil {
+		t.Fatalf("Anthropic Chat() error = %v", err)
+	}
+	blocks := anthropicBody.Messages[0].Content
+	if len(blocks) != 2 || blocks[0].Type != "image" || blocks[0].Source == nil ||
+		blocks[0].Source.MediaType != "image/png" || blocks[1].Text != "what is this?" {
+		t.Fatalf("Anthropic content blocks = %+v", blocks)
+	}
+}
+
+func TestSynthesizeSpeech(t *testing.T) {
+	var gotPath string
+	var gotBody struct {
+		Model          string `json:"model"`
+		Input          string `json:"input"`
+		Voice          string `json:"voice"`
+		ResponseFormat string `json:"response_format"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3audio"))
+	}))
+	defer server.Close()
+
+	svc := NewServiceWithProvider(ProviderConfig{APIKey: "key", BaseURL: server.URL + "/v1"}, "")
+	resp, err := svc.SynthesizeSpeech(context.Background(), " hello there ", "", "")
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech() error = %v", err)
+	}
+	if string(resp.Audio) != "ID3audio" || resp.Format != "mp3" {
+		t.Fatalf("SynthesizeSpeech() = %q (%s)", resp.Audio, resp.Format)
+	}
+	if gotPath != "/v1/audio/speech" {
+		t.Fatalf("request path = %q", gotPath)
+	}
+	if gotBody.Model != DefaultSpeechModel || gotBody.Voice != DefaultSpeechVoice || gotBody.Input != "hello there" || gotBody.ResponseFormat != "mp3" {
+		t.Fatalf("request body = %+v", gotBody)
+	}
+
+	anthropic := NewServiceWithProvider(ProviderConfig{Provider: ProviderAnthropic, APIKey: "key"}, "")
+	if _, err := anthropic.SynthesizeSpeech(context.Background(), "hello", "", ""); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Anthropic SynthesizeSpeech() error = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestAnthropicProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+	}))
+	defer server.Close()
+
+	provider, _ := NewProvider(ProviderConfig{Provider: ProviderAnthropic, APIKey: "bad", BaseURL: server.URL})
+	if _, err := provider.Chat(context.Background(), ChatRequest{Model: "m", MaxTokens: 1}); err == nil {
+		t.Fatalf("Chat() expected error for 401 response")
+	}
+}
+
+func TestOpenAICompatibleProviderStream(t *testing.T) {
+	var gotStream bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		gotStream = body.Stream
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"id":"1","object":"chat.completion.chunk","model":"llama3","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"llama3","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"llama3","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		} {
+			w.Write([]byte("data: " + chunk + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{BaseURL: server.URL + "/v1"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	var deltas []string
+	resp, err := provider.Chat(context.Background(), ChatRequest{
+		Model:    "llama3",
+		Messages: []ChatMessage{{Role: RoleUser, Content: "hi"}},
+		Stream:   func(delta string) { deltas = append(deltas, delta) },
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if !gotStream {
+		t.Fatalf("request did not ask for a stream")
+	}
+	if resp.Content != "Hello" || strings.Join(deltas, "|") != "Hel|lo" {
+		t.Fatalf("content = %q, deltas = %v", resp.Content, deltas)
+	}
+}
+
+func TestAnthropicProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"model":"claude-test","usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tu_1","name":"lookup","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"hours\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+			`{"type":"message_stop"}`,
+		} {
+			w.Write([]byte("event: x\ndata: " + event + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{Provider: ProviderAnthropic, APIKey: "k", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	var streamed strings.Builder
+	resp, err := provider.Chat(context.Background(), ChatRequest{
+		Model:     "claude-test",
+		Messages:  []ChatMessage{{Role: RoleUser, Content: "when do you open?"}},
+		MaxTokens: 100,
+		Stream:    func(delta string) { streamed.WriteString(delta) },
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "Checking" || streamed.String() != "Checking" {
+		t.Fatalf("content = %q, streamed = %q", resp.Content, streamed.String())
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "tu_1" || resp.ToolCalls[0].Arguments != `{"q":"hours"}` {
+		t.Fatalf("tool calls = %+v", resp.ToolCalls)
+	}
+	if resp.Model != "claude-test" || resp.PromptTokens != 12 || resp.CompletionTokens != 20 {
+		t.Fatalf("usage = %+v", resp)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ProviderConfig
+		wantErr bool
+	}{
+		{name: "DefaultOpenAI", cfg: ProviderConfig{APIKey: "k"}},
+		{name: "AzureWithoutBaseURL", cfg: ProviderConfig{Provider: ProviderAzure, APIKey: "k"}, wantErr: true},
+		{name: "AzureWithBaseURL", cfg: ProviderConfig{Provider: ProviderAzure, APIKey: "k", BaseURL: "https://x.openai.azure.com"}},
+		{name: "Anthropic", cfg: ProviderConfig{Provider: ProviderAnthropic, APIKey: "k"}},
+		{name: "Unknown", cfg: ProviderConfig{Provider: "foo", APIKey: "k"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProvider(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,26 +4,43 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
 	serp "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/serp"
-	"github.com/sashabaranov/go-openai"
 	"github.com/sirupsen/logrus"
 )
 
 type Service struct {
-	client     *openai.Client
+	provider    Provider
+	cfg         ProviderConfig
 	serpService *serp.SerpService
 }
 
 func NewService(apiToken string, serpAPIKey string) *Service {
-	if apiToken == "" {
+	return NewServiceWithProvider(ProviderConfig{Provider: ProviderOpenAI, APIKey: apiToken}, serpAPIKey)
+}
+
+// NewServiceWithProvider creates a service backed by the configured LLM provider.
+// Returns nil when the provider can't be used (no API key for a hosted API, unknown provider).
+func NewServiceWithProvider(cfg ProviderConfig, serpAPIKey string) *Service {
+	// Self-hosted endpoints (Ollama, LM Studio) often run without a key
+	if cfg.APIKey == "" && cfg.BaseURL == "" {
+		return nil
+	}
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		logrus.Errorf("❌ [AI Service] Failed to create provider: %v", err)
 		return nil
 	}
 	s := &Service{
-		client: openai.NewClient(apiToken),
+		provider: provider,
+		cfg:      cfg,
 	}
 	if serpAPIKey != "" {
 		s.serpService = serp.NewService(serpAPIKey)
@@ -38,19 +55,146 @@ func NewService(apiToken string, serpAPIKey string) *Service {
 	return s
 }
 
+// NewServiceForAgent creates a service using the agent's provider, endpoint and keys
+func NewServiceForAgent(a *agent.Agent) *Service {
+	if a == nil {
+		return nil
+	}
+	return NewServiceWithProvider(ProviderConfig{
+		Provider: a.Provider,
+		APIKey:   a.APIKey,
+		BaseURL:  a.BaseURL,
+		Model:    a.Model,
+	}, a.SerpAPIKey)
+}
+
+// chatModel resolves the model for agent replies
+func (s *Service) chatModel(model string) string {
+	if model != "" {
+		return model
+	}
+	if s.cfg.Model != "" {
+		return s.cfg.Model
+	}
+	// The global default only makes sense for the stock OpenAI endpoint
+	if s.cfg.BaseURL == "" && (s.cfg.Provider == "" || s.cfg.Provider == ProviderOpenAI) && config.AIChatbotModel != "" {
+		return config.AIChatbotModel
+	}
+	chat, _ := defaultModels(s.cfg)
+	return chat
+}
+
+// utilityModel resolves the model for translation, sentiment and summaries
+func (s *Service) utilityModel() string {
+	if s.cfg.UtilityModel != "" {
+		return s.cfg.UtilityModel
+	}
+	if _, utility := defaultModels(s.cfg); utility != "" {
+		return utility
+	}
+	return s.chatModel("")
+}
+
+// complete sends a single prompt with the utility model and returns the trimmed answer.
+// operation is reported with the usage of the call.
+func (s *Service) complete(ctx context.Context, operation, systemPrompt, prompt string, maxTokens int, temperature float64) (string, error) {
+	resp, err := s.chat(ctx, operation, ChatRequest{
+		Model:        s.utilityModel(),
+		SystemPrompt: systemPrompt,
+		Messages:     []ChatMessage{{Role: RoleUser, Content: prompt}},
+		MaxTokens:    maxTokens,
+		Temperature:  temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// chat calls the provider and records the tokens used. Backends that report no usage
+// (some self-hosted servers, streams) are recorded with estimated token counts.
+func (s *Service) chat(ctx context.Context, operation string, req ChatRequest) (*ChatResponse, error) {
+	started := time.Now()
+	resp, err := s.provider.Chat(ctx, req)
+	event := UsageEvent{Operation: operation, Model: req.Model, Err: err}
+	if resp != nil {
+		if resp.Model != "" {
+			event.Model = resp.Model
+		}
+		event.PromptTokens, event.CompletionTokens = resp.PromptTokens, resp.CompletionTokens
+		if event.PromptTokens == 0 && event.CompletionTokens == 0 {
+			event.PromptTokens, event.CompletionTokens = estimateRequestTokens(req), EstimateTokens(resp.Content)
+		}
+	}
+	s.recordUsage(ctx, event, started)
+	return resp, err
+}
+
+// estimateRequestTokens approximates the prompt size of a request
+func estimateRequestTokens(req ChatRequest) int {
+	tokens := EstimateTokens(req.SystemPrompt)
+	for _, m := range req.Messages {
+		tokens += EstimateTokens(m.Content) + 4 // Per-message overhead
+		for _, call := range m.ToolCalls {
+			tokens += EstimateTokens(call.Arguments)
+		}
+		tokens += len(m.Images) * estimatedImageTokens
+	}
+	return tokens
+}
+
+// estimatedImageTokens is roughly what a medium-sized photo costs on current vision models
+const estimatedImageTokens = 1000
+
+// Chat roles used in ChatMessage
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// ChatMessage is a single turn of a conversation passed to the model
+type ChatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Set on assistant turns that requested tools
+	ToolCallID string     `json:"tool_call_id,omitempty"` // Set on tool turns carrying a result
+	Images     []Image    `json:"-"`                      // Pictures sent with a user turn, for vision models
+}
+
 // GenerateResponse generates an AI response for the given user message
 func (s *Service) GenerateResponse(ctx context.Context, userMessage string, systemPrompt string, model string, maxTokens int, temperature float64) (string, error) {
-	if s == nil || s.client == nil {
+	messages := []ChatMessage{{Role: RoleUser, Content: userMessage}}
+	return s.GenerateChatResponse(ctx, messages, systemPrompt, model, maxTokens, temperature)
+}
+
+// GenerateChatResponse generates an AI response for a multi-turn conversation.
+// Messages must be in chronological order; the last one is treated as the current user turn.
+func (s *Service) GenerateChatResponse(ctx context.Context, messages []ChatMessage, systemPrompt string, model string, maxTokens int, temperature float64) (string, error) {
+	return s.GenerateChatResponseWithTools(ctx, messages, systemPrompt, model, maxTokens, temperature, nil, nil, 0)
+}
+
+// GenerateChatResponseWithTools is GenerateChatResponse with function calling. The model may
+// request tools up to maxRounds times; each call is run through handler and its result fed back.
+// With no tools the SerpAPI keyword heuristic is used instead.
+func (s *Service) GenerateChatResponseWithTools(ctx context.Context, messages []ChatMessage, systemPrompt string, model string, maxTokens int, temperature float64, tools []ToolDefinition, handler ToolHandler, maxRounds int) (string, error) {
+	return s.StreamChatResponse(ctx, messages, systemPrompt, model, maxTokens, temperature, tools, handler, maxRounds, nil)
+}
+
+// StreamChatResponse is GenerateChatResponseWithTools with the reply text passed to onDelta
+// as it is generated. With tools the final answer is passed once its round completes, so text
+// sent alongside tool calls never reaches the user. A nil onDelta makes a regular blocking request.
+func (s *Service) StreamChatResponse(ctx context.Context, messages []ChatMessage, systemPrompt string, model string, maxTokens int, temperature float64, tools []ToolDefinition, handler ToolHandler, maxRounds int, onDelta StreamHandler) (string, error) {
+	if s == nil || s.provider == nil {
 		return "", fmt.Errorf("AI service not initialized")
 	}
+	if len(messages) == 0 {
+		return "", fmt.Errorf("no messages to respond to")
+	}
 
 	// Use default model if not specified
-	if model == "" {
-		model = config.AIChatbotModel
-	}
-	if model == "" {
-		model = "gpt-4o-mini"
-	}
+	model = s.chatModel(model)
 
 	// Use default system prompt if not specified
 	if systemPrompt == "" {
@@ -60,23 +204,27 @@ func (s *Service) GenerateResponse(ctx context.Context, userMessage string, syst
 		systemPrompt = "You are a helpful assistant. Respond concisely and helpfully to user messages."
 	}
 
+	// Copy so search results don't leak into the caller's history
+	turns := make([]ChatMessage, len(messages))
+	copy(turns, messages)
+	last := &turns[len(turns)-1]
+
 	// Check if user is asking for current/real-time information
-	needsSearch := s.needsInternetSearch(userMessage)
-	var searchResults string
-	if needsSearch {
+	if len(tools) > 0 {
+		logrus.Debugf("ℹ️  [AI Service] Tools enabled, skipping keyword-based internet search")
+	} else if last.Role == RoleUser && s.needsInternetSearch(last.Content) {
 		if s.serpService == nil {
 			logrus.Debugf("🌐 [AI Service] SerpAPI service not available (no API key configured)")
 		} else {
 			// Extract search query from user message
-			searchQuery := s.extractSearchQuery(userMessage)
+			searchQuery := s.extractSearchQuery(last.Content)
 			if searchQuery != "" {
 				logrus.Infof("🔍 [AI Service] Searching internet for: %s", searchQuery)
 				results, err := s.serpService.Search(searchQuery)
 				if err == nil {
-					searchResults = results
-					logrus.Infof("✅ [AI Service] SerpAPI search successful, results length: %d", len(searchResults))
+					logrus.Infof("✅ [AI Service] SerpAPI search successful, results length: %d", len(results))
 					// Add search results to user message
-					userMessage = fmt.Sprintf("User question: %s\n\nSearch results from internet:\n%s\n\nPlease answer based on the search results above.", userMessage, searchResults)
+					last.Content = fmt.Sprintf("User question: %s\n\nSearch results from internet:\n%s\n\nPlease answer based on the search results above.", last.Content, results)
 				} else {
 					logrus.Warnf("⚠️  [AI Service] SerpAPI search failed: %v", err)
 				}
@@ -96,38 +244,149 @@ func (s *Service) GenerateResponse(ctx context.Context, userMessage string, syst
 		temperature = 0.7 // Default temperature
 	}
 
-	req := openai.ChatCompletionRequest{
-		Model: model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: systemPrompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: userMessage,
-			},
-		},
-		MaxTokens:   maxTokens,
-		Temperature: float32(temperature),
+	if maxRounds <= 0 {
+		maxRounds = DefaultMaxToolRounds
 	}
 
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+	req := ChatRequest{
+		Model:        model,
+		SystemPrompt: systemPrompt,
+		Messages:     turns,
+		MaxTokens:    maxTokens,
+		Temperature:  temperature,
+		Tools:        tools,
+		Stream:       onDelta,
+	}
+
+	// With tools, a round may answer with text and tool calls together. That text is not the
+	// reply, so deltas are held until the round turns out to be the final one.
+	var pending []string
+	flush := func() {}
+	if onDelta != nil && len(tools) > 0 && handler != nil {
+		req.Stream = func(delta string) { pending = append(pending, delta) }
+		flush = func() {
+			for _, delta := range pending {
+				onDelta(delta)
+			}
+		}
+	}
+
+	for round := 0; ; round++ {
+		pending = pending[:0]
+		resp, err := s.chat(ctx, OperationChat, req)
+		if err != nil {
+			logrus.Errorf("Failed to generate AI response: %v", err)
+			return "", fmt.Errorf("failed to generate AI response: %w", err)
+		}
+
+		if len(resp.ToolCalls) == 0 || handler == nil {
+			flush()
+			return strings.TrimSpace(resp.Content), nil
+		}
+		if round >= maxRounds {
+			logrus.Warnf("⚠️  [AI Service] Tool call limit (%d rounds) reached", maxRounds)
+			if content := strings.TrimSpace(resp.Content); content != "" {
+				flush()
+				return content, nil
+			}
+			return "", fmt.Errorf("tool call limit reached without a final answer")
+		}
+
+		req.Messages = append(req.Messages, ChatMessage{Role: RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			logrus.Infof("🛠️ [AI Service] Model requested tool %s", call.Name)
+			req.Messages = append(req.Messages, ChatMessage{
+				Role:       RoleTool,
+				Content:    handler(ctx, call),
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
+
+// SummarizeConversation folds older turns into a rolling summary.
+// previousSummary may be empty; the returned summary replaces it.
+func (s *Service) SummarizeConversation(ctx context.Context, previousSummary string, messages []ChatMessage) (string, error) {
+	if s == nil || s.provider == nil {
+		return "", fmt.Errorf("AI service not initialized")
+	}
+	if len(messages) == 0 {
+		return previousSummary, nil
+	}
+
+	var transcript strings.Builder
+	for _, m := range messages {
+		speaker := "Customer"
+		if m.Role == RoleAssistant {
+			speaker = "Assistant"
+		}
+		transcript.WriteString(fmt.Sprintf("%s: %s\n", speaker, m.Content))
+	}
+
+	prompt := fmt.Sprintf("Existing summary:\n%s\n\nNew messages:\n%s\nWrite an updated summary of the whole conversation. Keep names, facts, requests and commitments. Attribute statements to the Customer or the Assistant.", previousSummary, transcript.String())
+	if previousSummary == "" {
+		prompt = fmt.Sprintf("Messages:\n%s\nSummarize this conversation. Keep names, facts, requests and commitments. Attribute statements to the Customer or the Assistant.", transcript.String())
+	}
+
+	summary, err := s.complete(ctx, OperationSummary, "You summarize customer support conversations concisely and factually.", prompt, 300, 0.2)
 	if err != nil {
-		logrus.Errorf("Failed to generate AI response: %v", err)
-		return "", fmt.Errorf("failed to generate AI response: %w", err)
+		logrus.Errorf("Failed to summarize conversation: %v", err)
+		return "", fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	return summary, nil
+}
+
+// CreateEmbeddings returns one embedding vector per input, in order
+func (s *Service) CreateEmbeddings(ctx context.Context, inputs []string) ([][]float64, error) {
+	if s == nil || s.provider == nil {
+		return nil, fmt.Errorf("AI service not initialized")
+	}
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	if !SupportsEmbeddings(s.cfg.Provider) {
+		return nil, fmt.Errorf("failed to create embeddings: %w (%s)", ErrNotSupported, s.cfg.Provider)
+	}
+
+	model := s.cfg.EmbeddingModel
+	if model == "" {
+		model = "text-embedding-ada-002"
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response from AI")
+	started := time.Now()
+	resp, err := s.provider.Embed(ctx, model, inputs)
+	event := UsageEvent{Operation: OperationEmbedding, Model: model, Err: err}
+	if resp != nil {
+		event.PromptTokens = resp.PromptTokens
+		if event.PromptTokens == 0 {
+			for _, input := range inputs {
+				event.PromptTokens += EstimateTokens(input)
+			}
+		}
+	}
+	s.recordUsage(ctx, event, started)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
 	}
+	embeddings := resp.Embeddings
+	if len(embeddings) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(embeddings))
+	}
+	return embeddings, nil
+}
 
-	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+// EstimateTokens gives a rough token count for budgeting (about 4 characters per token)
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return utf8.RuneCountInString(text)/4 + 1
 }
 
-// TranscribeAudio transcribes an audio file using OpenAI Whisper API
+// TranscribeAudio transcribes an audio file using the provider's speech-to-text API
 func (s *Service) TranscribeAudio(ctx context.Context, audioPath string) (string, error) {
-	if s == nil || s.client == nil {
+	if s == nil || s.provider == nil {
 		return "", fmt.Errorf("AI service not initialized")
 	}
 
@@ -138,21 +397,13 @@ func (s *Service) TranscribeAudio(ctx context.Context, audioPath string) (string
 	}
 	defer audioFile.Close()
 
-	// Create the transcription request
-	req := openai.AudioRequest{
-		Model:    openai.Whisper1,
-		FilePath: audioPath,
-		Reader:   audioFile,
-		Format:   openai.AudioResponseFormatText,
-	}
-
-	transcription, err := s.client.CreateTranscription(ctx, req)
+	transcription, err := s.transcribe(ctx, audioFile, audioPath)
 	if err != nil {
 		logrus.Errorf("Failed to transcribe audio: %v", err)
 		return "", fmt.Errorf("failed to transcribe audio: %w", err)
 	}
 
-	return strings.TrimSpace(transcription.Text), nil
+	return strings.TrimSpace(transcription), nil
 }
 
 // needsInternetSearch checks if the user message requires internet search
@@ -197,29 +448,72 @@ func (s *Service) extractSearchQuery(message string) string {
 	return strings.TrimSpace(query)
 }
 
-// TranscribeAudioFromBytes transcribes audio from bytes using OpenAI Whisper API
+// TranscribeAudioFromBytes transcribes audio from bytes using the provider's speech-to-text API
 func (s *Service) TranscribeAudioFromBytes(ctx context.Context, audioBytes []byte, filename string) (string, error) {
-	if s == nil || s.client == nil {
+	if s == nil || s.provider == nil {
 		return "", fmt.Errorf("AI service not initialized")
 	}
 
 	// Create a reader from bytes
 	reader := bytes.NewReader(audioBytes)
 
-	// Create the transcription request
-	req := openai.AudioRequest{
-		Model:    openai.Whisper1,
-		FilePath: filename,
-		Reader:   reader,
-		Format:   openai.AudioResponseFormatText,
-	}
-
-	transcription, err := s.client.CreateTranscription(ctx, req)
+	transcription, err := s.transcribe(ctx, reader, filename)
 	if err != nil {
 		logrus.Errorf("Failed to transcribe audio from bytes: %v", err)
 		return "", fmt.Errorf("failed to transcribe audio: %w", err)
 	}
 
-	return strings.TrimSpace(transcription.Text), nil
+	return strings.TrimSpace(transcription), nil
 }
 
+// transcribe calls the provider's speech-to-text API and records the audio duration
+func (s *Service) transcribe(ctx context.Context, audio io.Reader, filename string) (string, error) {
+	started := time.Now()
+	resp, err := s.provider.Transcribe(ctx, audio, filename)
+	event := UsageEvent{Operation: OperationTranscription, Err: err}
+	if resp != nil {
+		event.Model, event.AudioSeconds = resp.Model, resp.DurationSeconds
+	}
+	s.recordUsage(ctx, event, started)
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+// Defaults for SynthesizeSpeech
+const (
+	DefaultSpeechModel = "tts-1"
+	DefaultSpeechVoice = "alloy"
+)
+
+// SynthesizeSpeech converts text to speech with the provider's text-to-speech API.
+// Speech is billed per input character, which is recorded as prompt tokens.
+func (s *Service) SynthesizeSpeech(ctx context.Context, text, voice, model string) (*SpeechResponse, error) {
+	if s == nil || s.provider == nil {
+		return nil, fmt.Errorf("AI service not initialized")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("no text to synthesize")
+	}
+	if model == "" {
+		model = DefaultSpeechModel
+	}
+	if voice == "" {
+		voice = DefaultSpeechVoice
+	}
+
+	started := time.Now()
+	resp, err := s.provider.Speak(ctx, SpeechRequest{Model: model, Voice: voice, Text: text})
+	s.recordUsage(ctx, UsageEvent{
+		Operation:    OperationSpeech,
+		Model:        model,
+		PromptTokens: utf8.RuneCountInString(text),
+		Err:          err,
+	}, started)
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize speech: %w", err)
+	}
+	return resp, nil
+}
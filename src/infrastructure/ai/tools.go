@@ -0,0 +1,24 @@
+package ai
+
+import "context"
+
+// DefaultMaxToolRounds limits how many times the model may call tools before it must answer
+const DefaultMaxToolRounds = 5
+
+// ToolDefinition describes a function the model may call
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"` // JSON Schema of the arguments object
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments object
+}
+
+// ToolHandler runs a tool call and returns the text handed back to the model.
+// Failures should be described in the returned text so the model can recover.
+type ToolHandler func(ctx context.Context, call ToolCall) string
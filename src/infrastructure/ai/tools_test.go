@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type toolTestRequest struct {
+	Messages []struct {
+		Role       string `json:"role"`
+		Content    string `json:"content"`
+		ToolCallID string `json:"tool_call_id"`
+	} `json:"messages"`
+	Tools []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+}
+
+func TestGenerateChatResponseWithTools(t *testing.T) {
+	var requests []toolTestRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req toolTestRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		if len(requests) == 1 {
+			w.Write([]byte(`{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":\"hours\"}"}}]},"finish_reason":"tool_calls"}]}`))
+			return
+		}
+		w.Write([]byte(`{"id":"2","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"We open at 9."},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	svc := NewServiceWithProvider(ProviderConfig{BaseURL: server.URL + "/v1", Model: "m"}, "")
+	tools := []ToolDefinition{{Name: "lookup", Description: "Look things up", Parameters: map[string]interface{}{"type": "object"}}}
+
+	var handled []ToolCall
+	handler := func(ctx context.Context, call ToolCall) string {
+		handled = append(handled, call)
+		return "Open 9-17"
+	}
+
+	got, err := svc.GenerateChatResponseWithTools(context.Background(), []ChatMessage{{Role: RoleUser, Content: "when do you open?"}}, "", "", 100, 0, tools, handler, DefaultMaxToolRounds)
+	if err != nil {
+		t.Fatalf("GenerateChatResponseWithTools() error = %v", err)
+	}
+	if got != "We open at 9." {
+		t.Fatalf("GenerateChatResponseWithTools() = %q", got)
+	}
+	if len(handled) != 1 || handled[0].Name != "lookup" || handled[0].Arguments != `{"q":"hours"}` {
+		t.Fatalf("handled calls = %+v", handled)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("provider called %d times, want 2", len(requests))
+	}
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Function.Name != "lookup" {
+		t.Fatalf("tools sent = %+v", requests[0].Tools)
+	}
+	last := requests[1].Messages[len(requests[1].Messages)-1]
+	if last.Role != RoleTool || last.ToolCallID != "call_1" || last.Content != "Open 9-17" {
+		t.Fatalf("tool result message = %+v", last)
+	}
+}
+
+// scriptedProvider streams and returns one canned response per chat call
+type scriptedProvider struct {
+	Provider
+	responses []ChatResponse
+	calls     int
+}
+
+func (p *scriptedProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp := p.responses[p.calls]
+	p.calls++
+	if req.Stream != nil {
+		for _, word := range strings.SplitAfter(resp.Content, " ") {
+			req.Stream(word)
+		}
+	}
+	return &resp, nil
+}
+
+func TestStreamChatResponseStreamsOnlyFinalRound(t *testing.T) {
+	provider := &scriptedProvider{responses: []ChatResponse{
+		{Content: "Let me check. ", ToolCalls: []ToolCall{{ID: "call_1", Name: "lookup", Arguments: "{}"}}},
+		{Content: "We open at 9."},
+	}}
+	svc := &Service{provider: provider, cfg: ProviderConfig{Model: "m"}}
+	tools := []ToolDefinition{{Name: "lookup", Parameters: map[string]interface{}{"type": "object"}}}
+	handler := func(ctx context.Context, call ToolCall) string { return "Open 9-17" }
+
+	var streamed strings.Builder
+	got, err := svc.StreamChatResponse(context.Background(), []ChatMessage{{Role: RoleUser, Content: "when do you open?"}},
+		"", "", 100, 0, tools, handler, DefaultMaxToolRounds, func(delta string) { streamed.WriteString(delta) })
+	if err != nil {
+		t.Fatalf("StreamChatResponse() error = %v", err)
+	}
+	if got != "We open at 9." || streamed.String() != "We open at 9." {
+		t.Fatalf("StreamChatResponse() = %q, streamed %q; want only the final answer", got, streamed.String())
+	}
+	if provider.calls != 2 {
+		t.Fatalf("provider called %d times, want 2", provider.calls)
+	}
+}
@@ -6,13 +6,12 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/sashabaranov/go-openai"
 	"github.com/sirupsen/logrus"
 )
 
-// TranslateText translates text from source language to target language using OpenAI
+// TranslateText translates text from source language to target language using the configured provider
 func (s *Service) TranslateText(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
-	if s == nil || s.client == nil {
+	if s == nil || s.provider == nil {
 		return "", fmt.Errorf("AI service not initialized")
 	}
 
@@ -20,41 +19,25 @@ func (s *Service) TranslateText(ctx context.Context, text, sourceLang, targetLan
 		return "", nil
 	}
 
-	// Use OpenAI for translation
+	// Use the utility model for translation
 	prompt := fmt.Sprintf("Translate the following text from %s to %s. Only return the translation, no explanations:\n\n%s", sourceLang, targetLang, text)
 
-	req := openai.ChatCompletionRequest{
-		Model: "gpt-4o-mini",
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are a professional translator. Translate accurately and preserve the meaning and tone.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		MaxTokens:   500,
-		Temperature: 0.3, // Lower temperature for more consistent translations
-	}
-
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+	content, err := s.complete(ctx, OperationTranslation, "You are a professional translator. Translate accurately and preserve the meaning and tone.", prompt, 500, 0.3)
 	if err != nil {
 		logrus.Errorf("Failed to translate text: %v", err)
 		return "", fmt.Errorf("failed to translate text: %w", err)
 	}
 
-	if len(resp.Choices) == 0 {
+	if content == "" {
 		return "", fmt.Errorf("no translation response")
 	}
 
-	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+	return content, nil
 }
 
-// DetectLanguage detects the language of the text using OpenAI
+// DetectLanguage detects the language of the text using the configured provider
 func (s *Service) DetectLanguage(ctx context.Context, text string) (string, error) {
-	if s == nil || s.client == nil {
+	if s == nil || s.provider == nil {
 		return "", fmt.Errorf("AI service not initialized")
 	}
 
@@ -64,33 +47,17 @@ func (s *Service) DetectLanguage(ctx context.Context, text string) (string, erro
 
 	prompt := fmt.Sprintf("Detect the language of the following text. Respond with only the ISO 639-1 language code (e.g., 'en', 'ru', 'es', 'fr'):\n\n%s", text)
 
-	req := openai.ChatCompletionRequest{
-		Model: "gpt-4o-mini",
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are a language detection expert. Respond with only the ISO 639-1 language code.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		MaxTokens:   10,
-		Temperature: 0.1,
-	}
-
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+	content, err := s.complete(ctx, OperationLanguage, "You are a language detection expert. Respond with only the ISO 639-1 language code.", prompt, 10, 0.1)
 	if err != nil {
 		logrus.Errorf("Failed to detect language: %v", err)
 		return "en", nil // Default to English on error
 	}
 
-	if len(resp.Choices) == 0 {
+	if content == "" {
 		return "en", nil
 	}
 
-	langCode := strings.TrimSpace(strings.ToLower(resp.Choices[0].Message.Content))
+	langCode := strings.ToLower(content)
 	// Validate it's a 2-letter code
 	if len(langCode) == 2 {
 		return langCode, nil
@@ -99,9 +66,49 @@ func (s *Service) DetectLanguage(ctx context.Context, text string) (string, erro
 	return "en", nil // Default to English if invalid
 }
 
+// DetectLanguageWithConfidence detects the language of the text and how sure the model is (0-1).
+// Short or mixed-language messages come back with a low confidence.
+func (s *Service) DetectLanguageWithConfidence(ctx context.Context, text string) (string, float64, error) {
+	if s == nil || s.provider == nil {
+		return "", 0, fmt.Errorf("AI service not initialized")
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return "", 0, nil
+	}
+
+	prompt := fmt.Sprintf("Detect the language of the following text. Respond with ONLY a JSON object in this exact format: {\"language\": \"es\", \"confidence\": 0.9}\n\nlanguage must be an ISO 639-1 code. confidence is between 0 and 1 and should be low for very short, ambiguous or mixed-language text.\n\nText: %s", text)
+
+	content, err := s.complete(ctx, OperationLanguage, "You are a language detection expert. Always respond with valid JSON only.", prompt, 30, 0.1)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to detect language: %w", err)
+	}
+
+	responseText := content
+	jsonStart := strings.Index(responseText, "{")
+	jsonEnd := strings.LastIndex(responseText, "}")
+	if jsonStart >= 0 && jsonEnd > jsonStart {
+		responseText = responseText[jsonStart : jsonEnd+1]
+	}
+
+	var result struct {
+		Language   string  `json:"language"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
+		return "", 0, fmt.Errorf("failed to parse language detection response: %w", err)
+	}
+
+	langCode := strings.ToLower(strings.TrimSpace(result.Language))
+	if len(langCode) != 2 {
+		return "", 0, fmt.Errorf("invalid language code %q", result.Language)
+	}
+	return langCode, result.Confidence, nil
+}
+
 // AnalyzeSentiment analyzes the sentiment of the text and returns a score (-1 to 1, where -1 is very negative, 1 is very positive)
 func (s *Service) AnalyzeSentiment(ctx context.Context, text string) (float64, string, error) {
-	if s == nil || s.client == nil {
+	if s == nil || s.provider == nil {
 		return 0, "neutral", fmt.Errorf("AI service not initialized")
 	}
 
@@ -111,34 +118,18 @@ func (s *Service) AnalyzeSentiment(ctx context.Context, text string) (float64, s
 
 	prompt := fmt.Sprintf("Analyze the sentiment of the following text. Respond with ONLY a JSON object in this exact format: {\"score\": -0.5, \"label\": \"negative\"}\n\nScore should be between -1 (very negative) and 1 (very positive). Label should be one of: \"very_negative\", \"negative\", \"neutral\", \"positive\", \"very_positive\".\n\nText: %s", text)
 
-	req := openai.ChatCompletionRequest{
-		Model: "gpt-4o-mini",
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are a sentiment analysis expert. Always respond with valid JSON only.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		MaxTokens:   50,
-		Temperature: 0.3,
-	}
-
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+	content, err := s.complete(ctx, OperationSentiment, "You are a sentiment analysis expert. Always respond with valid JSON only.", prompt, 50, 0.3)
 	if err != nil {
 		logrus.Errorf("Failed to analyze sentiment: %v", err)
 		return 0, "neutral", fmt.Errorf("failed to analyze sentiment: %w", err)
 	}
 
-	if len(resp.Choices) == 0 {
+	if content == "" {
 		return 0, "neutral", nil
 	}
 
 	// Parse JSON response
-	responseText := strings.TrimSpace(resp.Choices[0].Message.Content)
+	responseText := content
 	
 	// Try to extract JSON from response (in case there's extra text)
 	jsonStart := strings.Index(responseText, "{")
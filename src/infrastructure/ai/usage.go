@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Operations reported in UsageEvent.Operation
+const (
+	OperationChat          = "chat"
+	OperationTranslation   = "translation"
+	OperationLanguage      = "language_detection"
+	OperationSentiment     = "sentiment"
+	OperationSummary       = "summary"
+	OperationEmbedding     = "embedding"
+	OperationTranscription = "transcription"
+	OperationSpeech        = "speech"
+)
+
+// UsageScope tells whom an LLM call is billed to. It travels in the context so callers
+// attribute usage without threading IDs through every Service method.
+type UsageScope struct {
+	AgentID         string
+	ConversationID  string
+	FlowID          string
+	FlowExecutionID string
+	DryRun          bool // Calls made by flow test runs, which have no execution and are not recorded
+}
+
+// UsageEvent describes one provider call
+type UsageEvent struct {
+	UsageScope
+	Operation        string
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	AudioSeconds     float64 // Transcriptions are billed by duration
+	Latency          time.Duration
+	Err              error
+}
+
+// UsageRecorder receives every provider call made through a Service
+type UsageRecorder func(ctx context.Context, event UsageEvent)
+
+type usageScopeKey struct{}
+
+var (
+	usageRecorderMu sync.RWMutex
+	usageRecorder   UsageRecorder
+)
+
+// SetUsageRecorder installs the process-wide usage recorder (nil disables recording)
+func SetUsageRecorder(recorder UsageRecorder) {
+	usageRecorderMu.Lock()
+	defer usageRecorderMu.Unlock()
+	usageRecorder = recorder
+}
+
+// WithUsageScope returns a context whose LLM calls are attributed to scope. Empty fields keep
+// the value of an outer scope, so a flow run from an agent conversation is billed to both.
+func WithUsageScope(ctx context.Context, scope UsageScope) context.Context {
+	current := UsageScopeFromContext(ctx)
+	if scope.AgentID != "" {
+		current.AgentID = scope.AgentID
+	}
+	if scope.ConversationID != "" {
+		current.ConversationID = scope.ConversationID
+	}
+	if scope.FlowID != "" {
+		current.FlowID = scope.FlowID
+	}
+	if scope.FlowExecutionID != "" {
+		current.FlowExecutionID = scope.FlowExecutionID
+	}
+	if scope.DryRun {
+		current.DryRun = true
+	}
+	return context.WithValue(ctx, usageScopeKey{}, current)
+}
+
+// UsageScopeFromContext returns the scope set with WithUsageScope (zero value if none)
+func UsageScopeFromContext(ctx context.Context) UsageScope {
+	scope, _ := ctx.Value(usageScopeKey{}).(UsageScope)
+	return scope
+}
+
+// recordUsage reports a provider call to the installed recorder, unless it was made in a dry run
+func (s *Service) recordUsage(ctx context.Context, event UsageEvent, started time.Time) {
+	usageRecorderMu.RLock()
+	recorder := usageRecorder
+	usageRecorderMu.RUnlock()
+	event.UsageScope = UsageScopeFromContext(ctx)
+	if recorder == nil || event.DryRun {
+		return
+	}
+
+	event.Provider = s.cfg.Provider
+	if event.Provider == "" {
+		event.Provider = ProviderOpenAI
+	}
+	event.Latency = time.Since(started)
+	recorder(ctx, event)
+}
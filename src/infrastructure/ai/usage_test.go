@@ -0,0 +1,25 @@
+package ai
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordUsageSkipsDryRuns(t *testing.T) {
+	var events []UsageEvent
+	SetUsageRecorder(func(ctx context.Context, event UsageEvent) { events = append(events, event) })
+	t.Cleanup(func() { SetUsageRecorder(nil) })
+
+	svc := NewServiceWithProvider(ProviderConfig{BaseURL: "http://localhost/v1", Model: "m"}, "")
+	ctx := WithUsageScope(context.Background(), UsageScope{FlowID: "flow-1", FlowExecutionID: "exec-1"})
+	svc.recordUsage(ctx, UsageEvent{Operation: OperationChat}, time.Now())
+
+	// Scopes set inside a dry run, such as an agent called from a flow, stay dry
+	dry := WithUsageScope(context.Background(), UsageScope{FlowID: "flow-1", DryRun: true})
+	svc.recordUsage(WithUsageScope(dry, UsageScope{AgentID: "agent-1"}), UsageEvent{Operation: OperationChat}, time.Now())
+
+	if len(events) != 1 || events[0].FlowExecutionID != "exec-1" {
+		t.Fatalf("recorded events = %+v, want only the live call", events)
+	}
+}
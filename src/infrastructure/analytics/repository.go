@@ -293,6 +293,63 @@ func (r *SQLiteRepository) GetAgentStats(ctx context.Context, period string) ([]
 	return result, rows.Err()
 }
 
+// GetSentimentStats returns the sentiment breakdown of analysed user messages per agent
+func (r *SQLiteRepository) GetSentimentStats(ctx context.Context, period string) ([]analytics.SentimentStats, error) {
+	// Zero start time means all time
+	startTime := periodStart(period, time.Now())
+
+	rows, err := r.agentDB.QueryContext(ctx, `
+		SELECT
+			a.id as agent_id,
+			a.name as agent_name,
+			COUNT(m.id) as analyzed,
+			SUM(CASE WHEN m.sentiment_label LIKE '%positive' THEN 1 ELSE 0 END) as positive,
+			SUM(CASE WHEN m.sentiment_label LIKE '%negative' THEN 1 ELSE 0 END) as negative,
+			COALESCE(AVG(m.sentiment_score), 0) as average_score
+		FROM agents a
+		LEFT JOIN conversations c ON c.agent_id = a.id
+		LEFT JOIN messages m ON m.conversation_id = c.id
+			AND m.role = 'user' AND m.sentiment_score IS NOT NULL AND m.timestamp >= ?
+		GROUP BY a.id, a.name
+		ORDER BY analyzed DESC
+	`, startTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sentiment stats: %w", err)
+	}
+	defer rows.Close()
+
+	var result []analytics.SentimentStats
+	for rows.Next() {
+		var stat analytics.SentimentStats
+		var positive, negative sql.NullInt64
+		if err := rows.Scan(&stat.AgentID, &stat.AgentName, &stat.Analyzed, &positive, &negative, &stat.AverageScore); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		stat.Positive = int(positive.Int64)
+		stat.Negative = int(negative.Int64)
+		stat.Neutral = stat.Analyzed - stat.Positive - stat.Negative
+		result = append(result, stat)
+	}
+
+	return result, rows.Err()
+}
+
+// periodStart returns the beginning of an analytics period, or the zero time for all time
+func periodStart(period string, now time.Time) time.Time {
+	switch period {
+	case "today":
+		return now.Truncate(24 * time.Hour)
+	case "7days":
+		return now.AddDate(0, 0, -7)
+	case "30days":
+		return now.AddDate(0, 0, -30)
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Time{}
+	}
+}
+
 // Helper function to truncate string
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -3,9 +3,11 @@ package calendar
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/calendar"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/encryption"
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -64,17 +66,46 @@ func (r *SQLiteRepository) CreateCredential(ctx context.Context, cred *calendar.
 	}
 	cred.UpdatedAt = time.Now()
 
-	_, err := r.db.ExecContext(ctx,
+	secrets, err := encryptSecrets(cred)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
 		`INSERT INTO calendar_credentials (id, agent_id, name, client_id, client_secret, 
 		                                   access_token, refresh_token, token_expiry, 
 		                                   calendar_id, is_connected, created_at, updated_at)
 		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		cred.ID, cred.AgentID, cred.Name, cred.ClientID, cred.ClientSecret,
-		cred.AccessToken, cred.RefreshToken, cred.TokenExpiry,
+		cred.ID, cred.AgentID, cred.Name, cred.ClientID, secrets[0],
+		secrets[1], secrets[2], cred.TokenExpiry,
 		cred.CalendarID, cred.IsConnected, cred.CreatedAt, cred.UpdatedAt)
 	return err
 }
 
+// encryptSecrets returns the client secret, access token and refresh token as they are stored
+func encryptSecrets(cred *calendar.CalendarCredential) ([3]string, error) {
+	var secrets [3]string
+	for i, value := range []string{cred.ClientSecret, cred.AccessToken, cred.RefreshToken} {
+		encrypted, err := encryption.Encrypt(value)
+		if err != nil {
+			return secrets, fmt.Errorf("failed to encrypt calendar credential: %w", err)
+		}
+		secrets[i] = encrypted
+	}
+	return secrets, nil
+}
+
+// decryptSecrets replaces the stored secrets of a credential with their plaintext
+func decryptSecrets(cred *calendar.CalendarCredential) error {
+	for _, value := range []*string{&cred.ClientSecret, &cred.AccessToken, &cred.RefreshToken} {
+		plaintext, err := encryption.Decrypt(*value)
+		if err != nil {
+			return fmt.Errorf("calendar credential %s: %w", cred.ID, err)
+		}
+		*value = plaintext
+	}
+	return nil
+}
+
 func (r *SQLiteRepository) GetCredential(ctx context.Context, id string) (*calendar.CalendarCredential, error) {
 	cred := &calendar.CalendarCredential{}
 	var tokenExpiry sql.NullTime
@@ -94,6 +125,9 @@ func (r *SQLiteRepository) GetCredential(ctx context.Context, id string) (*calen
 	if tokenExpiry.Valid {
 		cred.TokenExpiry = tokenExpiry.Time
 	}
+	if err := decryptSecrets(cred); err != nil {
+		return nil, err
+	}
 	return cred, nil
 }
 
@@ -116,20 +150,27 @@ func (r *SQLiteRepository) GetCredentialByAgentID(ctx context.Context, agentID s
 	if tokenExpiry.Valid {
 		cred.TokenExpiry = tokenExpiry.Time
 	}
+	if err := decryptSecrets(cred); err != nil {
+		return nil, err
+	}
 	return cred, nil
 }
 
 func (r *SQLiteRepository) UpdateCredential(ctx context.Context, cred *calendar.CalendarCredential) error {
 	cred.UpdatedAt = time.Now()
-	
-	_, err := r.db.ExecContext(ctx,
+
+	secrets, err := encryptSecrets(cred)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
 		`UPDATE calendar_credentials SET 
 		 name = ?, client_id = ?, client_secret = ?, access_token = ?,
 		 refresh_token = ?, token_expiry = ?, calendar_id = ?, 
 		 is_connected = ?, updated_at = ?
 		 WHERE id = ?`,
-		cred.Name, cred.ClientID, cred.ClientSecret, cred.AccessToken,
-		cred.RefreshToken, cred.TokenExpiry, cred.CalendarID,
+		cred.Name, cred.ClientID, secrets[0], secrets[1],
+		secrets[2], cred.TokenExpiry, cred.CalendarID,
 		cred.IsConnected, cred.UpdatedAt, cred.ID)
 	return err
 }
@@ -139,6 +180,12 @@ func (r *SQLiteRepository) DeleteCredential(ctx context.Context, id string) erro
 	return err
 }
 
+// EncryptSecrets encrypts client secrets and OAuth tokens stored in plaintext or with an older
+// master key, returning how many credentials were rewritten
+func (r *SQLiteRepository) EncryptSecrets(ctx context.Context) (int, error) {
+	return encryption.RewriteColumns(ctx, r.db, "calendar_credentials", "id", "client_secret", "access_token", "refresh_token")
+}
+
 
 
 
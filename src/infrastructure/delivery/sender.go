@@ -0,0 +1,234 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	agentRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/agent"
+	instagramPkg "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/instagram"
+	telegramBot "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/telegram"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+)
+
+// Sender delivers flow messages through the agent's WhatsApp, Telegram and Instagram integrations
+type Sender struct {
+	agentRepo   agent.IAgentRepository
+	sendUsecase domainSend.ISendUsecase
+}
+
+// NewSender creates a sender; WhatsApp messages go through sendUsecase on the integration's device
+func NewSender(agentRepo agent.IAgentRepository, sendUsecase domainSend.ISendUsecase) *Sender {
+	return &Sender{agentRepo: agentRepo, sendUsecase: sendUsecase}
+}
+
+// Send delivers msg to msg.Recipient through msg.IntegrationID
+func (s *Sender) Send(ctx context.Context, msg flow.OutgoingMessage) (*flow.SentMessage, error) {
+	integration, err := s.agentRepo.GetIntegrationByID(ctx, msg.IntegrationID)
+	if err != nil {
+		return nil, fmt.Errorf("integration %s not found: %w", msg.IntegrationID, err)
+	}
+	if msg.AgentID != "" && integration.AgentID != msg.AgentID {
+		return nil, fmt.Errorf("integration %s does not belong to agent %s", msg.IntegrationID, msg.AgentID)
+	}
+	if msg.Media == nil && strings.TrimSpace(msg.Text) == "" {
+		return nil, fmt.Errorf("message is empty")
+	}
+
+	var messageID string
+	switch integration.Type {
+	case agent.IntegrationTypeWhatsApp:
+		messageID, err = s.sendWhatsApp(ctx, integration, msg)
+	case agent.IntegrationTypeTelegram:
+		messageID, err = sendTelegram(integration, msg)
+	case agent.IntegrationTypeInstagram:
+		messageID, err = sendInstagram(integration, msg)
+	default:
+		err = fmt.Errorf("unsupported integration type: %s", integration.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &flow.SentMessage{MessageID: messageID, Channel: integration.Type}, nil
+}
+
+// sendWhatsApp sends through the send usecase with the integration's device in the context
+func (s *Sender) sendWhatsApp(ctx context.Context, integration *agent.Integration, msg flow.OutgoingMessage) (messageID string, err error) {
+	if s.sendUsecase == nil {
+		return "", fmt.Errorf("WhatsApp sending is not configured")
+	}
+	waConfig, err := agentRepo.ParseWhatsAppConfig(integration.Config)
+	if err != nil || waConfig == nil {
+		return "", fmt.Errorf("invalid WhatsApp config")
+	}
+	deviceID := waConfig.DeviceID
+	if deviceID == "" {
+		deviceID = waConfig.JID
+	}
+	device, _, err := whatsapp.GetDeviceManager().ResolveDevice(deviceID)
+	if err != nil {
+		return "", err
+	}
+	ctx = whatsapp.ContextWithDevice(ctx, device)
+
+	// The send usecase panics when the device is not logged in
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to send WhatsApp message: %v", r)
+		}
+	}()
+
+	base := domainSend.BaseRequest{Phone: msg.Recipient}
+	var response domainSend.GenericResponse
+	switch {
+	case msg.Media == nil:
+		response, err = s.sendUsecase.SendText(ctx, domainSend.MessageRequest{BaseRequest: base, Message: msg.Text})
+
+	case msg.Media.Kind == flow.MediaKindImage && msg.Media.URL != "":
+		imageURL := msg.Media.URL
+		response, err = s.sendUsecase.SendImage(ctx, domainSend.ImageRequest{BaseRequest: base, Caption: msg.Text, ImageURL: &imageURL})
+
+	case msg.Media.Kind == flow.MediaKindImage:
+		image, fhErr := utils.NewFileHeader("image", mediaFileName(msg.Media, "image"), mediaMIMEType(msg.Media), msg.Media.Data)
+		if fhErr != nil {
+			return "", fhErr
+		}
+		response, err = s.sendUsecase.SendImage(ctx, domainSend.ImageRequest{BaseRequest: base, Caption: msg.Text, Image: image})
+
+	default:
+		if err = downloadMedia(msg.Media); err != nil {
+			return "", err
+		}
+		file, fhErr := utils.NewFileHeader("file", mediaFileName(msg.Media, "file"), mediaMIMEType(msg.Media), msg.Media.Data)
+		if fhErr != nil {
+			return "", fhErr
+		}
+		response, err = s.sendUsecase.SendFile(ctx, domainSend.FileRequest{BaseRequest: base, Caption: msg.Text, File: file})
+	}
+	if err != nil {
+		return "", err
+	}
+	return response.MessageID, nil
+}
+
+// sendTelegram sends through the Bot API; recipients are chat IDs, optionally prefixed with tg_
+func sendTelegram(integration *agent.Integration, msg flow.OutgoingMessage) (string, error) {
+	tgConfig, err := agentRepo.ParseTelegramConfig(integration.Config)
+	if err != nil || tgConfig == nil || tgConfig.BotToken == "" {
+		return "", fmt.Errorf("invalid Telegram config")
+	}
+	chatID, err := strconv.ParseInt(strings.TrimPrefix(msg.Recipient, "tg_"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid Telegram chat ID %q", msg.Recipient)
+	}
+
+	var messageID int
+	switch {
+	case msg.Media == nil:
+		messageID, err = telegramBot.SendText(tgConfig.BotToken, chatID, msg.Text)
+	case msg.Media.Kind == flow.MediaKindImage:
+		messageID, err = telegramBot.SendPhoto(tgConfig.BotToken, chatID, telegramInputFile(msg.Media), msg.Text)
+	default:
+		messageID, err = telegramBot.SendDocument(tgConfig.BotToken, chatID, telegramInputFile(msg.Media), msg.Text)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(messageID), nil
+}
+
+func telegramInputFile(media *flow.OutgoingMedia) telegramBot.InputFile {
+	return telegramBot.InputFile{URL: media.URL, Data: media.Data, FileName: mediaFileName(media, "file")}
+}
+
+// sendInstagram sends through the Graph API; recipients are user IDs, optionally prefixed with ig_.
+// Instagram only takes media by URL, and sends a caption as a separate message.
+func sendInstagram(integration *agent.Integration, msg flow.OutgoingMessage) (string, error) {
+	igConfig, err := agentRepo.ParseInstagramConfig(integration.Config)
+	if err != nil || igConfig == nil || igConfig.AccessToken == "" || igConfig.PageID == "" {
+		return "", fmt.Errorf("invalid Instagram config")
+	}
+	recipientID := strings.TrimPrefix(msg.Recipient, "ig_")
+
+	if msg.Media == nil {
+		return instagramPkg.SendInstagramText(igConfig.AccessToken, igConfig.PageID, recipientID, msg.Text)
+	}
+	if msg.Media.URL == "" {
+		return "", fmt.Errorf("Instagram media must be sent by URL")
+	}
+	messageID, err := instagramPkg.SendInstagramAttachment(igConfig.AccessToken, igConfig.PageID, recipientID, msg.Media.Kind, msg.Media.URL)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(msg.Text) != "" {
+		if _, err := instagramPkg.SendInstagramText(igConfig.AccessToken, igConfig.PageID, recipientID, msg.Text); err != nil {
+			return messageID, fmt.Errorf("media sent but caption failed: %w", err)
+		}
+	}
+	return messageID, nil
+}
+
+// downloadMedia fetches media given by URL, within the WhatsApp file size limit
+func downloadMedia(media *flow.OutgoingMedia) error {
+	if media.URL == "" {
+		return nil
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(media.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download media: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, config.WhatsappSettingMaxFileSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to download media: %w", err)
+	}
+	if int64(len(data)) > config.WhatsappSettingMaxFileSize {
+		return fmt.Errorf("media exceeds the maximum file size of %d bytes", config.WhatsappSettingMaxFileSize)
+	}
+	media.Data = data
+	if media.MIMEType == "" {
+		media.MIMEType = resp.Header.Get("Content-Type")
+	}
+	return nil
+}
+
+// mediaFileName returns the media's file name, or fallback with an extension for its type
+func mediaFileName(media *flow.OutgoingMedia, fallback string) string {
+	if media.FileName != "" {
+		return filepath.Base(media.FileName)
+	}
+	switch mediaMIMEType(media) {
+	case "image/jpeg":
+		return fallback + ".jpg"
+	case "image/png":
+		return fallback + ".png"
+	case "application/pdf":
+		return fallback + ".pdf"
+	}
+	return fallback
+}
+
+// mediaMIMEType returns the declared MIME type without parameters, sniffing the content when unknown
+func mediaMIMEType(media *flow.OutgoingMedia) string {
+	mimeType := media.MIMEType
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(media.Data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
@@ -0,0 +1,284 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/dop251/goja"
+)
+
+// Limits of code nodes. Scripts run in an embedded JavaScript runtime that has no network, file
+// or process access; they only see copies of the flow variables and input.
+const (
+	codeMaxCallStackSize = 1024
+	codeMaxResultSize    = 1 << 20 // Bytes of the JSON encoded result
+	codeMaxLogs          = 100
+	codeCheckInterval    = 5 * time.Millisecond
+	codeMaxConcurrent    = 4 // Scripts running at once
+)
+
+// codeLimits bounds the time and memory of a script. The memory limit is best-effort: single
+// allocations that builtins such as String.prototype.repeat or typed array constructors would make
+// are checked before they happen, and the rest is found by sampling the heap of the process every
+// codeCheckInterval, which only notices an allocation once it is done.
+type codeLimits struct {
+	Time   time.Duration
+	Memory uint64 // Heap growth of the process while the script runs, in bytes
+}
+
+var defaultCodeLimits = codeLimits{Time: 2 * time.Second, Memory: 64 << 20}
+
+var (
+	// codeSlots bounds the scripts running at once, and with it the memory they use together
+	codeSlots = make(chan struct{}, codeMaxConcurrent)
+	// codeRunning and codeStarted count the scripts running and started, so that a script's share
+	// of the process heap can allow for the scripts that ran alongside it
+	codeRunning atomic.Int64
+	codeStarted atomic.Int64
+)
+
+// codeAllocationGuards wraps the builtins that allocate in one call in proportion to an argument,
+// so that a single huge allocation throws instead of running before the heap is next sampled.
+// Sizes are rough: one byte per character and 16 per array element.
+var codeAllocationGuards = goja.MustCompile("guards", `(function (max, limit) {
+	function check(bytes) {
+		if (bytes > max) {
+			throw new RangeError("allocation of " + Math.ceil(bytes / 1048576) + " MB exceeds the " + limit);
+		}
+	}
+	function guard(target, name, size) {
+		var original = target[name];
+		Object.defineProperty(target, name, {
+			value: function () {
+				check(size.apply(this, arguments));
+				return original.apply(this, arguments);
+			},
+			writable: true,
+			configurable: true
+		});
+	}
+	function guardConstructor(name, size) {
+		var original = globalThis[name];
+		var guarded = function () {
+			if (new.target === undefined) {
+				throw new TypeError("Constructor " + name + " requires 'new'");
+			}
+			check(size(original, arguments));
+			return Reflect.construct(original, arguments, new.target);
+		};
+		Object.getOwnPropertyNames(original).forEach(function (key) {
+			if (key !== "length" && key !== "name") {
+				Object.defineProperty(guarded, key, Object.getOwnPropertyDescriptor(original, key));
+			}
+		});
+		globalThis[name] = guarded;
+	}
+
+	guard(String.prototype, "repeat", function (count) { return String(this).length * count; });
+	guard(String.prototype, "padStart", function (length) { return Number(length); });
+	guard(String.prototype, "padEnd", function (length) { return Number(length); });
+	guard(Array.prototype, "fill", function () { return (this.length >>> 0) * 16; });
+	guard(Array.prototype, "join", function (separator) {
+		return (this.length >>> 0) * (separator === undefined ? 1 : String(separator).length);
+	});
+	guard(Array, "from", function (items) { return items == null ? 0 : (Number(items.length) || 0) * 16; });
+	guardConstructor("ArrayBuffer", function (target, args) { return Number(args[0]) || 0; });
+	["Int8Array", "Uint8Array", "Uint8ClampedArray", "Int16Array", "Uint16Array", "Int32Array",
+		"Uint32Array", "Float32Array", "Float64Array"].forEach(function (name) {
+		guardConstructor(name, function (target, args) {
+			return typeof args[0] === "number" ? args[0] * target.BYTES_PER_ELEMENT : 0;
+		});
+	});
+})`, true)
+
+// wrapCode makes the code the body of a function, so that it can return its result
+func wrapCode(code string) string {
+	return "(function () {\n" + code + "\n})()"
+}
+
+// CompileJavaScript reports syntax errors in the code of a code node
+func CompileJavaScript(code string) error {
+	_, err := goja.Compile("code", wrapCode(code), false)
+	return err
+}
+
+func (e *FlowExecutor) executeCode(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+
+	language, _ := data["language"].(string)
+	if language != "" && language != "javascript" {
+		return nil, fmt.Errorf("unsupported language %q", language)
+	}
+	code, _ := data["code"].(string)
+	if strings.TrimSpace(code) == "" {
+		return nil, fmt.Errorf("code is required")
+	}
+
+	result, logs, err := runJavaScript(ctx, code, execCtx.Variables, execCtx.Input, defaultCodeLimits)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) > 0 {
+		result["_logs"] = logs
+	}
+	return result, nil
+}
+
+// runJavaScript runs code with the globals vars and input and returns the object it returns, and
+// the lines it logged with console.log. Scripts that exceed the limits are stopped.
+func runJavaScript(ctx context.Context, code string, vars, input map[string]interface{}, limits codeLimits) (map[string]interface{}, []string, error) {
+	program, err := goja.Compile("code", wrapCode(code), false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("syntax error: %w", err)
+	}
+
+	vm := goja.New()
+	vm.SetMaxCallStackSize(codeMaxCallStackSize)
+
+	// Values are copied in as JSON, so scripts cannot reach Go objects
+	for name, value := range map[string]interface{}{"vars": vars, "input": input} {
+		jsValue, err := jsonToJS(vm, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to pass %s to the script: %w", name, err)
+		}
+		vm.Set(name, jsValue)
+	}
+
+	var logs []string
+	console := vm.NewObject()
+	console.Set("log", func(call goja.FunctionCall) goja.Value {
+		if len(logs) < codeMaxLogs {
+			parts := make([]string, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				parts[i] = arg.String()
+			}
+			logs = append(logs, strings.Join(parts, " "))
+		}
+		return goja.Undefined()
+	})
+	vm.Set("console", console)
+
+	if err := guardAllocations(vm, limits.Memory); err != nil {
+		return nil, nil, fmt.Errorf("failed to set up the script: %w", err)
+	}
+
+	select {
+	case codeSlots <- struct{}{}:
+		defer func() { <-codeSlots }()
+	case <-ctx.Done():
+		return nil, nil, fmt.Errorf("script not started: %w", ctx.Err())
+	}
+
+	// The heap growth since the script started includes the allocations of every script that ran
+	// alongside it, so each of them adds a limit to its allowance
+	started := codeStarted.Add(1)
+	running := codeRunning.Add(1)
+	defer codeRunning.Add(-1)
+	scripts := func() uint64 {
+		n := running + codeStarted.Load() - started
+		if n > codeMaxConcurrent {
+			n = codeMaxConcurrent
+		}
+		return uint64(n)
+	}
+
+	// The baseline is read before the script starts so its first allocations count too
+	done := make(chan struct{})
+	defer close(done)
+	go watchScript(ctx, vm, limits, heapObjectBytes(), scripts, done)
+
+	value, err := vm.RunProgram(program)
+	if err != nil {
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			return nil, logs, fmt.Errorf("script stopped: %v", interrupted.Value())
+		}
+		return nil, logs, fmt.Errorf("script error: %w", err)
+	}
+
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return map[string]interface{}{}, logs, nil
+	}
+	obj, ok := value.(*goja.Object)
+	if !ok || obj.ClassName() != "Object" {
+		return nil, logs, fmt.Errorf("code must return an object, got %s", value.String())
+	}
+	resultJSON, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, logs, fmt.Errorf("failed to encode the result: %w", err)
+	}
+	if len(resultJSON) > codeMaxResultSize {
+		return nil, logs, fmt.Errorf("result is larger than %d KB", codeMaxResultSize>>10)
+	}
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(resultJSON, &result); err != nil {
+		return nil, logs, fmt.Errorf("failed to decode the result: %w", err)
+	}
+	return result, logs, nil
+}
+
+// guardAllocations makes the builtins of vm throw instead of allocating more than max bytes at once
+func guardAllocations(vm *goja.Runtime, max uint64) error {
+	guards, err := vm.RunProgram(codeAllocationGuards)
+	if err != nil {
+		return err
+	}
+	install, _ := goja.AssertFunction(guards)
+	_, err = install(goja.Undefined(), vm.ToValue(max), vm.ToValue(fmt.Sprintf("memory limit of %d MB", max>>20)))
+	return err
+}
+
+// jsonToJS converts value to a plain JavaScript value through JSON
+func jsonToJS(vm *goja.Runtime, value interface{}) (goja.Value, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	return parse(goja.Undefined(), vm.ToValue(string(raw)))
+}
+
+// watchScript interrupts the script when it runs out of time or memory or ctx is done, until done
+// is closed. Memory is measured as the heap growth of the process since start, so it is approximate;
+// the script may use the memory limit once for each of the scripts that ran alongside it.
+func watchScript(ctx context.Context, vm *goja.Runtime, limits codeLimits, start uint64, scripts func() uint64, done <-chan struct{}) {
+	timer := time.NewTimer(limits.Time)
+	defer timer.Stop()
+	ticker := time.NewTicker(codeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			vm.Interrupt(ctx.Err().Error())
+			return
+		case <-timer.C:
+			vm.Interrupt(fmt.Sprintf("time limit of %s exceeded", limits.Time))
+			return
+		case <-ticker.C:
+			heap := heapObjectBytes()
+			if heap < start {
+				// A collection freed garbage that was part of the baseline
+				start = heap
+			} else if heap-start > limits.Memory*scripts() {
+				vm.Interrupt(fmt.Sprintf("memory limit of %d MB exceeded", limits.Memory>>20))
+				return
+			}
+		}
+	}
+}
+
+func heapObjectBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
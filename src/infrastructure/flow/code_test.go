@@ -0,0 +1,130 @@
+package flow
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestCodeNodeMergesResult(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	ctx := context.Background()
+
+	f := &flow.Flow{
+		ID:      "flow-1",
+		AgentID: "agent-1",
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "code", Type: flow.NodeTypeCode, Data: map[string]interface{}{"language": "javascript", "code": `
+				console.log("items", vars.items.length);
+				vars.items.push(99);
+				const total = vars.items.reduce((sum, n) => sum + n, 0);
+				return { total: total, customer: input.name.toUpperCase() };`}},
+			{ID: "after", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "summary", "value": "{{customer}}: {{total}}"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "code"},
+			{ID: "e2", Source: "code", Target: "after"},
+		},
+	}
+
+	items := []interface{}{float64(1), float64(2), float64(3)}
+	result, err := NewFlowExecutor(repo).Run(ctx, f, "", map[string]interface{}{"name": "ana", "items": items})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	execution, err := repo.GetExecution(ctx, result.ExecutionID)
+	if err != nil {
+		t.Fatalf("GetExecution() error = %v", err)
+	}
+	if execution.Output["summary"] != "ANA: 105" {
+		t.Fatalf("output = %v", execution.Output)
+	}
+	if len(items) != 3 {
+		t.Fatalf("script changed the Go variables: %v", items)
+	}
+	logs, _ := execution.Trace[1].Output["_logs"].([]interface{})
+	if len(logs) != 1 || logs[0] != "items 3" {
+		t.Fatalf("logs = %v", execution.Trace[1].Output["_logs"])
+	}
+}
+
+func TestRunJavaScript(t *testing.T) {
+	ctx := context.Background()
+	vars := map[string]interface{}{"n": float64(2)}
+	limits := codeLimits{Time: 200 * time.Millisecond, Memory: 16 << 20}
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr string
+	}{
+		{name: "infinite loop", code: `while (true) {}`, wantErr: "time limit"},
+		{name: "memory", code: `const a = []; while (true) { a.push("x".repeat(1024) + a.length); }`, wantErr: "limit"},
+		{name: "single large string", code: `return { s: "x".repeat(5e8) };`, wantErr: "memory limit"},
+		{name: "single large padding", code: `return { s: "".padEnd(5e8, "x") };`, wantErr: "memory limit"},
+		{name: "single large array", code: `return { a: new Array(1e8).fill(0) };`, wantErr: "memory limit"},
+		{name: "single large buffer", code: `return { n: new Float64Array(1e8).length };`, wantErr: "memory limit"},
+		{name: "no require", code: `return { fs: require("fs") };`, wantErr: "require is not defined"},
+		{name: "no fetch", code: `return fetch("http://example.com");`, wantErr: "fetch is not defined"},
+		{name: "non-object result", code: `return vars.n * 2;`, wantErr: "must return an object"},
+		{name: "array result", code: `return [1, 2];`, wantErr: "must return an object"},
+		{name: "syntax error", code: `return {`, wantErr: "syntax error"},
+		{name: "runtime error", code: `throw new Error("boom");`, wantErr: "boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := runJavaScript(ctx, tt.code, vars, nil, limits)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("runJavaScript() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	result, _, err := runJavaScript(ctx, `return { double: vars.n * 2, missing: input === null };`, vars, nil, limits)
+	if err != nil {
+		t.Fatalf("runJavaScript() error = %v", err)
+	}
+	if result["double"] != float64(4) || result["missing"] != true {
+		t.Fatalf("runJavaScript() = %v", result)
+	}
+
+	// Guarded builtins still work below the limit
+	result, _, err = runJavaScript(ctx, `const bytes = new Uint8Array(4);
+		return { s: "ab".repeat(2) + "1".padStart(3, "0"), zeros: new Array(3).fill(0).join("-"), typed: bytes instanceof Uint8Array && bytes.length === 4 };`, vars, nil, limits)
+	if err != nil {
+		t.Fatalf("runJavaScript() error = %v", err)
+	}
+	if result["s"] != "abab001" || result["zeros"] != "0-0-0" || result["typed"] != true {
+		t.Fatalf("runJavaScript() = %v", result)
+	}
+}
+
+func TestRunJavaScriptConcurrentScriptsKeepOwnMemoryLimit(t *testing.T) {
+	// Each script holds 20 MB for a while; together they would exceed a 32 MB limit
+	code := `const s = "x".repeat(20 * 1024 * 1024);
+		const end = Date.now() + 100;
+		while (Date.now() < end) {}
+		return { n: s.length };`
+	limits := codeLimits{Time: 2 * time.Second, Memory: 32 << 20}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _, err := runJavaScript(context.Background(), code, nil, nil, limits)
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("runJavaScript() error = %v", err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// loopState is the progress of a for each node whose body is running
+type loopState struct {
+	Items []interface{} `json:"items"`
+	Index int           `json:"index"` // Item the body runs for
+}
+
+func (e *FlowExecutor) executeSwitch(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+
+	field, _ := data["field"].(string)
+	casesRaw, _ := data["cases"].([]interface{})
+	actualValue, err := e.evaluateExpression(field, execCtx.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("field %s failed: %w", field, err)
+	}
+
+	handle := flow.SwitchHandleDefault
+	for _, caseRaw := range casesRaw {
+		c, ok := caseRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := c["name"].(string)
+		operator, _ := c["operator"].(string)
+		if operator == "" {
+			operator = "eq"
+		}
+		if e.evaluateCondition(actualValue, operator, c["value"]) {
+			handle = name
+			break
+		}
+	}
+
+	return map[string]interface{}{
+		"switch_case": handle,
+		"_handle":     handle,
+	}, nil
+}
+
+// executeForEach starts the body for the next item, queueing the node again to run after it, or
+// continues on the done handle once every item had its turn
+func (e *FlowExecutor) executeForEach(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+
+	itemVariable, _ := data["item_variable"].(string)
+	if itemVariable == "" {
+		itemVariable = "item"
+	}
+	indexVariable, _ := data["index_variable"].(string)
+	if indexVariable == "" {
+		indexVariable = "index"
+	}
+
+	state := execCtx.loops[node.ID]
+	if state == nil {
+		itemsPath, _ := data["items"].(string)
+		value, err := e.evaluateExpression(itemsPath, execCtx.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("items %s failed: %w", itemsPath, err)
+		}
+		items, err := toItems(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", itemsPath, err)
+		}
+		state = &loopState{Items: items, Index: -1}
+		if execCtx.loops == nil {
+			execCtx.loops = make(map[string]*loopState)
+		}
+		execCtx.loops[node.ID] = state
+	}
+
+	state.Index++
+	if state.Index >= len(state.Items) {
+		delete(execCtx.loops, node.ID)
+		return map[string]interface{}{"_handle": flow.ForEachHandleDone}, nil
+	}
+
+	execCtx.Pending = append(execCtx.Pending, node.ID)
+	return map[string]interface{}{
+		itemVariable:  state.Items[state.Index],
+		indexVariable: state.Index,
+		"_handle":     flow.ForEachHandleBody,
+	}, nil
+}
+
+// toItems returns the items of an array variable; JSON arrays in strings are decoded
+func toItems(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	case string:
+		var items []interface{}
+		if err := json.Unmarshal([]byte(v), &items); err != nil {
+			return nil, fmt.Errorf("not an array")
+		}
+		return items, nil
+	}
+	if kind := reflect.TypeOf(value).Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return nil, fmt.Errorf("not an array")
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var items []interface{}
+	err = json.Unmarshal(raw, &items)
+	return items, err
+}
+
+// executeParallel runs the branches of the node at the same time and waits for all of them. Its
+// output is the variables the branches changed, later branches winning over earlier ones.
+func (e *FlowExecutor) executeParallel(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	var branches []*ExecutionContext
+	for _, edge := range execCtx.Flow.Edges {
+		if edge.Source != node.ID || edge.SourceHandle != flow.ParallelHandleBranch {
+			continue
+		}
+		loops := make(map[string]*loopState, len(execCtx.loops))
+		for id, state := range execCtx.loops {
+			loops[id] = state
+		}
+		branches = append(branches, &ExecutionContext{
+			ExecutionID: execCtx.ExecutionID,
+			Variables:   copyVariables(execCtx.Variables),
+			Input:       execCtx.Input,
+			Output:      make(map[string]interface{}),
+			Credentials: make(map[string]*flow.Credential),
+			Flow:        execCtx.Flow,
+			Pending:     []string{edge.Target},
+			DryRun:      execCtx.DryRun,
+			DryRunReply: execCtx.DryRunReply,
+			loops:       loops,
+			steps:       execCtx.steps,
+		})
+	}
+
+	errs := make([]error, len(branches))
+	var wg sync.WaitGroup
+	for i, branch := range branches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = e.runPending(ctx, branch)
+			if errs[i] == nil && branch.wait != nil {
+				errs[i] = fmt.Errorf("delay and wait for reply nodes cannot run in a parallel branch")
+			}
+		}()
+	}
+	wg.Wait()
+
+	var firstErr error
+	for i, branch := range branches {
+		execCtx.Trace = append(execCtx.Trace, branch.Trace...)
+		execCtx.Replies = append(execCtx.Replies, branch.Replies...)
+		if errs[i] != nil && firstErr == nil {
+			firstErr = fmt.Errorf("branch %d: %w", i+1, errs[i])
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	output := map[string]interface{}{}
+	for _, branch := range branches {
+		for k, v := range branch.Variables {
+			if old, ok := execCtx.Variables[k]; !ok || !reflect.DeepEqual(old, v) {
+				output[k] = v
+			}
+		}
+	}
+	output["_handle"] = flow.ParallelHandleDone
+	return output, nil
+}
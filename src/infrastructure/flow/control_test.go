@@ -0,0 +1,172 @@
+package flow
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func traceOrder(trace []flow.NodeTrace) string {
+	var order []string
+	for _, step := range trace {
+		order = append(order, step.NodeID+":"+step.Handle)
+	}
+	return strings.Join(order, ",")
+}
+
+func TestSwitchNode(t *testing.T) {
+	f := &flow.Flow{
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "route", Type: flow.NodeTypeSwitch, Data: map[string]interface{}{
+				"field": "order.status",
+				"cases": []interface{}{
+					map[string]interface{}{"name": "paid", "value": "paid"},
+					map[string]interface{}{"name": "refund", "operator": "starts_with", "value": "refund"},
+				},
+			}},
+			{ID: "ship", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "action", "value": "ship"}},
+			{ID: "refund", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "action", "value": "refund"}},
+			{ID: "other", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "action", "value": "review"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "route"},
+			{ID: "e2", Source: "route", Target: "ship", SourceHandle: "paid"},
+			{ID: "e3", Source: "route", Target: "refund", SourceHandle: "refund"},
+			{ID: "e4", Source: "route", Target: "other", SourceHandle: flow.SwitchHandleDefault},
+		},
+	}
+
+	for status, want := range map[string]string{"paid": "ship", "refund_requested": "refund", "pending": "review"} {
+		input := map[string]interface{}{"order": map[string]interface{}{"status": status}}
+		output, err := NewFlowExecutor(nil).Execute(context.Background(), f, input)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", status, err)
+		}
+		if output["action"] != want {
+			t.Fatalf("Execute(%s) action = %v, want %s", status, output["action"], want)
+		}
+	}
+}
+
+func TestForEachNode(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	executor := NewFlowExecutor(repo)
+	ctx := context.Background()
+
+	f := &flow.Flow{
+		AgentID:  "agent-1",
+		Name:     "greet all",
+		IsActive: true,
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "loop", Type: flow.NodeTypeForEach, Data: map[string]interface{}{"items": "names", "item_variable": "name"}},
+			{ID: "greet", Type: flow.NodeTypeCode, Data: map[string]interface{}{"code": `return { greetings: (vars.greetings || []).concat(vars.index + ":" + vars.name) };`}},
+			{ID: "wait", Type: flow.NodeTypeDelay, Data: map[string]interface{}{"duration": float64(1), "unit": "hours"}},
+			{ID: "after", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "status", "value": "done"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "loop"},
+			{ID: "e2", Source: "loop", Target: "greet", SourceHandle: flow.ForEachHandleBody},
+			{ID: "e3", Source: "greet", Target: "wait"},
+			{ID: "e4", Source: "wait", Target: "loop"},
+			{ID: "e5", Source: "loop", Target: "after", SourceHandle: flow.ForEachHandleDone},
+		},
+	}
+	if err := ValidateGraph(f.Nodes, f.Edges, 100); err != nil {
+		t.Fatalf("ValidateGraph() error = %v", err)
+	}
+	if err := repo.CreateFlow(ctx, f); err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+
+	// Each iteration pauses at the delay, so the loop survives resuming from storage
+	result, err := executor.Run(ctx, f, "", map[string]interface{}{"names": []interface{}{"Ana", "Budi"}})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if n := executor.ResumeDue(ctx, time.Now().Add(2*time.Hour)); n != 1 {
+			t.Fatalf("ResumeDue() resumed %d runs, want 1", n)
+		}
+	}
+
+	execution, err := repo.GetExecution(ctx, result.ExecutionID)
+	if err != nil {
+		t.Fatalf("GetExecution() error = %v", err)
+	}
+	if execution.Status != flow.ExecutionStatusSuccess || execution.Output["status"] != "done" {
+		t.Fatalf("execution = %+v", execution)
+	}
+	greetings, _ := execution.Trace[len(execution.Trace)-1].Input["greetings"].([]interface{})
+	if len(greetings) != 2 || greetings[0] != "0:Ana" || greetings[1] != "1:Budi" {
+		t.Fatalf("greetings = %v", greetings)
+	}
+	want := "trigger:,loop:body,greet:,wait:,loop:body,greet:,wait:,loop:done,after:"
+	if got := traceOrder(execution.Trace); got != want {
+		t.Fatalf("trace = %s, want %s", got, want)
+	}
+}
+
+func TestParallelNode(t *testing.T) {
+	f := &flow.Flow{
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "fan", Type: flow.NodeTypeParallel},
+			{ID: "a", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "a", "value": "from a"}},
+			{ID: "b", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "b", "value": "from b"}},
+			{ID: "b2", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "shared", "value": "b wins"}},
+			{ID: "join", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "joined", "value": "{{a}} + {{b}} ({{shared}})"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "fan"},
+			{ID: "e2", Source: "fan", Target: "a", SourceHandle: flow.ParallelHandleBranch},
+			{ID: "e3", Source: "fan", Target: "b", SourceHandle: flow.ParallelHandleBranch},
+			{ID: "e4", Source: "b", Target: "b2"},
+			{ID: "e5", Source: "fan", Target: "join", SourceHandle: flow.ParallelHandleDone},
+		},
+	}
+
+	result, err := NewFlowExecutor(nil).Run(context.Background(), f, "", map[string]interface{}{"shared": "unset"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Output["joined"] != "from a + from b (b wins)" {
+		t.Fatalf("output = %v", result.Output)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	defer func(limit int) { config.FlowMaxSteps = limit }(config.FlowMaxSteps)
+	config.FlowMaxSteps = 20
+
+	f := &flow.Flow{
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "loop", Type: flow.NodeTypeForEach, Data: map[string]interface{}{"items": "items"}},
+			{ID: "body", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "last", "value": "{{item}}"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "loop"},
+			{ID: "e2", Source: "loop", Target: "body", SourceHandle: flow.ForEachHandleBody},
+		},
+	}
+
+	items := make([]interface{}, 5)
+	if _, err := NewFlowExecutor(nil).Execute(context.Background(), f, map[string]interface{}{"items": items}); err != nil {
+		t.Fatalf("Execute() with 5 items error = %v", err)
+	}
+	items = make([]interface{}, 50)
+	_, err := NewFlowExecutor(nil).Execute(context.Background(), f, map[string]interface{}{"items": items})
+	if err == nil || !strings.Contains(err.Error(), "limit of 20 steps") {
+		t.Fatalf("Execute() with 50 items error = %v, want the step limit", err)
+	}
+}
@@ -0,0 +1,516 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Database nodes never put values into SQL text: every {{ }} expression in a where clause or raw
+// query becomes a bound parameter, and table and column names must be plain identifiers, which
+// are quoted for the driver.
+
+// identifierPattern matches a table or column name, optionally qualified by a schema
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)?$`)
+
+// sqlQueryer runs statements on a database or within a transaction
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// OpenDatabase opens the database of a credential
+func OpenDatabase(cfg flow.DatabaseCredential) (*sql.DB, error) {
+	switch cfg.Driver {
+	case "", flow.DatabaseDriverPostgres:
+		if cfg.Port == 0 {
+			cfg.Port = 5432
+		}
+		dsn := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(cfg.User, cfg.Password),
+			Host:   net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			Path:   "/" + cfg.Database,
+		}
+		if cfg.SSLMode != "" {
+			dsn.RawQuery = url.Values{"sslmode": {cfg.SSLMode}}.Encode()
+		}
+		return sql.Open("postgres", dsn.String())
+	case flow.DatabaseDriverMySQL:
+		if cfg.Port == 0 {
+			cfg.Port = 3306
+		}
+		dsn := mysql.NewConfig()
+		dsn.User = cfg.User
+		dsn.Passwd = cfg.Password
+		dsn.Net = "tcp"
+		dsn.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+		dsn.DBName = cfg.Database
+		switch cfg.SSLMode {
+		case "require":
+			dsn.TLSConfig = "skip-verify"
+		case "verify-ca", "verify-full":
+			dsn.TLSConfig = "true"
+		}
+		return sql.Open("mysql", dsn.FormatDSN())
+	case flow.DatabaseDriverSQLite:
+		path, err := sqliteDatabasePath(cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+		params := url.Values{"_busy_timeout": {"5000"}}
+		if cfg.ReadOnly {
+			params.Set("mode", "ro")
+			params.Set("_query_only", "true")
+		}
+		return sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+}
+
+// sqliteDatabasePath resolves the file of a SQLite database, which must be in the databases
+// directory of the storages so that flows cannot open the files of the application
+func sqliteDatabasePath(name string) (string, error) {
+	if name == "" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("sqlite database must be a file name within %s", filepath.Join(config.PathStorages, "databases"))
+	}
+	return filepath.Join(config.PathStorages, "databases", name), nil
+}
+
+// sqlBuilder collects the arguments of a statement for a driver
+type sqlBuilder struct {
+	driver string
+	tables []string
+	args   []interface{}
+}
+
+// bind adds an argument and returns its placeholder
+func (b *sqlBuilder) bind(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		value = string(raw)
+	}
+	b.args = append(b.args, value)
+	if b.driver == flow.DatabaseDriverPostgres {
+		return "$" + strconv.Itoa(len(b.args))
+	}
+	return "?"
+}
+
+// identifier quotes a table or column name
+func (b *sqlBuilder) identifier(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !identifierPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid identifier %q", name)
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if b.driver == flow.DatabaseDriverMySQL {
+			parts[i] = "`" + part + "`"
+		} else {
+			parts[i] = `"` + part + `"`
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// table quotes a table name that the credential allows
+func (b *sqlBuilder) table(name string) (string, error) {
+	if len(b.tables) > 0 {
+		allowed := false
+		for _, table := range b.tables {
+			if strings.EqualFold(strings.TrimSpace(table), strings.TrimSpace(name)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", fmt.Errorf("table %q is not allowed by the credential", name)
+		}
+	}
+	return b.identifier(name)
+}
+
+// bindTemplate replaces the {{ }} expressions in a where clause or query with bound parameters.
+// Quotes around an expression, as in name = '{{name}}', are dropped since the value is bound. An
+// expression elsewhere inside a quoted literal, as in '%{{q}}%', is rejected: the placeholder would
+// be part of the literal, so the expression has to build the whole value instead.
+func (e *FlowExecutor) bindTemplate(b *sqlBuilder, template string, vars map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	var quote byte // Quote character of the literal the text so far ends in, 0 outside literals
+	opened := -1   // Position of that quote
+	last := 0
+	for _, match := range templatePattern.FindAllStringSubmatchIndex(template, -1) {
+		start, end := match[0], match[1]
+		for i := last; i < start; i++ {
+			switch c := template[i]; {
+			case quote == 0 && (c == '\'' || c == '"' || c == '`'):
+				quote, opened = c, i
+			case c == quote:
+				quote = 0
+			}
+		}
+
+		if quote != 0 {
+			if quote != '\'' || opened != start-1 || end >= len(template) || template[end] != '\'' {
+				return "", fmt.Errorf("%s is inside a quoted literal; build the whole value in the expression instead, e.g. LIKE {{ '%%' + q + '%%' }}", template[start:end])
+			}
+			start--
+			end++
+			quote = 0
+		}
+		value, err := e.evaluateExpression(template[match[2]:match[3]], vars)
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %w", template[match[0]:match[1]], err)
+		}
+		sb.WriteString(template[last:start])
+		sb.WriteString(b.bind(value))
+		last = end
+	}
+	sb.WriteString(template[last:])
+	return sb.String(), nil
+}
+
+func (e *FlowExecutor) executeDatabase(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+
+	credentialID, _ := data["credential_id"].(string)
+	operation, _ := data["operation"].(string)
+	table, _ := data["table"].(string)
+	query, _ := data["query"].(string)
+
+	if credentialID == "" {
+		return nil, fmt.Errorf("database credential is required")
+	}
+
+	// Get database config
+	cred, err := e.flowRepo.GetCredentialByID(ctx, credentialID)
+	if err != nil {
+		return nil, fmt.Errorf("credential not found: %w", err)
+	}
+
+	var dbConfig flow.DatabaseCredential
+	if err := json.Unmarshal([]byte(cred.Config), &dbConfig); err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+	if dbConfig.Driver == "" {
+		dbConfig.Driver = flow.DatabaseDriverPostgres
+	}
+	if dbConfig.ReadOnly && operation != "select" && operation != "raw" {
+		return nil, fmt.Errorf("%s is not allowed with a read-only credential", operation)
+	}
+	// Raw queries can name any table, so they would get around the allow-list
+	if operation == "raw" && len(dbConfig.Tables) > 0 {
+		return nil, fmt.Errorf("raw queries are not allowed with a credential limited to tables %s", strings.Join(dbConfig.Tables, ", "))
+	}
+
+	// Dry runs record writes without connecting, and read as read-only credentials do
+	if execCtx.DryRun {
+		if operation == "insert" || operation == "update" || operation == "delete" || (operation == "raw" && !isReadQuery(query)) {
+			return e.recordDatabaseWrite(ctx, execCtx, dbConfig, operation, table, query, data)
+		}
+		dbConfig.ReadOnly = true
+	}
+
+	// Connect to database
+	db, err := OpenDatabase(dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer db.Close()
+
+	// Read-only credentials run in a read-only transaction; SQLite opens the file read-only
+	var q sqlQueryer = db
+	if dbConfig.ReadOnly && dbConfig.Driver != flow.DatabaseDriverSQLite {
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	b := &sqlBuilder{driver: dbConfig.Driver, tables: dbConfig.Tables}
+
+	// Execute based on operation
+	switch operation {
+	case "raw":
+		query, err = e.bindTemplate(b, query, execCtx.Variables)
+		if err != nil {
+			return nil, err
+		}
+		return e.executeRawSQL(ctx, q, query, b.args)
+	case "select":
+		return e.executeSelect(ctx, q, b, table, data, execCtx.Variables)
+	case "insert":
+		return e.executeInsert(ctx, q, b, table, data, execCtx.Variables)
+	case "update":
+		return e.executeUpdate(ctx, q, b, table, data, execCtx.Variables)
+	case "delete":
+		return e.executeDelete(ctx, q, b, table, data, execCtx.Variables)
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", operation)
+	}
+}
+
+// recordDatabaseWrite builds the statement of a write for a dry run and returns it as the output
+// of the node, with no rows affected
+func (e *FlowExecutor) recordDatabaseWrite(ctx context.Context, execCtx *ExecutionContext, dbConfig flow.DatabaseCredential, operation, table, query string, data map[string]interface{}) (map[string]interface{}, error) {
+	rec := &recordingQueryer{}
+	b := &sqlBuilder{driver: dbConfig.Driver, tables: dbConfig.Tables}
+	var err error
+	switch operation {
+	case "raw":
+		if query, err = e.bindTemplate(b, query, execCtx.Variables); err == nil {
+			_, err = rec.ExecContext(ctx, query, b.args...)
+		}
+	case "insert":
+		_, err = e.executeInsert(ctx, rec, b, table, data, execCtx.Variables)
+	case "update":
+		_, err = e.executeUpdate(ctx, rec, b, table, data, execCtx.Variables)
+	case "delete":
+		_, err = e.executeDelete(ctx, rec, b, table, data, execCtx.Variables)
+	}
+	if !errors.Is(err, errWriteRecorded) {
+		return nil, err
+	}
+
+	output := map[string]interface{}{"query": rec.query, "args": rec.args}
+	if operation == "insert" {
+		output["inserted"] = true
+	} else {
+		output["rows_affected"] = int64(0)
+	}
+	return stubbed(output), nil
+}
+
+func (e *FlowExecutor) executeRawSQL(ctx context.Context, q sqlQueryer, query string, args []interface{}) (map[string]interface{}, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"rows":  results,
+		"count": len(results),
+	}, nil
+}
+
+// scanRows reads rows as maps by column name; text that drivers return as bytes becomes a string
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, _ := rows.Columns()
+	var results []map[string]interface{}
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{})
+		for i, col := range columns {
+			if raw, ok := values[i].([]byte); ok {
+				values[i] = string(raw)
+			}
+			row[col] = values[i]
+		}
+		results = append(results, row)
+	}
+
+	return results, rows.Err()
+}
+
+// bindValues binds the values of an insert or update in column order, returning the quoted columns
+// and their placeholders
+func (e *FlowExecutor) bindValues(b *sqlBuilder, data map[string]interface{}, vars map[string]interface{}) ([]string, []string, error) {
+	values, _ := data["values"].(map[string]interface{})
+	cols := make([]string, 0, len(values))
+	for col := range values {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+
+	placeholders := make([]string, len(cols))
+	for i, col := range cols {
+		quoted, err := b.identifier(col)
+		if err != nil {
+			return nil, nil, err
+		}
+		val := values[col]
+		// Interpolate string values
+		if str, ok := val.(string); ok {
+			val = e.evaluateTemplate(str, vars)
+		}
+		cols[i] = quoted
+		placeholders[i] = b.bind(val)
+	}
+	return cols, placeholders, nil
+}
+
+func (e *FlowExecutor) executeSelect(ctx context.Context, q sqlQueryer, b *sqlBuilder, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	from, err := b.table(table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := "*"
+	if cols, ok := data["columns"].([]interface{}); ok && len(cols) > 0 {
+		colStrs := make([]string, len(cols))
+		for i, c := range cols {
+			name, _ := c.(string)
+			if colStrs[i], err = b.identifier(name); err != nil {
+				return nil, err
+			}
+		}
+		columns = strings.Join(colStrs, ", ")
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", columns, from)
+
+	if where, ok := data["where"].(string); ok && where != "" {
+		where, err = e.bindTemplate(b, where, vars)
+		if err != nil {
+			return nil, err
+		}
+		query += " WHERE " + where
+	}
+
+	if limit, ok := data["limit"].(float64); ok && limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", int(limit))
+	}
+
+	return e.executeRawSQL(ctx, q, query, b.args)
+}
+
+func (e *FlowExecutor) executeInsert(ctx context.Context, q sqlQueryer, b *sqlBuilder, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	if values, ok := data["values"].(map[string]interface{}); !ok || len(values) == 0 {
+		return nil, fmt.Errorf("values required for insert")
+	}
+	into, err := b.table(table)
+	if err != nil {
+		return nil, err
+	}
+	cols, placeholders, err := e.bindValues(b, data, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		into, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+
+	// MySQL cannot return the inserted row
+	if b.driver == flow.DatabaseDriverMySQL {
+		result, err := q.ExecContext(ctx, query, b.args...)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := result.LastInsertId()
+		return map[string]interface{}{"inserted": true, "last_insert_id": id}, nil
+	}
+
+	rows, err := q.QueryContext(ctx, query+" RETURNING *", b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(inserted) > 0 {
+		return map[string]interface{}{"inserted": inserted[0]}, nil
+	}
+
+	return map[string]interface{}{"inserted": true}, nil
+}
+
+func (e *FlowExecutor) executeUpdate(ctx context.Context, q sqlQueryer, b *sqlBuilder, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	if values, ok := data["values"].(map[string]interface{}); !ok || len(values) == 0 {
+		return nil, fmt.Errorf("values required for update")
+	}
+
+	where, _ := data["where"].(string)
+	if where == "" {
+		return nil, fmt.Errorf("where clause required for update")
+	}
+
+	target, err := b.table(table)
+	if err != nil {
+		return nil, err
+	}
+	cols, placeholders, err := e.bindValues(b, data, vars)
+	if err != nil {
+		return nil, err
+	}
+	sets := make([]string, len(cols))
+	for i := range cols {
+		sets[i] = cols[i] + " = " + placeholders[i]
+	}
+	if where, err = e.bindTemplate(b, where, vars); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", target, strings.Join(sets, ", "), where)
+
+	result, err := q.ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return map[string]interface{}{"rows_affected": affected}, nil
+}
+
+func (e *FlowExecutor) executeDelete(ctx context.Context, q sqlQueryer, b *sqlBuilder, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	where, _ := data["where"].(string)
+	if where == "" {
+		return nil, fmt.Errorf("where clause required for delete")
+	}
+
+	from, err := b.table(table)
+	if err != nil {
+		return nil, err
+	}
+	if where, err = e.bindTemplate(b, where, vars); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", from, where)
+
+	result, err := q.ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return map[string]interface{}{"rows_affected": affected}, nil
+}
@@ -0,0 +1,110 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestDatabaseNode(t *testing.T) {
+	defer func(path string) { config.PathStorages = path }(config.PathStorages)
+	config.PathStorages = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(config.PathStorages, "databases"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(config.PathStorages, "databases", "shop.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, note TEXT); CREATE TABLE secrets (value TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	ctx := context.Background()
+	credential := func(cfg flow.DatabaseCredential) string {
+		raw, _ := json.Marshal(cfg)
+		c := &flow.Credential{AgentID: "agent-1", Name: "shop", Type: flow.CredentialTypeDatabase, Config: string(raw)}
+		if err := repo.CreateCredential(ctx, c); err != nil {
+			t.Fatalf("CreateCredential() error = %v", err)
+		}
+		return c.ID
+	}
+	writer := credential(flow.DatabaseCredential{Driver: flow.DatabaseDriverSQLite, Database: "shop.db", Tables: []string{"users"}})
+	reader := credential(flow.DatabaseCredential{Driver: flow.DatabaseDriverSQLite, Database: "shop.db", ReadOnly: true})
+	outside := credential(flow.DatabaseCredential{Driver: flow.DatabaseDriverSQLite, Database: "../flows.db"})
+
+	run := func(data map[string]interface{}) (map[string]interface{}, error) {
+		f := &flow.Flow{
+			Nodes: []flow.Node{
+				{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+				{ID: "db", Type: flow.NodeTypeDatabase, Data: data},
+			},
+			Edges: []flow.Edge{{ID: "e1", Source: "trigger", Target: "db"}},
+		}
+		return NewFlowExecutor(repo).Execute(ctx, f, map[string]interface{}{"message": "x'); DROP TABLE users; --"})
+	}
+
+	// Values from variables are bound, never written into the SQL
+	output, err := run(map[string]interface{}{
+		"credential_id": writer, "operation": "insert", "table": "users",
+		"values": map[string]interface{}{"name": "{{message}}", "note": "said {{message}}"},
+	})
+	if err != nil {
+		t.Fatalf("insert error = %v", err)
+	}
+	if inserted, _ := output["inserted"].(map[string]interface{}); inserted["name"] != "x'); DROP TABLE users; --" {
+		t.Fatalf("inserted = %v", output["inserted"])
+	}
+	for _, where := range []string{"name = {{message}}", "name = '{{message}}'", "note = {{ 'said ' + message }}",
+		"note LIKE {{ '%' + message + '%' }} AND name <> 'it''s {x}'"} {
+		output, err = run(map[string]interface{}{"credential_id": writer, "operation": "select", "table": "users", "where": where})
+		if err != nil {
+			t.Fatalf("select where %s error = %v", where, err)
+		}
+		if output["count"] != 1 {
+			t.Fatalf("select where %s count = %v, want 1", where, output["count"])
+		}
+	}
+	output, err = run(map[string]interface{}{"credential_id": writer, "operation": "update", "table": "users",
+		"values": map[string]interface{}{"note": "updated"}, "where": "name = {{message}}"})
+	if err != nil || output["rows_affected"] != int64(1) {
+		t.Fatalf("update = %v, %v", output, err)
+	}
+
+	for name, tt := range map[string]struct {
+		data    map[string]interface{}
+		wantErr string
+	}{
+		"table name with sql": {map[string]interface{}{"credential_id": reader, "operation": "select", "table": "users; DROP TABLE users"}, "invalid identifier"},
+		"column name with sql": {map[string]interface{}{"credential_id": writer, "operation": "select", "table": "users",
+			"columns": []interface{}{"name FROM secrets --"}}, "invalid identifier"},
+		"table outside the allow-list": {map[string]interface{}{"credential_id": writer, "operation": "select", "table": "secrets"}, "not allowed"},
+		"delete when read-only":        {map[string]interface{}{"credential_id": reader, "operation": "delete", "table": "users", "where": "1 = 1"}, "read-only"},
+		"raw write when read-only":     {map[string]interface{}{"credential_id": reader, "operation": "raw", "query": "DELETE FROM users"}, "readonly"},
+		"raw with a table allow-list":  {map[string]interface{}{"credential_id": writer, "operation": "raw", "query": "SELECT value FROM secrets"}, "not allowed"},
+		"expression inside a literal": {map[string]interface{}{"credential_id": reader, "operation": "select", "table": "users",
+			"where": "name LIKE '%{{message}}%'"}, "inside a quoted literal"},
+		"sqlite file outside storage": {map[string]interface{}{"credential_id": outside, "operation": "raw", "query": "SELECT 1"}, "file name within"},
+	} {
+		if _, err := run(tt.data); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", name, err, tt.wantErr)
+		}
+	}
+
+	output, err = run(map[string]interface{}{"credential_id": reader, "operation": "raw", "query": "SELECT name FROM users WHERE note = {{ 'updated' }}"})
+	if err != nil || output["count"] != 1 {
+		t.Fatalf("read-only select = %v, %v", output, err)
+	}
+}
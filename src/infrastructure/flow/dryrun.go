@@ -0,0 +1,101 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+)
+
+// DryRunResult is the outcome of a dry run, kept when the run fails
+type DryRunResult struct {
+	Trace     []flow.NodeTrace
+	Variables map[string]interface{} // Flow variables when the run ended
+	Output    map[string]interface{} // Output of the last node executed
+	Replies   []string               // Text of send_message nodes replying to the trigger, in order
+	Duration  time.Duration
+}
+
+// DryRun executes a flow like Run, but nodes that send messages or email, make requests other than
+// GET, HEAD or OPTIONS, or write to a database or sheet record what they would do in the trace
+// instead, reporting success. GET, HEAD and OPTIONS requests and LLM calls still go out, but the
+// LLM usage is not recorded. Delays pass at once and wait for reply nodes receive reply, or time out
+// without one. Dry runs are not recorded as executions. The error is that of the run; the result is
+// nil only when the run could not start.
+func (e *FlowExecutor) DryRun(ctx context.Context, f *flow.Flow, triggerID string, input map[string]interface{}, reply string) (*DryRunResult, error) {
+	execCtx, triggerNode, err := e.prepare(f, triggerID, input)
+	if err != nil {
+		return nil, err
+	}
+	execCtx.DryRun = true
+	execCtx.DryRunReply = reply
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{FlowID: f.ID, DryRun: true})
+
+	started := time.Now()
+	execCtx.Pending = []string{triggerNode.ID}
+	err = e.runPending(ctx, execCtx)
+	return &DryRunResult{
+		Trace:     execCtx.Trace,
+		Variables: execCtx.Variables,
+		Output:    execCtx.Output,
+		Replies:   execCtx.Replies,
+		Duration:  time.Since(started),
+	}, err
+}
+
+// stubbed marks the output of a node whose side effect a dry run skipped
+func stubbed(output map[string]interface{}) map[string]interface{} {
+	output["_stubbed"] = true
+	return output
+}
+
+// safeMethod reports whether an HTTP method only reads
+func safeMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// errWriteRecorded stops a database write that a dry run recorded instead of running
+var errWriteRecorded = errors.New("write recorded")
+
+// recordingQueryer records the statement of a database write instead of running it
+type recordingQueryer struct {
+	query string
+	args  []interface{}
+}
+
+func (r *recordingQueryer) QueryContext(_ context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.query, r.args = query, args
+	return nil, errWriteRecorded
+}
+
+func (r *recordingQueryer) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.query, r.args = query, args
+	return nil, errWriteRecorded
+}
+
+// readQueryKeywords start raw queries that dry runs run, in a read-only transaction
+var readQueryKeywords = []string{"select", "with", "show", "explain", "describe", "pragma", "values"}
+
+// isReadQuery reports whether a raw query looks like it only reads
+func isReadQuery(query string) bool {
+	fields := strings.Fields(strings.ToLower(query))
+	if len(fields) == 0 {
+		return false
+	}
+	first := strings.TrimLeft(fields[0], "(")
+	for _, keyword := range readQueryKeywords {
+		if first == keyword {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestDryRun(t *testing.T) {
+	defer func(path string) { config.PathStorages = path }(config.PathStorages)
+	config.PathStorages = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(config.PathStorages, "databases"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(config.PathStorages, "databases", "shop.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT); INSERT INTO orders VALUES (1, 'new')`); err != nil {
+		t.Fatal(err)
+	}
+
+	var gets, posts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			w.Write([]byte(`{"stock":3}`))
+			return
+		}
+		posts.Add(1)
+	}))
+	defer server.Close()
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	ctx := context.Background()
+	raw, _ := json.Marshal(flow.DatabaseCredential{Driver: flow.DatabaseDriverSQLite, Database: "shop.db"})
+	credential := &flow.Credential{AgentID: "agent-1", Name: "shop", Type: flow.CredentialTypeDatabase, Config: string(raw)}
+	if err := repo.CreateCredential(ctx, credential); err != nil {
+		t.Fatalf("CreateCredential() error = %v", err)
+	}
+	executor := NewFlowExecutor(repo)
+	sender := &fakeSender{}
+	executor.SetMessageSender(sender)
+
+	f := &flow.Flow{
+		ID: "flow-1",
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWhatsApp},
+			{ID: "stock", Type: flow.NodeTypeHTTPRequest, Data: map[string]interface{}{"url": server.URL}},
+			{ID: "select", Type: flow.NodeTypeDatabase, Data: map[string]interface{}{"credential_id": credential.ID, "operation": "select", "table": "orders"}},
+			{ID: "update", Type: flow.NodeTypeDatabase, Data: map[string]interface{}{"credential_id": credential.ID, "operation": "update", "table": "orders", "values": map[string]interface{}{"status": "{{message}}"}, "where": "id = 1"}},
+			{ID: "webhook", Type: flow.NodeTypeHTTPRequest, Data: map[string]interface{}{"method": "POST", "url": server.URL, "body": `{"status":"{{message}}"}`}},
+			{ID: "wait", Type: flow.NodeTypeDelay, Data: map[string]interface{}{"duration": float64(2), "unit": "hours"}},
+			{ID: "ask", Type: flow.NodeTypeWaitForReply, Data: map[string]interface{}{"timeout": float64(1), "unit": "hours"}},
+			{ID: "reply", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"message": "Got {{reply}}"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "stock"},
+			{ID: "e2", Source: "stock", Target: "select"},
+			{ID: "e3", Source: "select", Target: "update"},
+			{ID: "e4", Source: "update", Target: "webhook"},
+			{ID: "e5", Source: "webhook", Target: "wait"},
+			{ID: "e6", Source: "wait", Target: "ask"},
+			{ID: "e7", Source: "ask", Target: "reply", SourceHandle: flow.WaitHandleReply},
+		},
+	}
+
+	result, err := executor.DryRun(ctx, f, "", map[string]interface{}{
+		"integration_id": "wa-1",
+		"sender":         "628999",
+		"message":        "paid",
+	}, "yes")
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+
+	// Reads happen, side effects do not
+	if gets.Load() != 1 || posts.Load() != 0 || len(sender.sent) != 0 {
+		t.Fatalf("GET requests = %d, POST requests = %d, messages sent = %d; want 1, 0, 0", gets.Load(), posts.Load(), len(sender.sent))
+	}
+	var status string
+	db.QueryRow(`SELECT status FROM orders WHERE id = 1`).Scan(&status)
+	if status != "new" {
+		t.Fatalf("order status = %q after a dry run, want new", status)
+	}
+	if count, _ := result.Variables["count"].(int); count != 1 {
+		t.Errorf("select count = %v, want 1", result.Variables["count"])
+	}
+
+	stubs := map[string]flow.NodeTrace{}
+	for _, trace := range result.Trace {
+		if trace.Stubbed {
+			stubs[trace.NodeID] = trace
+		}
+	}
+	if len(stubs) != 5 || len(result.Trace) != 8 {
+		t.Fatalf("stubbed nodes = %v of %d traced, want update, webhook, wait, ask and reply of 8", stubs, len(result.Trace))
+	}
+	if args, _ := stubs["update"].Output["args"].([]interface{}); len(args) != 1 || args[0] != "paid" {
+		t.Errorf("recorded update = %+v", stubs["update"].Output)
+	}
+	if request, _ := stubs["webhook"].Output["request"].(map[string]interface{}); request["body"] != `{"status":"paid"}` {
+		t.Errorf("recorded request = %+v", stubs["webhook"].Output)
+	}
+	if len(result.Replies) != 1 || result.Replies[0] != "Got yes" {
+		t.Errorf("Replies = %q", result.Replies)
+	}
+	if _, ok := result.Variables["_stubbed"]; ok {
+		t.Errorf("stub marker leaked into the variables")
+	}
+
+	// Dry runs are not recorded
+	executions, total, err := repo.ListExecutions(ctx, flow.ExecutionFilter{FlowID: f.ID, Limit: 10})
+	if err != nil || total != 0 || len(executions) != 0 {
+		t.Fatalf("ListExecutions() = %d executions, %v", total, err)
+	}
+}
+
+func TestIsReadQuery(t *testing.T) {
+	for query, want := range map[string]bool{
+		"SELECT * FROM orders":            true,
+		"  with t as (select 1) select *": true,
+		"(SELECT 1) UNION (SELECT 2)":     true,
+		"INSERT INTO orders VALUES (1)":   false,
+		"update orders set status = 'x'":  false,
+		"":                                false,
+	} {
+		if got := isReadQuery(query); got != want {
+			t.Errorf("isReadQuery(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
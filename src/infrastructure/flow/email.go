@@ -0,0 +1,545 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/google/uuid"
+)
+
+const (
+	smtpTimeout            = time.Minute // For a whole SMTP session
+	emailAttachmentMaxSize = 25 << 20    // Total size of the attachments of an email
+)
+
+// smtpSecurity returns how to secure an SMTP connection; empty means STARTTLS when the server
+// offers it
+func smtpSecurity(cfg flow.SMTPCredential) string {
+	switch {
+	case cfg.Security != "":
+		return cfg.Security
+	case cfg.Port == 465:
+		return flow.SMTPSecurityTLS
+	case cfg.UseTLS:
+		return flow.SMTPSecuritySTARTTLS
+	}
+	return ""
+}
+
+// dialSMTP connects and authenticates to the server of an SMTP credential
+func dialSMTP(ctx context.Context, cfg flow.SMTPCredential) (*smtp.Client, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	security := smtpSecurity(cfg)
+	port := cfg.Port
+	if port == 0 {
+		switch security {
+		case flow.SMTPSecurityTLS:
+			port = 465
+		case flow.SMTPSecurityNone:
+			port = 25
+		default:
+			port = 587
+		}
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.SkipVerify}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	switch security {
+	case flow.SMTPSecurityTLS:
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	case "", flow.SMTPSecuritySTARTTLS, flow.SMTPSecurityNone:
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	default:
+		return nil, fmt.Errorf("unknown SMTP security %q", security)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	deadline := time.Now().Add(smtpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.Hello("localhost"); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	startTLS, _ := c.Extension("STARTTLS")
+	if security == flow.SMTPSecuritySTARTTLS && !startTLS {
+		c.Close()
+		return nil, fmt.Errorf("server does not support STARTTLS")
+	}
+	if startTLS && (security == "" || security == flow.SMTPSecuritySTARTTLS) {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	// PLAIN authentication refuses connections without TLS, except to localhost
+	if cfg.User != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			c.Close()
+			return nil, fmt.Errorf("server does not support authentication")
+		}
+		if err := c.Auth(smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host)); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// TestSMTP checks that the server of an SMTP credential accepts its login
+func TestSMTP(ctx context.Context, cfg flow.SMTPCredential) error {
+	c, err := dialSMTP(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Quit()
+}
+
+// emailMessage is an email ready to send
+type emailMessage struct {
+	From        *mail.Address
+	To          []*mail.Address
+	CC          []*mail.Address
+	BCC         []*mail.Address // Not written in the headers
+	ReplyTo     []*mail.Address
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []*flow.OutgoingMedia
+	MessageID   string
+	Date        time.Time
+}
+
+// mimeEntity is a MIME part with its headers and encoded body
+type mimeEntity struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+func textEntity(subtype, text string) mimeEntity {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(text))
+	qp.Close()
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {"text/" + subtype + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: buf.Bytes(),
+	}
+}
+
+func attachmentEntity(media *flow.OutgoingMedia) mimeEntity {
+	encoded := base64.StdEncoding.EncodeToString(media.Data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	mediaType, params, err := mime.ParseMediaType(media.MIMEType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = media.FileName
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(mediaType, params)},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": media.FileName})},
+			"Content-Transfer-Encoding": {"base64"},
+		},
+		body: buf.Bytes(),
+	}
+}
+
+func multipartEntity(subtype string, parts ...mimeEntity) mimeEntity {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, part := range parts {
+		pw, _ := w.CreatePart(part.header)
+		pw.Write(part.body)
+	}
+	w.Close()
+	return mimeEntity{
+		header: textproto.MIMEHeader{"Content-Type": {"multipart/" + subtype + "; boundary=" + w.Boundary()}},
+		body:   buf.Bytes(),
+	}
+}
+
+// addressList formats addresses for a header
+func addressList(addresses []*mail.Address) string {
+	list := make([]string, len(addresses))
+	for i, address := range addresses {
+		list[i] = address.String()
+	}
+	return strings.Join(list, ", ")
+}
+
+// bytes renders the message in MIME format
+func (m *emailMessage) bytes() []byte {
+	var content mimeEntity
+	switch {
+	case m.HTML != "" && m.Text != "":
+		content = multipartEntity("alternative", textEntity("plain", m.Text), textEntity("html", m.HTML))
+	case m.HTML != "":
+		content = textEntity("html", m.HTML)
+	default:
+		content = textEntity("plain", m.Text)
+	}
+	if len(m.Attachments) > 0 {
+		parts := []mimeEntity{content}
+		for _, media := range m.Attachments {
+			parts = append(parts, attachmentEntity(media))
+		}
+		content = multipartEntity("mixed", parts...)
+	}
+
+	header := textproto.MIMEHeader{
+		"From":         {m.From.String()},
+		"Subject":      {mime.QEncoding.Encode("UTF-8", m.Subject)},
+		"Date":         {m.Date.Format(time.RFC1123Z)},
+		"Message-Id":   {m.MessageID},
+		"Mime-Version": {"1.0"},
+	}
+	if len(m.To) > 0 {
+		header.Set("To", addressList(m.To))
+	}
+	if len(m.CC) > 0 {
+		header.Set("Cc", addressList(m.CC))
+	}
+	if len(m.ReplyTo) > 0 {
+		header.Set("Reply-To", addressList(m.ReplyTo))
+	}
+	for key, values := range content.header {
+		header[key] = values
+	}
+
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(content.body)
+	return buf.Bytes()
+}
+
+// sendEmail delivers msg, returning the recipients the server accepted and rejected. It fails
+// only when no recipient is accepted.
+func sendEmail(ctx context.Context, cfg flow.SMTPCredential, msg *emailMessage) (accepted, rejected []string, err error) {
+	c, err := dialSMTP(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+
+	if err := c.Mail(msg.From.Address); err != nil {
+		return nil, nil, fmt.Errorf("sender rejected: %w", err)
+	}
+	for _, list := range [][]*mail.Address{msg.To, msg.CC, msg.BCC} {
+		for _, rcpt := range list {
+			if err := c.Rcpt(rcpt.Address); err != nil {
+				rejected = append(rejected, rcpt.Address+": "+err.Error())
+				continue
+			}
+			accepted = append(accepted, rcpt.Address)
+		}
+	}
+	if len(accepted) == 0 {
+		return nil, rejected, fmt.Errorf("all recipients rejected: %s", strings.Join(rejected, "; "))
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := w.Write(msg.bytes()); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, fmt.Errorf("message rejected: %w", err)
+	}
+	c.Quit()
+	return accepted, rejected, nil
+}
+
+// parseAddresses parses a list of addresses separated by commas or semicolons
+func parseAddresses(list string) ([]*mail.Address, error) {
+	list = strings.TrimSpace(strings.ReplaceAll(list, ";", ","))
+	if list == "" {
+		return nil, nil
+	}
+	addresses, err := mail.ParseAddressList(strings.Trim(list, ", "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid address list %q: %w", list, err)
+	}
+	return addresses, nil
+}
+
+func (e *FlowExecutor) executeEmail(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+	vars := execCtx.Variables
+
+	credentialID, _ := data["credential_id"].(string)
+	if credentialID == "" {
+		return nil, fmt.Errorf("SMTP credential is required")
+	}
+	cred, err := e.flowRepo.GetCredentialByID(ctx, credentialID)
+	if err != nil {
+		return nil, fmt.Errorf("credential not found: %w", err)
+	}
+	var cfg flow.SMTPCredential
+	if err := json.Unmarshal([]byte(cred.Config), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid SMTP config: %w", err)
+	}
+
+	fromEmail := cfg.FromEmail
+	if fromEmail == "" {
+		fromEmail = cfg.User
+	}
+	if _, err := mail.ParseAddress(fromEmail); err != nil {
+		return nil, fmt.Errorf("invalid sender address %q", fromEmail)
+	}
+	msg := &emailMessage{
+		From: &mail.Address{Name: cfg.FromName, Address: fromEmail},
+		Date: time.Now(),
+	}
+	domain := fromEmail[strings.LastIndex(fromEmail, "@")+1:]
+	msg.MessageID = fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)
+
+	for key, list := range map[string]*[]*mail.Address{"to": &msg.To, "cc": &msg.CC, "bcc": &msg.BCC, "reply_to": &msg.ReplyTo} {
+		text, _ := data[key].(string)
+		if *list, err = parseAddresses(e.interpolateVariables(text, vars)); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if len(msg.To)+len(msg.CC)+len(msg.BCC) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required")
+	}
+
+	subject, _ := data["subject"].(string)
+	body, _ := data["body"].(string)
+	htmlBody, _ := data["html"].(string)
+	msg.Subject = e.interpolateVariables(subject, vars)
+	msg.Text = e.interpolateVariables(body, vars)
+	// Values are escaped so that variables such as a received message cannot add markup
+	msg.HTML = templatePattern.ReplaceAllStringFunc(htmlBody, func(match string) string {
+		return html.EscapeString(e.interpolateVariables(match, vars))
+	})
+
+	attachments, _ := data["attachments"].([]interface{})
+	if msg.Attachments, err = e.emailAttachments(ctx, execCtx, attachments); err != nil {
+		return nil, err
+	}
+
+	if execCtx.DryRun {
+		names := make([]string, len(msg.Attachments))
+		for i, attachment := range msg.Attachments {
+			names[i] = attachment.FileName
+		}
+		return stubbed(map[string]interface{}{
+			"email_sent":  true,
+			"message_id":  msg.MessageID,
+			"accepted":    []string{},
+			"rejected":    []string{},
+			"from":        msg.From.String(),
+			"to":          addressList(msg.To),
+			"cc":          addressList(msg.CC),
+			"bcc":         addressList(msg.BCC),
+			"subject":     msg.Subject,
+			"text":        msg.Text,
+			"html":        msg.HTML,
+			"attachments": names,
+		}), nil
+	}
+
+	accepted, rejected, err := sendEmail(ctx, cfg, msg)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"email_sent": true,
+		"message_id": msg.MessageID,
+		"accepted":   accepted,
+		"rejected":   rejected,
+	}, nil
+}
+
+// emailAttachments loads the attachments of an email node
+func (e *FlowExecutor) emailAttachments(ctx context.Context, execCtx *ExecutionContext, items []interface{}) ([]*flow.OutgoingMedia, error) {
+	var attachments []*flow.OutgoingMedia
+	for i, item := range items {
+		data, _ := item.(map[string]interface{})
+		variable, _ := data["variable"].(string)
+		mediaFile, _ := data["media_file"].(string)
+		mediaURL, _ := data["url"].(string)
+		fileName, _ := data["file_name"].(string)
+
+		var loaded []*flow.OutgoingMedia
+		switch {
+		case strings.TrimSpace(variable) != "":
+			name := strings.Trim(strings.TrimSpace(variable), "{} ")
+			value, err := e.evaluateExpression(name, execCtx.Variables)
+			if err != nil || value == nil {
+				return nil, fmt.Errorf("attachment %d: variable %q holds no media", i+1, name)
+			}
+			if loaded, err = mediaValues(value); err != nil {
+				return nil, fmt.Errorf("attachment %d: variable %q: %w", i+1, name, err)
+			}
+		case mediaFile != "":
+			content, err := LoadMedia(mediaFile)
+			if err != nil {
+				return nil, fmt.Errorf("attachment %d: %w", i+1, err)
+			}
+			loaded = []*flow.OutgoingMedia{{Data: content, FileName: mediaFile}}
+		case strings.TrimSpace(mediaURL) != "":
+			loaded = []*flow.OutgoingMedia{{URL: strings.TrimSpace(e.interpolateVariables(mediaURL, execCtx.Variables))}}
+		default:
+			return nil, fmt.Errorf("attachment %d: no media configured", i+1)
+		}
+
+		// A file name applies to a single attachment
+		if fileName = e.interpolateVariables(fileName, execCtx.Variables); fileName != "" && len(loaded) == 1 {
+			loaded[0].FileName = fileName
+		}
+		attachments = append(attachments, loaded...)
+	}
+
+	total := 0
+	for _, media := range attachments {
+		if media.URL != "" && media.Data == nil {
+			if err := downloadAttachment(ctx, media, emailAttachmentMaxSize-total); err != nil {
+				return nil, err
+			}
+		}
+		total += len(media.Data)
+		if total > emailAttachmentMaxSize {
+			return nil, fmt.Errorf("attachments exceed %d MB", emailAttachmentMaxSize>>20)
+		}
+		if media.MIMEType == "" {
+			media.MIMEType = http.DetectContentType(media.Data)
+		}
+		if media.FileName == "" {
+			media.FileName = "attachment"
+			if exts, _ := mime.ExtensionsByType(media.MIMEType); len(exts) > 0 {
+				media.FileName += exts[0]
+			}
+		}
+	}
+	return attachments, nil
+}
+
+// mediaValues reads the media in a variable: a URL, data URL or base64 content, received media
+// with a media_file, or a list of these
+func mediaValues(value interface{}) ([]*flow.OutgoingMedia, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			break
+		}
+		media := &flow.OutgoingMedia{}
+		if err := decodeMediaValue(media, strings.TrimSpace(v)); err != nil {
+			return nil, err
+		}
+		return []*flow.OutgoingMedia{media}, nil
+	case map[string]interface{}:
+		mediaFile, _ := v["media_file"].(string)
+		fileName, _ := v["file_name"].(string)
+		mimeType, _ := v["mime_type"].(string)
+		if mediaFile == "" {
+			url, _ := v["url"].(string)
+			return mediaValues(url)
+		}
+		content, err := LoadMedia(mediaFile)
+		if err != nil {
+			return nil, err
+		}
+		return []*flow.OutgoingMedia{{Data: content, FileName: fileName, MIMEType: mimeType}}, nil
+	case []interface{}:
+		var list []*flow.OutgoingMedia
+		for _, item := range v {
+			media, err := mediaValues(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, media...)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("holds no media")
+}
+
+// downloadAttachment fetches the content of an attachment given by URL, up to maxSize bytes
+func downloadAttachment(ctx context.Context, media *flow.OutgoingMedia, maxSize int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, media.URL, nil)
+	if err != nil {
+		return fmt.Errorf("attachment %s: %w", media.URL, err)
+	}
+	resp, err := (&http.Client{Timeout: smtpTimeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("attachment %s: %w", media.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("attachment %s: status %d", media.URL, resp.StatusCode)
+	}
+	media.Data, err = io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return fmt.Errorf("attachment %s: %w", media.URL, err)
+	}
+	if len(media.Data) > maxSize {
+		return fmt.Errorf("attachments exceed %d MB", emailAttachmentMaxSize>>20)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		media.MIMEType = mediaType
+	}
+	if media.FileName == "" {
+		media.FileName = filepath.Base(strings.SplitN(media.URL, "?", 2)[0])
+	}
+	return nil
+}
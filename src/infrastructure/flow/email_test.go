@@ -0,0 +1,222 @@
+package flow
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// smtpStub is an in-process SMTP server that records the messages it receives
+type smtpStub struct {
+	port     int
+	tls      *tls.Config
+	implicit bool   // TLS from the start instead of STARTTLS
+	reject   string // Recipient answered with 550
+
+	mu       sync.Mutex
+	messages []stubMessage
+}
+
+type stubMessage struct {
+	from    string
+	to      []string
+	data    string
+	secured bool
+	auth    bool
+}
+
+func startSMTPStub(t *testing.T, tlsConfig *tls.Config, implicit bool, reject string) *smtpStub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	stub := &smtpStub{port: ln.Addr().(*net.TCPAddr).Port, tls: tlsConfig, implicit: implicit, reject: reject}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	secured := false
+	if s.implicit {
+		conn = tls.Server(conn, s.tls)
+		secured = true
+	}
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 stub ESMTP")
+
+	msg := stubMessage{}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.tls != nil && !secured {
+				tp.PrintfLine("250-stub")
+				tp.PrintfLine("250-STARTTLS")
+			} else {
+				tp.PrintfLine("250-stub")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			conn = tls.Server(conn, s.tls)
+			tp = textproto.NewConn(conn)
+			secured = true
+		case "AUTH":
+			msg.auth = true
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if to == s.reject {
+				tp.PrintfLine("550 no such user")
+				continue
+			}
+			msg.to = append(msg.to, to)
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data, msg.secured = string(data), secured
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestEmailNode(t *testing.T) {
+	defer func(path string) { config.PathStorages = path }(config.PathStorages)
+	config.PathStorages = t.TempDir()
+
+	// The test server of net/http/httptest has a certificate for 127.0.0.1
+	certServer := httptest.NewTLSServer(nil)
+	defer certServer.Close()
+	tlsConfig := &tls.Config{Certificates: certServer.TLS.Certificates}
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	ctx := context.Background()
+	mediaFile, err := SaveReceivedMedia([]byte("%PDF-1.4 invoice"), "invoice.pdf")
+	if err != nil {
+		t.Fatalf("SaveReceivedMedia() error = %v", err)
+	}
+	input := map[string]interface{}{
+		"email":       "ana@example.com",
+		"message":     "<b>hi</b>",
+		"attachments": []interface{}{map[string]interface{}{"type": "document", "mime_type": "application/pdf", "file_name": "invoice.pdf", "media_file": mediaFile}},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		security string
+		stub     *smtpStub
+	}{
+		{"plain", flow.SMTPSecurityNone, startSMTPStub(t, nil, false, "bob@example.com")},
+		{"starttls", flow.SMTPSecuritySTARTTLS, startSMTPStub(t, tlsConfig, false, "bob@example.com")},
+		{"implicit tls", flow.SMTPSecurityTLS, startSMTPStub(t, tlsConfig, true, "bob@example.com")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := json.Marshal(flow.SMTPCredential{
+				Host: "127.0.0.1", Port: tt.stub.port, User: "bot", Password: "secret",
+				FromName: "Shop Bot", FromEmail: "bot@shop.example", Security: tt.security, SkipVerify: true,
+			})
+			cred := &flow.Credential{AgentID: "agent-1", Name: "mail", Type: flow.CredentialTypeSMTP, Config: string(raw)}
+			if err := repo.CreateCredential(ctx, cred); err != nil {
+				t.Fatalf("CreateCredential() error = %v", err)
+			}
+			f := &flow.Flow{
+				Nodes: []flow.Node{
+					{ID: "trigger", Type: flow.NodeTypeTriggerWhatsApp},
+					{ID: "mail", Type: flow.NodeTypeEmail, Data: map[string]interface{}{
+						"credential_id": cred.ID,
+						"to":            "{{email}}, bob@example.com",
+						"bcc":           "audit@shop.example",
+						"subject":       "Receipt for {{email}}",
+						"body":          "You said {{message}}",
+						"html":          "<p>You said {{message}}</p>",
+						"attachments":   []interface{}{map[string]interface{}{"variable": "attachments"}},
+					}},
+				},
+				Edges: []flow.Edge{{ID: "e1", Source: "trigger", Target: "mail"}},
+			}
+
+			output, err := NewFlowExecutor(repo).Execute(ctx, f, input)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			accepted, _ := output["accepted"].([]string)
+			rejected, _ := output["rejected"].([]string)
+			if strings.Join(accepted, ",") != "ana@example.com,audit@shop.example" || len(rejected) != 1 {
+				t.Fatalf("accepted = %v, rejected = %v", accepted, rejected)
+			}
+
+			if len(tt.stub.messages) != 1 {
+				t.Fatalf("server received %d messages, want 1", len(tt.stub.messages))
+			}
+			got := tt.stub.messages[0]
+			if got.secured != (tt.security != flow.SMTPSecurityNone) || !got.auth || got.from != "bot@shop.example" {
+				t.Fatalf("message = %+v", got)
+			}
+			parsed, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(got.data)))
+			if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+			if parsed.Header.Get("Message-Id") != output["message_id"] || parsed.Header.Get("Bcc") != "" {
+				t.Fatalf("headers = %v, message_id = %v", parsed.Header, output["message_id"])
+			}
+			for _, want := range []string{"multipart/alternative", "&lt;b&gt;hi&lt;/b&gt;", `filename=invoice.pdf`, "application/pdf"} {
+				if !strings.Contains(got.data, want) {
+					t.Errorf("message does not contain %q:\n%s", want, got.data)
+				}
+			}
+		})
+	}
+
+	stub := startSMTPStub(t, nil, false, "")
+	if err := TestSMTP(ctx, flow.SMTPCredential{Host: "127.0.0.1", Port: stub.port, Security: flow.SMTPSecuritySTARTTLS}); err == nil ||
+		!strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("TestSMTP() without STARTTLS error = %v", err)
+	}
+	if err := TestSMTP(ctx, flow.SMTPCredential{Host: "127.0.0.1", Port: stub.port, User: "bot", Security: flow.SMTPSecurityNone}); err != nil {
+		t.Errorf("TestSMTP() error = %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/sirupsen/logrus"
+)
+
+// === Execution History ===
+
+// CreateExecution records the start of a run
+func (r *SQLiteRepository) CreateExecution(ctx context.Context, e *flow.Execution) error {
+	inputJSON, err := marshalExecutionJSON(e.Input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal input: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO flow_executions (id, flow_id, flow_version, agent_id, trigger_node_id, trigger_type, status, input, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.FlowID, e.FlowVersion, e.AgentID, e.TriggerNodeID, e.TriggerType, e.Status, inputJSON, e.StartedAt.UTC(),
+	)
+	return err
+}
+
+// UpdateExecution records the status, output and trace of a run
+func (r *SQLiteRepository) UpdateExecution(ctx context.Context, e *flow.Execution) error {
+	outputJSON, err := marshalExecutionJSON(e.Output)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+	traceJSON, err := marshalExecutionJSON(e.Trace)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trace: %w", err)
+	}
+
+	var finishedAt interface{}
+	if e.FinishedAt != nil {
+		finishedAt = e.FinishedAt.UTC()
+	}
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE flow_executions SET status=?, output=?, error=?, trace=?, finished_at=?, duration_ms=?
+		WHERE id=?`,
+		e.Status, outputJSON, e.Error, traceJSON, finishedAt, e.DurationMs, e.ID,
+	)
+	return err
+}
+
+// GetExecution returns a run with its trace
+func (r *SQLiteRepository) GetExecution(ctx context.Context, id string) (*flow.Execution, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT id, flow_id, COALESCE(flow_version, 0), agent_id, trigger_node_id, trigger_type, status, input, output, error, trace, started_at, finished_at, duration_ms
+		FROM flow_executions WHERE id = ?`, id,
+	)
+	e, err := scanExecution(row.Scan, true)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("execution not found: %w", err)
+	}
+	return e, err
+}
+
+// ListExecutions returns the runs matching filter, newest first, without their traces
+func (r *SQLiteRepository) ListExecutions(ctx context.Context, filter flow.ExecutionFilter) ([]*flow.Execution, int, error) {
+	where := []string{"flow_id = ?"}
+	args := []interface{}{filter.FlowID}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Since != nil {
+		where = append(where, "started_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		where = append(where, "started_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	whereSQL := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM flow_executions WHERE `+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, flow_id, COALESCE(flow_version, 0), agent_id, trigger_node_id, trigger_type, status, input, output, error, '[]', started_at, finished_at, duration_ms
+		FROM flow_executions WHERE `+whereSQL+` ORDER BY started_at DESC LIMIT ? OFFSET ?`,
+		append(args, filter.Limit, filter.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var executions []*flow.Execution
+	for rows.Next() {
+		e, err := scanExecution(rows.Scan, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		executions = append(executions, e)
+	}
+	return executions, total, rows.Err()
+}
+
+// PruneExecutions deletes runs that started before olderThan (when non-zero) and, per flow, all
+// but the newest maxPerFlow runs (when positive). Waiting runs are kept. It returns the number of
+// runs deleted.
+func (r *SQLiteRepository) PruneExecutions(ctx context.Context, olderThan time.Time, maxPerFlow int) (int64, error) {
+	var deleted int64
+	if !olderThan.IsZero() {
+		res, err := r.db.ExecContext(ctx, `DELETE FROM flow_executions WHERE started_at < ? AND status != ?`, olderThan.UTC(), flow.ExecutionStatusWaiting)
+		if err != nil {
+			return deleted, err
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	if maxPerFlow > 0 {
+		res, err := r.db.ExecContext(ctx,
+			`DELETE FROM flow_executions WHERE id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (PARTITION BY flow_id ORDER BY started_at DESC) AS rn
+					FROM flow_executions
+				) WHERE rn > ?
+			) AND status != ?`, maxPerFlow, flow.ExecutionStatusWaiting,
+		)
+		if err != nil {
+			return deleted, err
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
+
+func scanExecution(scan func(dest ...interface{}) error, withTrace bool) (*flow.Execution, error) {
+	e := &flow.Execution{}
+	var inputJSON, outputJSON, traceJSON string
+	var finishedAt sql.NullTime
+	if err := scan(&e.ID, &e.FlowID, &e.FlowVersion, &e.AgentID, &e.TriggerNodeID, &e.TriggerType, &e.Status,
+		&inputJSON, &outputJSON, &e.Error, &traceJSON, &e.StartedAt, &finishedAt, &e.DurationMs); err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		e.FinishedAt = &finishedAt.Time
+	}
+	if err := json.Unmarshal([]byte(inputJSON), &e.Input); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal input: %w", err)
+	}
+	if err := json.Unmarshal([]byte(outputJSON), &e.Output); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal output: %w", err)
+	}
+	if withTrace {
+		if err := json.Unmarshal([]byte(traceJSON), &e.Trace); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trace: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// marshalExecutionJSON encodes recorded values; values JSON cannot encode (channels, functions)
+// are recorded as their Go representation rather than failing the run's history
+func marshalExecutionJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err == nil {
+		return string(b), nil
+	}
+	b, err = json.Marshal(fmt.Sprintf("%v", v))
+	return string(b), err
+}
+
+// executionPruneInterval is how often execution history retention is enforced
+const executionPruneInterval = time.Hour
+
+// ExecutionPruner deletes flow executions beyond the retention limits
+type ExecutionPruner struct {
+	repo          *SQLiteRepository
+	retentionDays int // Runs older than this are deleted; 0 keeps them
+	maxPerFlow    int // Runs beyond the newest maxPerFlow of each flow are deleted; 0 keeps them
+	checkInterval time.Duration
+	stopChan      chan struct{}
+}
+
+// NewExecutionPruner creates a new execution pruner
+func NewExecutionPruner(repo *SQLiteRepository, retentionDays, maxPerFlow int) *ExecutionPruner {
+	return &ExecutionPruner{
+		repo:          repo,
+		retentionDays: retentionDays,
+		maxPerFlow:    maxPerFlow,
+		checkInterval: executionPruneInterval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start prunes execution history until Stop is called
+func (p *ExecutionPruner) Start(ctx context.Context) {
+	if p.retentionDays <= 0 && p.maxPerFlow <= 0 {
+		logrus.Info("🗄️  Flow execution history is kept without limits")
+		return
+	}
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+
+	p.prune(ctx, time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			p.prune(ctx, time.Now())
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+// Stop stops the execution pruner
+func (p *ExecutionPruner) Stop() {
+	close(p.stopChan)
+}
+
+func (p *ExecutionPruner) prune(ctx context.Context, now time.Time) {
+	var olderThan time.Time
+	if p.retentionDays > 0 {
+		olderThan = now.AddDate(0, 0, -p.retentionDays)
+	}
+	deleted, err := p.repo.PruneExecutions(ctx, olderThan, p.maxPerFlow)
+	if err != nil {
+		logrus.Errorf("❌ Flow execution pruner: Failed to prune executions: %v", err)
+		return
+	}
+	if deleted > 0 {
+		logrus.Infof("🗑️  Flow execution pruner: Deleted %d executions", deleted)
+	}
+
+	if p.retentionDays > 0 {
+		files, err := pruneReceivedMedia(olderThan)
+		if err != nil {
+			logrus.Errorf("❌ Flow execution pruner: Failed to prune received media: %v", err)
+		} else if files > 0 {
+			logrus.Infof("🗑️  Flow execution pruner: Deleted %d received media files", files)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package flow
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestRunRecordsExecution(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	executor := NewFlowExecutor(repo)
+	ctx := context.Background()
+
+	f := &flow.Flow{
+		ID:      "flow-1",
+		AgentID: "agent-1",
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook, Label: "Hook"},
+			{ID: "check", Type: flow.NodeTypeCondition, Label: "Is VIP", Data: map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{"field": "tier", "operator": "eq", "value": "vip"}},
+			}},
+			{ID: "vip", Type: flow.NodeTypeSetVariable, Label: "VIP", Data: map[string]interface{}{"name": "greeting", "value": "Welcome back"}},
+			{ID: "other", Type: flow.NodeTypeSetVariable, Label: "Other", Data: map[string]interface{}{"name": "greeting", "value": "Hello"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "check"},
+			{ID: "e2", Source: "check", Target: "vip", SourceHandle: "true"},
+			{ID: "e3", Source: "check", Target: "other", SourceHandle: "false"},
+		},
+	}
+
+	result, err := executor.Run(ctx, f, "", map[string]interface{}{"tier": "vip"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	execution, err := repo.GetExecution(ctx, result.ExecutionID)
+	if err != nil {
+		t.Fatalf("GetExecution() error = %v", err)
+	}
+	if execution.Status != flow.ExecutionStatusSuccess || execution.TriggerType != flow.NodeTypeTriggerWebhook || execution.FinishedAt == nil {
+		t.Fatalf("execution = %+v", execution)
+	}
+	if execution.Input["tier"] != "vip" || execution.Output["greeting"] != "Welcome back" {
+		t.Fatalf("execution input = %v, output = %v", execution.Input, execution.Output)
+	}
+	if len(execution.Trace) != 3 {
+		t.Fatalf("trace has %d nodes, want 3", len(execution.Trace))
+	}
+	check := execution.Trace[1]
+	if check.NodeID != "check" || check.Handle != "true" || check.Input["tier"] != "vip" {
+		t.Fatalf("condition trace = %+v", check)
+	}
+	if _, ok := check.Input["greeting"]; ok {
+		t.Fatalf("condition trace input has variables set after it ran: %v", check.Input)
+	}
+
+	// A failing node fails the run and is the last step of the trace
+	f.Nodes[2] = flow.Node{ID: "vip", Type: flow.NodeTypeSendMessage, Label: "Reply", Data: map[string]interface{}{"message": "Hi"}}
+	if _, err := executor.Run(ctx, f, "", map[string]interface{}{"tier": "vip"}); err == nil {
+		t.Fatal("Run() without a message sender succeeded, want an error")
+	}
+
+	failed, total, err := repo.ListExecutions(ctx, flow.ExecutionFilter{FlowID: "flow-1", Status: flow.ExecutionStatusFailed, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListExecutions() error = %v", err)
+	}
+	if total != 1 || len(failed) != 1 || failed[0].Error == "" || failed[0].Trace != nil {
+		t.Fatalf("ListExecutions(failed) = %d %+v", total, failed)
+	}
+	execution, err = repo.GetExecution(ctx, failed[0].ID)
+	if err != nil {
+		t.Fatalf("GetExecution() error = %v", err)
+	}
+	if last := execution.Trace[len(execution.Trace)-1]; last.NodeID != "vip" || last.Error == "" {
+		t.Fatalf("last trace step = %+v, want the failed node", last)
+	}
+}
+
+func TestPruneExecutions(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, started := range []time.Time{now.AddDate(0, 0, -40), now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour)} {
+		e := &flow.Execution{ID: string(rune('a' + i)), FlowID: "flow-1", Status: flow.ExecutionStatusSuccess, StartedAt: started}
+		if err := repo.CreateExecution(ctx, e); err != nil {
+			t.Fatalf("CreateExecution() error = %v", err)
+		}
+	}
+	if err := repo.CreateExecution(ctx, &flow.Execution{ID: "other", FlowID: "flow-2", Status: flow.ExecutionStatusSuccess, StartedAt: now}); err != nil {
+		t.Fatalf("CreateExecution() error = %v", err)
+	}
+
+	since := now.Add(-150 * time.Minute)
+	recent, total, err := repo.ListExecutions(ctx, flow.ExecutionFilter{FlowID: "flow-1", Since: &since, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListExecutions() error = %v", err)
+	}
+	if total != 2 || len(recent) != 2 || recent[0].ID != "d" {
+		t.Fatalf("ListExecutions(since) = %d %+v, want d and c", total, recent)
+	}
+
+	deleted, err := repo.PruneExecutions(ctx, now.AddDate(0, 0, -30), 2)
+	if err != nil {
+		t.Fatalf("PruneExecutions() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("PruneExecutions() deleted %d, want 2", deleted)
+	}
+	for id, kept := range map[string]bool{"a": false, "b": false, "c": true, "d": true, "other": true} {
+		_, err := repo.GetExecution(ctx, id)
+		if (err == nil) != kept {
+			t.Fatalf("execution %s kept = %v, want %v", id, err == nil, kept)
+		}
+	}
+}
@@ -3,24 +3,26 @@ package flow
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
 	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
-	_ "github.com/lib/pq"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 // FlowExecutor executes flows
 type FlowExecutor struct {
 	flowRepo *SQLiteRepository
+	sender   flow.IMessageSender
 }
 
 // NewFlowExecutor creates a new flow executor
@@ -28,24 +30,76 @@ func NewFlowExecutor(repo *SQLiteRepository) *FlowExecutor {
 	return &FlowExecutor{flowRepo: repo}
 }
 
+// SetMessageSender enables delivery from send_message, send_image and send_file nodes (called after initialization)
+func (e *FlowExecutor) SetMessageSender(sender flow.IMessageSender) {
+	e.sender = sender
+}
+
 // ExecutionContext holds the state during flow execution
 type ExecutionContext struct {
+	ExecutionID string
 	Variables   map[string]interface{}
 	Input       map[string]interface{}
 	Output      map[string]interface{}
 	Credentials map[string]*flow.Credential
 	CurrentNode string
 	Flow        *flow.Flow
+	Replies     []string // Messages for the sender of the triggering message
+	Trace       []flow.NodeTrace
+	Pending     []string // Nodes still to run, the next one last
+	DryRun      bool     // Side effects are stubbed, and the run is neither recorded nor paused
+	DryRunReply string   // Reply wait for reply nodes receive in a dry run; they time out without one
+
+	wait  *waitingRun           // Set by delay and wait for reply nodes to pause the run
+	loops map[string]*loopState // For each nodes whose body is running, by node ID
+	steps *atomic.Int64         // Nodes run so far, shared with parallel branches
+}
+
+// ExecutionResult is the outcome of a flow run
+type ExecutionResult struct {
+	ExecutionID string
+	Output      map[string]interface{} // Output of the last node executed
+	Replies     []string               // Text of send_message nodes replying to the trigger, in order
+	Waiting     bool                   // The run paused at a delay or wait for reply node and continues later
 }
 
 // Execute runs a flow with given input
 func (e *FlowExecutor) Execute(ctx context.Context, f *flow.Flow, input map[string]interface{}) (map[string]interface{}, error) {
+	result, err := e.Run(ctx, f, "", input)
+	if err != nil {
+		return nil, err
+	}
+	return result.Output, nil
+}
+
+// Run executes a flow starting at the trigger node triggerID, or at the first trigger when empty
+func (e *FlowExecutor) Run(ctx context.Context, f *flow.Flow, triggerID string, input map[string]interface{}) (*ExecutionResult, error) {
+	execCtx, triggerNode, err := e.prepare(f, triggerID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// LLM calls made by the nodes are billed to this run
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{FlowID: f.ID, FlowExecutionID: execCtx.ExecutionID})
+
+	execution := e.startExecution(ctx, execCtx, triggerNode)
+
+	// Execute from trigger
+	execCtx.Pending = []string{triggerNode.ID}
+	err = e.runPending(ctx, execCtx)
+	return e.settle(ctx, execCtx, execution, err)
+}
+
+// prepare creates the context of a run of f with input, and finds the trigger node triggerID, or
+// the first trigger when empty
+func (e *FlowExecutor) prepare(f *flow.Flow, triggerID string, input map[string]interface{}) (*ExecutionContext, *flow.Node, error) {
 	if f == nil || len(f.Nodes) == 0 {
-		return nil, fmt.Errorf("flow is empty")
+		return nil, nil, fmt.Errorf("flow is empty")
 	}
 
 	// Build execution context
 	execCtx := &ExecutionContext{
+		ExecutionID: uuid.New().String(),
 		Variables:   make(map[string]interface{}),
 		Input:       input,
 		Output:      make(map[string]interface{}),
@@ -65,16 +119,31 @@ func (e *FlowExecutor) Execute(ctx context.Context, f *flow.Flow, input map[stri
 
 	// Find trigger node (entry point)
 	triggerNode := e.findTriggerNode(f.Nodes)
+	if triggerID != "" {
+		triggerNode = e.findNode(f.Nodes, triggerID)
+	}
 	if triggerNode == nil {
-		return nil, fmt.Errorf("no trigger node found")
+		return nil, nil, fmt.Errorf("no trigger node found")
 	}
+	return execCtx, triggerNode, nil
+}
 
-	// Execute from trigger
-	if err := e.executeFromNode(ctx, execCtx, triggerNode.ID); err != nil {
+// settle saves a run that paused, or records the outcome of one that ended
+func (e *FlowExecutor) settle(ctx context.Context, execCtx *ExecutionContext, execution *flow.Execution, err error) (*ExecutionResult, error) {
+	if err == nil && execCtx.wait != nil {
+		err = e.pause(ctx, execCtx)
+	}
+	e.finishExecution(ctx, execCtx, execution, err)
+	if err != nil {
 		return nil, err
 	}
 
-	return execCtx.Output, nil
+	return &ExecutionResult{
+		ExecutionID: execCtx.ExecutionID,
+		Output:      execCtx.Output,
+		Replies:     execCtx.Replies,
+		Waiting:     execCtx.wait != nil,
+	}, nil
 }
 
 func (e *FlowExecutor) findTriggerNode(nodes []flow.Node) *flow.Node {
@@ -86,42 +155,158 @@ func (e *FlowExecutor) findTriggerNode(nodes []flow.Node) *flow.Node {
 	return nil
 }
 
-func (e *FlowExecutor) executeFromNode(ctx context.Context, execCtx *ExecutionContext, nodeID string) error {
-	node := e.findNode(execCtx.Flow.Nodes, nodeID)
-	if node == nil {
-		return fmt.Errorf("node %s not found", nodeID)
-	}
+// runPending executes the pending nodes depth first: the nodes after a node run, each with the
+// nodes after it, before the node's siblings. It stops early when a node pauses the run, and fails
+// once the run exceeds the step limit.
+func (e *FlowExecutor) runPending(ctx context.Context, execCtx *ExecutionContext) error {
+	for len(execCtx.Pending) > 0 {
+		nodeID := execCtx.Pending[len(execCtx.Pending)-1]
+		execCtx.Pending = execCtx.Pending[:len(execCtx.Pending)-1]
+
+		node := e.findNode(execCtx.Flow.Nodes, nodeID)
+		if node == nil {
+			return fmt.Errorf("node %s not found", nodeID)
+		}
+		if err := execCtx.step(); err != nil {
+			return err
+		}
 
-	execCtx.CurrentNode = nodeID
-	logrus.Debugf("Executing node: %s (%s)", node.Label, node.Type)
+		execCtx.CurrentNode = nodeID
+		logrus.Debugf("Executing node: %s (%s)", node.Label, node.Type)
 
-	// Execute current node
-	output, err := e.executeNode(ctx, execCtx, node)
-	if err != nil {
-		return fmt.Errorf("node %s failed: %w", node.Label, err)
+		// Execute current node
+		trace := flow.NodeTrace{
+			NodeID:    node.ID,
+			NodeType:  node.Type,
+			Label:     node.Label,
+			Input:     copyVariables(execCtx.Variables),
+			StartedAt: time.Now(),
+		}
+		traceAt := len(execCtx.Trace)
+		output, err := e.executeNode(ctx, execCtx, node)
+		if err == nil && execCtx.wait != nil {
+			// Traced when the run resumes
+			execCtx.wait.NodeID = node.ID
+			execCtx.wait.CreatedAt = trace.StartedAt
+			return nil
+		}
+		trace.DurationMs = time.Since(trace.StartedAt).Milliseconds()
+		trace.Output = copyVariables(output)
+		if handle, ok := output["_handle"].(string); ok {
+			trace.Handle = handle
+		}
+		trace.Stubbed, _ = output["_stubbed"].(bool)
+		if err != nil {
+			trace.Error = err.Error()
+		}
+		// Ahead of the nodes it ran itself, such as the branches of a parallel node
+		execCtx.Trace = append(execCtx.Trace, flow.NodeTrace{})
+		copy(execCtx.Trace[traceAt+1:], execCtx.Trace[traceAt:])
+		execCtx.Trace[traceAt] = trace
+		if err != nil {
+			return fmt.Errorf("node %s failed: %w", node.Label, err)
+		}
+
+		e.advance(execCtx, node.ID, output)
 	}
+	return nil
+}
 
-	// Store output in variables
+// advance stores the output of a node and queues the nodes after it
+func (e *FlowExecutor) advance(execCtx *ExecutionContext, nodeID string, output map[string]interface{}) {
+	// Store output in variables; the handle only picks the edges to follow
 	if output != nil {
 		for k, v := range output {
-			execCtx.Variables[k] = v
+			if k != "_handle" && k != "_stubbed" {
+				execCtx.Variables[k] = v
+			}
 		}
 		execCtx.Output = output
 	}
 
-	// Find next nodes
+	// Queue next nodes, the first to run last. Edges back to a running for each node end an
+	// iteration; the node is already queued to start the next one.
 	nextNodeIDs := e.findNextNodes(execCtx.Flow.Edges, nodeID, output)
-	
-	// Execute next nodes
-	for _, nextID := range nextNodeIDs {
-		if err := e.executeFromNode(ctx, execCtx, nextID); err != nil {
-			return err
+	for i := len(nextNodeIDs) - 1; i >= 0; i-- {
+		if _, running := execCtx.loops[nextNodeIDs[i]]; running {
+			continue
 		}
+		execCtx.Pending = append(execCtx.Pending, nextNodeIDs[i])
 	}
+}
 
+// step counts a node about to run, and fails once the run exceeds the step limit
+func (execCtx *ExecutionContext) step() error {
+	if execCtx.steps == nil {
+		execCtx.steps = new(atomic.Int64)
+	}
+	if n := execCtx.steps.Add(1); config.FlowMaxSteps > 0 && n > int64(config.FlowMaxSteps) {
+		return fmt.Errorf("run exceeded the limit of %d steps", config.FlowMaxSteps)
+	}
 	return nil
 }
 
+// startExecution records the start of a run; failures to record are logged and do not stop the run
+func (e *FlowExecutor) startExecution(ctx context.Context, execCtx *ExecutionContext, trigger *flow.Node) *flow.Execution {
+	if e.flowRepo == nil {
+		return nil
+	}
+	execution := &flow.Execution{
+		ID:            execCtx.ExecutionID,
+		FlowID:        execCtx.Flow.ID,
+		FlowVersion:   execCtx.Flow.Version,
+		AgentID:       execCtx.Flow.AgentID,
+		TriggerNodeID: trigger.ID,
+		TriggerType:   trigger.Type,
+		Status:        flow.ExecutionStatusRunning,
+		Input:         execCtx.Input,
+		StartedAt:     time.Now(),
+	}
+	if err := e.flowRepo.CreateExecution(context.WithoutCancel(ctx), execution); err != nil {
+		logrus.Warnf("⚠️  [Flow] Failed to record execution of flow %s: %v", execCtx.Flow.ID, err)
+		return nil
+	}
+	return execution
+}
+
+// finishExecution records the outcome and node trace of a run started with startExecution, or its
+// progress when it paused
+func (e *FlowExecutor) finishExecution(ctx context.Context, execCtx *ExecutionContext, execution *flow.Execution, runErr error) {
+	if execution == nil {
+		return
+	}
+	now := time.Now()
+	execution.DurationMs = now.Sub(execution.StartedAt).Milliseconds()
+	execution.Output = execCtx.Output
+	execution.Trace = execCtx.Trace
+	switch {
+	case runErr != nil:
+		execution.Status = flow.ExecutionStatusFailed
+		execution.Error = runErr.Error()
+		execution.FinishedAt = &now
+	case execCtx.wait != nil:
+		execution.Status = flow.ExecutionStatusWaiting
+	default:
+		execution.Status = flow.ExecutionStatusSuccess
+		execution.FinishedAt = &now
+	}
+	if err := e.flowRepo.UpdateExecution(context.WithoutCancel(ctx), execution); err != nil {
+		logrus.Warnf("⚠️  [Flow] Failed to record outcome of execution %s: %v", execution.ID, err)
+	}
+}
+
+// copyVariables returns a shallow copy of vars, so a trace keeps the values a node saw
+func copyVariables(vars map[string]interface{}) map[string]interface{} {
+	if vars == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		copied[k] = v
+	}
+	return copied
+}
+
 func (e *FlowExecutor) findNode(nodes []flow.Node, id string) *flow.Node {
 	for i := range nodes {
 		if nodes[i].ID == id {
@@ -153,7 +338,7 @@ func (e *FlowExecutor) findNextNodes(edges []flow.Edge, sourceID string, output
 func (e *FlowExecutor) executeNode(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
 	switch node.Type {
 	// Triggers just pass input through
-	case flow.NodeTypeTriggerWhatsApp, flow.NodeTypeTriggerTelegram, flow.NodeTypeTriggerInstagram, flow.NodeTypeTriggerWebhook:
+	case flow.NodeTypeTriggerWhatsApp, flow.NodeTypeTriggerTelegram, flow.NodeTypeTriggerInstagram, flow.NodeTypeTriggerWebhook, flow.NodeTypeTriggerSchedule:
 		return execCtx.Input, nil
 
 	case flow.NodeTypeAIAgent:
@@ -168,15 +353,42 @@ func (e *FlowExecutor) executeNode(ctx context.Context, execCtx *ExecutionContex
 	case flow.NodeTypeCondition:
 		return e.executeCondition(ctx, execCtx, node)
 
+	case flow.NodeTypeSwitch:
+		return e.executeSwitch(ctx, execCtx, node)
+
+	case flow.NodeTypeForEach:
+		return e.executeForEach(ctx, execCtx, node)
+
+	case flow.NodeTypeParallel:
+		return e.executeParallel(ctx, execCtx, node)
+
 	case flow.NodeTypeDelay:
 		return e.executeDelay(ctx, execCtx, node)
 
+	case flow.NodeTypeWaitForReply:
+		return e.executeWaitForReply(ctx, execCtx, node)
+
 	case flow.NodeTypeSendMessage:
 		return e.executeSendMessage(ctx, execCtx, node)
 
+	case flow.NodeTypeSendImage:
+		return e.executeSendMedia(ctx, execCtx, node, flow.MediaKindImage)
+
+	case flow.NodeTypeSendFile:
+		return e.executeSendMedia(ctx, execCtx, node, flow.MediaKindFile)
+
 	case flow.NodeTypeSetVariable:
 		return e.executeSetVariable(ctx, execCtx, node)
 
+	case flow.NodeTypeCode:
+		return e.executeCode(ctx, execCtx, node)
+
+	case flow.NodeTypeEmail:
+		return e.executeEmail(ctx, execCtx, node)
+
+	case flow.NodeTypeGoogleSheets:
+		return e.executeGoogleSheets(ctx, execCtx, node)
+
 	default:
 		return execCtx.Variables, nil
 	}
@@ -190,28 +402,31 @@ func (e *FlowExecutor) executeAIAgent(ctx context.Context, execCtx *ExecutionCon
 	credentialID, _ := data["credential_id"].(string)
 	model, _ := data["model"].(string)
 	systemPrompt, _ := data["system_prompt"].(string)
-	
-	if model == "" {
-		model = "gpt-4o-mini"
-	}
+	provider, _ := data["provider"].(string)
 
-	// Get API key from credential or data
-	apiKey := ""
+	// Get provider settings from credential or data
+	providerCfg := aiService.ProviderConfig{Provider: aiService.ProviderOpenAI}
 	if credentialID != "" {
 		cred, err := e.flowRepo.GetCredentialByID(ctx, credentialID)
 		if err == nil {
-			var config flow.OpenAICredential
-			json.Unmarshal([]byte(cred.Config), &config)
-			apiKey = config.APIKey
+			providerCfg = aiProviderConfigFromCredential(cred)
 		}
 	}
-	if apiKey == "" {
-		apiKey, _ = data["api_key"].(string)
+	if provider != "" {
+		providerCfg.Provider = provider
+	}
+	if providerCfg.APIKey == "" {
+		providerCfg.APIKey, _ = data["api_key"].(string)
+	}
+	if providerCfg.BaseURL == "" {
+		providerCfg.BaseURL, _ = data["base_url"].(string)
 	}
 
-	if apiKey == "" {
+	// Self-hosted OpenAI-compatible servers may run without a key
+	if providerCfg.APIKey == "" && providerCfg.BaseURL == "" {
 		return nil, fmt.Errorf("AI agent requires API key")
 	}
+	providerCfg.Model = model
 
 	// Get user message from input
 	userMessage, _ := execCtx.Variables["message"].(string)
@@ -227,7 +442,10 @@ func (e *FlowExecutor) executeAIAgent(ctx context.Context, execCtx *ExecutionCon
 	systemPrompt = e.interpolateVariables(systemPrompt, execCtx.Variables)
 
 	// Call AI (Flow executor doesn't use SerpAPI, so pass empty string)
-	ai := aiService.NewService(apiKey, "")
+	ai := aiService.NewServiceWithProvider(providerCfg, "")
+	if ai == nil {
+		return nil, fmt.Errorf("failed to initialize AI provider %q", providerCfg.Provider)
+	}
 
 	// Use sensible defaults for flows; they are independent from per-agent settings
 	const defaultMaxTokens = 500
@@ -245,6 +463,33 @@ func (e *FlowExecutor) executeAIAgent(ctx context.Context, execCtx *ExecutionCon
 	}, nil
 }
 
+// aiProviderConfigFromCredential maps an OpenAI or Anthropic credential to provider settings
+func aiProviderConfigFromCredential(cred *flow.Credential) aiService.ProviderConfig {
+	if cred.Type == flow.CredentialTypeAnthropic {
+		var config flow.AnthropicCredential
+		json.Unmarshal([]byte(cred.Config), &config)
+		return aiService.ProviderConfig{
+			Provider: aiService.ProviderAnthropic,
+			APIKey:   config.APIKey,
+			BaseURL:  config.BaseURL,
+		}
+	}
+
+	var config flow.OpenAICredential
+	json.Unmarshal([]byte(cred.Config), &config)
+	provider := config.Provider
+	if provider == "" {
+		provider = aiService.ProviderOpenAI
+	}
+	return aiService.ProviderConfig{
+		Provider:     provider,
+		APIKey:       config.APIKey,
+		BaseURL:      config.BaseURL,
+		Organization: config.Organization,
+		APIVersion:   config.APIVersion,
+	}
+}
+
 func (e *FlowExecutor) executeHTTPRequest(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
 	data := node.Data
 
@@ -290,6 +535,21 @@ func (e *FlowExecutor) executeHTTPRequest(ctx context.Context, execCtx *Executio
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	// Requests that may change something, such as outgoing webhooks, are only recorded in dry runs
+	if execCtx.DryRun && !safeMethod(req.Method) {
+		return stubbed(map[string]interface{}{
+			"status_code": http.StatusOK,
+			"body":        nil,
+			"headers":     http.Header{},
+			"request": map[string]interface{}{
+				"method":  req.Method,
+				"url":     url,
+				"headers": req.Header,
+				"body":    bodyStr,
+			},
+		}), nil
+	}
+
 	// Execute request
 	client := &http.Client{Timeout: time.Duration(timeoutSec) * time.Second}
 	resp, err := client.Do(req)
@@ -321,221 +581,6 @@ func (e *FlowExecutor) executeHTTPRequest(ctx context.Context, execCtx *Executio
 	}, nil
 }
 
-func (e *FlowExecutor) executeDatabase(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
-	data := node.Data
-
-	credentialID, _ := data["credential_id"].(string)
-	operation, _ := data["operation"].(string)
-	table, _ := data["table"].(string)
-	query, _ := data["query"].(string)
-
-	if credentialID == "" {
-		return nil, fmt.Errorf("database credential is required")
-	}
-
-	// Get database config
-	cred, err := e.flowRepo.GetCredentialByID(ctx, credentialID)
-	if err != nil {
-		return nil, fmt.Errorf("credential not found: %w", err)
-	}
-
-	var dbConfig flow.DatabaseCredential
-	if err := json.Unmarshal([]byte(cred.Config), &dbConfig); err != nil {
-		return nil, fmt.Errorf("invalid database config: %w", err)
-	}
-
-	// Connect to database
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		dbConfig.Host, dbConfig.Port, dbConfig.User, dbConfig.Password, dbConfig.Database, dbConfig.SSLMode,
-	)
-
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %w", err)
-	}
-	defer db.Close()
-
-	// Execute based on operation
-	switch operation {
-	case "raw":
-		query = e.interpolateVariables(query, execCtx.Variables)
-		return e.executeRawSQL(ctx, db, query)
-	case "select":
-		return e.executeSelect(ctx, db, table, data, execCtx.Variables)
-	case "insert":
-		return e.executeInsert(ctx, db, table, data, execCtx.Variables)
-	case "update":
-		return e.executeUpdate(ctx, db, table, data, execCtx.Variables)
-	case "delete":
-		return e.executeDelete(ctx, db, table, data, execCtx.Variables)
-	default:
-		return nil, fmt.Errorf("unknown operation: %s", operation)
-	}
-}
-
-func (e *FlowExecutor) executeRawSQL(ctx context.Context, db *sql.DB, query string) (map[string]interface{}, error) {
-	rows, err := db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	columns, _ := rows.Columns()
-	var results []map[string]interface{}
-
-	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
-
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, err
-		}
-
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			row[col] = values[i]
-		}
-		results = append(results, row)
-	}
-
-	return map[string]interface{}{
-		"rows":  results,
-		"count": len(results),
-	}, nil
-}
-
-func (e *FlowExecutor) executeSelect(ctx context.Context, db *sql.DB, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
-	columns := "*"
-	if cols, ok := data["columns"].([]interface{}); ok && len(cols) > 0 {
-		colStrs := make([]string, len(cols))
-		for i, c := range cols {
-			colStrs[i] = c.(string)
-		}
-		columns = strings.Join(colStrs, ", ")
-	}
-
-	query := fmt.Sprintf("SELECT %s FROM %s", columns, table)
-
-	if where, ok := data["where"].(string); ok && where != "" {
-		query += " WHERE " + e.interpolateVariables(where, vars)
-	}
-
-	if limit, ok := data["limit"].(float64); ok && limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", int(limit))
-	}
-
-	return e.executeRawSQL(ctx, db, query)
-}
-
-func (e *FlowExecutor) executeInsert(ctx context.Context, db *sql.DB, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
-	values, ok := data["values"].(map[string]interface{})
-	if !ok || len(values) == 0 {
-		return nil, fmt.Errorf("values required for insert")
-	}
-
-	var cols []string
-	var placeholders []string
-	var args []interface{}
-	i := 1
-
-	for col, val := range values {
-		cols = append(cols, col)
-		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-		// Interpolate string values
-		if str, ok := val.(string); ok {
-			args = append(args, e.interpolateVariables(str, vars))
-		} else {
-			args = append(args, val)
-		}
-		i++
-	}
-
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING *",
-		table, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
-
-	rows, err := db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	if rows.Next() {
-		columns, _ := rows.Columns()
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
-		rows.Scan(valuePtrs...)
-
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			row[col] = values[i]
-		}
-		return map[string]interface{}{"inserted": row}, nil
-	}
-
-	return map[string]interface{}{"inserted": true}, nil
-}
-
-func (e *FlowExecutor) executeUpdate(ctx context.Context, db *sql.DB, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
-	values, ok := data["values"].(map[string]interface{})
-	if !ok || len(values) == 0 {
-		return nil, fmt.Errorf("values required for update")
-	}
-
-	where, _ := data["where"].(string)
-	if where == "" {
-		return nil, fmt.Errorf("where clause required for update")
-	}
-
-	var sets []string
-	var args []interface{}
-	i := 1
-
-	for col, val := range values {
-		sets = append(sets, fmt.Sprintf("%s = $%d", col, i))
-		if str, ok := val.(string); ok {
-			args = append(args, e.interpolateVariables(str, vars))
-		} else {
-			args = append(args, val)
-		}
-		i++
-	}
-
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
-		table, strings.Join(sets, ", "), e.interpolateVariables(where, vars))
-
-	result, err := db.ExecContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	affected, _ := result.RowsAffected()
-	return map[string]interface{}{"rows_affected": affected}, nil
-}
-
-func (e *FlowExecutor) executeDelete(ctx context.Context, db *sql.DB, table string, data map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
-	where, _ := data["where"].(string)
-	if where == "" {
-		return nil, fmt.Errorf("where clause required for delete")
-	}
-
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s", table, e.interpolateVariables(where, vars))
-
-	result, err := db.ExecContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-
-	affected, _ := result.RowsAffected()
-	return map[string]interface{}{"rows_affected": affected}, nil
-}
-
 func (e *FlowExecutor) executeCondition(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
 	data := node.Data
 
@@ -544,9 +589,24 @@ func (e *FlowExecutor) executeCondition(ctx context.Context, execCtx *ExecutionC
 	if combineWith == "" {
 		combineWith = "and"
 	}
+	// A single condition may be set on the node itself
+	if _, ok := data["conditions"]; !ok {
+		if _, ok := data["field"]; ok {
+			conditionsRaw = []interface{}{data}
+		}
+	}
 
 	results := make([]bool, 0)
 
+	// An expression condition is true when its value is truthy
+	if expression, _ := data["expression"].(string); strings.TrimSpace(expression) != "" {
+		value, err := e.evaluateExpression(expression, execCtx.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("expression failed: %w", err)
+		}
+		results = append(results, isTruthy(value))
+	}
+
 	for _, condRaw := range conditionsRaw {
 		cond, ok := condRaw.(map[string]interface{})
 		if !ok {
@@ -557,8 +617,11 @@ func (e *FlowExecutor) executeCondition(ctx context.Context, execCtx *ExecutionC
 		operator, _ := cond["operator"].(string)
 		value := cond["value"]
 
-		// Get actual value from variables
-		actualValue := e.getNestedValue(execCtx.Variables, field)
+		// Get actual value from variables, or of an expression
+		actualValue, err := e.evaluateExpression(field, execCtx.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("field %s failed: %w", field, err)
+		}
 
 		// Evaluate condition
 		result := e.evaluateCondition(actualValue, operator, value)
@@ -619,6 +682,9 @@ func (e *FlowExecutor) evaluateCondition(actual interface{}, operator string, ex
 		return fmt.Sprintf("%v", actual) == ""
 	case "not_empty":
 		return fmt.Sprintf("%v", actual) != ""
+	case "matches":
+		matched, err := regexp.MatchString(fmt.Sprintf("%v", expected), fmt.Sprintf("%v", actual))
+		return err == nil && matched
 	default:
 		return false
 	}
@@ -633,42 +699,180 @@ func (e *FlowExecutor) executeDelay(ctx context.Context, execCtx *ExecutionConte
 	if duration <= 0 {
 		return execCtx.Variables, nil
 	}
+	if execCtx.DryRun {
+		return stubbed(copyVariables(execCtx.Variables)), nil
+	}
+
+	// Pause the run; it is resumed by the flow resumer
+	execCtx.wait = &waitingRun{Kind: waitKindDelay, ResumeAt: time.Now().Add(waitDuration(duration, unit))}
+	return nil, nil
+}
+
+func (e *FlowExecutor) executeWaitForReply(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+
+	timeout, _ := data["timeout"].(float64)
+	unit, _ := data["unit"].(string)
+	if timeout <= 0 {
+		return nil, fmt.Errorf("timeout is required")
+	}
+	integrationID, contact, err := e.messageTarget(execCtx, node)
+	if err != nil {
+		return nil, err
+	}
+	if execCtx.DryRun {
+		var reply map[string]interface{}
+		if execCtx.DryRunReply != "" {
+			reply = map[string]interface{}{"message": execCtx.DryRunReply, "sender": contact, "integration_id": integrationID}
+		}
+		return stubbed(waitOutput(&waitingRun{Kind: waitKindReply}, execCtx, reply)), nil
+	}
+
+	// Pause the run until the contact's next message or the timeout
+	execCtx.wait = &waitingRun{
+		Kind:       waitKindReply,
+		ContactKey: ContactKey(integrationID, contact),
+		ResumeAt:   time.Now().Add(waitDuration(timeout, unit)),
+	}
+	return nil, nil
+}
 
-	var sleepDuration time.Duration
+// waitDuration converts the duration of a delay or wait for reply node
+func waitDuration(amount float64, unit string) time.Duration {
 	switch unit {
 	case "minutes":
-		sleepDuration = time.Duration(duration) * time.Minute
+		return time.Duration(amount * float64(time.Minute))
 	case "hours":
-		sleepDuration = time.Duration(duration) * time.Hour
+		return time.Duration(amount * float64(time.Hour))
+	case "days":
+		return time.Duration(amount * float64(24*time.Hour))
 	default:
-		sleepDuration = time.Duration(duration) * time.Second
+		return time.Duration(amount * float64(time.Second))
 	}
+}
 
-	// Limit max delay to 5 minutes for safety
-	if sleepDuration > 5*time.Minute {
-		sleepDuration = 5 * time.Minute
+func (e *FlowExecutor) executeSendMessage(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+
+	message, _ := data["message"].(string)
+	message = e.interpolateVariables(message, execCtx.Variables)
+	if strings.TrimSpace(message) == "" {
+		return nil, fmt.Errorf("message is empty")
 	}
 
-	select {
-	case <-time.After(sleepDuration):
-		return execCtx.Variables, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	return e.deliver(ctx, execCtx, node, flow.OutgoingMessage{Text: message})
+}
+
+func (e *FlowExecutor) executeSendMedia(ctx context.Context, execCtx *ExecutionContext, node *flow.Node, kind string) (map[string]interface{}, error) {
+	data := node.Data
+
+	caption, _ := data["caption"].(string)
+	caption = e.interpolateVariables(caption, execCtx.Variables)
+
+	media, err := e.resolveMedia(execCtx, data)
+	if err != nil {
+		return nil, err
 	}
+	media.Kind = kind
+
+	return e.deliver(ctx, execCtx, node, flow.OutgoingMessage{Text: caption, Media: media})
 }
 
-func (e *FlowExecutor) executeSendMessage(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+// deliver sends msg to the target of node and returns the node output
+func (e *FlowExecutor) deliver(ctx context.Context, execCtx *ExecutionContext, node *flow.Node, msg flow.OutgoingMessage) (map[string]interface{}, error) {
+	if e.sender == nil && !execCtx.DryRun {
+		return nil, fmt.Errorf("message delivery is not configured")
+	}
+
+	integrationID, recipient, err := e.messageTarget(execCtx, node)
+	if err != nil {
+		return nil, err
+	}
+	msg.AgentID = execCtx.Flow.AgentID
+	msg.IntegrationID = integrationID
+	msg.Recipient = recipient
+
+	sent := &flow.SentMessage{}
+	if !execCtx.DryRun {
+		if sent, err = e.sender.Send(ctx, msg); err != nil {
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
+	// Messages to whoever triggered the flow become part of their conversation
+	triggerIntegration, _ := execCtx.Input["integration_id"].(string)
+	triggerSender, _ := execCtx.Input["sender"].(string)
+	replyToTrigger := integrationID == triggerIntegration && recipient == triggerSender
+	if replyToTrigger {
+		execCtx.Replies = append(execCtx.Replies, replyText(msg))
+	}
+
+	output := map[string]interface{}{
+		"message":          msg.Text,
+		"response":         msg.Text,
+		"message_id":       sent.MessageID,
+		"channel":          sent.Channel,
+		"recipient":        recipient,
+		"reply_to_trigger": replyToTrigger,
+	}
+	if execCtx.DryRun {
+		output["integration_id"] = integrationID
+		if msg.Media != nil {
+			output["media"] = map[string]interface{}{"kind": msg.Media.Kind, "file_name": msg.Media.FileName, "mime_type": msg.Media.MIMEType, "url": msg.Media.URL}
+		}
+		return stubbed(output), nil
+	}
+	return output, nil
+}
+
+// messageTarget returns the integration and recipient of a send node: the ones configured on the
+// node, or the sender of the triggering message when the node replies to the trigger or has no target.
+// A recipient without an integration is reached through the trigger's integration.
+func (e *FlowExecutor) messageTarget(execCtx *ExecutionContext, node *flow.Node) (integrationID, recipient string, err error) {
 	data := node.Data
+	replyToTrigger, _ := data["reply_to_trigger"].(bool)
+	integrationID, _ = data["integration_id"].(string)
+	recipient, _ = data["recipient"].(string)
+	integrationID = strings.TrimSpace(e.interpolateVariables(integrationID, execCtx.Variables))
+	recipient = strings.TrimSpace(e.interpolateVariables(recipient, execCtx.Variables))
+
+	triggerIntegration, _ := execCtx.Input["integration_id"].(string)
+	triggerSender, _ := execCtx.Input["sender"].(string)
+	if replyToTrigger || (integrationID == "" && recipient == "") {
+		if triggerIntegration == "" || triggerSender == "" {
+			return "", "", fmt.Errorf("no trigger message to reply to; set an integration and recipient")
+		}
+		return triggerIntegration, triggerSender, nil
+	}
 
-	message, _ := data["message"].(string)
-	message = e.interpolateVariables(message, execCtx.Variables)
+	if integrationID == "" {
+		integrationID = triggerIntegration
+	}
+	if integrationID == "" {
+		return "", "", fmt.Errorf("integration is required")
+	}
+	if recipient == "" {
+		return "", "", fmt.Errorf("recipient is required")
+	}
+	return integrationID, recipient, nil
+}
 
-	// Store the message to be sent
-	return map[string]interface{}{
-		"message":         message,
-		"response":        message,
-		"reply_to_trigger": data["reply_to_trigger"],
-	}, nil
+// replyText is how a delivered message is recorded in the recipient's conversation
+func replyText(msg flow.OutgoingMessage) string {
+	if msg.Media == nil {
+		return msg.Text
+	}
+	label := "[Image]"
+	if msg.Media.Kind == flow.MediaKindFile {
+		label = "[File]"
+		if msg.Media.FileName != "" {
+			label = "[File: " + msg.Media.FileName + "]"
+		}
+	}
+	if msg.Text == "" {
+		return label
+	}
+	return label + "\n" + msg.Text
 }
 
 func (e *FlowExecutor) executeSetVariable(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
@@ -681,9 +885,9 @@ func (e *FlowExecutor) executeSetVariable(ctx context.Context, execCtx *Executio
 		return execCtx.Variables, nil
 	}
 
-	// Interpolate if string
+	// Interpolate if string; a value that is a single expression keeps its type
 	if str, ok := value.(string); ok {
-		value = e.interpolateVariables(str, execCtx.Variables)
+		value = e.evaluateTemplate(str, execCtx.Variables)
 	}
 
 	execCtx.Variables[name] = value
@@ -695,14 +899,23 @@ func (e *FlowExecutor) executeSetVariable(ctx context.Context, execCtx *Executio
 
 // === Helpers ===
 
+// interpolateVariables replaces the {{ }} expressions in template with their values. Variables
+// that do not exist and expressions that fail are left as written.
 func (e *FlowExecutor) interpolateVariables(template string, vars map[string]interface{}) string {
-	re := regexp.MustCompile(`\{\{([^}]+)\}\}`)
-	return re.ReplaceAllStringFunc(template, func(match string) string {
-		varName := strings.TrimSpace(match[2 : len(match)-2])
-		if val := e.getNestedValue(vars, varName); val != nil {
-			return fmt.Sprintf("%v", val)
+	return templatePattern.ReplaceAllStringFunc(template, func(match string) string {
+		expr := templatePattern.FindStringSubmatch(match)[1]
+		value, err := e.evaluateExpression(expr, vars)
+		if isPlainPath(expr) {
+			if err != nil || value == nil {
+				return match
+			}
+			return fmt.Sprintf("%v", value)
+		}
+		if err != nil {
+			logrus.Warnf("⚠️  [Flow] Expression %s failed: %v", match, err)
+			return match
 		}
-		return match
+		return formatValue(value)
 	})
 }
 
@@ -722,6 +935,23 @@ func (e *FlowExecutor) getNestedValue(data map[string]interface{}, path string)
 	return current
 }
 
+// isTruthy reports whether value counts as true, as in JavaScript
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0 && v == v
+	}
+	return true
+}
+
 func toFloat(v interface{}) float64 {
 	switch val := v.(type) {
 	case float64:
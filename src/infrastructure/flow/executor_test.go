@@ -0,0 +1,89 @@
+package flow
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+type fakeSender struct {
+	sent []flow.OutgoingMessage
+}
+
+func (f *fakeSender) Send(ctx context.Context, msg flow.OutgoingMessage) (*flow.SentMessage, error) {
+	f.sent = append(f.sent, msg)
+	return &flow.SentMessage{MessageID: "wamid-1", Channel: "whatsapp"}, nil
+}
+
+func TestSendNodes(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	executor := NewFlowExecutor(repo)
+	sender := &fakeSender{}
+	executor.SetMessageSender(sender)
+
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	f := &flow.Flow{
+		AgentID: "agent-1",
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWhatsApp},
+			{ID: "reply", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"message": "Hi {{sender}}"}},
+			{ID: "notify", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"integration_id": "wa-2", "recipient": "{{owner}}", "message": "New lead"}},
+			{ID: "image", Type: flow.NodeTypeSendImage, Data: map[string]interface{}{"media_variable": "photo", "caption": "Your receipt"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "reply"},
+			{ID: "e2", Source: "reply", Target: "notify"},
+			{ID: "e3", Source: "notify", Target: "image"},
+		},
+		Variables: []flow.Variable{
+			{Name: "owner", Value: "628111"},
+			{Name: "photo", Value: base64.StdEncoding.EncodeToString(png)},
+		},
+	}
+
+	result, err := executor.Run(context.Background(), f, "trigger", map[string]interface{}{
+		"integration_id": "wa-1",
+		"sender":         "628999@s.whatsapp.net",
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(sender.sent) != 3 {
+		t.Fatalf("sent %d messages, want 3", len(sender.sent))
+	}
+	if got := sender.sent[0]; got.IntegrationID != "wa-1" || got.Recipient != "628999@s.whatsapp.net" || got.Text != "Hi 628999@s.whatsapp.net" || got.AgentID != "agent-1" {
+		t.Fatalf("reply = %+v", got)
+	}
+	if got := sender.sent[1]; got.IntegrationID != "wa-2" || got.Recipient != "628111" {
+		t.Fatalf("notification = %+v", got)
+	}
+	if got := sender.sent[2]; got.Media == nil || got.Media.Kind != flow.MediaKindImage || string(got.Media.Data) != string(png) || got.Media.MIMEType != "image/png" || got.Text != "Your receipt" {
+		t.Fatalf("image = %+v", got)
+	}
+
+	// Only messages to the trigger's sender are replies
+	if len(result.Replies) != 2 || result.Replies[0] != "Hi 628999@s.whatsapp.net" || result.Replies[1] != "[Image]\nYour receipt" {
+		t.Fatalf("Replies = %q", result.Replies)
+	}
+	if result.Output["message_id"] != "wamid-1" || result.Output["channel"] != "whatsapp" {
+		t.Fatalf("Output = %+v", result.Output)
+	}
+}
+
+func TestSendMessageWithoutTarget(t *testing.T) {
+	executor := NewFlowExecutor(nil)
+	executor.SetMessageSender(&fakeSender{})
+	execCtx := &ExecutionContext{Flow: &flow.Flow{}, Variables: map[string]interface{}{}, Input: map[string]interface{}{}}
+
+	_, err := executor.executeSendMessage(context.Background(), execCtx, &flow.Node{Data: map[string]interface{}{"message": "Hi"}})
+	if err == nil {
+		t.Fatal("executeSendMessage() without a trigger or recipient should fail")
+	}
+}
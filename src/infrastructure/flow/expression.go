@@ -0,0 +1,303 @@
+package flow
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+)
+
+// Expressions are JavaScript expressions written inside {{ }} in node fields, such as
+// {{ order.total * 1.1 }} or {{ nickname ?? "friend" }}. They run in an embedded JavaScript
+// runtime with a copy of the flow variables in scope, variables that do not exist being undefined,
+// plus the helpers formatDate and jsonPath. Like code nodes they have no network, file or process
+// access, and they are bounded by expressionTimeLimit and expressionMemoryLimit. A plain variable
+// path such as {{customer.name}} is looked up directly first, so paths with dashes keep working.
+
+const (
+	// expressionTimeLimit stops expressions that do not finish, such as endless loops in a callback
+	expressionTimeLimit = 100 * time.Millisecond
+	// expressionMemoryLimit bounds single allocations, such as "x".repeat(n)
+	expressionMemoryLimit = 16 << 20
+	// expressionCacheSize is the number of compiled expressions kept for reuse
+	expressionCacheSize = 512
+)
+
+var (
+	templatePattern  = regexp.MustCompile(`(?s)\{\{(.+?)\}\}`)
+	plainPathPattern = regexp.MustCompile(`^\s*[\w-]+(\.[\w-]+)*\s*$`)
+
+	expressionPrograms = newProgramCache(expressionCacheSize)
+)
+
+// programCache keeps the most recently used compiled expressions by source, so that edited or
+// removed expressions are eventually dropped
+type programCache struct {
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+}
+
+type programEntry struct {
+	source  string
+	program *goja.Program
+}
+
+func newProgramCache(capacity int) *programCache {
+	return &programCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *programCache) get(source string) (*goja.Program, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[source]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*programEntry).program, true
+}
+
+func (c *programCache) put(source string, program *goja.Program) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[source]; ok {
+		el.Value.(*programEntry).program = program
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[source] = c.order.PushFront(&programEntry{source: source, program: program})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*programEntry).source)
+	}
+}
+
+func (c *programCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// isPlainPath reports whether expr is a variable path rather than an expression
+func isPlainPath(expr string) bool {
+	return plainPathPattern.MatchString(expr)
+}
+
+// compileExpression compiles expr, reusing earlier compilations
+func compileExpression(expr string) (*goja.Program, error) {
+	if program, ok := expressionPrograms.get(expr); ok {
+		return program, nil
+	}
+	// The parentheses only admit a single expression; the newline ends a trailing comment. Names
+	// resolve to variables, then to built-ins such as Math and JSON, then to undefined.
+	program, err := goja.Compile("expression",
+		"with (new Proxy(vars, { has: (vars, name) => name in vars || !(name in globalThis) })) {("+expr+"\n)}", false)
+	if err != nil {
+		return nil, err
+	}
+	expressionPrograms.put(expr, program)
+	return program, nil
+}
+
+// CompileExpression reports a syntax error in an expression; variable paths always compile
+func CompileExpression(expr string) error {
+	if strings.TrimSpace(expr) == "" || isPlainPath(expr) {
+		return nil
+	}
+	_, err := compileExpression(expr)
+	return err
+}
+
+// CompileTemplate reports the first {{ }} expression in text that does not compile
+func CompileTemplate(text string) error {
+	for _, match := range templatePattern.FindAllStringSubmatch(text, -1) {
+		if err := CompileExpression(match[1]); err != nil {
+			return fmt.Errorf("{{%s}}: %w", match[1], err)
+		}
+	}
+	return nil
+}
+
+// evaluateExpression returns the value of expr, a variable path or a JavaScript expression, with
+// vars as its globals
+func (e *FlowExecutor) evaluateExpression(expr string, vars map[string]interface{}) (interface{}, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	if isPlainPath(expr) {
+		value := e.getNestedValue(vars, strings.TrimSpace(expr))
+		if value != nil || strings.Contains(expr, "-") {
+			return value, nil
+		}
+		// Paths into lists, such as items.length
+	}
+	program, err := compileExpression(expr)
+	if err != nil {
+		return nil, fmt.Errorf("syntax error: %w", err)
+	}
+
+	vm := goja.New()
+	vm.SetMaxCallStackSize(codeMaxCallStackSize)
+	if err := guardAllocations(vm, expressionMemoryLimit); err != nil {
+		return nil, err
+	}
+	// A copy, so that expressions cannot change the variables of the flow
+	jsVars, err := jsonToJS(vm, vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pass the variables: %w", err)
+	}
+	vm.Set("vars", jsVars)
+	vm.Set("formatDate", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(formatDate(call.Argument(0), call.Argument(1).String(), call.Argument(2).String()))
+	})
+	vm.Set("jsonPath", func(value goja.Value, path string) interface{} {
+		return jsonPath(value.Export(), path)
+	})
+
+	timer := time.AfterFunc(expressionTimeLimit, func() {
+		vm.Interrupt(fmt.Sprintf("time limit of %s exceeded", expressionTimeLimit))
+	})
+	defer timer.Stop()
+
+	value, err := vm.RunProgram(program)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, nil
+	}
+	if obj, ok := value.(*goja.Object); ok && obj.ClassName() == "Date" {
+		return obj.Export().(time.Time).UTC().Format(time.RFC3339), nil
+	}
+	return value.Export(), nil
+}
+
+// evaluateTemplate returns the value of a field that is a single {{ }} expression as it is, such
+// as a number or a list, and interpolates any other text
+func (e *FlowExecutor) evaluateTemplate(template string, vars map[string]interface{}) interface{} {
+	match := templatePattern.FindStringSubmatchIndex(template)
+	if match == nil || match[0] != 0 || match[1] != len(template) {
+		return e.interpolateVariables(template, vars)
+	}
+	expr := template[match[2]:match[3]]
+	value, err := e.evaluateExpression(expr, vars)
+	if isPlainPath(expr) && (err != nil || value == nil) {
+		return template
+	}
+	if err != nil {
+		logrus.Warnf("⚠️  [Flow] Expression %s failed: %v", template, err)
+		return template
+	}
+	return value
+}
+
+// formatValue renders the value of an expression within text
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		if raw, err := json.Marshal(v); err == nil {
+			return string(raw)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// dateLayout converts a layout such as YYYY-MM-DD HH:mm to a Go time layout
+var dateLayout = strings.NewReplacer(
+	"YYYY", "2006", "YY", "06",
+	"MMMM", "January", "MMM", "Jan", "MM", "01",
+	"DD", "02", "dddd", "Monday", "ddd", "Mon",
+	"HH", "15", "hh", "03", "mm", "04", "ss", "05",
+	"A", "PM", "Z", "Z07:00",
+)
+
+// formatDate formats a date, a date string or Unix seconds with a layout such as YYYY-MM-DD
+// (RFC 3339 when empty) in a timezone (UTC when empty). It returns "" for values that are not dates.
+func formatDate(value goja.Value, layout, timezone string) string {
+	var t time.Time
+	switch v := value.Export().(type) {
+	case time.Time:
+		t = v
+	case int64:
+		t = time.Unix(v, 0)
+	case float64:
+		t = time.Unix(int64(v), 0)
+	case string:
+		for _, l := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if parsed, err := time.Parse(l, v); err == nil {
+				t = parsed
+				break
+			}
+		}
+	}
+	if t.IsZero() {
+		return ""
+	}
+
+	if timezone == "" || timezone == "undefined" {
+		timezone = "UTC"
+	}
+	if loc, err := time.LoadLocation(timezone); err == nil {
+		t = t.In(loc)
+	}
+	if layout == "" || layout == "undefined" {
+		return t.Format(time.RFC3339)
+	}
+	return t.Format(dateLayout.Replace(layout))
+}
+
+// jsonPathPattern matches the steps of a path such as $.items[0].name or items[0]["full name"]
+var jsonPathPattern = regexp.MustCompile(`\.?([^.\[\]]+)|\[(\d+)\]|\["([^"]*)"\]`)
+
+// jsonPath returns the value at path in value, which may be JSON text, or nil when there is none
+func jsonPath(value interface{}, path string) interface{} {
+	if text, ok := value.(string); ok {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+			return nil
+		}
+		value = decoded
+	}
+
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	for _, step := range jsonPathPattern.FindAllStringSubmatch(path, -1) {
+		switch {
+		case step[2] != "":
+			items, ok := value.([]interface{})
+			index, _ := strconv.Atoi(step[2])
+			if !ok || index >= len(items) {
+				return nil
+			}
+			value = items[index]
+		default:
+			key := step[1] + step[3]
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			value = object[key]
+		}
+	}
+	return value
+}
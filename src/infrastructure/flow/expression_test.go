@@ -0,0 +1,155 @@
+package flow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/dop251/goja"
+)
+
+func TestInterpolateExpressions(t *testing.T) {
+	e := NewFlowExecutor(nil)
+	vars := map[string]interface{}{
+		"name":  "ana",
+		"price": float64(12.5),
+		"qty":   float64(3),
+		"items": []interface{}{"tea", "cake"},
+		"order": map[string]interface{}{"id": "A-1", "created": "2025-03-14T09:30:00Z"},
+		"http":  map[string]interface{}{"body": `{"data": {"users": [{"email": "ana@example.com"}]}}`},
+		"phone": "+62 812-3456",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"Hi {{name}}, order {{order.id}}", "Hi ana, order A-1"},
+		{"Total: {{ price * qty }}", "Total: 37.5"},
+		{"{{ name.toUpperCase() }} has {{ items.length }} items, first {{ items[0] }}", "ANA has 2 items, first tea"},
+		{"Hello {{ nickname ?? name }}", "Hello ana"},
+		{"{{ vars.missing || 'none' }}", "none"},
+		{"{{ formatDate(order.created, 'DD/MM/YYYY HH:mm', 'Asia/Jakarta') }}", "14/03/2025 16:30"},
+		{"{{ jsonPath(http.body, '$.data.users[0].email') }}", "ana@example.com"},
+		{"{{ /^\\+62/.test(phone) ? 'ID' : 'other' }}", "ID"},
+		{"{{ items }}", "[tea cake]"},
+		{"{{ items.map(i => i + '!') }}", `["tea!","cake!"]`},
+		{"Keep {{unknown}} as is", "Keep {{unknown}} as is"},
+		{"Keep {{ unknown.toString() }} when it fails", "Keep {{ unknown.toString() }} when it fails"},
+		{"Stop {{ (() => { while (true) {} })() }}", "Stop {{ (() => { while (true) {} })() }}"},
+	}
+	for _, tt := range tests {
+		if got := e.interpolateVariables(tt.template, vars); got != tt.want {
+			t.Errorf("interpolateVariables(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	// A field that is a single expression keeps the type of its value
+	if got := e.evaluateTemplate("{{ qty * 2 }}", vars); got != int64(6) {
+		t.Errorf("evaluateTemplate() = %#v, want 6", got)
+	}
+	if got, _ := e.evaluateTemplate("{{ items.slice(1) }}", vars).([]interface{}); len(got) != 1 || got[0] != "cake" {
+		t.Errorf("evaluateTemplate() = %#v, want [cake]", got)
+	}
+}
+
+func TestCompileTemplate(t *testing.T) {
+	for _, text := range []string{"Hi {{name}}", "{{ a + b }} and {{ c ?? 'd' }}", "no expressions", "{{ customer-phone }}"} {
+		if err := CompileTemplate(text); err != nil {
+			t.Errorf("CompileTemplate(%q) error = %v", text, err)
+		}
+	}
+	for _, text := range []string{"{{ a + }}", "Hi {{ name. }}", "{{ a; b }}"} {
+		if err := CompileTemplate(text); err == nil {
+			t.Errorf("CompileTemplate(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func TestProgramCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newProgramCache(2)
+	for _, source := range []string{"a + 1", "b + 1"} {
+		program, err := goja.Compile("expression", source, false)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", source, err)
+		}
+		cache.put(source, program)
+	}
+	cache.get("a + 1")
+	cache.put("c + 1", nil)
+
+	if cache.len() != 2 {
+		t.Fatalf("len() = %d, want 2", cache.len())
+	}
+	if _, ok := cache.get("b + 1"); ok {
+		t.Fatalf("least recently used program was kept")
+	}
+	if _, ok := cache.get("a + 1"); !ok {
+		t.Fatalf("recently used program was evicted")
+	}
+}
+
+func TestExpressionsCannotChangeVariables(t *testing.T) {
+	e := NewFlowExecutor(nil)
+	execCtx := &ExecutionContext{Variables: map[string]interface{}{
+		"order": map[string]interface{}{"id": "A-1"},
+		"items": []interface{}{"tea"},
+	}}
+
+	expr := `(order.id = "B-2", items.push("cake"), vars.injected = true, order.id)`
+	if got, err := e.evaluateExpression(expr, execCtx.Variables); err != nil || got != "B-2" {
+		t.Fatalf("evaluateExpression() = %v, %v", got, err)
+	}
+	if id := execCtx.Variables["order"].(map[string]interface{})["id"]; id != "A-1" {
+		t.Errorf("order.id = %v, want A-1", id)
+	}
+	if items := execCtx.Variables["items"].([]interface{}); len(items) != 1 {
+		t.Errorf("items = %v, want [tea]", items)
+	}
+	if _, ok := execCtx.Variables["injected"]; ok {
+		t.Errorf("expression added a variable")
+	}
+
+	if _, err := e.evaluateExpression(`"x".repeat(5e8)`, execCtx.Variables); err == nil || !strings.Contains(err.Error(), "memory limit") {
+		t.Errorf("evaluateExpression() error = %v, want the memory limit", err)
+	}
+}
+
+func TestConditionExpressions(t *testing.T) {
+	f := &flow.Flow{
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "check", Type: flow.NodeTypeCondition, Data: map[string]interface{}{
+				"expression": "total * qty >= 100",
+				"conditions": []interface{}{
+					map[string]interface{}{"field": "email", "operator": "matches", "value": `@example\.com$`},
+				},
+			}},
+			{ID: "yes", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "tier", "value": "big"}},
+			{ID: "no", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "tier", "value": "small"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "check"},
+			{ID: "e2", Source: "check", Target: "yes", SourceHandle: "true"},
+			{ID: "e3", Source: "check", Target: "no", SourceHandle: "false"},
+		},
+	}
+
+	for _, tt := range []struct {
+		input map[string]interface{}
+		want  string
+	}{
+		{map[string]interface{}{"total": float64(40), "qty": float64(3), "email": "ana@example.com"}, "big"},
+		{map[string]interface{}{"total": float64(40), "qty": float64(2), "email": "ana@example.com"}, "small"},
+		{map[string]interface{}{"total": float64(40), "qty": float64(3), "email": "ana@example.org"}, "small"},
+	} {
+		output, err := NewFlowExecutor(nil).Execute(context.Background(), f, tt.input)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if output["tier"] != tt.want {
+			t.Errorf("Execute(%v) tier = %v, want %s", tt.input, output["tier"], tt.want)
+		}
+	}
+}
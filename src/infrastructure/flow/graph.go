@@ -0,0 +1,244 @@
+package flow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// ValidateGraph checks that a flow can run: edges connect existing nodes from outputs their source
+// has, switch and for each nodes are configured, parallel branches cannot pause, every cycle goes
+// back to a for each node from its body, and one pass through the flow, counting each loop body
+// once, takes at most maxSteps nodes (when positive).
+func ValidateGraph(nodes []flow.Node, edges []flow.Edge, maxSteps int) error {
+	byID := make(map[string]*flow.Node, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].ID] = &nodes[i]
+	}
+	out := make(map[string][]flow.Edge)
+	for _, edge := range edges {
+		source, target := byID[edge.Source], byID[edge.Target]
+		if source == nil || target == nil {
+			return fmt.Errorf("edge %s connects a node that does not exist", edge.ID)
+		}
+		if err := checkEdgeHandle(source, edge.SourceHandle); err != nil {
+			return fmt.Errorf("%s %q: %w", source.Type, source.Label, err)
+		}
+		out[edge.Source] = append(out[edge.Source], edge)
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		switch node.Type {
+		case flow.NodeTypeSwitch:
+			if _, err := switchCaseNames(node); err != nil {
+				return fmt.Errorf("switch %q: %w", node.Label, err)
+			}
+		case flow.NodeTypeForEach:
+			if items, _ := node.Data["items"].(string); strings.TrimSpace(items) == "" {
+				return fmt.Errorf("for each %q: the items variable is required", node.Label)
+			}
+		case flow.NodeTypeParallel:
+			for _, edge := range out[node.ID] {
+				if edge.SourceHandle != flow.ParallelHandleBranch {
+					continue
+				}
+				if id := findReachable(edge.Target, out, func(n string) bool {
+					return byID[n].Type == flow.NodeTypeDelay || byID[n].Type == flow.NodeTypeWaitForReply
+				}); id != "" {
+					return fmt.Errorf("parallel %q: %s %q cannot run in a parallel branch", node.Label, byID[id].Type, byID[id].Label)
+				}
+			}
+		}
+	}
+
+	// Edges from the body of a for each node back to it end an iteration; any other cycle would
+	// never end
+	forward := make(map[string][]flow.Edge, len(out))
+	for source, sourceEdges := range out {
+		for _, edge := range sourceEdges {
+			if !isLoopBack(edge, byID, out) {
+				forward[source] = append(forward[source], edge)
+			}
+		}
+	}
+	if cycle := findCycle(nodes, forward); cycle != nil {
+		labels := make([]string, len(cycle))
+		for i, id := range cycle {
+			labels[i] = byID[id].Label
+			if labels[i] == "" {
+				labels[i] = id
+			}
+		}
+		return fmt.Errorf("%s form a cycle; flows may only loop through a for each node", strings.Join(labels, " → "))
+	}
+
+	if maxSteps > 0 {
+		memo := make(map[string]int)
+		for i := range nodes {
+			if steps := countSteps(nodes[i].ID, byID, forward, memo, maxSteps); steps > maxSteps {
+				return fmt.Errorf("a run from %q can take more than the limit of %d steps", nodes[i].Label, maxSteps)
+			}
+		}
+	}
+	return nil
+}
+
+// checkEdgeHandle rejects edges leaving a switch, for each or parallel node from an output it does not have
+func checkEdgeHandle(source *flow.Node, handle string) error {
+	switch source.Type {
+	case flow.NodeTypeSwitch:
+		names, _ := switchCaseNames(source)
+		if handle != flow.SwitchHandleDefault && !names[handle] {
+			return fmt.Errorf("connect it from a case or the default output")
+		}
+	case flow.NodeTypeForEach:
+		if handle != flow.ForEachHandleBody && handle != flow.ForEachHandleDone {
+			return fmt.Errorf("connect it from the body or done output")
+		}
+	case flow.NodeTypeParallel:
+		if handle != flow.ParallelHandleBranch && handle != flow.ParallelHandleDone {
+			return fmt.Errorf("connect it from the branch or done output")
+		}
+	}
+	return nil
+}
+
+// switchCaseNames returns the case names of a switch node, which must be unique, not empty and
+// not the default handle
+func switchCaseNames(node *flow.Node) (map[string]bool, error) {
+	names := map[string]bool{}
+	cases, _ := node.Data["cases"].([]interface{})
+	for _, caseRaw := range cases {
+		c, _ := caseRaw.(map[string]interface{})
+		name, _ := c["name"].(string)
+		if name == "" || name == flow.SwitchHandleDefault || names[name] {
+			return names, fmt.Errorf("case names must be unique, not empty and not %q", flow.SwitchHandleDefault)
+		}
+		names[name] = true
+	}
+	return names, nil
+}
+
+// isLoopBack reports whether edge goes back to a for each node from that node's body
+func isLoopBack(edge flow.Edge, byID map[string]*flow.Node, out map[string][]flow.Edge) bool {
+	if byID[edge.Target].Type != flow.NodeTypeForEach {
+		return false
+	}
+	if edge.Source == edge.Target {
+		return edge.SourceHandle == flow.ForEachHandleBody
+	}
+	for _, body := range out[edge.Target] {
+		if body.SourceHandle != flow.ForEachHandleBody {
+			continue
+		}
+		if findReachable(body.Target, out, func(n string) bool { return n == edge.Source }, edge.Target) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// findReachable returns the first node reachable from start, start included, that matches,
+// without passing through the nodes in stop; or "" when there is none
+func findReachable(start string, out map[string][]flow.Edge, match func(string) bool, stop ...string) string {
+	seen := map[string]bool{}
+	for _, id := range stop {
+		seen[id] = true
+	}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if match(id) {
+			return id
+		}
+		for _, edge := range out[id] {
+			queue = append(queue, edge.Target)
+		}
+	}
+	return ""
+}
+
+// findCycle returns the nodes of a cycle, the first repeated at the end, or nil when there is none
+func findCycle(nodes []flow.Node, out map[string][]flow.Edge) []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		path = append(path, id)
+		for _, edge := range out[id] {
+			switch state[edge.Target] {
+			case visiting:
+				for i, p := range path {
+					if p == edge.Target {
+						return append(append([]string{}, path[i:]...), edge.Target)
+					}
+				}
+			case 0:
+				if cycle := visit(edge.Target); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+	for _, node := range nodes {
+		if state[node.ID] == 0 {
+			if cycle := visit(node.ID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// countSteps returns how many nodes a pass starting at id can run at most, or limit+1 once that is
+// more than limit. Nodes run once for every path leading to them; of the outputs of a condition,
+// switch or wait for reply node only one is taken. A for each node counts twice, for its first
+// item and its end, with its body once.
+func countSteps(id string, byID map[string]*flow.Node, out map[string][]flow.Edge, memo map[string]int, limit int) int {
+	if steps, ok := memo[id]; ok {
+		return steps
+	}
+
+	exclusive := false
+	steps := 1
+	switch byID[id].Type {
+	case flow.NodeTypeCondition, flow.NodeTypeSwitch, flow.NodeTypeWaitForReply:
+		exclusive = true
+	case flow.NodeTypeForEach:
+		steps = 2
+	}
+
+	byHandle := map[string]int{}
+	for _, edge := range out[id] {
+		handle := edge.SourceHandle
+		if !exclusive {
+			handle = ""
+		}
+		byHandle[handle] = min(byHandle[handle]+countSteps(edge.Target, byID, out, memo, limit), limit+1)
+	}
+	always, most := byHandle[""], 0
+	for handle, n := range byHandle {
+		if handle != "" {
+			most = max(most, n)
+		}
+	}
+	steps = min(steps+always+most, limit+1)
+
+	memo[id] = steps
+	return steps
+}
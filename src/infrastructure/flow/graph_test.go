@@ -0,0 +1,114 @@
+package flow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestValidateGraph(t *testing.T) {
+	node := func(id, nodeType string, data map[string]interface{}) flow.Node {
+		return flow.Node{ID: id, Type: nodeType, Label: id, Data: data}
+	}
+	edge := func(source, target, handle string) flow.Edge {
+		return flow.Edge{ID: source + "-" + target, Source: source, Target: target, SourceHandle: handle}
+	}
+	loop := node("loop", flow.NodeTypeForEach, map[string]interface{}{"items": "items"})
+	route := node("route", flow.NodeTypeSwitch, map[string]interface{}{
+		"field": "x",
+		"cases": []interface{}{map[string]interface{}{"name": "one", "value": "1"}},
+	})
+
+	tests := []struct {
+		name    string
+		nodes   []flow.Node
+		edges   []flow.Edge
+		wantErr string
+	}{
+		{
+			name:  "loop back to a for each node",
+			nodes: []flow.Node{node("t", flow.NodeTypeTriggerWebhook, nil), loop, node("a", "", nil), node("b", "", nil)},
+			edges: []flow.Edge{edge("t", "loop", ""), edge("loop", "a", "body"), edge("a", "b", ""), edge("b", "loop", "")},
+		},
+		{
+			name:    "cycle without a for each node",
+			nodes:   []flow.Node{node("t", flow.NodeTypeTriggerWebhook, nil), node("a", "", nil), node("b", "", nil)},
+			edges:   []flow.Edge{edge("t", "a", ""), edge("a", "b", ""), edge("b", "a", "")},
+			wantErr: "a → b → a form a cycle",
+		},
+		{
+			name:    "cycle through the done output",
+			nodes:   []flow.Node{node("t", flow.NodeTypeTriggerWebhook, nil), loop, node("a", "", nil)},
+			edges:   []flow.Edge{edge("t", "loop", ""), edge("loop", "a", "done"), edge("a", "loop", "")},
+			wantErr: "form a cycle",
+		},
+		{
+			name:    "for each without items",
+			nodes:   []flow.Node{node("loop", flow.NodeTypeForEach, nil)},
+			wantErr: "items variable is required",
+		},
+		{
+			name:    "for each edge without an output",
+			nodes:   []flow.Node{loop, node("a", "", nil)},
+			edges:   []flow.Edge{edge("loop", "a", "")},
+			wantErr: "body or done output",
+		},
+		{
+			name:  "switch cases",
+			nodes: []flow.Node{route, node("a", "", nil), node("b", "", nil)},
+			edges: []flow.Edge{edge("route", "a", "one"), edge("route", "b", "default")},
+		},
+		{
+			name:    "switch edge from an unknown case",
+			nodes:   []flow.Node{route, node("a", "", nil)},
+			edges:   []flow.Edge{edge("route", "a", "two")},
+			wantErr: "from a case or the default output",
+		},
+		{
+			name: "switch with duplicate cases",
+			nodes: []flow.Node{node("route", flow.NodeTypeSwitch, map[string]interface{}{
+				"cases": []interface{}{map[string]interface{}{"name": "one"}, map[string]interface{}{"name": "one"}},
+			})},
+			wantErr: "case names must be unique",
+		},
+		{
+			name:    "delay in a parallel branch",
+			nodes:   []flow.Node{node("fan", flow.NodeTypeParallel, nil), node("a", "", nil), node("wait", flow.NodeTypeDelay, nil)},
+			edges:   []flow.Edge{edge("fan", "a", "branch"), edge("a", "wait", "")},
+			wantErr: "cannot run in a parallel branch",
+		},
+		{
+			name:    "edge to a missing node",
+			nodes:   []flow.Node{node("a", "", nil)},
+			edges:   []flow.Edge{edge("a", "gone", "")},
+			wantErr: "does not exist",
+		},
+		{
+			// Each doubled edge runs everything after it twice
+			name: "too many steps",
+			nodes: []flow.Node{node("n0", "", nil), node("n1", "", nil), node("n2", "", nil), node("n3", "", nil),
+				node("n4", "", nil), node("n5", "", nil)},
+			edges: []flow.Edge{
+				edge("n0", "n1", ""), edge("n0", "n1", ""), edge("n1", "n2", ""), edge("n1", "n2", ""),
+				edge("n2", "n3", ""), edge("n2", "n3", ""), edge("n3", "n4", ""), edge("n3", "n4", ""),
+				edge("n4", "n5", ""), edge("n4", "n5", ""),
+			},
+			wantErr: "more than the limit of 50 steps",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGraph(tt.nodes, tt.edges, 50)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateGraph() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateGraph() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
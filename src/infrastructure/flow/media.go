@@ -0,0 +1,157 @@
+package flow
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/google/uuid"
+)
+
+// mediaDir holds files uploaded for send_image and send_file nodes
+func mediaDir() string {
+	return filepath.Join(config.PathStorages, "flow_media")
+}
+
+// SaveMedia stores an uploaded file for use by send nodes and returns its ID
+func SaveMedia(data []byte, fileName string) (string, error) {
+	if err := os.MkdirAll(mediaDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create media directory: %w", err)
+	}
+	id := uuid.New().String() + strings.ToLower(filepath.Ext(fileName))
+	if err := os.WriteFile(filepath.Join(mediaDir(), id), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save media: %w", err)
+	}
+	return id, nil
+}
+
+// receivedMediaPrefix marks media received with trigger messages, which the execution pruner
+// deletes with the runs that used them
+const receivedMediaPrefix = "received-"
+
+// SaveReceivedMedia stores media received with a message for the flows it triggers and returns its ID
+func SaveReceivedMedia(data []byte, fileName string) (string, error) {
+	id, err := SaveMedia(data, fileName)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(filepath.Join(mediaDir(), id), filepath.Join(mediaDir(), receivedMediaPrefix+id)); err != nil {
+		return "", fmt.Errorf("failed to save media: %w", err)
+	}
+	return receivedMediaPrefix + id, nil
+}
+
+// pruneReceivedMedia deletes received media older than olderThan
+func pruneReceivedMedia(olderThan time.Time) (int, error) {
+	entries, err := os.ReadDir(mediaDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	deleted := 0
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), receivedMediaPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(olderThan) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(mediaDir(), entry.Name())); err == nil {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// LoadMedia reads a file stored by SaveMedia
+func LoadMedia(id string) ([]byte, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid media file %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(mediaDir(), id))
+	if err != nil {
+		return nil, fmt.Errorf("media file %q not found", id)
+	}
+	return data, nil
+}
+
+// resolveMedia loads the media of a send_image or send_file node from, in order of precedence,
+// its URL, a variable holding a URL, data URL or base64 content, or an uploaded file
+func (e *FlowExecutor) resolveMedia(execCtx *ExecutionContext, data map[string]interface{}) (*flow.OutgoingMedia, error) {
+	fileName, _ := data["file_name"].(string)
+	media := &flow.OutgoingMedia{FileName: e.interpolateVariables(fileName, execCtx.Variables)}
+
+	mediaURL, _ := data["media_url"].(string)
+	variable, _ := data["media_variable"].(string)
+	mediaFile, _ := data["media_file"].(string)
+
+	switch {
+	case strings.TrimSpace(mediaURL) != "":
+		media.URL = strings.TrimSpace(e.interpolateVariables(mediaURL, execCtx.Variables))
+
+	case strings.TrimSpace(variable) != "":
+		name := strings.Trim(strings.TrimSpace(variable), "{} ")
+		value, _ := e.getNestedValue(execCtx.Variables, name).(string)
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("variable %q holds no media", name)
+		}
+		if err := decodeMediaValue(media, value); err != nil {
+			return nil, fmt.Errorf("variable %q: %w", name, err)
+		}
+
+	case mediaFile != "":
+		content, err := LoadMedia(mediaFile)
+		if err != nil {
+			return nil, err
+		}
+		media.Data = content
+		if media.FileName == "" {
+			media.FileName = mediaFile
+		}
+
+	default:
+		return nil, fmt.Errorf("no media configured")
+	}
+
+	if media.URL == "" && media.MIMEType == "" {
+		media.MIMEType = http.DetectContentType(media.Data)
+	}
+	if media.URL != "" && media.FileName == "" {
+		media.FileName = filepath.Base(strings.SplitN(media.URL, "?", 2)[0])
+	}
+	return media, nil
+}
+
+// decodeMediaValue reads media given as an http(s) URL, a data URL or base64 content
+func decodeMediaValue(media *flow.OutgoingMedia, value string) error {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		media.URL = value
+		return nil
+	}
+
+	if strings.HasPrefix(value, "data:") {
+		header, payload, ok := strings.Cut(value[len("data:"):], ",")
+		if !ok || !strings.HasSuffix(header, ";base64") {
+			return fmt.Errorf("unsupported data URL")
+		}
+		media.MIMEType = strings.TrimSuffix(header, ";base64")
+		value = payload
+	}
+
+	content, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("media is not a URL or base64 content")
+	}
+	media.Data = content
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/encryption"
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -41,9 +42,27 @@ func (r *SQLiteRepository) migrate() error {
 			nodes TEXT DEFAULT '[]',
 			edges TEXT DEFAULT '[]',
 			variables TEXT DEFAULT '[]',
+			version INTEGER DEFAULT 0,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS flow_versions (
+			flow_id TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			nodes TEXT NOT NULL,
+			edges TEXT NOT NULL,
+			variables TEXT NOT NULL,
+			note TEXT DEFAULT '',
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (flow_id, version)
+		)`,
+		`CREATE TABLE IF NOT EXISTS flow_drafts (
+			flow_id TEXT PRIMARY KEY,
+			nodes TEXT NOT NULL,
+			edges TEXT NOT NULL,
+			variables TEXT NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
 		`CREATE TABLE IF NOT EXISTS credentials (
 			id TEXT PRIMARY KEY,
 			agent_id TEXT NOT NULL,
@@ -53,6 +72,42 @@ func (r *SQLiteRepository) migrate() error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS flow_schedules (
+			flow_id TEXT NOT NULL,
+			node_id TEXT NOT NULL,
+			last_run_at DATETIME NOT NULL,
+			PRIMARY KEY (flow_id, node_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS flow_executions (
+			id TEXT PRIMARY KEY,
+			flow_id TEXT NOT NULL,
+			agent_id TEXT NOT NULL,
+			trigger_node_id TEXT DEFAULT '',
+			trigger_type TEXT DEFAULT '',
+			status TEXT NOT NULL,
+			input TEXT DEFAULT '{}',
+			output TEXT DEFAULT '{}',
+			error TEXT DEFAULT '',
+			trace TEXT DEFAULT '[]',
+			flow_version INTEGER DEFAULT 0,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME,
+			duration_ms INTEGER DEFAULT 0
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_flow_executions_flow ON flow_executions(flow_id, started_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_flow_executions_started ON flow_executions(started_at)`,
+		`CREATE TABLE IF NOT EXISTS flow_waits (
+			execution_id TEXT PRIMARY KEY,
+			flow_id TEXT NOT NULL,
+			node_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			contact_key TEXT DEFAULT '',
+			resume_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			state TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_flow_waits_resume_at ON flow_waits(resume_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_flow_waits_contact ON flow_waits(contact_key)`,
 		`CREATE INDEX IF NOT EXISTS idx_flows_agent_id ON flows(agent_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_credentials_agent_id ON credentials(agent_id)`,
 	}
@@ -63,6 +118,26 @@ func (r *SQLiteRepository) migrate() error {
 		}
 	}
 
+	// Safe migrations for existing tables (ignore errors if columns already exist)
+	safeMigrations := []string{
+		`ALTER TABLE flows ADD COLUMN version INTEGER DEFAULT 0`,
+		`ALTER TABLE flow_executions ADD COLUMN flow_version INTEGER DEFAULT 0`,
+	}
+	for _, query := range safeMigrations {
+		r.db.Exec(query) // Ignore errors (column may already exist)
+	}
+
+	// Flows saved before versioning become their version 1
+	if _, err := r.db.Exec(
+		`INSERT OR IGNORE INTO flow_versions (flow_id, version, nodes, edges, variables, note, created_at)
+		SELECT id, 1, nodes, edges, variables, 'Published before versioning', updated_at FROM flows WHERE version = 0`,
+	); err != nil {
+		return fmt.Errorf("failed to execute migration: %w", err)
+	}
+	if _, err := r.db.Exec(`UPDATE flows SET version = 1 WHERE version = 0`); err != nil {
+		return fmt.Errorf("failed to execute migration: %w", err)
+	}
+
 	return nil
 }
 
@@ -95,12 +170,31 @@ func (r *SQLiteRepository) CreateFlow(ctx context.Context, f *flow.Flow) error {
 		return fmt.Errorf("failed to marshal variables: %w", err)
 	}
 
-	_, err = r.db.ExecContext(ctx,
-		`INSERT INTO flows (id, agent_id, name, description, is_active, nodes, edges, variables, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		f.ID, f.AgentID, f.Name, f.Description, f.IsActive, string(nodesJSON), string(edgesJSON), string(variablesJSON), f.CreatedAt, f.UpdatedAt,
+	// The definition a flow is created with is its first published version
+	f.Version = 1
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO flows (id, agent_id, name, description, is_active, nodes, edges, variables, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.ID, f.AgentID, f.Name, f.Description, f.IsActive, string(nodesJSON), string(edgesJSON), string(variablesJSON), f.Version, f.CreatedAt, f.UpdatedAt,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO flow_versions (flow_id, version, nodes, edges, variables, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		f.ID, f.Version, string(nodesJSON), string(edgesJSON), string(variablesJSON), "Created", f.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *SQLiteRepository) GetFlowByID(ctx context.Context, id string) (*flow.Flow, error) {
@@ -108,9 +202,9 @@ func (r *SQLiteRepository) GetFlowByID(ctx context.Context, id string) (*flow.Fl
 	var nodesJSON, edgesJSON, variablesJSON string
 
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, agent_id, name, description, is_active, nodes, edges, variables, created_at, updated_at
+		`SELECT id, agent_id, name, description, is_active, nodes, edges, variables, version, created_at, updated_at
 		FROM flows WHERE id = ?`, id,
-	).Scan(&f.ID, &f.AgentID, &f.Name, &f.Description, &f.IsActive, &nodesJSON, &edgesJSON, &variablesJSON, &f.CreatedAt, &f.UpdatedAt)
+	).Scan(&f.ID, &f.AgentID, &f.Name, &f.Description, &f.IsActive, &nodesJSON, &edgesJSON, &variablesJSON, &f.Version, &f.CreatedAt, &f.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("flow not found")
@@ -133,10 +227,22 @@ func (r *SQLiteRepository) GetFlowByID(ctx context.Context, id string) (*flow.Fl
 }
 
 func (r *SQLiteRepository) GetFlowsByAgentID(ctx context.Context, agentID string) ([]*flow.Flow, error) {
-	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, agent_id, name, description, is_active, nodes, edges, variables, created_at, updated_at
+	return r.queryFlows(ctx,
+		`SELECT id, agent_id, name, description, is_active, nodes, edges, variables, version, created_at, updated_at
 		FROM flows WHERE agent_id = ? ORDER BY created_at DESC`, agentID,
 	)
+}
+
+// GetActiveFlows returns the active flows of all agents, oldest first
+func (r *SQLiteRepository) GetActiveFlows(ctx context.Context) ([]*flow.Flow, error) {
+	return r.queryFlows(ctx,
+		`SELECT id, agent_id, name, description, is_active, nodes, edges, variables, version, created_at, updated_at
+		FROM flows WHERE is_active = 1 ORDER BY created_at ASC`,
+	)
+}
+
+func (r *SQLiteRepository) queryFlows(ctx context.Context, query string, args ...interface{}) ([]*flow.Flow, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +253,7 @@ func (r *SQLiteRepository) GetFlowsByAgentID(ctx context.Context, agentID string
 		f := &flow.Flow{}
 		var nodesJSON, edgesJSON, variablesJSON string
 
-		if err := rows.Scan(&f.ID, &f.AgentID, &f.Name, &f.Description, &f.IsActive, &nodesJSON, &edgesJSON, &variablesJSON, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.AgentID, &f.Name, &f.Description, &f.IsActive, &nodesJSON, &edgesJSON, &variablesJSON, &f.Version, &f.CreatedAt, &f.UpdatedAt); err != nil {
 			return nil, err
 		}
 
@@ -193,10 +299,52 @@ func (r *SQLiteRepository) UpdateFlow(ctx context.Context, f *flow.Flow) error {
 }
 
 func (r *SQLiteRepository) DeleteFlow(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM flow_schedules WHERE flow_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM flow_executions WHERE flow_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM flow_waits WHERE flow_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM flow_versions WHERE flow_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM flow_drafts WHERE flow_id = ?`, id); err != nil {
+		return err
+	}
 	_, err := r.db.ExecContext(ctx, `DELETE FROM flows WHERE id = ?`, id)
 	return err
 }
 
+// === Schedule State ===
+
+// GetScheduleLastRun returns the last scheduled time handled for a schedule trigger;
+// ok is false when the trigger has not been seen yet
+func (r *SQLiteRepository) GetScheduleLastRun(ctx context.Context, flowID, nodeID string) (lastRun time.Time, ok bool, err error) {
+	err = r.db.QueryRowContext(ctx,
+		`SELECT last_run_at FROM flow_schedules WHERE flow_id = ? AND node_id = ?`, flowID, nodeID,
+	).Scan(&lastRun)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return lastRun, true, nil
+}
+
+// SetScheduleLastRun records the last scheduled time handled for a schedule trigger
+func (r *SQLiteRepository) SetScheduleLastRun(ctx context.Context, flowID, nodeID string, lastRun time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO flow_schedules (flow_id, node_id, last_run_at) VALUES (?, ?, ?)
+		ON CONFLICT(flow_id, node_id) DO UPDATE SET last_run_at = excluded.last_run_at`,
+		flowID, nodeID, lastRun.UTC(),
+	)
+	return err
+}
+
 // === Credential CRUD ===
 
 func (r *SQLiteRepository) CreateCredential(ctx context.Context, c *flow.Credential) error {
@@ -207,14 +355,28 @@ func (r *SQLiteRepository) CreateCredential(ctx context.Context, c *flow.Credent
 	c.CreatedAt = now
 	c.UpdatedAt = now
 
-	_, err := r.db.ExecContext(ctx,
+	config, err := encryption.Encrypt(c.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
 		`INSERT INTO credentials (id, agent_id, name, type, config, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		c.ID, c.AgentID, c.Name, c.Type, c.Config, c.CreatedAt, c.UpdatedAt,
+		c.ID, c.AgentID, c.Name, c.Type, config, c.CreatedAt, c.UpdatedAt,
 	)
 	return err
 }
 
+// decryptCredential replaces the stored config of a credential with its plaintext
+func decryptCredential(c *flow.Credential) error {
+	config, err := encryption.Decrypt(c.Config)
+	if err != nil {
+		return fmt.Errorf("credential %s: %w", c.ID, err)
+	}
+	c.Config = config
+	return nil
+}
+
 func (r *SQLiteRepository) GetCredentialByID(ctx context.Context, id string) (*flow.Credential, error) {
 	c := &flow.Credential{}
 	err := r.db.QueryRowContext(ctx,
@@ -225,7 +387,13 @@ func (r *SQLiteRepository) GetCredentialByID(ctx context.Context, id string) (*f
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("credential not found")
 	}
-	return c, err
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptCredential(c); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (r *SQLiteRepository) GetCredentialsByAgentID(ctx context.Context, agentID string) ([]*flow.Credential, error) {
@@ -244,6 +412,9 @@ func (r *SQLiteRepository) GetCredentialsByAgentID(ctx context.Context, agentID
 		if err := rows.Scan(&c.ID, &c.AgentID, &c.Name, &c.Type, &c.Config, &c.CreatedAt, &c.UpdatedAt); err != nil {
 			return nil, err
 		}
+		if err := decryptCredential(c); err != nil {
+			return nil, err
+		}
 		credentials = append(credentials, c)
 	}
 	return credentials, rows.Err()
@@ -251,9 +422,13 @@ func (r *SQLiteRepository) GetCredentialsByAgentID(ctx context.Context, agentID
 
 func (r *SQLiteRepository) UpdateCredential(ctx context.Context, c *flow.Credential) error {
 	c.UpdatedAt = time.Now()
-	_, err := r.db.ExecContext(ctx,
+	config, err := encryption.Encrypt(c.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
 		`UPDATE credentials SET name=?, config=?, updated_at=? WHERE id=?`,
-		c.Name, c.Config, c.UpdatedAt, c.ID,
+		c.Name, config, c.UpdatedAt, c.ID,
 	)
 	return err
 }
@@ -263,6 +438,12 @@ func (r *SQLiteRepository) DeleteCredential(ctx context.Context, id string) erro
 	return err
 }
 
+// EncryptSecrets encrypts credential configs stored in plaintext or with an older master key,
+// returning how many were rewritten
+func (r *SQLiteRepository) EncryptSecrets(ctx context.Context) (int, error) {
+	return encryption.RewriteColumns(ctx, r.db, "credentials", "id", "config")
+}
+
 
 
 
@@ -0,0 +1,246 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+	"github.com/sirupsen/logrus"
+)
+
+// resumeCheckInterval is how often paused runs are checked for a passed delay or timeout
+const resumeCheckInterval = 30 * time.Second
+
+// pause saves a run stopped by a delay or wait for reply node
+func (e *FlowExecutor) pause(ctx context.Context, execCtx *ExecutionContext) error {
+	if e.flowRepo == nil {
+		return fmt.Errorf("runs cannot pause without flow storage")
+	}
+	w := execCtx.wait
+	w.ExecutionID = execCtx.ExecutionID
+	w.FlowID = execCtx.Flow.ID
+	w.State = executionState{
+		Input:     execCtx.Input,
+		Variables: execCtx.Variables,
+		Output:    execCtx.Output,
+		Trace:     execCtx.Trace,
+		Pending:   execCtx.Pending,
+		Loops:     execCtx.loops,
+		Steps:     execCtx.steps.Load(),
+	}
+	if execCtx.Flow.Version == 0 {
+		w.State.Definition = &flow.FlowDraft{Nodes: execCtx.Flow.Nodes, Edges: execCtx.Flow.Edges, Variables: execCtx.Flow.Variables}
+	}
+	if err := e.flowRepo.saveWait(context.WithoutCancel(ctx), w); err != nil {
+		return fmt.Errorf("failed to save paused run: %w", err)
+	}
+
+	// Resume short waits on time instead of at the next check
+	if wait := time.Until(w.ResumeAt); wait < resumeCheckInterval {
+		time.AfterFunc(wait, func() {
+			if _, _, err := e.resumeWait(context.Background(), w.ExecutionID, nil); err != nil {
+				logrus.Warnf("⚠️  [Flow] Run %s of flow %s failed after resuming: %v", w.ExecutionID, w.FlowID, err)
+			}
+		})
+	}
+	logrus.Infof("⏸️  [Flow] Run %s of flow %s paused at node %s until %s", w.ExecutionID, w.FlowID, w.NodeID, w.ResumeAt.Format(time.RFC3339))
+	return nil
+}
+
+// ResumeDue resumes the paused runs whose delay has passed or whose wait for a reply timed out,
+// and returns how many were resumed
+func (e *FlowExecutor) ResumeDue(ctx context.Context, now time.Time) int {
+	if e.flowRepo == nil {
+		return 0
+	}
+	ids, err := e.flowRepo.dueWaits(ctx, now)
+	if err != nil {
+		logrus.Errorf("❌ Flow resumer: Failed to get paused runs: %v", err)
+		return 0
+	}
+
+	resumed := 0
+	for _, id := range ids {
+		_, claimed, err := e.resumeWait(ctx, id, nil)
+		if claimed {
+			resumed++
+		}
+		if err != nil {
+			logrus.Warnf("⚠️  Flow resumer: Run %s failed after resuming: %v", id, err)
+		}
+	}
+	return resumed
+}
+
+// ResumeReply resumes the runs waiting for a reply from sender on the integration with message as
+// the reply. It returns how many runs were resumed, and the messages they addressed to the sender.
+func (e *FlowExecutor) ResumeReply(ctx context.Context, integrationID, sender string, message map[string]interface{}) (int, []string, error) {
+	if e.flowRepo == nil {
+		return 0, nil, nil
+	}
+	ids, err := e.flowRepo.contactWaits(ctx, ContactKey(integrationID, sender))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get waiting runs: %w", err)
+	}
+
+	resumed := 0
+	var replies []string
+	for _, id := range ids {
+		result, claimed, err := e.resumeWait(ctx, id, message)
+		if claimed {
+			resumed++
+		}
+		if err != nil {
+			logrus.Warnf("⚠️  [Flow] Run %s failed after resuming on a reply from %s: %v", id, sender, err)
+			continue
+		}
+		if result != nil {
+			replies = append(replies, result.Replies...)
+		}
+	}
+	return resumed, replies, nil
+}
+
+// resumeWait continues a paused run, with reply as the message a reply wait received or nil when
+// the delay passed or the wait timed out. claimed is false when the run was already resumed.
+func (e *FlowExecutor) resumeWait(ctx context.Context, executionID string, reply map[string]interface{}) (result *ExecutionResult, claimed bool, err error) {
+	w, err := e.flowRepo.claimWait(ctx, executionID)
+	if err != nil || w == nil {
+		return nil, false, err
+	}
+
+	execution, err := e.flowRepo.GetExecution(ctx, w.ExecutionID)
+	if err != nil {
+		logrus.Warnf("⚠️  [Flow] Resuming run %s without its execution record: %v", w.ExecutionID, err)
+		execution = nil
+	}
+	execCtx := &ExecutionContext{
+		ExecutionID: w.ExecutionID,
+		Variables:   w.State.Variables,
+		Input:       w.State.Input,
+		Output:      w.State.Output,
+		Credentials: make(map[string]*flow.Credential),
+		Trace:       w.State.Trace,
+		Pending:     w.State.Pending,
+		loops:       w.State.Loops,
+		steps:       new(atomic.Int64),
+	}
+	execCtx.steps.Store(w.State.Steps)
+	if execCtx.Variables == nil {
+		execCtx.Variables = make(map[string]interface{})
+	}
+	if execCtx.Output == nil {
+		execCtx.Output = make(map[string]interface{})
+	}
+
+	// The flow may have gone or been turned off while the run waited. When another version was
+	// published meanwhile, the run continues on the version it started with.
+	f, err := e.flowRepo.GetFlowByID(ctx, w.FlowID)
+	if err == nil && !f.IsActive {
+		err = fmt.Errorf("flow %s is not active", f.Name)
+	}
+	switch {
+	case err != nil:
+	case w.State.Definition != nil:
+		f.Nodes, f.Edges, f.Variables, f.Version = w.State.Definition.Nodes, w.State.Definition.Edges, w.State.Definition.Variables, 0
+	case execution != nil && execution.FlowVersion > 0 && execution.FlowVersion != f.Version:
+		var v *flow.FlowVersion
+		if v, err = e.flowRepo.GetFlowVersion(ctx, f.ID, execution.FlowVersion); err == nil {
+			f.Nodes, f.Edges, f.Variables, f.Version = v.Nodes, v.Edges, v.Variables, v.Version
+		}
+	}
+	var node *flow.Node
+	if err == nil {
+		execCtx.Flow = f
+		if node = e.findNode(f.Nodes, w.NodeID); node == nil {
+			err = fmt.Errorf("node %s was removed from the flow", w.NodeID)
+		}
+	}
+	if err != nil {
+		_, err = e.settle(ctx, execCtx, execution, err)
+		return nil, true, err
+	}
+
+	logrus.Infof("▶️  [Flow] Resuming run %s of flow %s (%s) at node %s", w.ExecutionID, f.ID, f.Name, node.ID)
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{FlowID: f.ID, FlowExecutionID: w.ExecutionID})
+
+	output := waitOutput(w, execCtx, reply)
+	trace := flow.NodeTrace{
+		NodeID:     node.ID,
+		NodeType:   node.Type,
+		Label:      node.Label,
+		Input:      copyVariables(execCtx.Variables),
+		Output:     copyVariables(output),
+		StartedAt:  w.CreatedAt,
+		DurationMs: time.Since(w.CreatedAt).Milliseconds(),
+	}
+	trace.Handle, _ = output["_handle"].(string)
+	execCtx.Trace = append(execCtx.Trace, trace)
+	e.advance(execCtx, node.ID, output)
+
+	result, err = e.settle(ctx, execCtx, execution, e.runPending(ctx, execCtx))
+	return result, true, err
+}
+
+// waitOutput is the output of the node a run waited at
+func waitOutput(w *waitingRun, execCtx *ExecutionContext, reply map[string]interface{}) map[string]interface{} {
+	if w.Kind != waitKindReply {
+		return execCtx.Variables
+	}
+	if reply == nil {
+		return map[string]interface{}{
+			"reply":     "",
+			"timed_out": true,
+			"_handle":   flow.WaitHandleTimeout,
+		}
+	}
+	text, _ := reply["message"].(string)
+	return map[string]interface{}{
+		"reply":         text,
+		"reply_message": reply,
+		"timed_out":     false,
+		"_handle":       flow.WaitHandleReply,
+	}
+}
+
+// FlowResumer resumes paused runs when their delay passes or their wait for a reply times out
+type FlowResumer struct {
+	executor      *FlowExecutor
+	checkInterval time.Duration
+	stopChan      chan struct{}
+}
+
+// NewFlowResumer creates a new flow resumer
+func NewFlowResumer(executor *FlowExecutor) *FlowResumer {
+	return &FlowResumer{
+		executor:      executor,
+		checkInterval: resumeCheckInterval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start resumes due runs, including those left by a previous process, until Stop is called
+func (r *FlowResumer) Start(ctx context.Context) {
+	logrus.Info("⏯️  Flow resumer started")
+	ticker := time.NewTicker(r.checkInterval)
+	defer ticker.Stop()
+
+	r.executor.ResumeDue(ctx, time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			r.executor.ResumeDue(ctx, time.Now())
+		case <-r.stopChan:
+			logrus.Info("🛑 Flow resumer stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the flow resumer
+func (r *FlowResumer) Stop() {
+	close(r.stopChan)
+}
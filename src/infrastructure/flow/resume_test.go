@@ -0,0 +1,145 @@
+package flow
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestDelayResumesAfterRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "flows.db")
+	repo, err := NewSQLiteRepository(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	ctx := context.Background()
+
+	f := &flow.Flow{
+		AgentID:  "agent-1",
+		Name:     "reminder",
+		IsActive: true,
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "wait", Type: flow.NodeTypeDelay, Data: map[string]interface{}{"duration": float64(2), "unit": "hours"}},
+			{ID: "after", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "greeting", "value": "Hi {{name}}"}},
+			{ID: "sibling", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "sibling", "value": "done"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "wait"},
+			{ID: "e2", Source: "wait", Target: "after"},
+			{ID: "e3", Source: "trigger", Target: "sibling"},
+		},
+	}
+	if err := repo.CreateFlow(ctx, f); err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+
+	result, err := NewFlowExecutor(repo).Run(ctx, f, "", map[string]interface{}{"name": "Ana"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.Waiting {
+		t.Fatalf("Run() = %+v, want a waiting run", result)
+	}
+	if execution, _ := repo.GetExecution(ctx, result.ExecutionID); execution.Status != flow.ExecutionStatusWaiting || execution.FinishedAt != nil {
+		t.Fatalf("execution = %+v, want waiting", execution)
+	}
+
+	// A new process picks the run up once the delay has passed
+	repo, err = NewSQLiteRepository(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	executor := NewFlowExecutor(repo)
+	if n := executor.ResumeDue(ctx, time.Now()); n != 0 {
+		t.Fatalf("ResumeDue() before the delay resumed %d runs", n)
+	}
+	if n := executor.ResumeDue(ctx, time.Now().Add(3*time.Hour)); n != 1 {
+		t.Fatalf("ResumeDue() after the delay resumed %d runs, want 1", n)
+	}
+	if n := executor.ResumeDue(ctx, time.Now().Add(3*time.Hour)); n != 0 {
+		t.Fatalf("ResumeDue() resumed a run twice")
+	}
+
+	execution, err := repo.GetExecution(ctx, result.ExecutionID)
+	if err != nil {
+		t.Fatalf("GetExecution() error = %v", err)
+	}
+	if execution.Status != flow.ExecutionStatusSuccess || execution.Output["sibling"] != "done" {
+		t.Fatalf("execution = %+v", execution)
+	}
+	var order []string
+	for _, step := range execution.Trace {
+		order = append(order, step.NodeID)
+	}
+	if got := strings.Join(order, ","); got != "trigger,wait,after,sibling" {
+		t.Fatalf("trace order = %s, want trigger,wait,after,sibling", got)
+	}
+	if greeting := execution.Trace[3].Input["greeting"]; greeting != "Hi Ana" {
+		t.Fatalf("greeting after resuming = %v, want Hi Ana", greeting)
+	}
+}
+
+func TestWaitForReply(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	executor := NewFlowExecutor(repo)
+	ctx := context.Background()
+
+	f := &flow.Flow{
+		AgentID:  "agent-1",
+		Name:     "survey",
+		IsActive: true,
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWhatsApp},
+			{ID: "wait", Type: flow.NodeTypeWaitForReply, Data: map[string]interface{}{"timeout": float64(1), "unit": "hours"}},
+			{ID: "answered", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "answer", "value": "{{reply}}"}},
+			{ID: "silent", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "answer", "value": "none"}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "wait"},
+			{ID: "e2", Source: "wait", Target: "answered", SourceHandle: flow.WaitHandleReply},
+			{ID: "e3", Source: "wait", Target: "silent", SourceHandle: flow.WaitHandleTimeout},
+		},
+	}
+	if err := repo.CreateFlow(ctx, f); err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+	input := map[string]interface{}{"integration_id": "wa-1", "sender": "628999@s.whatsapp.net"}
+
+	replied, err := executor.Run(ctx, f, "", input)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if n, _, err := executor.ResumeReply(ctx, "wa-2", "628999@s.whatsapp.net", map[string]interface{}{"message": "yes"}); err != nil || n != 0 {
+		t.Fatalf("ResumeReply() on another integration = %d, %v", n, err)
+	}
+	if n, _, err := executor.ResumeReply(ctx, "wa-1", "+628999", map[string]interface{}{"message": "yes"}); err != nil || n != 1 {
+		t.Fatalf("ResumeReply() = %d, %v, want 1 run resumed", n, err)
+	}
+	execution, _ := repo.GetExecution(ctx, replied.ExecutionID)
+	if execution.Status != flow.ExecutionStatusSuccess || execution.Output["answer"] != "yes" || execution.Trace[1].Handle != flow.WaitHandleReply {
+		t.Fatalf("execution after the reply = %+v", execution)
+	}
+
+	timedOut, err := executor.Run(ctx, f, "", input)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if n := executor.ResumeDue(ctx, time.Now().Add(2*time.Hour)); n != 1 {
+		t.Fatalf("ResumeDue() after the timeout resumed %d runs, want 1", n)
+	}
+	execution, _ = repo.GetExecution(ctx, timedOut.ExecutionID)
+	if execution.Output["answer"] != "none" || execution.Trace[1].Handle != flow.WaitHandleTimeout {
+		t.Fatalf("execution after the timeout = %+v", execution)
+	}
+	if n, _, _ := executor.ResumeReply(ctx, "wa-1", "628999", map[string]interface{}{"message": "late"}); n != 0 {
+		t.Fatalf("ResumeReply() after the timeout resumed %d runs", n)
+	}
+}
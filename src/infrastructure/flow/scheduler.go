@@ -0,0 +1,215 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// scheduleCheckInterval is how often schedule triggers are checked; cron has minute resolution
+	scheduleCheckInterval = 30 * time.Second
+	// missedRunGrace is how late a scheduled time may be found before it counts as missed
+	missedRunGrace = 2 * time.Minute
+	// maxMissedRuns bounds the count of missed times after a long downtime
+	maxMissedRuns = 10000
+)
+
+// ParseSchedule parses the cron expression and timezone of a schedule trigger; the timezone defaults to UTC
+func ParseSchedule(cron, timezone string) (*utils.CronSchedule, *time.Location, error) {
+	schedule, err := utils.ParseCron(cron)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc := time.UTC
+	if timezone = strings.TrimSpace(timezone); timezone != "" {
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, nil, fmt.Errorf("unknown timezone %q", timezone)
+		}
+	}
+	return schedule, loc, nil
+}
+
+// FlowScheduler runs active flows from their schedule triggers
+type FlowScheduler struct {
+	repo          *SQLiteRepository
+	executor      *FlowExecutor
+	checkInterval time.Duration
+	stopChan      chan struct{}
+
+	mu      sync.Mutex
+	running map[string]bool   // Flows with a scheduled run in progress
+	invalid map[string]string // Invalid schedules already logged, by flow/node
+}
+
+// NewFlowScheduler creates a new flow scheduler
+func NewFlowScheduler(repo *SQLiteRepository, executor *FlowExecutor) *FlowScheduler {
+	return &FlowScheduler{
+		repo:          repo,
+		executor:      executor,
+		checkInterval: scheduleCheckInterval,
+		stopChan:      make(chan struct{}),
+		running:       make(map[string]bool),
+		invalid:       make(map[string]string),
+	}
+}
+
+// Start checks schedule triggers until Stop is called
+func (s *FlowScheduler) Start(ctx context.Context) {
+	logrus.Info("⏰ Flow scheduler started")
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	s.runDue(ctx, time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			s.runDue(ctx, time.Now())
+		case <-s.stopChan:
+			logrus.Info("🛑 Flow scheduler stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the flow scheduler
+func (s *FlowScheduler) Stop() {
+	close(s.stopChan)
+}
+
+// runDue starts the flows whose schedule triggers are due at now
+func (s *FlowScheduler) runDue(ctx context.Context, now time.Time) {
+	flows, err := s.repo.GetActiveFlows(ctx)
+	if err != nil {
+		logrus.Errorf("❌ Flow scheduler: Failed to get flows: %v", err)
+		return
+	}
+	for _, f := range flows {
+		for i := range f.Nodes {
+			if f.Nodes[i].Type == flow.NodeTypeTriggerSchedule {
+				s.checkTrigger(ctx, f, &f.Nodes[i], now)
+			}
+		}
+	}
+}
+
+// lastScheduled returns the last time of schedule at or before now, searching back from now over
+// growing windows. It returns fallback when none is found within the five years Next looks ahead.
+func lastScheduled(schedule *utils.CronSchedule, loc *time.Location, now, fallback time.Time) time.Time {
+	windows := []time.Duration{time.Minute, time.Hour, 24 * time.Hour, 7 * 24 * time.Hour, 31 * 24 * time.Hour, 366 * 24 * time.Hour, 5 * 366 * 24 * time.Hour}
+	for _, window := range windows {
+		last := schedule.Next(now.Add(-window).In(loc))
+		if last.IsZero() || last.After(now) {
+			continue
+		}
+		for next := schedule.Next(last); !next.IsZero() && !next.After(now); next = schedule.Next(last) {
+			last = next
+		}
+		return last
+	}
+	return fallback
+}
+
+// checkTrigger runs f from node when a scheduled time has passed since the last one handled.
+// Times found later than missedRunGrace (the server was down or busy) are missed: they are
+// skipped, or with catch_up run once for all of them.
+func (s *FlowScheduler) checkTrigger(ctx context.Context, f *flow.Flow, node *flow.Node, now time.Time) {
+	cron, _ := node.Data["cron"].(string)
+	timezone, _ := node.Data["timezone"].(string)
+	missedRuns, _ := node.Data["missed_runs"].(string)
+
+	key := f.ID + "/" + node.ID
+	schedule, loc, err := ParseSchedule(cron, timezone)
+	if err != nil {
+		s.mu.Lock()
+		logged := s.invalid[key] == cron+"|"+timezone
+		s.invalid[key] = cron + "|" + timezone
+		s.mu.Unlock()
+		if !logged {
+			logrus.Warnf("⚠️  Flow scheduler: Invalid schedule on flow %s (%s) node %s: %v", f.ID, f.Name, node.ID, err)
+		}
+		return
+	}
+
+	lastRun, seen, err := s.repo.GetScheduleLastRun(ctx, f.ID, node.ID)
+	if err != nil {
+		logrus.Errorf("❌ Flow scheduler: Failed to get last run of flow %s: %v", f.ID, err)
+		return
+	}
+	if !seen {
+		// New schedules start counting from now
+		if err := s.repo.SetScheduleLastRun(ctx, f.ID, node.ID, now); err != nil {
+			logrus.Errorf("❌ Flow scheduler: Failed to record schedule of flow %s: %v", f.ID, err)
+		}
+		return
+	}
+
+	due := schedule.Next(lastRun.In(loc))
+	if due.IsZero() || due.After(now) {
+		return
+	}
+	missed, capped := 0, false
+	for {
+		next := schedule.Next(due)
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		if missed == maxMissedRuns {
+			// Too many to count one by one; later checks start from the last one
+			due, capped = lastScheduled(schedule, loc, now, due), true
+			break
+		}
+		due = next
+		missed++
+	}
+	late := now.Sub(due) > missedRunGrace
+	if late {
+		missed++
+	}
+
+	if err := s.repo.SetScheduleLastRun(ctx, f.ID, node.ID, due); err != nil {
+		logrus.Errorf("❌ Flow scheduler: Failed to record run of flow %s: %v", f.ID, err)
+		return
+	}
+	if late && missedRuns != flow.MissedRunsCatchUp {
+		if capped {
+			logrus.Infof("⏭️  Flow scheduler: Skipped more than %d missed runs of flow %s (%s)", maxMissedRuns, f.ID, f.Name)
+		} else {
+			logrus.Infof("⏭️  Flow scheduler: Skipped %d missed runs of flow %s (%s)", missed, f.ID, f.Name)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	if s.running[f.ID] {
+		s.mu.Unlock()
+		logrus.Warnf("⚠️  Flow scheduler: Flow %s (%s) is still running, skipping the run due at %s", f.ID, f.Name, due.Format(time.RFC3339))
+		return
+	}
+	s.running[f.ID] = true
+	s.mu.Unlock()
+
+	input := map[string]interface{}{
+		"trigger":      "schedule",
+		"scheduled_at": due.Format(time.RFC3339),
+		"timezone":     loc.String(),
+		"missed_runs":  missed,
+	}
+	logrus.Infof("⏰ Flow scheduler: Running flow %s (%s) scheduled at %s", f.ID, f.Name, due.Format(time.RFC3339))
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, f.ID)
+			s.mu.Unlock()
+		}()
+		if _, err := s.executor.Run(ctx, f, node.ID, input); err != nil {
+			logrus.Warnf("⚠️  Flow scheduler: Flow %s (%s) failed: %v", f.ID, f.Name, err)
+		}
+	}()
+}
@@ -0,0 +1,162 @@
+package flow
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+)
+
+// notifyingSender reports each message on a channel, blocking until it is received
+type notifyingSender struct {
+	sent chan flow.OutgoingMessage
+}
+
+func (n *notifyingSender) Send(ctx context.Context, msg flow.OutgoingMessage) (*flow.SentMessage, error) {
+	n.sent <- msg
+	return &flow.SentMessage{MessageID: "1"}, nil
+}
+
+func TestFlowSchedulerCheckTrigger(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	executor := NewFlowExecutor(repo)
+	sender := &notifyingSender{sent: make(chan flow.OutgoingMessage)}
+	executor.SetMessageSender(sender)
+	scheduler := NewFlowScheduler(repo, executor)
+	ctx := context.Background()
+
+	newFlow := func(missedRuns string) (*flow.Flow, *flow.Node) {
+		f := &flow.Flow{
+			AgentID:  "agent-1",
+			IsActive: true,
+			Nodes: []flow.Node{
+				{ID: "schedule", Type: flow.NodeTypeTriggerSchedule, Data: map[string]interface{}{"cron": "0 9 * * *", "timezone": "Asia/Jakarta", "missed_runs": missedRuns}},
+				{ID: "send", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"integration_id": "wa-1", "recipient": "628111", "message": "Report for {{scheduled_at}}"}},
+			},
+			Edges: []flow.Edge{{ID: "e1", Source: "schedule", Target: "send"}},
+		}
+		if err := repo.CreateFlow(ctx, f); err != nil {
+			t.Fatalf("CreateFlow() error = %v", err)
+		}
+		return f, &f.Nodes[0]
+	}
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+	at := func(day, hour, minute int) time.Time { return time.Date(2025, 3, day, hour, minute, 0, 0, jakarta) }
+	lastRun := func(f *flow.Flow) time.Time {
+		last, _, _ := repo.GetScheduleLastRun(ctx, f.ID, "schedule")
+		return last
+	}
+	expectRun := func(want string) {
+		t.Helper()
+		select {
+		case msg := <-sender.sent:
+			if msg.Text != want {
+				t.Fatalf("sent %q, want %q", msg.Text, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("flow did not run")
+		}
+	}
+	waitIdle := func(f *flow.Flow) {
+		for i := 0; i < 500; i++ {
+			scheduler.mu.Lock()
+			running := scheduler.running[f.ID]
+			scheduler.mu.Unlock()
+			if !running {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("flow still running")
+	}
+
+	f, node := newFlow(flow.MissedRunsSkip)
+
+	// A new schedule starts counting from now
+	scheduler.checkTrigger(ctx, f, node, at(14, 8, 0))
+	if !lastRun(f).Equal(at(14, 8, 0)) {
+		t.Fatalf("last run = %v", lastRun(f))
+	}
+
+	// Not due yet
+	scheduler.checkTrigger(ctx, f, node, at(14, 8, 59))
+
+	// Due; a second check while it runs does not start another run
+	scheduler.checkTrigger(ctx, f, node, at(14, 9, 0))
+	scheduler.checkTrigger(ctx, f, node, at(15, 9, 0))
+	expectRun("Report for 2025-03-14T09:00:00+07:00")
+	waitIdle(f)
+	if !lastRun(f).Equal(at(15, 9, 0)) {
+		t.Fatalf("last run = %v", lastRun(f))
+	}
+
+	// Down for three days: the missed runs are skipped
+	scheduler.checkTrigger(ctx, f, node, at(18, 12, 0))
+	if !lastRun(f).Equal(at(18, 9, 0)) {
+		t.Fatalf("last run after skipping = %v", lastRun(f))
+	}
+
+	// With catch_up they run once
+	f2, node2 := newFlow(flow.MissedRunsCatchUp)
+	scheduler.checkTrigger(ctx, f2, node2, at(14, 8, 0))
+	scheduler.checkTrigger(ctx, f2, node2, at(18, 12, 0))
+	expectRun("Report for 2025-03-18T09:00:00+07:00")
+	waitIdle(f2)
+
+	select {
+	case msg := <-sender.sent:
+		t.Fatalf("unexpected run: %q", msg.Text)
+	default:
+	}
+}
+
+func TestFlowSchedulerSkipsPastMissedRunCap(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	scheduler := NewFlowScheduler(repo, NewFlowExecutor(repo))
+	ctx := context.Background()
+
+	f := &flow.Flow{
+		AgentID:  "agent-1",
+		IsActive: true,
+		Nodes:    []flow.Node{{ID: "schedule", Type: flow.NodeTypeTriggerSchedule, Data: map[string]interface{}{"cron": "0 * * * *", "missed_runs": flow.MissedRunsSkip}}},
+	}
+	if err := repo.CreateFlow(ctx, f); err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.SetScheduleLastRun(ctx, f.ID, "schedule", start); err != nil {
+		t.Fatalf("SetScheduleLastRun() error = %v", err)
+	}
+
+	// Two years of hourly runs is over maxMissedRuns; all of them are skipped at once, so the
+	// next check has nothing left to skip
+	now := time.Date(2025, 3, 31, 0, 30, 0, 0, time.UTC)
+	scheduler.checkTrigger(ctx, f, &f.Nodes[0], now)
+	last, _, _ := repo.GetScheduleLastRun(ctx, f.ID, "schedule")
+	if want := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC); !last.Equal(want) {
+		t.Fatalf("last run = %v, want %v", last, want)
+	}
+
+	// Weekly on Mondays at 9:00; now is a Monday before 9:00
+	if due := lastScheduled(mustParseCron(t, "0 9 * * 1"), time.UTC, now, start); !due.Equal(time.Date(2025, 3, 24, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("lastScheduled() = %v", due)
+	}
+}
+
+func mustParseCron(t *testing.T, cron string) *utils.CronSchedule {
+	t.Helper()
+	schedule, _, err := ParseSchedule(cron, "")
+	if err != nil {
+		t.Fatalf("ParseSchedule(%q) error = %v", cron, err)
+	}
+	return schedule
+}
@@ -0,0 +1,466 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+const (
+	defaultSheetsBaseURL  = "https://sheets.googleapis.com"
+	defaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+	sheetsScope           = "https://www.googleapis.com/auth/spreadsheets"
+	sheetsTimeout         = 30 * time.Second
+)
+
+// serviceAccount is the key file of a Google service account
+type serviceAccount struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+type googleToken struct {
+	value   string
+	expires time.Time
+}
+
+var (
+	googleTokensMu sync.Mutex
+	googleTokens   = map[string]googleToken{} // Access tokens by service account and token URL
+)
+
+// parseServiceAccount reads a service account key file
+func parseServiceAccount(raw string) (*serviceAccount, error) {
+	var sa serviceAccount
+	if err := json.Unmarshal([]byte(raw), &sa); err != nil {
+		return nil, fmt.Errorf("invalid service account JSON: %w", err)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("service account JSON must have client_email and private_key")
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = defaultGoogleTokenURL
+	}
+
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("service account private_key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid service account private_key: %w", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("service account private_key is not an RSA key")
+	}
+	sa.key = key
+	return &sa, nil
+}
+
+// assertion returns the signed JWT that the token endpoint exchanges for an access token
+func (sa *serviceAccount) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": sa.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   sa.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sa.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// accessToken returns an access token of the service account, reusing it until shortly before it expires
+func (sa *serviceAccount) accessToken(ctx context.Context) (string, error) {
+	cacheKey := sa.ClientEmail + "|" + sa.TokenURI
+	googleTokensMu.Lock()
+	token, ok := googleTokens[cacheKey]
+	googleTokensMu.Unlock()
+	if ok && time.Now().Add(time.Minute).Before(token.expires) {
+		return token.value, nil
+	}
+
+	assertion, err := sa.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := (&http.Client{Timeout: sheetsTimeout}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("token request failed: status %d", resp.StatusCode)
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("token request failed: %s %s", result.Error, result.ErrorDescription)
+	}
+
+	token = googleToken{value: result.AccessToken, expires: time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)}
+	googleTokensMu.Lock()
+	googleTokens[cacheKey] = token
+	googleTokensMu.Unlock()
+	return token.value, nil
+}
+
+// sheetsClient calls the values API of one spreadsheet
+type sheetsClient struct {
+	baseURL       string
+	token         string
+	spreadsheetID string
+	http          *http.Client
+}
+
+// call sends a request for a range; suffix is appended to the range, as in :append
+func (c *sheetsClient) call(ctx context.Context, method, a1Range, suffix string, query url.Values, body, out interface{}) error {
+	endpoint := c.baseURL + "/v4/spreadsheets/" + url.PathEscape(c.spreadsheetID) + "/values/" + url.PathEscape(a1Range) + suffix
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		raw, _ := json.Marshal(body)
+		reqBody = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("sheets request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("sheets API: %s", apiErr.Error.Message)
+		}
+		return fmt.Errorf("sheets API: status %d", resp.StatusCode)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// get reads the values of a range
+func (c *sheetsClient) get(ctx context.Context, a1Range string) ([][]interface{}, error) {
+	var result struct {
+		Values [][]interface{} `json:"values"`
+	}
+	err := c.call(ctx, http.MethodGet, a1Range, "", nil, nil, &result)
+	return result.Values, err
+}
+
+// sheetRange returns an A1 range within a sheet, the whole sheet when a1 is empty
+func sheetRange(sheet, a1 string) string {
+	quoted := "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+	if a1 == "" {
+		return quoted
+	}
+	return quoted + "!" + a1
+}
+
+// columnName returns the letters of a column, A for 0
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// rowNumberPattern finds the first row of a range such as 'Leads'!A5:C5
+var rowNumberPattern = regexp.MustCompile(`![A-Z]+(\d+)`)
+
+// rowMap returns the cells of a row by column header
+func rowMap(headers []string, row []interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(headers))
+	for i, header := range headers {
+		if header == "" {
+			continue
+		}
+		if i < len(row) {
+			result[header] = row[i]
+		} else {
+			result[header] = ""
+		}
+	}
+	return result
+}
+
+// sheetHeaders returns the column headers in the first of rows
+func sheetHeaders(rows [][]interface{}) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	headers := make([]string, len(rows[0]))
+	for i, cell := range rows[0] {
+		headers[i] = strings.TrimSpace(formatValue(cell))
+	}
+	return headers
+}
+
+// sheetCell interpolates the template of a cell. Cells are entered as if typed, so a value from a
+// variable that would start a formula, such as a message reading =IMPORTXML(...), is kept as text.
+func (e *FlowExecutor) sheetCell(template string, vars map[string]interface{}) string {
+	value := e.interpolateVariables(template, vars)
+	if strings.HasPrefix(strings.TrimSpace(template), "=") || value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '@':
+		return "'" + value
+	case '-':
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "'" + value
+		}
+	}
+	return value
+}
+
+// sheetRow sets the cells of row from the values of a node by column header
+func (e *FlowExecutor) sheetRow(headers []string, row []interface{}, values map[string]interface{}, vars map[string]interface{}) ([]interface{}, error) {
+	for len(row) < len(headers) {
+		row = append(row, "")
+	}
+	for column, template := range values {
+		index := -1
+		for i, header := range headers {
+			if strings.EqualFold(header, strings.TrimSpace(column)) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("column %q is not in the first row of the sheet", column)
+		}
+		text, _ := template.(string)
+		row[index] = e.sheetCell(text, vars)
+	}
+	return row, nil
+}
+
+func (e *FlowExecutor) executeGoogleSheets(ctx context.Context, execCtx *ExecutionContext, node *flow.Node) (map[string]interface{}, error) {
+	data := node.Data
+	vars := execCtx.Variables
+
+	credentialID, _ := data["credential_id"].(string)
+	if credentialID == "" {
+		return nil, fmt.Errorf("google sheets credential is required")
+	}
+	cred, err := e.flowRepo.GetCredentialByID(ctx, credentialID)
+	if err != nil {
+		return nil, fmt.Errorf("credential not found: %w", err)
+	}
+	var cfg flow.GoogleSheetsCredential
+	if err := json.Unmarshal([]byte(cred.Config), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid google sheets config: %w", err)
+	}
+	sa, err := parseServiceAccount(cfg.ServiceAccountJSON)
+	if err != nil {
+		return nil, err
+	}
+	token, err := sa.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	spreadsheetID, _ := data["spreadsheet_id"].(string)
+	spreadsheetID = strings.TrimSpace(e.interpolateVariables(spreadsheetID, vars))
+	if spreadsheetID == "" {
+		return nil, fmt.Errorf("spreadsheet ID is required")
+	}
+	sheet, _ := data["sheet"].(string)
+	if sheet = strings.TrimSpace(e.interpolateVariables(sheet, vars)); sheet == "" {
+		sheet = "Sheet1"
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultSheetsBaseURL
+	}
+	c := &sheetsClient{baseURL: baseURL, token: token, spreadsheetID: spreadsheetID, http: &http.Client{Timeout: sheetsTimeout}}
+
+	operation, _ := data["operation"].(string)
+	values, _ := data["values"].(map[string]interface{})
+	lookupColumn, _ := data["lookup_column"].(string)
+	lookupValue, _ := data["lookup_value"].(string)
+	lookupValue = strings.TrimSpace(e.interpolateVariables(lookupValue, vars))
+
+	switch operation {
+	case flow.SheetsOperationAppend:
+		if len(values) == 0 {
+			return nil, fmt.Errorf("values required for append")
+		}
+		first, err := c.get(ctx, sheetRange(sheet, "1:1"))
+		if err != nil {
+			return nil, err
+		}
+		headers := sheetHeaders(first)
+		row, err := e.sheetRow(headers, nil, values, vars)
+		if err != nil {
+			return nil, err
+		}
+		if execCtx.DryRun {
+			return stubbed(map[string]interface{}{"row_number": 0, "updated_range": "", "row": rowMap(headers, row)}), nil
+		}
+		var result struct {
+			Updates struct {
+				UpdatedRange string `json:"updatedRange"`
+			} `json:"updates"`
+		}
+		query := url.Values{"valueInputOption": {"USER_ENTERED"}, "insertDataOption": {"INSERT_ROWS"}}
+		if err := c.call(ctx, http.MethodPost, sheetRange(sheet, "A1"), ":append", query,
+			map[string]interface{}{"values": [][]interface{}{row}}, &result); err != nil {
+			return nil, err
+		}
+		rowNumber := 0
+		if match := rowNumberPattern.FindStringSubmatch(result.Updates.UpdatedRange); match != nil {
+			rowNumber, _ = strconv.Atoi(match[1])
+		}
+		return map[string]interface{}{
+			"row_number":    rowNumber,
+			"updated_range": result.Updates.UpdatedRange,
+			"row":           rowMap(headers, row),
+		}, nil
+
+	case flow.SheetsOperationLookup, flow.SheetsOperationUpdate:
+		rows, err := c.get(ctx, sheetRange(sheet, ""))
+		if err != nil {
+			return nil, err
+		}
+		headers := sheetHeaders(rows)
+
+		// Find the row, counting from 1 for the header row
+		rowNumber := 0
+		rowNumberText, _ := data["row_number"].(string)
+		if rowNumberText = strings.TrimSpace(e.interpolateVariables(rowNumberText, vars)); operation == flow.SheetsOperationUpdate && rowNumberText != "" {
+			if rowNumber, err = strconv.Atoi(rowNumberText); err != nil || rowNumber < 2 {
+				return nil, fmt.Errorf("invalid row number %q", rowNumberText)
+			}
+		} else {
+			column := -1
+			for i, header := range headers {
+				if strings.EqualFold(header, strings.TrimSpace(lookupColumn)) {
+					column = i
+					break
+				}
+			}
+			if column < 0 {
+				return nil, fmt.Errorf("lookup column %q is not in the first row of the sheet", lookupColumn)
+			}
+			for i := 1; i < len(rows); i++ {
+				if column < len(rows[i]) && strings.TrimSpace(formatValue(rows[i][column])) == lookupValue {
+					rowNumber = i + 1
+					break
+				}
+			}
+		}
+
+		var row []interface{}
+		if rowNumber > 0 && rowNumber <= len(rows) {
+			row = rows[rowNumber-1]
+		}
+		if operation == flow.SheetsOperationLookup {
+			if rowNumber == 0 {
+				return map[string]interface{}{"found": false, "row": nil, "row_number": 0}, nil
+			}
+			return map[string]interface{}{"found": true, "row": rowMap(headers, row), "row_number": rowNumber}, nil
+		}
+
+		if rowNumber == 0 {
+			return map[string]interface{}{"updated": false, "row_number": 0}, nil
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("values required for update")
+		}
+		row, err = e.sheetRow(headers, append([]interface{}{}, row...), values, vars)
+		if err != nil {
+			return nil, err
+		}
+		a1 := fmt.Sprintf("A%d:%s%d", rowNumber, columnName(len(row)-1), rowNumber)
+		if execCtx.DryRun {
+			return stubbed(map[string]interface{}{"updated": true, "row_number": rowNumber, "row": rowMap(headers, row), "range": sheetRange(sheet, a1)}), nil
+		}
+		if err := c.call(ctx, http.MethodPut, sheetRange(sheet, a1), "", url.Values{"valueInputOption": {"USER_ENTERED"}},
+			map[string]interface{}{"values": [][]interface{}{row}}, nil); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"updated": true, "row_number": rowNumber, "row": rowMap(headers, row)}, nil
+
+	case flow.SheetsOperationRead:
+		a1, _ := data["range"].(string)
+		rows, err := c.get(ctx, sheetRange(sheet, strings.TrimSpace(e.interpolateVariables(a1, vars))))
+		if err != nil {
+			return nil, err
+		}
+		// Rows after the first are keyed by the headers in the first
+		headers := sheetHeaders(rows)
+		records := make([]interface{}, 0, len(rows))
+		for i := 1; i < len(rows); i++ {
+			records = append(records, rowMap(headers, rows[i]))
+		}
+		return map[string]interface{}{"values": rows, "rows": records, "count": len(records)}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", operation)
+	}
+}
@@ -0,0 +1,171 @@
+package flow
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// fakeSheets serves the token endpoint and the values API for one sheet named Leads
+type fakeSheets struct {
+	key *rsa.PrivateKey
+
+	mu   sync.Mutex
+	rows [][]interface{}
+}
+
+func (f *fakeSheets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[len(parts)-1])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" ||
+			rsa.VerifyPKCS1v15(&f.key.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "fake-token", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer fake-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	a1 := strings.TrimPrefix(r.URL.Path, "/v4/spreadsheets/sheet-1/values/")
+	var body struct {
+		Values [][]interface{} `json:"values"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	switch {
+	case r.Method == http.MethodGet && a1 == "'Leads'!1:1":
+		json.NewEncoder(w).Encode(map[string]interface{}{"values": f.rows[:1]})
+	case r.Method == http.MethodGet && a1 == "'Leads'":
+		json.NewEncoder(w).Encode(map[string]interface{}{"values": f.rows})
+	case r.Method == http.MethodPost && a1 == "'Leads'!A1:append":
+		f.rows = append(f.rows, body.Values[0])
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"updates": map[string]string{"updatedRange": fmt.Sprintf("Leads!A%d:C%d", len(f.rows), len(f.rows))},
+		})
+	case r.Method == http.MethodPut && strings.HasPrefix(a1, "'Leads'!A"):
+		var row int
+		fmt.Sscanf(a1, "'Leads'!A%d:", &row)
+		f.rows[row-1] = body.Values[0]
+		w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": "Unable to parse range: " + a1}})
+	}
+}
+
+func TestGoogleSheetsNode(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeSheets{key: key, rows: [][]interface{}{{"Name", "Phone", "Status"}, {"Ana", "62811", "new"}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	account, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "bot@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL + "/token",
+	})
+	raw, _ := json.Marshal(flow.GoogleSheetsCredential{ServiceAccountJSON: string(account), BaseURL: server.URL})
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	ctx := context.Background()
+	cred := &flow.Credential{AgentID: "agent-1", Name: "sales", Type: flow.CredentialTypeGoogleSheets, Config: string(raw)}
+	if err := repo.CreateCredential(ctx, cred); err != nil {
+		t.Fatalf("CreateCredential() error = %v", err)
+	}
+
+	execute := func(data map[string]interface{}) (map[string]interface{}, error) {
+		data["credential_id"] = cred.ID
+		data["spreadsheet_id"] = "sheet-1"
+		data["sheet"] = "Leads"
+		f := &flow.Flow{
+			Nodes: []flow.Node{
+				{ID: "trigger", Type: flow.NodeTypeTriggerWhatsApp},
+				{ID: "sheet", Type: flow.NodeTypeGoogleSheets, Data: data},
+			},
+			Edges: []flow.Edge{{ID: "e1", Source: "trigger", Target: "sheet"}},
+		}
+		return NewFlowExecutor(repo).Execute(ctx, f, map[string]interface{}{"name": "Budi", "sender": "62822", "message": "=IMPORTXML(\"x\")"})
+	}
+	run := func(data map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		output, err := execute(data)
+		if err != nil {
+			t.Fatalf("Execute(%s) error = %v", data["operation"], err)
+		}
+		return output
+	}
+
+	output := run(map[string]interface{}{"operation": "append", "values": map[string]interface{}{"name": "{{name}}", "Phone": "{{sender}}", "Status": "{{message}}"}})
+	if output["row_number"] != 3 {
+		t.Fatalf("append row_number = %v", output["row_number"])
+	}
+	if got := fake.rows[2]; got[0] != "Budi" || got[1] != "62822" || got[2] != `'=IMPORTXML("x")` {
+		t.Fatalf("appended row = %v, want the formula from the message kept as text", got)
+	}
+
+	output = run(map[string]interface{}{"operation": "lookup", "lookup_column": "Phone", "lookup_value": "{{sender}}"})
+	if row, _ := output["row"].(map[string]interface{}); output["found"] != true || output["row_number"] != 3 || row["Name"] != "Budi" {
+		t.Fatalf("lookup = %v", output)
+	}
+	output = run(map[string]interface{}{"operation": "lookup", "lookup_column": "Phone", "lookup_value": "000"})
+	if output["found"] != false {
+		t.Fatalf("lookup of a missing value = %v", output)
+	}
+
+	output = run(map[string]interface{}{"operation": "update", "lookup_column": "Name", "lookup_value": "Ana", "values": map[string]interface{}{"Status": "contacted"}})
+	if output["updated"] != true || fake.rows[1][0] != "Ana" || fake.rows[1][2] != "contacted" {
+		t.Fatalf("update = %v, rows = %v", output, fake.rows)
+	}
+
+	output = run(map[string]interface{}{"operation": "read"})
+	rows, _ := output["rows"].([]interface{})
+	if output["count"] != 2 || len(rows) != 2 || rows[1].(map[string]interface{})["Phone"] != "62822" {
+		t.Fatalf("read = %v", output)
+	}
+
+	// Columns must exist in the header row
+	_, err = execute(map[string]interface{}{"operation": "append", "values": map[string]interface{}{"Email": "x"}})
+	if err == nil || !strings.Contains(err.Error(), `column "Email"`) {
+		t.Fatalf("append to an unknown column error = %v", err)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", index, got, want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// === Versions and Drafts ===
+
+// marshalDefinition encodes the nodes, edges and variables of a flow definition
+func marshalDefinition(nodes []flow.Node, edges []flow.Edge, variables []flow.Variable) (nodesJSON, edgesJSON, variablesJSON string, err error) {
+	if nodes == nil {
+		nodes = []flow.Node{}
+	}
+	if edges == nil {
+		edges = []flow.Edge{}
+	}
+	if variables == nil {
+		variables = []flow.Variable{}
+	}
+	n, err := json.Marshal(nodes)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to marshal nodes: %w", err)
+	}
+	e, err := json.Marshal(edges)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to marshal edges: %w", err)
+	}
+	v, err := json.Marshal(variables)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to marshal variables: %w", err)
+	}
+	return string(n), string(e), string(v), nil
+}
+
+// unmarshalDefinition decodes the nodes, edges and variables of a flow definition
+func unmarshalDefinition(nodesJSON, edgesJSON, variablesJSON string, nodes *[]flow.Node, edges *[]flow.Edge, variables *[]flow.Variable) error {
+	if err := json.Unmarshal([]byte(nodesJSON), nodes); err != nil {
+		return fmt.Errorf("failed to unmarshal nodes: %w", err)
+	}
+	if err := json.Unmarshal([]byte(edgesJSON), edges); err != nil {
+		return fmt.Errorf("failed to unmarshal edges: %w", err)
+	}
+	if err := json.Unmarshal([]byte(variablesJSON), variables); err != nil {
+		return fmt.Errorf("failed to unmarshal variables: %w", err)
+	}
+	return nil
+}
+
+// UpdateFlowDetails updates the name, description and active state of a flow, leaving its
+// published definition as it is
+func (r *SQLiteRepository) UpdateFlowDetails(ctx context.Context, f *flow.Flow) error {
+	f.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE flows SET name=?, description=?, is_active=?, updated_at=? WHERE id=?`,
+		f.Name, f.Description, f.IsActive, f.UpdatedAt, f.ID,
+	)
+	return err
+}
+
+// GetFlowDraft returns the draft of a flow, or nil when it has none
+func (r *SQLiteRepository) GetFlowDraft(ctx context.Context, flowID string) (*flow.FlowDraft, error) {
+	d := &flow.FlowDraft{}
+	var nodesJSON, edgesJSON, variablesJSON string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT nodes, edges, variables, updated_at FROM flow_drafts WHERE flow_id = ?`, flowID,
+	).Scan(&nodesJSON, &edgesJSON, &variablesJSON, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalDefinition(nodesJSON, edgesJSON, variablesJSON, &d.Nodes, &d.Edges, &d.Variables); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SaveFlowDraft creates or replaces the draft of a flow
+func (r *SQLiteRepository) SaveFlowDraft(ctx context.Context, flowID string, d *flow.FlowDraft) error {
+	nodesJSON, edgesJSON, variablesJSON, err := marshalDefinition(d.Nodes, d.Edges, d.Variables)
+	if err != nil {
+		return err
+	}
+	d.UpdatedAt = time.Now()
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO flow_drafts (flow_id, nodes, edges, variables, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(flow_id) DO UPDATE SET nodes = excluded.nodes, edges = excluded.edges,
+			variables = excluded.variables, updated_at = excluded.updated_at`,
+		flowID, nodesJSON, edgesJSON, variablesJSON, d.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteFlowDraft discards the draft of a flow
+func (r *SQLiteRepository) DeleteFlowDraft(ctx context.Context, flowID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM flow_drafts WHERE flow_id = ?`, flowID)
+	return err
+}
+
+// PublishFlowVersion adds v as the next version of its flow and makes it the definition that
+// runs, setting v.Version. With clearDraft the draft of the flow is discarded in the same step.
+func (r *SQLiteRepository) PublishFlowVersion(ctx context.Context, v *flow.FlowVersion, clearDraft bool) error {
+	nodesJSON, edgesJSON, variablesJSON, err := marshalDefinition(v.Nodes, v.Edges, v.Variables)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) + 1 FROM flow_versions WHERE flow_id = ?`, v.FlowID,
+	).Scan(&v.Version); err != nil {
+		return err
+	}
+	v.CreatedAt = time.Now()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO flow_versions (flow_id, version, nodes, edges, variables, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		v.FlowID, v.Version, nodesJSON, edgesJSON, variablesJSON, v.Note, v.CreatedAt,
+	); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE flows SET nodes=?, edges=?, variables=?, version=?, updated_at=? WHERE id=?`,
+		nodesJSON, edgesJSON, variablesJSON, v.Version, v.CreatedAt, v.FlowID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("flow not found")
+	}
+	if clearDraft {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM flow_drafts WHERE flow_id = ?`, v.FlowID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetFlowVersions lists the versions of a flow without their definitions, newest first
+func (r *SQLiteRepository) GetFlowVersions(ctx context.Context, flowID string) ([]*flow.FlowVersion, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT flow_id, version, note, created_at FROM flow_versions WHERE flow_id = ? ORDER BY version DESC`, flowID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []*flow.FlowVersion{}
+	for rows.Next() {
+		v := &flow.FlowVersion{}
+		if err := rows.Scan(&v.FlowID, &v.Version, &v.Note, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetFlowVersion returns a version of a flow with its definition
+func (r *SQLiteRepository) GetFlowVersion(ctx context.Context, flowID string, version int) (*flow.FlowVersion, error) {
+	v := &flow.FlowVersion{}
+	var nodesJSON, edgesJSON, variablesJSON string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT flow_id, version, nodes, edges, variables, note, created_at
+		FROM flow_versions WHERE flow_id = ? AND version = ?`, flowID, version,
+	).Scan(&v.FlowID, &v.Version, &nodesJSON, &edgesJSON, &variablesJSON, &v.Note, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("version %d of flow not found: %w", version, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalDefinition(nodesJSON, edgesJSON, variablesJSON, &v.Nodes, &v.Edges, &v.Variables); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
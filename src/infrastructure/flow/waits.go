@@ -0,0 +1,130 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// Kinds of paused runs
+const (
+	waitKindDelay = "delay"
+	waitKindReply = "reply"
+)
+
+// waitingRun is a run paused at a delay or wait for reply node, saved so that it survives restarts
+type waitingRun struct {
+	ExecutionID string
+	FlowID      string
+	NodeID      string // The node the run is paused at
+	Kind        string // delay or reply
+	ContactKey  string // For reply waits, the contact whose next message resumes the run
+	ResumeAt    time.Time
+	CreatedAt   time.Time
+	State       executionState
+}
+
+// executionState is what a paused run needs to continue
+type executionState struct {
+	Input     map[string]interface{} `json:"input"`
+	Variables map[string]interface{} `json:"variables"`
+	Output    map[string]interface{} `json:"output"`
+	Trace     []flow.NodeTrace       `json:"trace,omitempty"`
+	Pending   []string               `json:"pending,omitempty"`
+	Loops     map[string]*loopState  `json:"loops,omitempty"`
+	Steps     int64                  `json:"steps,omitempty"`
+
+	// Definition of runs of an unpublished flow, such as a draft test run, which resume on it
+	Definition *flow.FlowDraft `json:"definition,omitempty"`
+}
+
+// ContactKey identifies a contact on an integration for reply waits. Phone numbers, JIDs
+// and the tg_/ig_ chat IDs of the same contact give the same key.
+func ContactKey(integrationID, contact string) string {
+	contact = strings.TrimSpace(contact)
+	if i := strings.IndexAny(contact, "@:"); i >= 0 {
+		contact = contact[:i]
+	}
+	contact = strings.TrimPrefix(contact, "+")
+	contact = strings.TrimPrefix(strings.TrimPrefix(contact, "tg_"), "ig_")
+	return integrationID + "|" + contact
+}
+
+// saveWait stores a paused run
+func (r *SQLiteRepository) saveWait(ctx context.Context, w *waitingRun) error {
+	stateJSON, err := json.Marshal(w.State)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO flow_waits (execution_id, flow_id, node_id, kind, contact_key, resume_at, created_at, state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		w.ExecutionID, w.FlowID, w.NodeID, w.Kind, w.ContactKey, w.ResumeAt.UTC(), w.CreatedAt.UTC(), string(stateJSON),
+	)
+	return err
+}
+
+// claimWait removes a paused run and returns it, or nil when it has already been resumed.
+// Only one caller gets a run, so a reply and a timeout racing each other resume it once.
+func (r *SQLiteRepository) claimWait(ctx context.Context, executionID string) (*waitingRun, error) {
+	w := &waitingRun{}
+	var stateJSON string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT execution_id, flow_id, node_id, kind, contact_key, resume_at, created_at, state
+		FROM flow_waits WHERE execution_id = ?`, executionID,
+	).Scan(&w.ExecutionID, &w.FlowID, &w.NodeID, &w.Kind, &w.ContactKey, &w.ResumeAt, &w.CreatedAt, &stateJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM flow_waits WHERE execution_id = ?`, executionID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(stateJSON), &w.State); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	return w, nil
+}
+
+// dueWaits returns the paused runs to resume at now, oldest first
+func (r *SQLiteRepository) dueWaits(ctx context.Context, now time.Time) ([]string, error) {
+	return r.queryWaitIDs(ctx, `SELECT execution_id FROM flow_waits WHERE resume_at <= ? ORDER BY resume_at`, now.UTC())
+}
+
+// contactWaits returns the runs waiting for a reply from the contact, oldest first
+func (r *SQLiteRepository) contactWaits(ctx context.Context, contactKey string) ([]string, error) {
+	return r.queryWaitIDs(ctx,
+		`SELECT execution_id FROM flow_waits WHERE kind = ? AND contact_key = ? ORDER BY created_at`,
+		waitKindReply, contactKey,
+	)
+}
+
+func (r *SQLiteRepository) queryWaitIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -171,6 +171,31 @@ func ParseInstagramConfig(configJSON string) (*agent.InstagramConfig, error) {
 
 // SendInstagramMessage sends a message via Instagram Graph API
 func SendInstagramMessage(accessToken, pageID, recipientID, message string) error {
+	_, err := SendInstagramText(accessToken, pageID, recipientID, message)
+	return err
+}
+
+// SendInstagramText sends a text message and returns its ID
+func SendInstagramText(accessToken, pageID, recipientID, message string) (string, error) {
+	return sendInstagramPayload(accessToken, pageID, recipientID, map[string]interface{}{
+		"text": message,
+	})
+}
+
+// SendInstagramAttachment sends media by URL; attachmentType is "image" or "file"
+func SendInstagramAttachment(accessToken, pageID, recipientID, attachmentType, mediaURL string) (string, error) {
+	return sendInstagramPayload(accessToken, pageID, recipientID, map[string]interface{}{
+		"attachment": map[string]interface{}{
+			"type": attachmentType,
+			"payload": map[string]interface{}{
+				"url": mediaURL,
+			},
+		},
+	})
+}
+
+// sendInstagramPayload posts a message to recipientID and returns the message ID
+func sendInstagramPayload(accessToken, pageID, recipientID string, message map[string]interface{}) (string, error) {
 	// Instagram Graph API endpoint for sending messages
 	url := fmt.Sprintf("https://graph.facebook.com/v18.0/%s/messages", pageID)
 
@@ -178,20 +203,18 @@ func SendInstagramMessage(accessToken, pageID, recipientID, message string) erro
 		"recipient": map[string]string{
 			"id": recipientID,
 		},
-		"message": map[string]string{
-			"text": message,
-		},
+		"message": message,
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	// Make HTTP POST request
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -200,17 +223,22 @@ func SendInstagramMessage(accessToken, pageID, recipientID, message string) erro
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Instagram API error (status %d): %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("Instagram API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
+	var result struct {
+		MessageID string `json:"message_id"`
+	}
+	json.Unmarshal(body, &result)
+
 	logrus.Debugf("✅ [Instagram] Message sent successfully to %s", recipientID)
-	return nil
+	return result.MessageID, nil
 }
 
 func min(a, b int) int {
@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/notification"
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	repo := &SQLiteRepository{db: db}
+	if err := repo.migrate(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *SQLiteRepository) migrate() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id TEXT PRIMARY KEY,
+		user_id TEXT DEFAULT '',
+		type TEXT NOT NULL,
+		title TEXT NOT NULL,
+		message TEXT DEFAULT '',
+		agent_id TEXT DEFAULT '',
+		agent_name TEXT DEFAULT '',
+		data TEXT DEFAULT '',
+		is_read INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, is_read, created_at DESC);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteRepository) Create(ctx context.Context, notif *notification.Notification) error {
+	if notif.ID == "" {
+		notif.ID = uuid.New().String()
+	}
+	if notif.CreatedAt.IsZero() {
+		notif.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO notifications (id, user_id, type, title, message, agent_id, agent_name, data, is_read, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, notif.ID, notif.UserID, notif.Type, notif.Title, notif.Message, notif.AgentID, notif.AgentName,
+		notif.Data, notif.IsRead, notif.CreatedAt)
+	return err
+}
+
+// GetByUserID returns the newest notifications for a user. Notifications without a user
+// are shared, so an empty userID returns all of them.
+func (r *SQLiteRepository) GetByUserID(ctx context.Context, userID string, limit int, unreadOnly bool) ([]*notification.Notification, error) {
+	query := `
+		SELECT id, user_id, type, title, message, agent_id, agent_name, data, is_read, created_at
+		FROM notifications
+		WHERE (? = '' OR user_id = ? OR user_id = '')`
+	if unreadOnly {
+		query += ` AND is_read = 0`
+	}
+	query += ` ORDER BY created_at DESC LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*notification.Notification
+	for rows.Next() {
+		n := &notification.Notification{}
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Title, &n.Message, &n.AgentID, &n.AgentName,
+			&n.Data, &n.IsRead, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *SQLiteRepository) MarkAsRead(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE notifications SET is_read = 1 WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) MarkAllAsRead(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE notifications SET is_read = 1 WHERE is_read = 0 AND (? = '' OR user_id = ? OR user_id = '')`,
+		userID, userID)
+	return err
+}
+
+func (r *SQLiteRepository) GetUnreadCount(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notifications WHERE is_read = 0 AND (? = '' OR user_id = ? OR user_id = '')`,
+		userID, userID).Scan(&count)
+	return count, err
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/encryption"
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -42,6 +44,14 @@ func (r *SQLiteRepository) migrate() error {
 		translation TEXT,
 		follow_up TEXT,
 		sentiment TEXT,
+		history TEXT,
+		tools TEXT,
+		knowledge TEXT,
+		streaming TEXT,
+		budget TEXT,
+		vision TEXT,
+		voice_reply TEXT,
+		flows TEXT,
 		max_tokens_per_msg INTEGER DEFAULT 500,
 		temperature REAL DEFAULT 0.7,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -68,21 +78,39 @@ func (r *SQLiteRepository) migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_agent_settings_agent ON agent_settings(agent_id);
 	CREATE INDEX IF NOT EXISTS idx_broadcasts_agent ON broadcasts(agent_id);
 	`
-	_, err := r.db.Exec(query)
-	return err
+	if _, err := r.db.Exec(query); err != nil {
+		return err
+	}
+
+	// Safe migrations for existing tables (ignore errors if columns already exist)
+	safeMigrations := []string{
+		`ALTER TABLE agent_settings ADD COLUMN history TEXT`,
+		`ALTER TABLE agent_settings ADD COLUMN tools TEXT`,
+		`ALTER TABLE agent_settings ADD COLUMN knowledge TEXT`,
+		`ALTER TABLE agent_settings ADD COLUMN streaming TEXT`,
+		`ALTER TABLE agent_settings ADD COLUMN budget TEXT`,
+		`ALTER TABLE agent_settings ADD COLUMN vision TEXT`,
+		`ALTER TABLE agent_settings ADD COLUMN voice_reply TEXT`,
+		`ALTER TABLE agent_settings ADD COLUMN flows TEXT`,
+	}
+	for _, query := range safeMigrations {
+		r.db.Exec(query) // Ignore errors (column may already exist)
+	}
+
+	return nil
 }
 
 func (r *SQLiteRepository) GetAgentSettings(ctx context.Context, agentID string) (*settings.AgentSettings, error) {
 	row := r.db.QueryRowContext(ctx,
-		`SELECT id, agent_id, working_hours, translation, follow_up, sentiment, 
+		`SELECT id, agent_id, working_hours, translation, follow_up, sentiment, history, tools, knowledge, streaming, budget, vision, voice_reply, flows,
 		        max_tokens_per_msg, temperature, created_at, updated_at 
 		 FROM agent_settings WHERE agent_id = ?`, agentID)
 
 	s := &settings.AgentSettings{}
-	var workingHoursJSON, translationJSON, followUpJSON, sentimentJSON sql.NullString
+	var workingHoursJSON, translationJSON, followUpJSON, sentimentJSON, historyJSON, toolsJSON, knowledgeJSON, streamingJSON, budgetJSON, visionJSON, voiceReplyJSON, flowsJSON sql.NullString
 
-	err := row.Scan(&s.ID, &s.AgentID, &workingHoursJSON, &translationJSON, 
-		&followUpJSON, &sentimentJSON, &s.MaxTokensPerMsg, &s.Temperature, 
+	err := row.Scan(&s.ID, &s.AgentID, &workingHoursJSON, &translationJSON,
+		&followUpJSON, &sentimentJSON, &historyJSON, &toolsJSON, &knowledgeJSON, &streamingJSON, &budgetJSON, &visionJSON, &voiceReplyJSON, &flowsJSON, &s.MaxTokensPerMsg, &s.Temperature, 
 		&s.CreatedAt, &s.UpdatedAt)
 	
 	if err == sql.ErrNoRows {
@@ -105,6 +133,57 @@ func (r *SQLiteRepository) GetAgentSettings(ctx context.Context, agentID string)
 	}
 	if sentimentJSON.Valid {
 		json.Unmarshal([]byte(sentimentJSON.String), &s.Sentiment)
+		// Webhook URLs such as Slack's carry their own token
+		if s.Sentiment.WebhookURL, err = encryption.Decrypt(s.Sentiment.WebhookURL); err != nil {
+			return nil, fmt.Errorf("agent %s sentiment webhook URL: %w", agentID, err)
+		}
+	}
+	// Rows saved before history settings existed get the defaults
+	s.History = settings.DefaultAgentSettings(agentID).History
+	if historyJSON.Valid && historyJSON.String != "" {
+		json.Unmarshal([]byte(historyJSON.String), &s.History)
+	}
+
+	// Rows saved before tools settings existed get the defaults
+	s.Tools = settings.DefaultAgentSettings(agentID).Tools
+	if toolsJSON.Valid && toolsJSON.String != "" {
+		json.Unmarshal([]byte(toolsJSON.String), &s.Tools)
+	}
+
+	// Rows saved before knowledge settings existed get the defaults
+	s.Knowledge = settings.DefaultAgentSettings(agentID).Knowledge
+	if knowledgeJSON.Valid && knowledgeJSON.String != "" {
+		json.Unmarshal([]byte(knowledgeJSON.String), &s.Knowledge)
+	}
+
+	// Rows saved before streaming settings existed get the defaults
+	s.Streaming = settings.DefaultAgentSettings(agentID).Streaming
+	if streamingJSON.Valid && streamingJSON.String != "" {
+		json.Unmarshal([]byte(streamingJSON.String), &s.Streaming)
+	}
+
+	// Rows saved before budget settings existed get the defaults
+	s.Budget = settings.DefaultAgentSettings(agentID).Budget
+	if budgetJSON.Valid && budgetJSON.String != "" {
+		json.Unmarshal([]byte(budgetJSON.String), &s.Budget)
+	}
+
+	// Rows saved before vision settings existed get the defaults
+	s.Vision = settings.DefaultAgentSettings(agentID).Vision
+	if visionJSON.Valid && visionJSON.String != "" {
+		json.Unmarshal([]byte(visionJSON.String), &s.Vision)
+	}
+
+	// Rows saved before voice_reply settings existed get the defaults
+	s.VoiceReply = settings.DefaultAgentSettings(agentID).VoiceReply
+	if voiceReplyJSON.Valid && voiceReplyJSON.String != "" {
+		json.Unmarshal([]byte(voiceReplyJSON.String), &s.VoiceReply)
+	}
+
+	// Rows saved before flows settings existed get the defaults
+	s.Flows = settings.DefaultAgentSettings(agentID).Flows
+	if flowsJSON.Valid && flowsJSON.String != "" {
+		json.Unmarshal([]byte(flowsJSON.String), &s.Flows)
 	}
 
 	return s, nil
@@ -119,27 +198,101 @@ func (r *SQLiteRepository) SaveAgentSettings(ctx context.Context, s *settings.Ag
 	workingHoursJSON, _ := json.Marshal(s.WorkingHours)
 	translationJSON, _ := json.Marshal(s.Translation)
 	followUpJSON, _ := json.Marshal(s.FollowUp)
-	sentimentJSON, _ := json.Marshal(s.Sentiment)
-
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO agent_settings (id, agent_id, working_hours, translation, follow_up, sentiment, 
+	sentiment := s.Sentiment
+	webhookURL, err := encryption.Encrypt(sentiment.WebhookURL)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt sentiment webhook URL: %w", err)
+	}
+	sentiment.WebhookURL = webhookURL
+	sentimentJSON, _ := json.Marshal(sentiment)
+	historyJSON, _ := json.Marshal(s.History)
+	toolsJSON, _ := json.Marshal(s.Tools)
+	knowledgeJSON, _ := json.Marshal(s.Knowledge)
+	streamingJSON, _ := json.Marshal(s.Streaming)
+	budgetJSON, _ := json.Marshal(s.Budget)
+	visionJSON, _ := json.Marshal(s.Vision)
+	voiceReplyJSON, _ := json.Marshal(s.VoiceReply)
+	flowsJSON, _ := json.Marshal(s.Flows)
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO agent_settings (id, agent_id, working_hours, translation, follow_up, sentiment, history, tools, knowledge, streaming, budget, vision, voice_reply, flows,
 		                             max_tokens_per_msg, temperature, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		 ON CONFLICT(agent_id) DO UPDATE SET
 		 	working_hours = excluded.working_hours,
 		 	translation = excluded.translation,
 		 	follow_up = excluded.follow_up,
 		 	sentiment = excluded.sentiment,
+		 	history = excluded.history,
+		 	tools = excluded.tools,
+		 	knowledge = excluded.knowledge,
+		 	streaming = excluded.streaming,
+		 	budget = excluded.budget,
+		 	vision = excluded.vision,
+		 	voice_reply = excluded.voice_reply,
+		 	flows = excluded.flows,
 		 	max_tokens_per_msg = excluded.max_tokens_per_msg,
 		 	temperature = excluded.temperature,
 		 	updated_at = excluded.updated_at`,
 		s.ID, s.AgentID, string(workingHoursJSON), string(translationJSON),
-		string(followUpJSON), string(sentimentJSON), s.MaxTokensPerMsg, 
+		string(followUpJSON), string(sentimentJSON), string(historyJSON), string(toolsJSON), string(knowledgeJSON), string(streamingJSON), string(budgetJSON), string(visionJSON), string(voiceReplyJSON), string(flowsJSON), s.MaxTokensPerMsg,
 		s.Temperature, s.CreatedAt, s.UpdatedAt)
 
 	return err
 }
 
+// EncryptSecrets encrypts sentiment webhook URLs stored in plaintext or with an older master key,
+// returning how many settings were rewritten
+func (r *SQLiteRepository) EncryptSecrets(ctx context.Context) (int, error) {
+	if !encryption.Enabled() {
+		return 0, encryption.ErrNoMasterKey
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, sentiment FROM agent_settings WHERE sentiment IS NOT NULL AND sentiment != ''`)
+	if err != nil {
+		return 0, err
+	}
+	updates := map[string]string{}
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		// Decoded into a map so that fields unknown to this version are kept
+		var sentiment map[string]interface{}
+		if json.Unmarshal([]byte(raw), &sentiment) != nil {
+			continue
+		}
+		webhookURL, _ := sentiment["webhook_url"].(string)
+		if !encryption.NeedsEncrypt(webhookURL) {
+			continue
+		}
+		if sentiment["webhook_url"], err = encryption.Reencrypt(webhookURL); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("agent_settings %s sentiment webhook URL: %w", id, err)
+		}
+		updated, _ := json.Marshal(sentiment)
+		updates[id] = string(updated)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, sentiment := range updates {
+		if _, err := tx.ExecContext(ctx, `UPDATE agent_settings SET sentiment = ? WHERE id = ?`, sentiment, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(updates), tx.Commit()
+}
+
 func (r *SQLiteRepository) CreateBroadcast(ctx context.Context, b *settings.BroadcastMessage) error {
 	if b.ID == "" {
 		b.ID = uuid.New().String()
@@ -1,15 +1,16 @@
 package telegram
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
 	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/usecase"
@@ -24,13 +25,38 @@ type TelegramUpdate struct {
 
 // TelegramMessage represents a Telegram message
 type TelegramMessage struct {
-	MessageID int            `json:"message_id"`
-	From      *TelegramUser  `json:"from,omitempty"`
-	Chat      *TelegramChat  `json:"chat"`
-	Date      int            `json:"date"`
-	Text      string         `json:"text,omitempty"`
-	Voice     *TelegramVoice `json:"voice,omitempty"`
-	Audio     *TelegramAudio `json:"audio,omitempty"`
+	MessageID int                 `json:"message_id"`
+	From      *TelegramUser       `json:"from,omitempty"`
+	Chat      *TelegramChat       `json:"chat"`
+	Date      int                 `json:"date"`
+	Text      string              `json:"text,omitempty"`
+	Voice     *TelegramVoice      `json:"voice,omitempty"`
+	Audio     *TelegramAudio      `json:"audio,omitempty"`
+	Caption   string              `json:"caption,omitempty"`
+	Photo     []TelegramPhotoSize `json:"photo,omitempty"` // Sizes in ascending order
+	Document  *TelegramDocument   `json:"document,omitempty"`
+	Sticker   *TelegramSticker    `json:"sticker,omitempty"`
+}
+
+type TelegramPhotoSize struct {
+	FileID   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int64  `json:"file_size"`
+}
+
+type TelegramDocument struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	FileSize int64  `json:"file_size"`
+}
+
+type TelegramSticker struct {
+	FileID     string `json:"file_id"`
+	IsAnimated bool   `json:"is_animated"`
+	IsVideo    bool   `json:"is_video"`
+	FileSize   int64  `json:"file_size"`
 }
 
 type TelegramVoice struct {
@@ -318,7 +344,8 @@ func (b *TelegramBot) handleUpdate(update TelegramUpdate) {
 	}
 
 	// Skip updates without content we can handle
-	if update.Message.Text == "" && update.Message.Voice == nil && update.Message.Audio == nil {
+	if update.Message.Text == "" && update.Message.Voice == nil && update.Message.Audio == nil &&
+		len(update.Message.Photo) == 0 && update.Message.Document == nil && update.Message.Sticker == nil {
 		return
 	}
 
@@ -375,7 +402,8 @@ func (b *TelegramBot) handleUpdate(update TelegramUpdate) {
 					logrus.Errorf("❌ [Telegram] Failed to download audio file: %v", err)
 				} else {
 					// Create AI service with agent's key
-					aiSvc := aiService.NewService(agentData.APIKey, agentData.SerpAPIKey)
+					aiSvc := aiService.NewServiceForAgent(agentData)
+					ctx := aiService.WithUsageScope(ctx, aiService.UsageScope{AgentID: agentData.ID})
 
 					// Transcribe
 					logrus.Infof("speech-to-text: transcribing %s...", fileID)
@@ -392,8 +420,14 @@ func (b *TelegramBot) handleUpdate(update TelegramUpdate) {
 		}
 	}
 
+	// Photos, stickers and documents carry their text in the caption
 	if userMessage == "" {
-		// If text is still empty (no text and no transcription), skip
+		userMessage = msg.Caption
+	}
+	attachments := b.downloadAttachments(msg)
+
+	if userMessage == "" && len(attachments) == 0 {
+		// If there is still nothing (no text, transcription or attachment), skip
 		return
 	}
 
@@ -407,8 +441,20 @@ func (b *TelegramBot) handleUpdate(update TelegramUpdate) {
 
 	// Get AI response from agent service
 	ctx := context.Background()
+	if fileID != "" {
+		ctx = agent.ContextWithMessageType(ctx, agent.MessageTypeAudio)
+	}
 	logrus.Infof("🤖 [Telegram] Calling HandleIncomingMessage for agent %s, integration %s, user %s", agentID, integrationID, userID)
-	response, err := agentSvc.HandleIncomingMessage(ctx, agentID, integrationID, userID, userMessage)
+	stream := newTelegramReplyStream(token, chatID)
+	defer stream.Close()
+
+	// Voice replies are spoken once complete, so they are not streamed as text
+	voiceReply := fileID != "" && agentSvc.VoiceRepliesEnabled(ctx, agentID)
+	var replyStream agent.ReplyStream = stream
+	if voiceReply {
+		replyStream = nil
+	}
+	response, err := agentSvc.HandleIncomingMessageStream(ctx, agentID, integrationID, userID, userMessage, attachments, replyStream)
 	if err != nil {
 		// Just log the error, don't send anything to user
 		logrus.Errorf("❌ [Telegram] Failed to get AI response for agent %s: %v", agentID, err)
@@ -422,15 +468,82 @@ func (b *TelegramBot) handleUpdate(update TelegramUpdate) {
 
 	logrus.Infof("💡 [Telegram] AI response generated for agent %s: %s", agentID, response[:min(50, len(response))])
 
-	// Send response using captured token
+	if voiceReply {
+		audio, err := agentSvc.SynthesizeVoiceReply(ctx, agentID, response)
+		if err == nil {
+			err = sendTelegramVoice(token, chatID, audio)
+		}
+		if err == nil {
+			logrus.Infof("✅ [Telegram] Voice response sent successfully to chat %d", chatID)
+			return
+		}
+		logrus.Warnf("⚠️  [Telegram] Voice reply failed for agent %s, sending text instead: %v", agentID, err)
+	}
+
+	// Send response using captured token; streamed replies may already be partly visible
 	logrus.Infof("📤 [Telegram] Sending response to chat %d", chatID)
-	if err := sendTelegramMessage(token, chatID, response); err != nil {
+	if stream.started {
+		err = stream.Finish()
+	} else {
+		err = sendTelegramMessage(token, chatID, response)
+	}
+	if err != nil {
 		logrus.Errorf("❌ [Telegram] Failed to send message to chat %d: %v", chatID, err)
 	} else {
 		logrus.Infof("✅ [Telegram] Response sent successfully to chat %d", chatID)
 	}
 }
 
+// downloadAttachments fetches the photo, sticker or document of a message. Files over the size
+// limits are not downloaded and are passed without data, so the agent can still mention them.
+func (b *TelegramBot) downloadAttachments(msg *TelegramMessage) []agent.Attachment {
+	var attachments []agent.Attachment
+	add := func(fileID string, size, limit int64, att agent.Attachment) {
+		defer func() { attachments = append(attachments, att) }()
+		if size > limit {
+			logrus.Warnf("⚠️  [Telegram] Not downloading %s %q: %d bytes exceeds the %d byte limit", att.Type, att.FileName, size, limit)
+			return
+		}
+		fileInfo, err := b.getFile(fileID)
+		if err != nil {
+			logrus.Errorf("❌ [Telegram] Failed to get file info: %v", err)
+			return
+		}
+		if att.Data, err = b.downloadFile(fileInfo.FilePath); err != nil {
+			logrus.Errorf("❌ [Telegram] Failed to download %s: %v", att.Type, err)
+		}
+	}
+
+	// Use the largest photo size within the limit (sizes are in ascending order)
+	if len(msg.Photo) > 0 {
+		photo := msg.Photo[0]
+		for _, size := range msg.Photo[1:] {
+			if size.FileSize <= config.WhatsappSettingMaxImageSize {
+				photo = size
+			}
+		}
+		logrus.Infof("🖼️  [Telegram] Photo received: %s (%dx%d)", photo.FileID, photo.Width, photo.Height)
+		add(photo.FileID, photo.FileSize, config.WhatsappSettingMaxImageSize, agent.Attachment{Type: agent.AttachmentTypeImage, MIMEType: "image/jpeg"})
+	}
+
+	// Animated and video stickers are not images
+	if st := msg.Sticker; st != nil && !st.IsAnimated && !st.IsVideo {
+		add(st.FileID, st.FileSize, config.WhatsappSettingMaxImageSize, agent.Attachment{Type: agent.AttachmentTypeImage, MIMEType: "image/webp"})
+	}
+
+	if doc := msg.Document; doc != nil {
+		logrus.Infof("📄 [Telegram] Document received: %s (%s)", doc.FileName, doc.MimeType)
+		att := agent.Attachment{Type: agent.AttachmentTypeDocument, MIMEType: doc.MimeType, FileName: doc.FileName}
+		limit := config.WhatsappSettingMaxFileSize
+		if strings.HasPrefix(doc.MimeType, "image/") {
+			att.Type, limit = agent.AttachmentTypeImage, config.WhatsappSettingMaxImageSize
+		}
+		add(doc.FileID, doc.FileSize, limit, att)
+	}
+
+	return attachments
+}
+
 func (b *TelegramBot) getFile(fileID string) (*TelegramFile, error) {
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/getFile?file_id=%s", b.Token, fileID)
 	resp, err := http.Get(url)
@@ -477,30 +590,14 @@ func sendTelegramMessage(token string, chatID int64, text string) error {
 
 // SendMessageDirect sends a message directly via Telegram API (exported for use by other packages)
 func SendMessageDirect(token string, chatID int64, text string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
-
-	payload := map[string]interface{}{
-		"chat_id": chatID,
-		"text":    text,
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram API error: %s", string(body))
-	}
+	_, err := SendText(token, chatID, text)
+	return err
+}
 
-	return nil
+// sendTelegramVoice sends OGG/Opus audio as a voice message
+func sendTelegramVoice(token string, chatID int64, audio []byte) error {
+	_, err := sendTelegramFile(token, "sendVoice", "voice", chatID, InputFile{Data: audio, FileName: "voice.ogg"}, "")
+	return err
 }
 
 // LoadBotsFromDB loads and starts all active Telegram integrations
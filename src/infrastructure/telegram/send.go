@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+)
+
+// InputFile is media sent to a chat, either by URL (fetched by Telegram) or uploaded
+type InputFile struct {
+	URL      string
+	Data     []byte
+	FileName string
+}
+
+// SendText sends a text message and returns its ID
+func SendText(token string, chatID int64, text string) (int, error) {
+	return sendMessageWithID(token, chatID, text)
+}
+
+// SendPhoto sends an image with an optional caption and returns the message ID
+func SendPhoto(token string, chatID int64, photo InputFile, caption string) (int, error) {
+	return sendTelegramFile(token, "sendPhoto", "photo", chatID, photo, caption)
+}
+
+// SendDocument sends a file with an optional caption and returns the message ID
+func SendDocument(token string, chatID int64, document InputFile, caption string) (int, error) {
+	return sendTelegramFile(token, "sendDocument", "document", chatID, document, caption)
+}
+
+// sendTelegramFile calls a Bot API send method taking a file in field, passing URLs as is
+// and uploading data as multipart form
+func sendTelegramFile(token, method, field string, chatID int64, file InputFile, caption string) (int, error) {
+	var msg TelegramMessage
+	if file.URL != "" {
+		payload := map[string]interface{}{
+			"chat_id": chatID,
+			field:     file.URL,
+		}
+		if caption != "" {
+			payload["caption"] = caption
+		}
+		err := callTelegramAPI(token, method, payload, &msg)
+		return msg.MessageID, err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("chat_id", strconv.FormatInt(chatID, 10)); err != nil {
+		return 0, err
+	}
+	if caption != "" {
+		if err := writer.WriteField("caption", caption); err != nil {
+			return 0, err
+		}
+	}
+	fileName := file.FileName
+	if fileName == "" {
+		fileName = field
+	}
+	part, err := writer.CreateFormFile(field, fileName)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := part.Write(file.Data); err != nil {
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", token, method)
+	resp, err := http.Post(url, writer.FormDataContentType(), &body)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	err = decodeTelegramResponse(resp, &msg)
+	return msg.MessageID, err
+}
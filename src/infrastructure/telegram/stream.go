@@ -0,0 +1,233 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// telegramMaxMessageLength is the Bot API limit for message text
+	telegramMaxMessageLength = 4096
+	// chatActionRefreshInterval re-sends "typing" before Telegram clears it (after 5s)
+	chatActionRefreshInterval = 4 * time.Second
+	// minProgressiveChars is how much text is collected before the first progressive message
+	minProgressiveChars = 20
+)
+
+// callTelegramAPI posts a Bot API method and decodes its result into result (optional)
+func callTelegramAPI(token, method string, payload interface{}, result interface{}) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", token, method)
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeTelegramResponse(resp, result)
+}
+
+// decodeTelegramResponse checks a Bot API response and decodes its result into result (optional)
+func decodeTelegramResponse(resp *http.Response, result interface{}) error {
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram API error: %s", string(body))
+	}
+	if result == nil {
+		return nil
+	}
+
+	var envelope struct {
+		Ok     bool            `json:"ok"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return err
+	}
+	if !envelope.Ok {
+		return fmt.Errorf("telegram API error: %s", string(body))
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// sendChatAction shows an action such as "typing" in the chat for about five seconds
+func sendChatAction(token string, chatID int64, action string) error {
+	return callTelegramAPI(token, "sendChatAction", map[string]interface{}{
+		"chat_id": chatID,
+		"action":  action,
+	}, nil)
+}
+
+// sendMessageWithID sends a message and returns its ID so it can be edited later
+func sendMessageWithID(token string, chatID int64, text string) (int, error) {
+	var msg TelegramMessage
+	err := callTelegramAPI(token, "sendMessage", map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}, &msg)
+	return msg.MessageID, err
+}
+
+// editMessageText replaces the text of a message sent by the bot
+func editMessageText(token string, chatID int64, messageID int, text string) error {
+	return callTelegramAPI(token, "editMessageText", map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"text":       text,
+	}, nil)
+}
+
+// splitMessage cuts text into parts that fit the Bot API message limit
+func splitMessage(text string, limit int) []string {
+	runes := []rune(text)
+	var parts []string
+	for len(runes) > limit {
+		cut := limit
+		// Prefer to break at a newline or space in the last part of the window
+		for i := limit; i > limit*3/4; i-- {
+			if runes[i] == '\n' || runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+// telegramReplyStream shows "typing" while an agent reply is generated and, with progressive
+// edits enabled, sends the reply early and keeps editing it as more text arrives
+type telegramReplyStream struct {
+	token  string
+	chatID int64
+
+	opts      settings.StreamingSettings
+	started   bool
+	text      strings.Builder
+	messageID int
+	shown     string // text currently visible in the progressive message
+	lastEdit  time.Time
+
+	typingOnce sync.Once
+	stopTyping chan struct{}
+}
+
+func newTelegramReplyStream(token string, chatID int64) *telegramReplyStream {
+	return &telegramReplyStream{
+		token:      token,
+		chatID:     chatID,
+		stopTyping: make(chan struct{}),
+	}
+}
+
+// Begin implements agent.ReplyStream
+func (t *telegramReplyStream) Begin(opts settings.StreamingSettings) {
+	t.opts = opts
+	t.started = true
+	if opts.TypingIndicator {
+		go t.keepTyping()
+	}
+}
+
+// Delta implements agent.ReplyStream
+func (t *telegramReplyStream) Delta(text string) {
+	t.text.WriteString(text)
+	if !t.opts.ProgressiveEdit {
+		return
+	}
+
+	current := strings.TrimSpace(t.text.String())
+	if len([]rune(current)) > telegramMaxMessageLength {
+		return // The rest is sent as separate messages by Finish
+	}
+	if t.messageID == 0 {
+		if len(current) < minProgressiveChars {
+			return
+		}
+		id, err := sendMessageWithID(t.token, t.chatID, current)
+		if err != nil {
+			logrus.Warnf("⚠️  [Telegram] Failed to send progressive message to chat %d: %v", t.chatID, err)
+			t.opts.ProgressiveEdit = false
+			return
+		}
+		t.messageID, t.shown, t.lastEdit = id, current, time.Now()
+		return
+	}
+	if time.Since(t.lastEdit) < time.Duration(t.opts.EditIntervalMs)*time.Millisecond {
+		return
+	}
+	t.edit(current)
+}
+
+// Finish delivers the complete reply: the progressive message gets its final text and
+// anything beyond the message limit is sent as follow-up messages
+func (t *telegramReplyStream) Finish() error {
+	t.Close()
+
+	parts := splitMessage(strings.TrimSpace(t.text.String()), telegramMaxMessageLength)
+	if len(parts) == 0 {
+		return nil
+	}
+	if t.messageID != 0 {
+		if err := t.edit(parts[0]); err != nil {
+			return err
+		}
+		parts = parts[1:]
+	}
+	for _, part := range parts {
+		if err := sendTelegramMessage(t.token, t.chatID, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the typing indicator without sending anything
+func (t *telegramReplyStream) Close() {
+	t.typingOnce.Do(func() { close(t.stopTyping) })
+}
+
+func (t *telegramReplyStream) edit(text string) error {
+	if text == t.shown {
+		return nil // Telegram rejects edits that do not change the text
+	}
+	if err := editMessageText(t.token, t.chatID, t.messageID, text); err != nil {
+		logrus.Warnf("⚠️  [Telegram] Failed to edit message %d in chat %d: %v", t.messageID, t.chatID, err)
+		return err
+	}
+	t.shown, t.lastEdit = text, time.Now()
+	return nil
+}
+
+func (t *telegramReplyStream) keepTyping() {
+	ticker := time.NewTicker(chatActionRefreshInterval)
+	defer ticker.Stop()
+	for {
+		if err := sendChatAction(t.token, t.chatID, "typing"); err != nil {
+			logrus.Debugf("⚠️  [Telegram] Failed to send chat action to chat %d: %v", t.chatID, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-t.stopTyping:
+			return
+		}
+	}
+}
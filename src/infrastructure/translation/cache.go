@@ -0,0 +1,165 @@
+package translation
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// DefaultCapacity is the number of translations kept in memory
+	DefaultCapacity = 1000
+	// retention is how long a translation that is never read again stays in SQLite
+	retention = 90 * 24 * time.Hour
+)
+
+// Cache stores translations keyed by text hash and language pair. Recently used entries are
+// kept in an in-memory LRU in front of a SQLite table, so they survive restarts.
+type Cache struct {
+	db       *sql.DB
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+}
+
+type cacheEntry struct {
+	key         string
+	translation string
+}
+
+// NewCache opens (or creates) the SQLite translation cache. An empty dbPath gives a memory-only cache.
+func NewCache(dbPath string, capacity int) (*Cache, error) {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	c := &Cache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+	if dbPath == "" {
+		return c, nil
+	}
+
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	c.db = db
+	if err := c.migrate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cache) migrate() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS translations (
+		cache_key TEXT PRIMARY KEY,
+		source_lang TEXT NOT NULL,
+		target_lang TEXT NOT NULL,
+		translation TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		used_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_translations_used_at ON translations(used_at);
+	`
+	if _, err := c.db.Exec(query); err != nil {
+		return err
+	}
+	// Drop translations nobody asked for in a long time
+	_, err := c.db.Exec(`DELETE FROM translations WHERE used_at < ?`, time.Now().Add(-retention))
+	return err
+}
+
+func (c *Cache) Close() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// Key identifies a translation of text from sourceLang to targetLang
+func Key(text, sourceLang, targetLang string) string {
+	sum := sha256.Sum256([]byte(text))
+	return sourceLang + ":" + targetLang + ":" + hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached translation of text, if any
+func (c *Cache) Get(ctx context.Context, text, sourceLang, targetLang string) (string, bool) {
+	key := Key(text, sourceLang, targetLang)
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		translated := el.Value.(*cacheEntry).translation
+		c.mu.Unlock()
+		return translated, true
+	}
+	c.mu.Unlock()
+
+	if c.db == nil {
+		return "", false
+	}
+	var translated string
+	err := c.db.QueryRowContext(ctx, `SELECT translation FROM translations WHERE cache_key = ?`, key).Scan(&translated)
+	if err != nil {
+		return "", false
+	}
+	c.db.ExecContext(ctx, `UPDATE translations SET used_at = ? WHERE cache_key = ?`, time.Now(), key)
+	c.remember(key, translated)
+	return translated, true
+}
+
+// Put stores the translation of text
+func (c *Cache) Put(ctx context.Context, text, sourceLang, targetLang, translated string) error {
+	key := Key(text, sourceLang, targetLang)
+	c.remember(key, translated)
+
+	if c.db == nil {
+		return nil
+	}
+	now := time.Now()
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO translations (cache_key, source_lang, target_lang, translation, created_at, used_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(cache_key) DO UPDATE SET translation = excluded.translation, used_at = excluded.used_at
+	`, key, sourceLang, targetLang, translated, now, now)
+	return err
+}
+
+// Len returns the number of translations held in memory
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remember(key, translated string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).translation = translated
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, translation: translated})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
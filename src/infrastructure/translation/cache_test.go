@@ -0,0 +1,42 @@
+package translation
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "translations.db")
+
+	cache, err := NewCache(dbPath, 2)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	cache.Put(ctx, "hola", "es", "en", "hello")
+	cache.Put(ctx, "adiós", "es", "en", "goodbye")
+	cache.Get(ctx, "hola", "es", "en") // "hola" becomes the most recently used
+	cache.Put(ctx, "gracias", "es", "en", "thanks")
+
+	if cache.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", cache.Len())
+	}
+	if got, ok := cache.Get(ctx, "hola", "es", "en"); !ok || got != "hello" {
+		t.Errorf("Get(hola) = %q, %v", got, ok)
+	}
+	if _, ok := cache.Get(ctx, "hola", "es", "fr"); ok {
+		t.Error("a different language pair must not hit")
+	}
+	cache.Close()
+
+	// Evicted and persisted entries are read back from SQLite
+	reopened, err := NewCache(dbPath, 2)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if got, ok := reopened.Get(ctx, "adiós", "es", "en"); !ok || got != "goodbye" {
+		t.Errorf("Get(adiós) after reopen = %q, %v", got, ok)
+	}
+}
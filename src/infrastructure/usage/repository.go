@@ -0,0 +1,266 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/usage"
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	repo := &SQLiteRepository{db: db}
+	if err := repo.migrate(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *SQLiteRepository) migrate() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS usage_records (
+		id TEXT PRIMARY KEY,
+		agent_id TEXT DEFAULT '',
+		conversation_id TEXT DEFAULT '',
+		flow_id TEXT DEFAULT '',
+		flow_execution_id TEXT DEFAULT '',
+		operation TEXT NOT NULL,
+		provider TEXT DEFAULT '',
+		model TEXT DEFAULT '',
+		prompt_tokens INTEGER DEFAULT 0,
+		completion_tokens INTEGER DEFAULT 0,
+		audio_seconds REAL DEFAULT 0,
+		latency_ms INTEGER DEFAULT 0,
+		cost REAL DEFAULT 0,
+		error TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_usage_agent ON usage_records(agent_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_usage_conversation ON usage_records(conversation_id);
+	CREATE INDEX IF NOT EXISTS idx_usage_flow_execution ON usage_records(flow_execution_id);
+	CREATE INDEX IF NOT EXISTS idx_usage_created ON usage_records(created_at);
+
+	CREATE TABLE IF NOT EXISTS model_prices (
+		model TEXT PRIMARY KEY,
+		prompt_per_million REAL DEFAULT 0,
+		completion_per_million REAL DEFAULT 0,
+		audio_per_minute REAL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteRepository) AddRecord(ctx context.Context, rec *usage.Record) error {
+	if rec.ID == "" {
+		rec.ID = uuid.New().String()
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	rec.CreatedAt = rec.CreatedAt.UTC() // Stored in UTC so time ranges compare as text
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO usage_records (id, agent_id, conversation_id, flow_id, flow_execution_id, operation, provider, model,
+			prompt_tokens, completion_tokens, audio_seconds, latency_ms, cost, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rec.ID, rec.AgentID, rec.ConversationID, rec.FlowID, rec.FlowExecutionID, rec.Operation, rec.Provider, rec.Model,
+		rec.PromptTokens, rec.CompletionTokens, rec.AudioSeconds, rec.LatencyMs, rec.Cost, rec.Error, rec.CreatedAt)
+	return err
+}
+
+// where builds the WHERE clause for a filter
+func where(filter usage.Filter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+	if filter.AgentID != "" {
+		add("agent_id = ?", filter.AgentID)
+	}
+	if filter.ConversationID != "" {
+		add("conversation_id = ?", filter.ConversationID)
+	}
+	if filter.FlowID != "" {
+		add("flow_id = ?", filter.FlowID)
+	}
+	if filter.FlowExecutionID != "" {
+		add("flow_execution_id = ?", filter.FlowExecutionID)
+	}
+	if filter.Operation != "" {
+		add("operation = ?", filter.Operation)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= ?", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		add("created_at < ?", filter.To.UTC())
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *SQLiteRepository) ListRecords(ctx context.Context, filter usage.Filter) ([]*usage.Record, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	clause, args := where(filter)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, agent_id, conversation_id, flow_id, flow_execution_id, operation, provider, model,
+			prompt_tokens, completion_tokens, audio_seconds, latency_ms, cost, error, created_at
+		FROM usage_records`+clause+`
+		ORDER BY created_at DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*usage.Record
+	for rows.Next() {
+		rec := &usage.Record{}
+		if err := rows.Scan(&rec.ID, &rec.AgentID, &rec.ConversationID, &rec.FlowID, &rec.FlowExecutionID, &rec.Operation,
+			&rec.Provider, &rec.Model, &rec.PromptTokens, &rec.CompletionTokens, &rec.AudioSeconds, &rec.LatencyMs,
+			&rec.Cost, &rec.Error, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// groupColumns maps group-by values to SQL expressions
+var groupColumns = map[string]string{
+	usage.GroupByAgent:        "agent_id",
+	usage.GroupByConversation: "conversation_id",
+	usage.GroupByFlow:         "flow_id",
+	usage.GroupByModel:        "model",
+	usage.GroupByOperation:    "operation",
+	usage.GroupByDay:          "substr(created_at, 1, 10)",
+}
+
+// Summarize aggregates the matching records, per group when groupBy is set
+func (r *SQLiteRepository) Summarize(ctx context.Context, filter usage.Filter, groupBy string) ([]usage.Totals, error) {
+	key := "''"
+	if groupBy != "" {
+		column, ok := groupColumns[groupBy]
+		if !ok {
+			return nil, fmt.Errorf("unsupported group by %q", groupBy)
+		}
+		key = column
+	}
+
+	clause, args := where(filter)
+	query := `
+		SELECT ` + key + ` AS group_key,
+			COUNT(*), SUM(CASE WHEN error != '' THEN 1 ELSE 0 END),
+			COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(audio_seconds), 0),
+			COALESCE(SUM(cost), 0), COALESCE(AVG(latency_ms), 0)
+		FROM usage_records` + clause
+	if groupBy != "" {
+		order := "SUM(cost) DESC"
+		if groupBy == usage.GroupByDay {
+			order = "group_key"
+		}
+		query += ` GROUP BY group_key ORDER BY ` + order
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []usage.Totals
+	for rows.Next() {
+		var t usage.Totals
+		var errorCount sql.NullInt64
+		if err := rows.Scan(&t.Key, &t.Calls, &errorCount, &t.PromptTokens, &t.CompletionTokens, &t.AudioSeconds,
+			&t.Cost, &t.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		t.Errors = int(errorCount.Int64)
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// TotalCost returns what an agent has spent since a point in time
+func (r *SQLiteRepository) TotalCost(ctx context.Context, agentID string, since time.Time) (float64, error) {
+	var total float64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(cost), 0) FROM usage_records WHERE agent_id = ? AND created_at >= ?`,
+		agentID, since.UTC()).Scan(&total)
+	return total, err
+}
+
+func (r *SQLiteRepository) GetPrices(ctx context.Context) ([]usage.ModelPrice, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT model, prompt_per_million, completion_per_million, audio_per_minute, updated_at
+		FROM model_prices ORDER BY model`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []usage.ModelPrice
+	for rows.Next() {
+		var p usage.ModelPrice
+		if err := rows.Scan(&p.Model, &p.PromptPerMillion, &p.CompletionPerMillion, &p.AudioPerMinute, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+func (r *SQLiteRepository) SavePrice(ctx context.Context, price *usage.ModelPrice) error {
+	price.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO model_prices (model, prompt_per_million, completion_per_million, audio_per_minute, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(model) DO UPDATE SET prompt_per_million = excluded.prompt_per_million,
+			completion_per_million = excluded.completion_per_million,
+			audio_per_minute = excluded.audio_per_minute, updated_at = excluded.updated_at
+	`, price.Model, price.PromptPerMillion, price.CompletionPerMillion, price.AudioPerMinute, price.UpdatedAt)
+	return err
+}
+
+func (r *SQLiteRepository) DeletePrice(ctx context.Context, model string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM model_prices WHERE model = ?`, model)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -2,7 +2,6 @@ package whatsapp
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"sync"
 
@@ -20,10 +19,24 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// MessageResponder produces the agent reply for an incoming message and its attachments, streaming
+// it to stream when the agent has streaming enabled.
+// It is wired to AgentService.HandleIncomingMessageStream from cmd to avoid an import cycle.
+type MessageResponder func(ctx context.Context, agentID, integrationID, remoteJID, message string, attachments []agent.Attachment, stream agent.ReplyStream) (string, error)
+
+// VoiceReplier answers voice notes with synthesized voice notes.
+// It is implemented by AgentService and wired from cmd to avoid an import cycle.
+type VoiceReplier interface {
+	VoiceRepliesEnabled(ctx context.Context, agentID string) bool
+	SendWhatsAppVoiceReply(ctx context.Context, agentID, phone, text string) error
+}
+
 // AgentMessageHandler handles incoming messages for agents with WhatsApp integrations
 type AgentMessageHandler struct {
-	agentRepo *agentRepo.SQLiteRepository
-	mu        sync.RWMutex
+	agentRepo    *agentRepo.SQLiteRepository
+	responder    MessageResponder
+	voiceReplier VoiceReplier
+	mu           sync.RWMutex
 }
 
 var (
@@ -48,6 +61,26 @@ func GetAgentHandler() *AgentMessageHandler {
 	return agentHandler
 }
 
+// SetResponder sets the function that generates agent replies
+func (h *AgentMessageHandler) SetResponder(responder MessageResponder) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.responder = responder
+}
+
+// SetVoiceReplier enables voice replies to voice notes
+func (h *AgentMessageHandler) SetVoiceReplier(voiceReplier VoiceReplier) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.voiceReplier = voiceReplier
+}
+
 // HandleIncomingMessage processes incoming WhatsApp messages for all active agents
 func (h *AgentMessageHandler) HandleIncomingMessage(
 	ctx context.Context,
@@ -80,9 +113,9 @@ func (h *AgentMessageHandler) HandleIncomingMessage(
 		return
 	}
 
-	// Extract user message
-	userMessage := h.extractMessage(ctx, evt, client)
-	if userMessage == "" {
+	// Extract user message and any image or document sent with it
+	userMessage, attachments := h.extractMessage(ctx, evt, client)
+	if userMessage == "" && len(attachments) == 0 {
 		return
 	}
 
@@ -241,7 +274,7 @@ func (h *AgentMessageHandler) HandleIncomingMessage(
 		foundAgent = true
 		logrus.Infof("🚀 [WhatsApp Agent] Processing message for agent %s (%s) with integration %s", ag.ID, ag.Name, matchingIntegration.ID)
 		// Process message for this agent
-		go h.processMessageForAgent(ctx, ag, matchingIntegration, remoteJID, userMessage, chatStorageRepo, client)
+		go h.processMessageForAgent(ctx, ag, matchingIntegration, remoteJID, userMessage, attachments, chatStorageRepo, client)
 	}
 
 	if !foundAgent {
@@ -249,7 +282,7 @@ func (h *AgentMessageHandler) HandleIncomingMessage(
 	}
 }
 
-func (h *AgentMessageHandler) extractMessage(ctx context.Context, evt *events.Message, client *whatsmeow.Client) string {
+func (h *AgentMessageHandler) extractMessage(ctx context.Context, evt *events.Message, client *whatsmeow.Client) (string, []agent.Attachment) {
 	// Unwrap FutureProof wrappers
 	innerMsg := evt.Message
 	for i := 0; i < 3; i++ {
@@ -274,35 +307,76 @@ func (h *AgentMessageHandler) extractMessage(ctx context.Context, evt *events.Me
 
 	// Extract text from message
 	if conv := innerMsg.GetConversation(); conv != "" {
-		return conv
+		return conv, nil
 	} else if ext := innerMsg.GetExtendedTextMessage(); ext != nil && ext.GetText() != "" {
-		return ext.GetText()
+		return ext.GetText(), nil
 	} else if protoMsg := innerMsg.GetProtocolMessage(); protoMsg != nil {
 		if edited := protoMsg.GetEditedMessage(); edited != nil {
 			if ext := edited.GetExtendedTextMessage(); ext != nil && ext.GetText() != "" {
-				return ext.GetText()
+				return ext.GetText(), nil
 			} else if conv := edited.GetConversation(); conv != "" {
-				return conv
+				return conv, nil
 			}
 		}
 	} else if audioMsg := innerMsg.GetAudioMessage(); audioMsg != nil {
 		// Handle audio messages - transcribe them
 		if !config.WhatsappAutoDownloadMedia {
 			logrus.Warnf("Agent received audio message but auto-download-media is disabled")
-			return ""
+			return "", nil
 		}
 
 		extractedMedia, err := utils.ExtractMedia(ctx, client, config.PathMedia, audioMsg)
 		if err != nil {
 			logrus.Errorf("Failed to download audio for transcription: %v", err)
-			return ""
+			return "", nil
 		}
 
 		// We'll transcribe in processMessageForAgent using the agent's API key
-		return "[AUDIO:" + extractedMedia.MediaPath + "]"
+		return "[AUDIO:" + extractedMedia.MediaPath + "]", nil
+	} else if imageMsg := innerMsg.GetImageMessage(); imageMsg != nil {
+		att := agent.Attachment{Type: agent.AttachmentTypeImage, MIMEType: imageMsg.GetMimetype()}
+		return imageMsg.GetCaption(), downloadAttachment(ctx, client, imageMsg, att, imageMsg.GetFileLength(), config.WhatsappSettingMaxImageSize)
+	} else if sticker := innerMsg.GetStickerMessage(); sticker != nil && !sticker.GetIsAnimated() {
+		att := agent.Attachment{Type: agent.AttachmentTypeImage, MIMEType: sticker.GetMimetype()}
+		return "", downloadAttachment(ctx, client, sticker, att, sticker.GetFileLength(), config.WhatsappSettingMaxImageSize)
+	} else if docMsg := documentMessage(innerMsg); docMsg != nil {
+		att := agent.Attachment{Type: agent.AttachmentTypeDocument, MIMEType: docMsg.GetMimetype(), FileName: docMsg.GetFileName()}
+		limit := config.WhatsappSettingMaxFileSize
+		if strings.HasPrefix(docMsg.GetMimetype(), "image/") {
+			att.Type, limit = agent.AttachmentTypeImage, config.WhatsappSettingMaxImageSize
+		}
+		return docMsg.GetCaption(), downloadAttachment(ctx, client, docMsg, att, docMsg.GetFileLength(), limit)
 	}
 
-	return ""
+	return "", nil
+}
+
+// documentMessage returns the document of a message, including documents sent with a caption
+func documentMessage(msg *waE2E.Message) *waE2E.DocumentMessage {
+	if doc := msg.GetDocumentMessage(); doc != nil {
+		return doc
+	}
+	return msg.GetDocumentWithCaptionMessage().GetMessage().GetDocumentMessage()
+}
+
+// downloadAttachment downloads an image or document for the agent. Files over the size limit, or
+// when media download is disabled, are passed without data so the agent can still mention them.
+func downloadAttachment(ctx context.Context, client *whatsmeow.Client, media whatsmeow.DownloadableMessage,
+	att agent.Attachment, size uint64, limit int64) []agent.Attachment {
+	switch {
+	case !config.WhatsappAutoDownloadMedia:
+		logrus.Warnf("Agent received %s but auto-download-media is disabled", att.Type)
+	case int64(size) > limit:
+		logrus.Warnf("⚠️  [WhatsApp Agent] Not downloading %s %q: %d bytes exceeds the %d byte limit", att.Type, att.FileName, size, limit)
+	default:
+		data, err := client.Download(ctx, media)
+		if err != nil {
+			logrus.Errorf("Failed to download %s for agent: %v", att.Type, err)
+		} else {
+			att.Data = data
+		}
+	}
+	return []agent.Attachment{att}
 }
 
 func (h *AgentMessageHandler) processMessageForAgent(
@@ -311,21 +385,24 @@ func (h *AgentMessageHandler) processMessageForAgent(
 	integration *agent.Integration,
 	remoteJID string,
 	userMessage string,
+	attachments []agent.Attachment,
 	chatStorageRepo domainChatStorage.IChatStorageRepository,
 	client *whatsmeow.Client,
 ) {
 	logrus.Infof("🤖 [WhatsApp Agent] Processing message for agent %s (%s): %s", ag.ID, ag.Name, userMessage[:min(50, len(userMessage))])
 
 	// Create AI service with agent's API key
-	aiSvc := aiService.NewService(ag.APIKey, ag.SerpAPIKey)
+	aiSvc := aiService.NewServiceForAgent(ag)
 	if aiSvc == nil {
 		logrus.Errorf("❌ [WhatsApp Agent] Failed to create AI service for agent %s (API key: %v)", ag.ID, ag.APIKey != "")
 		return
 	}
 	logrus.Debugf("✅ [WhatsApp Agent] AI service created for agent %s", ag.ID)
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{AgentID: ag.ID})
 
 	// Handle audio transcription if needed
-	if strings.HasPrefix(userMessage, "[AUDIO:") && strings.HasSuffix(userMessage, "]") {
+	isVoice := strings.HasPrefix(userMessage, "[AUDIO:") && strings.HasSuffix(userMessage, "]")
+	if isVoice {
 		audioPath := userMessage[7 : len(userMessage)-1]
 		transcription, err := aiSvc.TranscribeAudio(ctx, audioPath)
 		if err != nil {
@@ -334,113 +411,91 @@ func (h *AgentMessageHandler) processMessageForAgent(
 		}
 		userMessage = transcription
 		logrus.Infof("Agent %s transcribed audio: %s", ag.ID, userMessage)
+		ctx = agent.ContextWithMessageType(ctx, agent.MessageTypeAudio)
 	}
 
-	// Get or create conversation
-	conv, err := h.agentRepo.GetOrCreateConversation(ctx, ag.ID, integration.ID, remoteJID)
-	if err != nil {
-		logrus.Errorf("❌ [WhatsApp Agent] Failed to get conversation for agent %s: %v", ag.ID, err)
-		return
-	}
-	logrus.Debugf("💬 [WhatsApp Agent] Conversation %s found/created for agent %s", conv.ID, ag.ID)
-
-	// Check if in manual mode (manager took over)
-	if conv.IsManualMode {
-		// Store user message but don't generate AI response
-		userMsg := &agent.Message{
-			ConversationID: conv.ID,
-			Role:           "user",
-			Content:        userMessage,
-		}
-		h.agentRepo.AddMessage(ctx, userMsg)
-		logrus.Infof("⏸️  [WhatsApp Agent] Conversation %s is in manual mode, skipping AI response", conv.ID)
+	h.mu.RLock()
+	responder := h.responder
+	voiceReplier := h.voiceReplier
+	h.mu.RUnlock()
+	if responder == nil {
+		logrus.Errorf("❌ [WhatsApp Agent] No responder configured, cannot process message for agent %s", ag.ID)
 		return
 	}
 
-	// Store user message
-	userMsg := &agent.Message{
-		ConversationID: conv.ID,
-		Role:           "user",
-		Content:        userMessage,
+	recipientJID := utils.FormatJID(remoteJID)
+	send := func(ctx context.Context, text string) error {
+		return h.sendReply(ctx, client, recipientJID, text, chatStorageRepo)
 	}
-	if err := h.agentRepo.AddMessage(ctx, userMsg); err != nil {
-		logrus.Errorf("Failed to store user message: %v", err)
+	stream := newWhatsAppReplyStream(ctx, client, recipientJID, send)
+	defer stream.Close()
+
+	// Voice replies are spoken once complete, so they are not streamed as text
+	voiceReply := isVoice && voiceReplier != nil && voiceReplier.VoiceRepliesEnabled(ctx, ag.ID)
+	var replyStream agent.ReplyStream = stream
+	if voiceReply {
+		replyStream = nil
 	}
 
-	var response string
-
-	// Check if this is the first reply - send welcome message
-	if !conv.IsFirstReply && ag.WelcomeMessage != "" {
-		response = ag.WelcomeMessage
-		conv.IsFirstReply = true
-		if err := h.agentRepo.UpdateConversation(ctx, conv); err != nil {
-			logrus.Errorf("Failed to update conversation: %v", err)
-		}
-	} else {
-		// Get recent messages for context (limited to 5 for efficiency)
-		recentMessages, err := h.agentRepo.GetRecentMessages(ctx, conv.ID, 5)
-		if err != nil {
-			logrus.Errorf("Failed to get recent messages: %v", err)
-		}
-
-		// Build context from recent messages
-		var contextBuilder strings.Builder
-		for _, msg := range recentMessages {
-			if msg.Role == "user" {
-				contextBuilder.WriteString(fmt.Sprintf("User: %s\n", msg.Content))
-			} else if msg.Role == "assistant" {
-				contextBuilder.WriteString(fmt.Sprintf("Assistant: %s\n", msg.Content))
-			}
-		}
-
-		// If we have context, include it in the prompt
-		finalPrompt := userMessage
-		if contextBuilder.Len() > 0 {
-			finalPrompt = fmt.Sprintf("Previous conversation:\n%s\nCurrent message: %s", contextBuilder.String(), userMessage)
-		}
-
-		response, err = aiSvc.GenerateResponse(ctx, finalPrompt, ag.SystemPrompt, ag.Model, 500, 0.7)
-		if err != nil {
-			logrus.Errorf("❌ [WhatsApp Agent] Failed to generate AI response for agent %s: %v", ag.ID, err)
-			return
-		}
-
-		// Mark conversation as having had first reply
-		if !conv.IsFirstReply {
-			conv.IsFirstReply = true
-			h.agentRepo.UpdateConversation(ctx, conv)
-		}
+	// Conversation storage, history, settings and manual mode are handled by the responder
+	response, err := responder(ctx, ag.ID, integration.ID, remoteJID, userMessage, attachments, replyStream)
+	if err != nil {
+		logrus.Errorf("❌ [WhatsApp Agent] Failed to generate AI response for agent %s: %v", ag.ID, err)
+		return
 	}
 
 	if response == "" {
-		logrus.Warnf("⚠️  [WhatsApp Agent] Agent %s: AI returned empty response", ag.ID)
+		logrus.Warnf("⚠️  [WhatsApp Agent] Agent %s: AI returned empty response (manual mode or error)", ag.ID)
 		return
 	}
 
 	logrus.Infof("💡 [WhatsApp Agent] AI response generated for agent %s: %s", ag.ID, response[:min(100, len(response))])
 
-	// Store assistant response
-	assistantMsg := &agent.Message{
-		ConversationID: conv.ID,
-		Role:           "assistant",
-		Content:        response,
+	// Voice replies fall back to text; streamed replies were partly delivered already, so only
+	// what is left is sent
+	if voiceReply {
+		err = sendVoiceReply(ctx, voiceReplier, ag.ID, recipientJID.String(), response, send)
+	} else if stream.started {
+		err = stream.Finish()
+	} else {
+		err = send(ctx, response)
 	}
-	if err := h.agentRepo.AddMessage(ctx, assistantMsg); err != nil {
-		logrus.Errorf("Failed to store assistant message: %v", err)
+	if err != nil {
+		logrus.Errorf("❌ [WhatsApp Agent] Failed to send agent %s response to %s: %v", ag.ID, recipientJID, err)
+		return
 	}
 
-	// Send the response via WhatsApp
-	recipientJID := utils.FormatJID(remoteJID)
-	logrus.Infof("📤 [WhatsApp Agent] Sending response to %s via agent %s", recipientJID, ag.ID)
+	logrus.Infof("✅ [WhatsApp Agent] Agent %s successfully processed and sent response to %s", ag.Name, remoteJID)
+}
+
+// sendVoiceReply sends response as a voice note, or as text with sendText when the voice note
+// can't be synthesized, converted or sent
+func sendVoiceReply(ctx context.Context, replier VoiceReplier, agentID, phone, response string, sendText func(context.Context, string) error) error {
+	err := replier.SendWhatsAppVoiceReply(ctx, agentID, phone, response)
+	if err == nil {
+		logrus.Infof("✅ [WhatsApp Agent] Agent %s sent voice response to %s", agentID, phone)
+		return nil
+	}
+	logrus.Warnf("⚠️  [WhatsApp Agent] Voice reply failed for agent %s, sending text instead: %v", agentID, err)
+	return sendText(ctx, response)
+}
+
+// sendReply sends one text message to the recipient and stores it in chat storage
+func (h *AgentMessageHandler) sendReply(
+	ctx context.Context,
+	client *whatsmeow.Client,
+	recipientJID types.JID,
+	text string,
+	chatStorageRepo domainChatStorage.IChatStorageRepository,
+) error {
+	logrus.Infof("📤 [WhatsApp Agent] Sending response to %s", recipientJID)
 	sendResp, err := client.SendMessage(
 		ctx,
 		recipientJID,
-		&waE2E.Message{Conversation: proto.String(response)},
+		&waE2E.Message{Conversation: proto.String(text)},
 	)
-
 	if err != nil {
-		logrus.Errorf("❌ [WhatsApp Agent] Failed to send agent %s response to %s: %v", ag.ID, recipientJID, err)
-		return
+		return err
 	}
 	logrus.Infof("✅ [WhatsApp Agent] Response sent successfully (message ID: %s)", sendResp.ID)
 
@@ -456,14 +511,13 @@ func (h *AgentMessageHandler) processMessageForAgent(
 			sendResp.ID,
 			senderJID,
 			recipientJID.String(),
-			response,
+			text,
 			sendResp.Timestamp,
 		); err != nil {
 			logrus.Errorf("Failed to store agent response in chat storage: %v", err)
 		}
 	}
-
-	logrus.Infof("✅ [WhatsApp Agent] Agent %s successfully processed and sent response to %s", ag.Name, remoteJID)
+	return nil
 }
 
 func min(a, b int) int {
@@ -0,0 +1,31 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingVoiceReplier fails every voice note, as when ffmpeg can't convert the speech
+type failingVoiceReplier struct{}
+
+func (failingVoiceReplier) VoiceRepliesEnabled(ctx context.Context, agentID string) bool { return true }
+
+func (failingVoiceReplier) SendWhatsAppVoiceReply(ctx context.Context, agentID, phone, text string) error {
+	return errors.New("failed to convert speech to opus: ffmpeg not found")
+}
+
+func TestSendVoiceReplyFallsBackToText(t *testing.T) {
+	var sent []string
+	sendText := func(ctx context.Context, text string) error {
+		sent = append(sent, text)
+		return nil
+	}
+
+	if err := sendVoiceReply(context.Background(), failingVoiceReplier{}, "agent-1", "628111@s.whatsapp.net", "We open at 9.", sendText); err != nil {
+		t.Fatalf("sendVoiceReply() error = %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "We open at 9." {
+		t.Fatalf("text sent = %q, want the reply once", sent)
+	}
+}
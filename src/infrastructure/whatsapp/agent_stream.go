@@ -0,0 +1,166 @@
+package whatsapp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// composingRefreshInterval re-sends "composing" before WhatsApp clears the indicator
+const composingRefreshInterval = 10 * time.Second
+
+// paragraphSplitter cuts streamed text into paragraph-sized messages
+type paragraphSplitter struct {
+	minChars int
+	buf      string
+}
+
+// Push appends text and returns the paragraphs that are complete and at least minChars long.
+// Shorter paragraphs stay buffered and are merged with the next one.
+func (p *paragraphSplitter) Push(text string) []string {
+	p.buf += text
+	var chunks []string
+	for {
+		cut := -1
+		for from := 0; ; {
+			idx := strings.Index(p.buf[from:], "\n\n")
+			if idx < 0 {
+				break
+			}
+			idx += from
+			if len(strings.TrimSpace(p.buf[:idx])) >= p.minChars {
+				cut = idx
+				break
+			}
+			from = idx + 2
+		}
+		if cut < 0 {
+			return chunks
+		}
+		chunks = append(chunks, strings.TrimSpace(p.buf[:cut]))
+		p.buf = strings.TrimLeft(p.buf[cut:], "\n")
+	}
+}
+
+// Flush returns whatever text is still buffered
+func (p *paragraphSplitter) Flush() string {
+	rest := strings.TrimSpace(p.buf)
+	p.buf = ""
+	return rest
+}
+
+// replySender sends one reply message to the chat
+type replySender func(ctx context.Context, text string) error
+
+// whatsappReplyStream shows "composing" while an agent reply is generated and, when
+// paragraph splitting is enabled, sends each finished paragraph as its own message
+type whatsappReplyStream struct {
+	ctx       context.Context
+	client    *whatsmeow.Client
+	recipient types.JID
+	send      replySender
+
+	opts     settings.StreamingSettings
+	started  bool
+	splitter paragraphSplitter
+	sendErr  error
+
+	typingOnce sync.Once
+	stopTyping chan struct{}
+	typingDone chan struct{}
+}
+
+func newWhatsAppReplyStream(ctx context.Context, client *whatsmeow.Client, recipient types.JID, send replySender) *whatsappReplyStream {
+	return &whatsappReplyStream{
+		ctx:        ctx,
+		client:     client,
+		recipient:  recipient,
+		send:       send,
+		stopTyping: make(chan struct{}),
+		typingDone: make(chan struct{}),
+	}
+}
+
+// Begin implements agent.ReplyStream
+func (w *whatsappReplyStream) Begin(opts settings.StreamingSettings) {
+	w.opts = opts
+	w.started = true
+	w.splitter.minChars = opts.MinChunkChars
+	if opts.TypingIndicator {
+		go w.keepComposing()
+	} else {
+		close(w.typingDone)
+	}
+}
+
+// Delta implements agent.ReplyStream
+func (w *whatsappReplyStream) Delta(text string) {
+	if !w.opts.SplitParagraphs || w.sendErr != nil {
+		w.splitter.buf += text
+		return
+	}
+	for _, chunk := range w.splitter.Push(text) {
+		if err := w.send(w.ctx, chunk); err != nil {
+			w.sendErr = err
+			return
+		}
+		// Sending a message clears the indicator while more text is still coming
+		if w.opts.TypingIndicator {
+			w.setPresence(types.ChatPresenceComposing)
+		}
+	}
+}
+
+// Finish sends the text that has not been delivered yet and clears the typing indicator
+func (w *whatsappReplyStream) Finish() error {
+	w.Close()
+	if w.sendErr != nil {
+		return w.sendErr
+	}
+	if rest := w.splitter.Flush(); rest != "" {
+		return w.send(w.ctx, rest)
+	}
+	return nil
+}
+
+// Close stops the typing indicator without sending anything
+func (w *whatsappReplyStream) Close() {
+	w.typingOnce.Do(func() {
+		close(w.stopTyping)
+		if !w.started {
+			return
+		}
+		<-w.typingDone
+		if w.opts.TypingIndicator {
+			w.setPresence(types.ChatPresencePaused)
+		}
+	})
+}
+
+func (w *whatsappReplyStream) keepComposing() {
+	defer close(w.typingDone)
+	ticker := time.NewTicker(composingRefreshInterval)
+	defer ticker.Stop()
+	for {
+		w.setPresence(types.ChatPresenceComposing)
+		select {
+		case <-ticker.C:
+		case <-w.stopTyping:
+			return
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *whatsappReplyStream) setPresence(state types.ChatPresence) {
+	if err := w.client.SendChatPresence(w.ctx, w.recipient, state, types.ChatPresenceMediaText); err != nil {
+		logrus.Debugf("⚠️  [WhatsApp Agent] Failed to send %s presence to %s: %v", state, w.recipient, err)
+	}
+}
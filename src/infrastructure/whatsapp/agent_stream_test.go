@@ -0,0 +1,26 @@
+package whatsapp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParagraphSplitter(t *testing.T) {
+	splitter := paragraphSplitter{minChars: 10}
+
+	var chunks []string
+	for _, delta := range []string{"Hi!\n\nThanks for", " asking about", " pricing.\n", "\nBasic is $10", " a month.\n\n\nPro is $25."} {
+		chunks = append(chunks, splitter.Push(delta)...)
+	}
+
+	want := []string{"Hi!\n\nThanks for asking about pricing.", "Basic is $10 a month."}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Fatalf("Push() chunks = %q, want %q", chunks, want)
+	}
+	if rest := splitter.Flush(); rest != "Pro is $25." {
+		t.Fatalf("Flush() = %q, want %q", rest, "Pro is $25.")
+	}
+	if rest := splitter.Flush(); rest != "" {
+		t.Fatalf("second Flush() = %q, want empty", rest)
+	}
+}
@@ -0,0 +1,255 @@
+// Package encryption encrypts secrets stored in the application databases.
+//
+// Values use envelope encryption: each value is encrypted with its own random data key, and the
+// data key is encrypted with a master key. Stored values read
+//
+//	enc:v1:<master key ID>:<encrypted data key>:<encrypted value>
+//
+// Several master keys may be configured to rotate them: the first encrypts, and all decrypt.
+// Values without the prefix are plaintext written before a master key was configured; they are
+// returned as they are until re-encrypted.
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	prefix  = "enc:v1:"
+	keySize = 32 // AES-256
+)
+
+// ErrNoMasterKey is returned when reading an encrypted value without a master key configured
+var ErrNoMasterKey = errors.New("value is encrypted but no master key is configured")
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the master keys; the first one encrypts
+type Keyring struct {
+	keys []masterKey
+}
+
+// NewKeyring returns a keyring of base64 encoded 32-byte master keys
+func NewKeyring(keys ...string) (*Keyring, error) {
+	k := &Keyring{}
+	seen := map[string]bool{}
+	for i, encoded := range keys {
+		encoded = strings.TrimSpace(encoded)
+		if encoded == "" {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != keySize {
+			return nil, fmt.Errorf("master key %d must be %d bytes encoded in base64", i+1, keySize)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		// The ID tells which key encrypted a value without revealing the key
+		sum := sha256.Sum256(raw)
+		id := hex.EncodeToString(sum[:4])
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		k.keys = append(k.keys, masterKey{id: id, aead: aead})
+	}
+	if len(k.keys) == 0 {
+		return nil, fmt.Errorf("no master key given")
+	}
+	return k, nil
+}
+
+// ReadKeyFile reads master keys from a file, one per line; blank lines and lines starting with #
+// are skipped
+func ReadKeyFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// GenerateKey returns a new random master key encoded in base64
+func GenerateKey() (string, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// KeyID returns the ID of the master key that encrypts
+func (k *Keyring) KeyID() string {
+	return k.keys[0].id
+}
+
+// Encrypt encrypts a value with a new data key. Empty values stay empty.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	master := k.keys[0]
+	wrapped, err := seal(master.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return prefix + master.id + ":" + wrapped + ":" + ciphertext, nil
+}
+
+// Decrypt returns the plaintext of a value; plaintext values are returned as they are
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	var master *masterKey
+	for i := range k.keys {
+		if k.keys[i].id == parts[0] {
+			master = &k.keys[i]
+			break
+		}
+	}
+	if master == nil {
+		return "", fmt.Errorf("value was encrypted with master key %s, which is not configured", parts[0])
+	}
+	dataKey, err := open(master.aead, parts[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, parts[2])
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Current reports whether a value is empty or encrypted with the master key that encrypts
+func (k *Keyring) Current(value string) bool {
+	return value == "" || strings.HasPrefix(value, prefix+k.keys[0].id+":")
+}
+
+// IsEncrypted reports whether a value was written by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data, returning the nonce and ciphertext encoded in base64
+func seal(aead cipher.AEAD, data []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+func open(aead cipher.AEAD, encoded string) ([]byte, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	return aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// SetKeyring sets the keyring the repositories use; nil stores new values in plaintext
+func SetKeyring(k *Keyring) {
+	defaultMu.Lock()
+	defaultKeyring = k
+	defaultMu.Unlock()
+}
+
+func keyring() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
+
+// Enabled reports whether a master key is configured
+func Enabled() bool {
+	return keyring() != nil
+}
+
+// Encrypt encrypts a value with the configured keyring, or returns it as it is without one
+func Encrypt(plaintext string) (string, error) {
+	k := keyring()
+	if k == nil {
+		return plaintext, nil
+	}
+	return k.Encrypt(plaintext)
+}
+
+// Decrypt decrypts a value with the configured keyring
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	k := keyring()
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+	return k.Decrypt(value)
+}
+
+// NeedsEncrypt reports whether a value should be rewritten: plaintext, or encrypted with a
+// master key other than the one that encrypts. It is false without a master key.
+func NeedsEncrypt(value string) bool {
+	k := keyring()
+	return k != nil && !k.Current(value)
+}
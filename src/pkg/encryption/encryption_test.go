@@ -0,0 +1,119 @@
+package encryption
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newKey(t *testing.T) string {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyring(t *testing.T) {
+	oldKey, newKeyValue := newKey(t), newKey(t)
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	encrypted, err := old.Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "sk-secret") {
+		t.Fatalf("Encrypt() = %q", encrypted)
+	}
+	if again, _ := old.Encrypt("sk-secret"); again == encrypted {
+		t.Errorf("Encrypt() returned the same value twice")
+	}
+	if got, err := old.Decrypt(encrypted); err != nil || got != "sk-secret" {
+		t.Fatalf("Decrypt() = %q, %v", got, err)
+	}
+	if got, err := old.Decrypt("plain"); err != nil || got != "plain" {
+		t.Errorf("Decrypt() of plaintext = %q, %v", got, err)
+	}
+	if got, _ := old.Encrypt(""); got != "" {
+		t.Errorf("Encrypt() of an empty value = %q", got)
+	}
+
+	// A rotated keyring still reads values of the old key, but they are no longer current
+	rotated, err := NewKeyring(newKeyValue, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	if got, err := rotated.Decrypt(encrypted); err != nil || got != "sk-secret" {
+		t.Fatalf("Decrypt() with the rotated keyring = %q, %v", got, err)
+	}
+	if rotated.Current(encrypted) || !old.Current(encrypted) {
+		t.Errorf("Current() does not follow the first key")
+	}
+
+	other, _ := NewKeyring(newKeyValue)
+	if _, err := other.Decrypt(encrypted); err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("Decrypt() without the key error = %v", err)
+	}
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if _, err := old.Decrypt(tampered); err == nil {
+		t.Errorf("Decrypt() of a tampered value succeeded")
+	}
+
+	for _, keys := range [][]string{{"short"}, {"not base64!"}, {""}} {
+		if _, err := NewKeyring(keys...); err == nil {
+			t.Errorf("NewKeyring(%q) succeeded", keys)
+		}
+	}
+}
+
+func TestRewriteColumns(t *testing.T) {
+	defer SetKeyring(nil)
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE agents (id TEXT PRIMARY KEY, api_key TEXT, serp_api_key TEXT);
+		INSERT INTO agents VALUES ('a', 'sk-a', NULL), ('b', '', '')`); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	SetKeyring(nil)
+	if _, err := RewriteColumns(ctx, db, "agents", "id", "api_key"); err != ErrNoMasterKey {
+		t.Fatalf("RewriteColumns() without a key error = %v", err)
+	}
+
+	read := func() string {
+		var value string
+		db.QueryRow(`SELECT api_key FROM agents WHERE id = 'a'`).Scan(&value)
+		return value
+	}
+	oldKey := newKey(t)
+	for i, keys := range [][]string{{oldKey}, {newKey(t), oldKey}} {
+		keyring, _ := NewKeyring(keys...)
+		SetKeyring(keyring)
+		count, err := RewriteColumns(ctx, db, "agents", "id", "api_key", "serp_api_key")
+		if err != nil || count != 1 {
+			t.Fatalf("pass %d: RewriteColumns() = %d, %v; want 1 row", i+1, count, err)
+		}
+		if stored := read(); !keyring.Current(stored) {
+			t.Fatalf("pass %d: stored value %q is not encrypted with the first key", i+1, stored)
+		}
+		if got, err := Decrypt(read()); err != nil || got != "sk-a" {
+			t.Fatalf("pass %d: Decrypt() = %q, %v", i+1, got, err)
+		}
+	}
+
+	if count, err := RewriteColumns(ctx, db, "agents", "id", "api_key", "serp_api_key"); err != nil || count != 0 {
+		t.Errorf("RewriteColumns() of current values = %d, %v", count, err)
+	}
+}
@@ -0,0 +1,87 @@
+package encryption
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Reencrypt returns a value encrypted with the master key that encrypts, decrypting it first
+// when it was encrypted with an older one
+func Reencrypt(value string) (string, error) {
+	plaintext, err := Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(plaintext)
+}
+
+// RewriteColumns encrypts the values of columns of a table that are stored in plaintext or with
+// an older master key, returning how many rows were rewritten. Table and column names are
+// trusted. It does nothing without a master key.
+func RewriteColumns(ctx context.Context, db *sql.DB, table, idColumn string, columns ...string) (int, error) {
+	if !Enabled() {
+		return 0, ErrNoMasterKey
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT %s, %s FROM %s", idColumn, strings.Join(columns, ", "), table))
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		id     string
+		values []sql.NullString
+	}
+	var pending []row
+	for rows.Next() {
+		r := row{values: make([]sql.NullString, len(columns))}
+		dest := []interface{}{&r.id}
+		for i := range r.values {
+			dest = append(dest, &r.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		for _, value := range r.values {
+			if value.Valid && NeedsEncrypt(value.String) {
+				pending = append(pending, r)
+				break
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = column + " = ?"
+	}
+	update := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", table, strings.Join(assignments, ", "), idColumn)
+	for _, r := range pending {
+		args := make([]interface{}, 0, len(columns)+1)
+		for i, value := range r.values {
+			if !value.Valid {
+				args = append(args, nil)
+				continue
+			}
+			encrypted, err := Reencrypt(value.String)
+			if err != nil {
+				return 0, fmt.Errorf("%s %s.%s: %w", table, r.id, columns[i], err)
+			}
+			args = append(args, encrypted)
+		}
+		if _, err := tx.ExecContext(ctx, update, append(args, r.id)...); err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), tx.Commit()
+}
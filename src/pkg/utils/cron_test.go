@@ -0,0 +1,64 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	require.NoError(t, err)
+	from := time.Date(2025, 3, 14, 10, 7, 30, 0, time.UTC) // Friday
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", from, time.Date(2025, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", from, time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"30 8-18/2 * * *", from, time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", from, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * 5", from, time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)}, // Either day field matches
+		{"0 0 * * 7", from, time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * *", from.In(jakarta), time.Date(2025, 3, 15, 9, 0, 0, 0, jakarta)},
+		{"0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := utils.ParseCron(tt.expr)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(schedule.Next(tt.from)), "Next() = %v, want %v", schedule.Next(tt.from), tt.want)
+		})
+	}
+}
+
+func TestCronScheduleNextAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	schedule, err := utils.ParseCron("30 2 * * *")
+	require.NoError(t, err)
+
+	// 2:30 does not exist on 9 March 2025, so that day's run is skipped
+	next := schedule.Next(time.Date(2025, 3, 8, 12, 0, 0, 0, newYork))
+	assert.Equal(t, time.Date(2025, 3, 10, 2, 30, 0, 0, newYork), next)
+
+	// 1:30 happens twice on 2 November 2025; it runs once
+	schedule, err = utils.ParseCron("30 1 * * *")
+	require.NoError(t, err)
+	first := schedule.Next(time.Date(2025, 11, 2, 0, 0, 0, 0, newYork))
+	assert.Equal(t, time.Date(2025, 11, 2, 1, 30, 0, 0, newYork), first)
+	assert.Equal(t, time.Date(2025, 11, 3, 1, 30, 0, 0, newYork), schedule.Next(first))
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * MON-FOO", "*/0 * * * *", "5-1 * * * *"} {
+		_, err := utils.ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,26 @@
+package utils_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFileHeader(t *testing.T) {
+	data := []byte("OggS\x00voice")
+	fh, err := utils.NewFileHeader("audio", "voice-reply.ogg", "audio/ogg", data)
+	require.NoError(t, err)
+	assert.Equal(t, "voice-reply.ogg", fh.Filename)
+	assert.Equal(t, int64(len(data)), fh.Size)
+	assert.Equal(t, "audio/ogg", fh.Header.Get("Content-Type"))
+
+	file, err := fh.Open()
+	require.NoError(t, err)
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+}
@@ -0,0 +1,844 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	maxPDFStreamSize   = 32 << 20 // Bounds a single decompressed PDF stream
+	maxPDFInflatedSize = 64 << 20 // Bounds all decompressed streams of one document together
+	maxPDFNesting      = 64       // Deeper arrays and dictionaries are dropped instead of parsed
+)
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfEncrypt      = regexp.MustCompile(`/Encrypt\s*\d+\s+\d+\s+R`)
+)
+
+// ExtractPDFText returns the text of each page of a PDF, in page order, reading at most maxPages
+// pages (0 = all). Extraction is best effort: it handles plain and Flate-compressed content,
+// object streams and ToUnicode maps, but not encrypted files or text drawn as images.
+func ExtractPDFText(data []byte, maxPages int) ([]string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if pdfEncrypt.Match(data) {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+
+	doc := &pdfDocument{objects: make(map[int]*pdfObject)}
+	doc.load(data)
+
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, errors.New("no pages found in PDF")
+	}
+	if maxPages > 0 && len(pages) > maxPages {
+		pages = pages[:maxPages]
+	}
+
+	texts := make([]string, 0, len(pages))
+	for _, page := range pages {
+		texts = append(texts, doc.pageText(page))
+	}
+	return texts, nil
+}
+
+// PDF object model
+
+type pdfName string
+
+type pdfRef int
+
+type pdfDict map[string]interface{}
+
+type pdfKeyword string
+
+type pdfObject struct {
+	value  interface{}
+	stream []byte // Raw (still encoded) stream data, nil for plain objects
+}
+
+type pdfDocument struct {
+	objects  map[int]*pdfObject
+	inflated int // Decompressed bytes so far, checked against maxPDFInflatedSize
+}
+
+// load indexes every "N G obj ... endobj" in the file, later definitions overriding earlier ones
+// as incremental updates do, then unpacks compressed object streams.
+func (d *pdfDocument) load(data []byte) {
+	for _, loc := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		if err != nil {
+			continue
+		}
+		lex := &pdfLexer{data: data, pos: loc[1]}
+		value := lex.parseValue()
+		obj := &pdfObject{value: value}
+
+		if dict, ok := value.(pdfDict); ok {
+			obj.stream = lex.readStream(dict)
+		}
+		d.objects[num] = obj
+	}
+
+	for _, obj := range d.objects {
+		dict, ok := obj.value.(pdfDict)
+		if !ok || dict["Type"] != pdfName("ObjStm") || obj.stream == nil {
+			continue
+		}
+		d.loadObjectStream(dict, obj.stream)
+	}
+}
+
+// loadObjectStream adds the objects packed in a PDF 1.5 object stream
+func (d *pdfDocument) loadObjectStream(dict pdfDict, raw []byte) {
+	data, err := d.decodeStream(dict, raw)
+	if err != nil {
+		return
+	}
+	count := pdfInt(d.resolve(dict["N"]))
+	first := pdfInt(d.resolve(dict["First"]))
+	if first <= 0 || first > len(data) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:first]}
+	for i := 0; i < count; i++ {
+		num, ok1 := header.next().(float64)
+		offset, ok2 := header.next().(float64)
+		if !ok1 || !ok2 {
+			return
+		}
+		if _, exists := d.objects[int(num)]; exists {
+			continue
+		}
+		start := first + int(offset)
+		if start < 0 || start >= len(data) {
+			continue
+		}
+		lex := &pdfLexer{data: data, pos: start}
+		d.objects[int(num)] = &pdfObject{value: lex.parseValue()}
+	}
+}
+
+// resolve follows an indirect reference
+func (d *pdfDocument) resolve(value interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		obj, ok := d.objects[int(ref)]
+		if !ok {
+			return nil
+		}
+		value = obj.value
+	}
+	return nil
+}
+
+func (d *pdfDocument) dict(value interface{}) pdfDict {
+	dict, _ := d.resolve(value).(pdfDict)
+	return dict
+}
+
+// streamData returns the decoded stream an indirect reference points to
+func (d *pdfDocument) streamData(value interface{}) []byte {
+	ref, ok := value.(pdfRef)
+	if !ok {
+		return nil
+	}
+	obj, ok := d.objects[int(ref)]
+	if !ok || obj.stream == nil {
+		return nil
+	}
+	dict, _ := obj.value.(pdfDict)
+	data, err := d.decodeStream(dict, obj.stream)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// decodeStream applies the stream filters; only FlateDecode is supported
+func (d *pdfDocument) decodeStream(dict pdfDict, raw []byte) ([]byte, error) {
+	var filters []interface{}
+	switch f := d.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{f}
+	case []interface{}:
+		filters = f
+	}
+
+	data := raw
+	for _, f := range filters {
+		switch d.resolve(f) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			budget := min(maxPDFStreamSize, maxPDFInflatedSize-d.inflated)
+			if budget <= 0 {
+				return nil, errors.New("PDF decompressed size limit reached")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := io.ReadAll(io.LimitReader(zr, int64(budget)))
+			zr.Close()
+			d.inflated += len(decoded)
+			// Truncated streams are common; keep whatever was inflated
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, fmt.Errorf("unsupported PDF filter %v", f)
+		}
+	}
+	return data, nil
+}
+
+// pages returns the page dictionaries in document order
+func (d *pdfDocument) pages() []pdfDict {
+	var pages []pdfDict
+	visited := make(map[pdfRef]bool)
+	var walk func(node interface{}, depth int)
+	walk = func(node interface{}, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil || depth > 64 {
+			return
+		}
+		if dict["Type"] == pdfName("Page") {
+			pages = append(pages, dict)
+			return
+		}
+		kids, _ := d.resolve(dict["Kids"]).([]interface{})
+		for _, kid := range kids {
+			walk(kid, depth+1)
+		}
+	}
+
+	for _, obj := range d.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			walk(dict["Pages"], 0)
+			if len(pages) > 0 {
+				return pages
+			}
+		}
+	}
+
+	// No usable page tree: fall back to page objects in object number order
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if dict, ok := d.objects[num].value.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			pages = append(pages, dict)
+		}
+	}
+	return pages
+}
+
+// pageText extracts the text drawn by a page's content streams
+func (d *pdfDocument) pageText(page pdfDict) string {
+	var content bytes.Buffer
+	switch contents := page["Contents"].(type) {
+	case pdfRef:
+		if arr, ok := d.resolve(contents).([]interface{}); ok {
+			for _, ref := range arr {
+				content.Write(d.streamData(ref))
+				content.WriteByte('\n')
+			}
+		} else {
+			content.Write(d.streamData(contents))
+		}
+	case []interface{}:
+		for _, ref := range contents {
+			content.Write(d.streamData(ref))
+			content.WriteByte('\n')
+		}
+	}
+
+	fonts := d.pageFonts(page)
+	return strings.TrimSpace(extractContentText(content.Bytes(), fonts))
+}
+
+// pdfFont knows how to turn shown string bytes into text
+type pdfFont struct {
+	composite bool              // Type0 fonts use two-byte codes
+	toUnicode map[string]string // From the ToUnicode CMap, keyed by raw code bytes
+}
+
+func (f *pdfFont) decode(raw []byte) string {
+	if f == nil {
+		return latin1(raw)
+	}
+	width := 1
+	if f.composite {
+		width = 2
+	}
+	var sb strings.Builder
+	for i := 0; i+width <= len(raw); i += width {
+		code := string(raw[i : i+width])
+		if text, ok := f.toUnicode[code]; ok {
+			sb.WriteString(text)
+		} else if !f.composite {
+			sb.WriteString(latin1(raw[i : i+1]))
+		}
+	}
+	return sb.String()
+}
+
+// pageFonts loads the fonts of a page's resources, which may be inherited from parent nodes
+func (d *pdfDocument) pageFonts(page pdfDict) map[string]*pdfFont {
+	fonts := make(map[string]*pdfFont)
+	node := page
+	for depth := 0; node != nil && depth < 64; depth++ {
+		if resources := d.dict(node["Resources"]); resources != nil {
+			for name, ref := range d.dict(resources["Font"]) {
+				if _, ok := fonts[name]; ok {
+					continue
+				}
+				fontDict := d.dict(ref)
+				if fontDict == nil {
+					continue
+				}
+				font := &pdfFont{composite: fontDict["Subtype"] == pdfName("Type0")}
+				if cmap := d.streamData(fontDict["ToUnicode"]); cmap != nil {
+					font.toUnicode = parseToUnicode(cmap)
+				}
+				fonts[name] = font
+			}
+			break
+		}
+		node = d.dict(node["Parent"])
+	}
+	return fonts
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseToUnicode(data []byte) map[string]string {
+	mapping := make(map[string]string)
+	lex := &pdfLexer{data: data}
+	var operands []interface{}
+	for {
+		tok := lex.parseValue()
+		if tok == nil && lex.pos >= len(data) {
+			return mapping
+		}
+		kw, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+		switch kw {
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					mapping[string(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 {
+					continue
+				}
+				start, end := bytesToInt(lo), bytesToInt(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(utf16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						text := append([]rune{}, base...)
+						text[len(text)-1] += rune(code - start)
+						mapping[string(intToBytes(code, len(lo)))] = string(text)
+					}
+				case []interface{}:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && start+j <= end {
+							mapping[string(intToBytes(start+j, len(lo)))] = utf16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// extractContentText interprets the text operators of a content stream
+func extractContentText(content []byte, fonts map[string]*pdfFont) string {
+	var sb strings.Builder
+	var font *pdfFont
+	var operands []interface{}
+	lastY, haveY := 0.0, false
+
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+	show := func(value interface{}) {
+		if s, ok := value.(pdfString); ok {
+			sb.WriteString(font.decode(s))
+		}
+	}
+
+	lex := &pdfLexer{data: content}
+	for lex.pos < len(content) {
+		tok := lex.parseValue()
+		kw, ok := tok.(pdfKeyword)
+		if !ok {
+			if tok != nil {
+				operands = append(operands, tok)
+			}
+			continue
+		}
+
+		switch kw {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				items, _ := operands[len(operands)-1].([]interface{})
+				for _, item := range items {
+					// Large negative kerning is how many generators encode a word space
+					if n, ok := item.(float64); ok && n < -200 {
+						sb.WriteByte(' ')
+					}
+					show(item)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+					newline()
+				} else if tx, ok := operands[len(operands)-2].(float64); ok && tx > 0 {
+					sb.WriteByte(' ')
+				}
+			}
+		case "T*":
+			newline()
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[len(operands)-1].(float64); ok {
+					if haveY && y != lastY {
+						newline()
+					}
+					lastY, haveY = y, true
+				}
+			}
+		case "BT":
+			// Each text object starts at the origin
+			lastY, haveY = 0, true
+		case "ET":
+			sb.WriteByte(' ')
+		case "ID":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+
+	// Collapse the spacing introduced by operators
+	lines := strings.Split(sb.String(), "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// Lexer
+
+type pdfString []byte
+
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int // Arrays and dictionaries currently open in parseValue
+}
+
+type pdfDelimiter string
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFWhitespace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// next returns the next token: float64, pdfString, pdfName, pdfKeyword or pdfDelimiter
+func (l *pdfLexer) next() interface{} {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString()
+	case c == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfDelimiter("<<")
+		}
+		return l.hexString()
+	case c == '>':
+		l.pos++
+		if l.pos < len(l.data) && l.data[l.pos] == '>' {
+			l.pos++
+			return pdfDelimiter(">>")
+		}
+		return l.next()
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+		return pdfDelimiter(string(c))
+	case c == '/':
+		l.pos++
+		return pdfName(l.regular())
+	case c == ')':
+		l.pos++
+		return l.next()
+	}
+
+	word := l.regular()
+	if word == "" {
+		l.pos++
+		return l.next()
+	}
+	if (word[0] >= '0' && word[0] <= '9') || word[0] == '-' || word[0] == '+' || word[0] == '.' {
+		if n, err := strconv.ParseFloat(word, 64); err == nil {
+			return n
+		}
+	}
+	return pdfKeyword(word)
+}
+
+// regular reads a run of regular characters, decoding #xx escapes
+func (l *pdfLexer) regular() string {
+	var sb strings.Builder
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFWhitespace(c) || isPDFDelimiter(c) {
+			break
+		}
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				sb.WriteByte(byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		sb.WriteByte(c)
+		l.pos++
+	}
+	return sb.String()
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		c := l.data[l.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// isPDFObjectBoundary reports whether tok can only appear between objects, which means an
+// unterminated array or dictionary ran into the next object
+func isPDFObjectBoundary(tok interface{}) bool {
+	switch tok {
+	case pdfKeyword("obj"), pdfKeyword("endobj"), pdfKeyword("stream"), pdfKeyword("endstream"):
+		return true
+	}
+	return false
+}
+
+// parseValue reads a complete value, assembling arrays, dictionaries and "N G R" references.
+// Keywords are returned as pdfKeyword; nil means end of input. Containers nested deeper than
+// maxPDFNesting are returned as nil so hostile files can't exhaust the stack.
+func (l *pdfLexer) parseValue() interface{} {
+	tok := l.next()
+	switch t := tok.(type) {
+	case pdfDelimiter:
+		switch t {
+		case "[":
+			if l.depth >= maxPDFNesting {
+				return nil
+			}
+			l.depth++
+			defer func() { l.depth-- }()
+			var arr []interface{}
+			for {
+				save := l.pos
+				if next := l.next(); next == pdfDelimiter("]") || next == nil || isPDFObjectBoundary(next) {
+					if isPDFObjectBoundary(next) {
+						l.pos = save
+					}
+					return arr
+				}
+				l.pos = save
+				arr = append(arr, l.parseValue())
+			}
+		case "<<":
+			if l.depth >= maxPDFNesting {
+				return nil
+			}
+			l.depth++
+			defer func() { l.depth-- }()
+			dict := make(pdfDict)
+			for {
+				save := l.pos
+				key := l.next()
+				if key == pdfDelimiter(">>") || key == nil || isPDFObjectBoundary(key) {
+					if isPDFObjectBoundary(key) {
+						l.pos = save
+					}
+					return dict
+				}
+				name, ok := key.(pdfName)
+				if !ok {
+					continue
+				}
+				dict[string(name)] = l.parseValue()
+			}
+		}
+		return nil
+	case float64:
+		save := l.pos
+		if gen, ok := l.next().(float64); ok && gen >= 0 {
+			if l.next() == pdfKeyword("R") {
+				return pdfRef(int(t))
+			}
+		}
+		l.pos = save
+		return t
+	case pdfKeyword:
+		switch t {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+	}
+	return tok
+}
+
+// readStream returns the raw data following a stream dictionary, if any
+func (l *pdfLexer) readStream(dict pdfDict) []byte {
+	save := l.pos
+	if l.next() != pdfKeyword("stream") {
+		l.pos = save
+		return nil
+	}
+	// The keyword is followed by CRLF or LF
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end <= len(l.data) {
+			rest := bytes.TrimLeft(l.data[end:min(end+32, len(l.data))], "\r\n \t")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				l.pos = end
+				return l.data[start:end]
+			}
+		}
+	}
+
+	// Indirect or wrong /Length: scan for the end marker instead
+	idx := bytes.Index(l.data[start:], []byte("endstream"))
+	if idx < 0 {
+		return l.data[start:]
+	}
+	end := start + idx
+	l.pos = end
+	return bytes.TrimRight(l.data[start:end], "\r\n")
+}
+
+// skipInlineImage moves past inline image data, which ends at the first "EI" after whitespace
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if isPDFWhitespace(l.data[l.pos]) && l.data[l.pos+1] == 'E' && l.data[l.pos+2] == 'I' &&
+			(l.pos+3 >= len(l.data) || isPDFWhitespace(l.data[l.pos+3]) || isPDFDelimiter(l.data[l.pos+3])) {
+			l.pos += 3
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
+
+// Helpers
+
+func pdfInt(value interface{}) int {
+	n, _ := value.(float64)
+	return int(n)
+}
+
+func latin1(raw []byte) string {
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func utf16BE(raw []byte) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func bytesToInt(raw []byte) int {
+	v := 0
+	for _, b := range raw {
+		v = v<<8 | int(b)
+	}
+	return v
+}
+
+func intToBytes(v, width int) []byte {
+	out := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		out[i] = byte(v)
+		v >>= 8
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package utils_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildPDF writes numbered objects (starting at 1) into a minimal PDF file
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func pdfStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(data string) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestExtractPDFTextPlain(t *testing.T) {
+	pdf := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		pdfStream("", []byte("BT /F1 12 Tf 72 720 Td (Invoice \\(copy\\)) Tj 0 -14 Td [(Total:)-250(42)] TJ ET")),
+		pdfStream("", []byte("BT /F1 12 Tf 72 720 Td (Second page) Tj ET")),
+	)
+
+	pages, err := utils.ExtractPDFText(pdf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Invoice (copy)\nTotal: 42", "Second page"}, pages)
+
+	pages, err = utils.ExtractPDFText(pdf, 1)
+	require.NoError(t, err)
+	assert.Len(t, pages, 1)
+}
+
+func TestExtractPDFTextCompressedWithToUnicode(t *testing.T) {
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"2 beginbfchar <0001> <0048> <0002> <0069> endbfchar\n" +
+		"1 beginbfrange <0010> <0012> <00E9> endbfrange\n" +
+		"endcmap CMapName currentdict /CMap defineresource pop end end"
+	pdf := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F2 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Noto /Encoding /Identity-H /ToUnicode 6 0 R >>",
+		pdfStream("/Filter /FlateDecode", deflate("BT /F2 11 Tf <00010002> Tj 1 0 0 1 72 700 Tm <001000110012> Tj ET")),
+		pdfStream("", []byte(cmap)),
+	)
+
+	pages, err := utils.ExtractPDFText(pdf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hi\néêë"}, pages)
+}
+
+func TestExtractPDFTextRejectsInvalidInput(t *testing.T) {
+	_, err := utils.ExtractPDFText([]byte("hello"), 0)
+	assert.Error(t, err)
+
+	_, err = utils.ExtractPDFText(buildPDF("<< /Type /Catalog >>"), 0)
+	assert.Error(t, err)
+}
+
+func TestExtractPDFTextDeepNesting(t *testing.T) {
+	// Deeply nested containers used to recurse until the stack overflowed. A small stack
+	// limit reproduces that with a test-sized file.
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+	for _, open := range []string{"[", "<< /A "} {
+		pdf := buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+			pdfStream("", []byte("BT (Still here) Tj ET")),
+			strings.Repeat(open, 1<<20),
+		)
+
+		pages, err := utils.ExtractPDFText(pdf, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Still here"}, pages)
+	}
+}
@@ -33,6 +33,7 @@ func InitRestAgent(app fiber.Router, service *usecase.AgentService, appService d
 	// Integration endpoints
 	app.Post("/agents/:id/integrations/:type", handler.CreateIntegration)
 	app.Delete("/agents/:id/integrations/:integrationId", handler.DeleteIntegration)
+	app.Put("/agents/:id/integrations/:integrationId/language", handler.SetIntegrationLanguage)
 	app.Post("/agents/:id/integrations/:integrationId/connect", handler.ConnectIntegration)
 	app.Post("/agents/:id/integrations/:integrationId/disconnect", handler.DisconnectIntegration)
 	
@@ -68,7 +69,8 @@ func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
 	if req.Name == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "Name is required")
 	}
-	if req.APIKey == "" {
+	// Self-hosted OpenAI-compatible servers may not need a key
+	if req.APIKey == "" && req.BaseURL == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "API key is required")
 	}
 	if req.Model == "" {
@@ -242,6 +244,32 @@ func (h *AgentHandler) DeleteIntegration(c *fiber.Ctx) error {
 	})
 }
 
+// SetIntegrationLanguage sets the fixed customer language used when translation auto-detect is off
+func (h *AgentHandler) SetIntegrationLanguage(c *fiber.Ctx) error {
+	integrationID := c.Params("integrationId")
+	if integrationID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Integration ID is required")
+	}
+
+	var req struct {
+		Language string `json:"language"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.Service.SetIntegrationLanguage(c.UserContext(), integrationID, req.Language); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Integration language updated",
+		Results: nil,
+	})
+}
+
 // ConnectIntegration connects an integration (e.g., starts Telegram bot)
 func (h *AgentHandler) ConnectIntegration(c *fiber.Ctx) error {
 	agentID := c.Params("id")
@@ -17,6 +17,7 @@ func InitRestAnalytics(app fiber.Router, service *usecase.AnalyticsService) Anal
 	app.Get("/analytics/messages/daily", handler.GetMessagesDaily)
 	app.Get("/analytics/activity", handler.GetRecentActivity)
 	app.Get("/analytics/agents", handler.GetAgentStats)
+	app.Get("/analytics/sentiment", handler.GetSentimentStats)
 
 	return handler
 }
@@ -95,3 +96,23 @@ func (h *AnalyticsHandler) GetAgentStats(c *fiber.Ctx) error {
 		Results: stats,
 	})
 }
+
+// GetSentimentStats returns the sentiment breakdown of incoming messages per agent
+func (h *AnalyticsHandler) GetSentimentStats(c *fiber.Ctx) error {
+	period := c.Query("period", "7days")
+	if period != "today" && period != "7days" && period != "30days" && period != "month" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid period. Must be: today, 7days, 30days, or month")
+	}
+
+	stats, err := h.Service.GetSentimentStats(c.UserContext(), period)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Sentiment stats retrieved",
+		Results: stats,
+	})
+}
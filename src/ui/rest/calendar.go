@@ -7,15 +7,18 @@ import (
 )
 
 type CalendarHandler struct {
-	Repo calendar.ICalendarRepository
+	Repo    calendar.ICalendarRepository
+	Service calendar.ICalendarService
 }
 
-func InitRestCalendar(app fiber.Router, repo calendar.ICalendarRepository) CalendarHandler {
-	handler := CalendarHandler{Repo: repo}
+func InitRestCalendar(app fiber.Router, repo calendar.ICalendarRepository, service calendar.ICalendarService) CalendarHandler {
+	handler := CalendarHandler{Repo: repo, Service: service}
 
 	app.Get("/agents/:agentId/calendar", handler.GetCredential)
 	app.Post("/agents/:agentId/calendar", handler.SaveCredential)
 	app.Delete("/agents/:agentId/calendar", handler.DeleteCredential)
+	app.Get("/agents/:agentId/calendar/auth-url", handler.GetAuthURL)
+	app.Get("/calendar/oauth/callback", handler.OAuthCallback)
 
 	return handler
 }
@@ -115,6 +118,44 @@ func (h *CalendarHandler) DeleteCredential(c *fiber.Ctx) error {
 	})
 }
 
+// GetAuthURL returns the Google consent URL for connecting the agent's calendar
+func (h *CalendarHandler) GetAuthURL(c *fiber.Ctx) error {
+	agentID := c.Params("agentId")
+	if agentID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Agent ID required")
+	}
 
+	authURL, err := h.Service.GetAuthURL(c.UserContext(), agentID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Calendar auth URL generated",
+		Results: map[string]string{"auth_url": authURL},
+	})
+}
 
+// OAuthCallback completes the Google OAuth flow; the state parameter identifies the pending consent
+func (h *CalendarHandler) OAuthCallback(c *fiber.Ctx) error {
+	state := c.Query("state")
+	code := c.Query("code")
+	if errMsg := c.Query("error"); errMsg != "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Authorization denied: "+errMsg)
+	}
+	if state == "" || code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing state or code")
+	}
+
+	if err := h.Service.HandleCallback(c.UserContext(), state, code); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Calendar connected",
+	})
+}
@@ -33,25 +33,30 @@ func InitRestConversation(app fiber.Router, agentService *usecase.AgentService)
 	app.Post("/conversations/:id/takeover", handler.TakeOver)
 	app.Post("/conversations/:id/release", handler.Release)
 	app.Post("/conversations/:id/notes", handler.AddNote)
+	app.Put("/conversations/:id/language", handler.SetLanguage)
 	app.Get("/conversations/:id/export", handler.ExportChat)
+	app.Get("/conversations/:id/tool-calls", handler.GetToolCalls)
 
 	return handler
 }
 
 // ConversationResponse represents a conversation with additional info
 type ConversationResponse struct {
-	ID              string `json:"id"`
-	AgentID         string `json:"agent_id"`
-	AgentName       string `json:"agent_name,omitempty"`
-	IntegrationID   string `json:"integration_id"`
-	IntegrationType string `json:"integration_type,omitempty"`
-	RemoteJID       string `json:"remote_jid"`
-	LastMessage     string `json:"last_message,omitempty"`
-	UnreadCount     int    `json:"unread_count"`
-	IsManualMode    bool   `json:"is_manual_mode"`
-	Notes           string `json:"notes,omitempty"`
-	CreatedAt       string `json:"created_at"`
-	UpdatedAt       string `json:"updated_at"`
+	ID                 string  `json:"id"`
+	AgentID            string  `json:"agent_id"`
+	AgentName          string  `json:"agent_name,omitempty"`
+	IntegrationID      string  `json:"integration_id"`
+	IntegrationType    string  `json:"integration_type,omitempty"`
+	RemoteJID          string  `json:"remote_jid"`
+	LastMessage        string  `json:"last_message,omitempty"`
+	UnreadCount        int     `json:"unread_count"`
+	IsManualMode       bool    `json:"is_manual_mode"`
+	Notes              string  `json:"notes,omitempty"`
+	Language           string  `json:"language,omitempty"`
+	LanguageConfidence float64 `json:"language_confidence,omitempty"`
+	LanguageManual     bool    `json:"language_manual"`
+	CreatedAt          string  `json:"created_at"`
+	UpdatedAt          string  `json:"updated_at"`
 }
 
 // MessageResponse represents a message
@@ -94,18 +99,21 @@ func (h *ConversationHandler) GetConversations(c *fiber.Ctx) error {
 		}
 
 		results = append(results, ConversationResponse{
-			ID:              conv.ID,
-			AgentID:         conv.AgentID,
-			AgentName:       agentName,
-			IntegrationID:   conv.IntegrationID,
-			IntegrationType: integrationType,
-			RemoteJID:       conv.RemoteJID,
-			LastMessage:     lastMsgContent,
-			UnreadCount:     0,
-			IsManualMode:    conv.IsManualMode,
-			Notes:           conv.Notes,
-			CreatedAt:       conv.CreatedAt.Format("2006-01-02T15:04:05Z"),
-			UpdatedAt:       conv.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+			ID:                 conv.ID,
+			AgentID:            conv.AgentID,
+			AgentName:          agentName,
+			IntegrationID:      conv.IntegrationID,
+			IntegrationType:    integrationType,
+			RemoteJID:          conv.RemoteJID,
+			LastMessage:        lastMsgContent,
+			UnreadCount:        0,
+			IsManualMode:       conv.IsManualMode,
+			Notes:              conv.Notes,
+			Language:           conv.Language,
+			LanguageConfidence: conv.LanguageConfidence,
+			LanguageManual:     conv.LanguageManual,
+			CreatedAt:          conv.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			UpdatedAt:          conv.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 		})
 	}
 
@@ -140,15 +148,18 @@ func (h *ConversationHandler) GetConversation(c *fiber.Ctx) error {
 		Code:    "SUCCESS",
 		Message: "Conversation retrieved",
 		Results: ConversationResponse{
-			ID:            conv.ID,
-			AgentID:       conv.AgentID,
-			AgentName:     agentName,
-			IntegrationID: conv.IntegrationID,
-			RemoteJID:     conv.RemoteJID,
-			IsManualMode:  conv.IsManualMode,
-			Notes:         conv.Notes,
-			CreatedAt:     conv.CreatedAt.Format("2006-01-02T15:04:05Z"),
-			UpdatedAt:     conv.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+			ID:                 conv.ID,
+			AgentID:            conv.AgentID,
+			AgentName:          agentName,
+			IntegrationID:      conv.IntegrationID,
+			RemoteJID:          conv.RemoteJID,
+			IsManualMode:       conv.IsManualMode,
+			Notes:              conv.Notes,
+			Language:           conv.Language,
+			LanguageConfidence: conv.LanguageConfidence,
+			LanguageManual:     conv.LanguageManual,
+			CreatedAt:          conv.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			UpdatedAt:          conv.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 		},
 	})
 }
@@ -183,6 +194,26 @@ func (h *ConversationHandler) GetMessages(c *fiber.Ctx) error {
 	})
 }
 
+// GetToolCalls returns the audit log of tools the agent called in a conversation
+func (h *ConversationHandler) GetToolCalls(c *fiber.Ctx) error {
+	conversationID := c.Params("id")
+	if conversationID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Conversation ID is required")
+	}
+
+	invocations, err := h.AgentService.GetToolInvocations(c.UserContext(), conversationID, c.QueryInt("limit", 100))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Tool calls retrieved",
+		Results: invocations,
+	})
+}
+
 // SendMessage sends a manual message (from manager)
 func (h *ConversationHandler) SendMessage(c *fiber.Ctx) error {
 	conversationID := c.Params("id")
@@ -365,6 +396,36 @@ func (h *ConversationHandler) AddNote(c *fiber.Ctx) error {
 	})
 }
 
+// SetLanguage overrides the customer language used for translation. An empty language
+// removes the override so the language is auto-detected again.
+func (h *ConversationHandler) SetLanguage(c *fiber.Ctx) error {
+	conversationID := c.Params("id")
+	if conversationID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Conversation ID is required")
+	}
+
+	var req struct {
+		Language string `json:"language"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if _, err := h.AgentService.GetConversation(c.UserContext(), conversationID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Conversation not found")
+	}
+	if err := h.AgentService.SetConversationLanguage(c.UserContext(), conversationID, req.Language); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Conversation language updated",
+		Results: nil,
+	})
+}
+
 // ExportChat exports conversation as CSV
 func (h *ConversationHandler) ExportChat(c *fiber.Ctx) error {
 	conversationID := c.Params("id")
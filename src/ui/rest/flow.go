@@ -2,13 +2,31 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/helpers"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/usecase"
 	"github.com/gofiber/fiber/v2"
 )
 
+// IsFlowWebhookPath reports whether path is a flow webhook URL under the app base path, which
+// callers such as payment providers reach without basic auth credentials
+func IsFlowWebhookPath(path string) bool {
+	rest, ok := strings.CutPrefix(path, config.AppBasePath+"/api/flows/")
+	if !ok {
+		return false
+	}
+	parts := strings.Split(strings.TrimSuffix(rest, "/"), "/")
+	return len(parts) == 3 && parts[0] != "" && parts[1] == "webhook" && parts[2] != ""
+}
+
 type FlowHandler struct {
 	Service *usecase.FlowService
 }
@@ -19,10 +37,28 @@ func InitRestFlow(app fiber.Router, service *usecase.FlowService) FlowHandler {
 	// Flow CRUD
 	app.Get("/flows", handler.GetAllFlows)
 	app.Post("/flows", handler.CreateFlow)
+	app.Post("/flows/media", handler.UploadMedia)
 	app.Get("/flows/:id", handler.GetFlow)
 	app.Put("/flows/:id", handler.UpdateFlow)
 	app.Delete("/flows/:id", handler.DeleteFlow)
 
+	// Versions; saving a flow changes its draft until it is published
+	app.Post("/flows/:id/publish", handler.PublishFlow)
+	app.Post("/flows/:id/rollback", handler.RollbackFlow)
+	app.Get("/flows/:id/versions", handler.GetFlowVersions)
+	app.Get("/flows/:id/versions/diff", handler.DiffFlowVersions)
+	app.Get("/flows/:id/versions/:version", handler.GetFlowVersion)
+	app.Delete("/flows/:id/draft", handler.DiscardDraft)
+	app.Post("/flows/:id/draft/run", handler.RunDraft) // Dry run; ?live=true for real side effects
+	app.Post("/flows/:id/test", handler.TestFlow)
+
+	// Execution history
+	app.Get("/flows/:id/executions", handler.GetExecutions)
+	app.Get("/flows/:id/executions/:executionId", handler.GetExecution)
+
+	// Webhook triggers; authenticated by the token in the URL instead of basic auth
+	app.Post("/flows/:id/webhook/:token", handler.Webhook)
+
 	// Credential CRUD
 	app.Get("/credentials", handler.GetAllCredentials)
 	app.Post("/credentials", handler.CreateCredential)
@@ -30,6 +66,7 @@ func InitRestFlow(app fiber.Router, service *usecase.FlowService) FlowHandler {
 	app.Put("/credentials/:id", handler.UpdateCredential)
 	app.Delete("/credentials/:id", handler.DeleteCredential)
 	app.Post("/credentials/test-database", handler.TestDatabaseConnection)
+	app.Post("/credentials/test-smtp", handler.TestSMTPConnection)
 
 	return handler
 }
@@ -71,6 +108,9 @@ func (h *FlowHandler) CreateFlow(c *fiber.Ctx) error {
 	}
 
 	result, err := h.Service.CreateFlow(c.UserContext(), req)
+	if errors.Is(err, usecase.ErrInvalidFlow) {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
@@ -116,6 +156,9 @@ func (h *FlowHandler) UpdateFlow(c *fiber.Ctx) error {
 	}
 
 	result, err := h.Service.UpdateFlow(c.UserContext(), id, req)
+	if errors.Is(err, usecase.ErrInvalidFlow) {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
@@ -147,6 +190,377 @@ func (h *FlowHandler) DeleteFlow(c *fiber.Ctx) error {
 	})
 }
 
+// versionError maps errors of the version operations to HTTP errors
+func versionError(err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrVersionNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, usecase.ErrNoDraft), errors.Is(err, usecase.ErrInvalidFlow):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+}
+
+// PublishFlow makes the draft of a flow its next version
+func (h *FlowHandler) PublishFlow(c *fiber.Ctx) error {
+	var req flow.PublishFlowRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+		}
+	}
+
+	result, err := h.Service.PublishFlow(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return versionError(err)
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Flow published as version %d", result.Version),
+		Results: result,
+	})
+}
+
+// RollbackFlow publishes an earlier version of a flow again
+func (h *FlowHandler) RollbackFlow(c *fiber.Ctx) error {
+	var req flow.RollbackFlowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if req.Version <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "version is required")
+	}
+
+	result, err := h.Service.RollbackFlow(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return versionError(err)
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Version %d published again as version %d", req.Version, result.Version),
+		Results: result,
+	})
+}
+
+// GetFlowVersions lists the published versions of a flow, newest first
+func (h *FlowHandler) GetFlowVersions(c *fiber.Ctx) error {
+	result, err := h.Service.GetFlowVersions(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return versionError(err)
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Versions retrieved successfully",
+		Results: result,
+	})
+}
+
+// GetFlowVersion returns a published version of a flow with its definition
+func (h *FlowHandler) GetFlowVersion(c *fiber.Ctx) error {
+	version, err := c.ParamsInt("version")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid version: "+c.Params("version"))
+	}
+
+	result, err := h.Service.GetFlowVersion(c.UserContext(), c.Params("id"), version)
+	if err != nil {
+		return versionError(err)
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Version retrieved successfully",
+		Results: result,
+	})
+}
+
+// DiffFlowVersions lists the changes between two definitions of a flow, given by from and to
+// parameters with a version number or draft. They default to the published version and the draft.
+func (h *FlowHandler) DiffFlowVersions(c *fiber.Ctx) error {
+	from, to := c.Query("from"), c.Query("to", "draft")
+	if from == "" {
+		current, err := h.Service.GetFlow(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		from = fmt.Sprint(current.Version)
+	}
+
+	result, err := h.Service.DiffFlowVersions(c.UserContext(), c.Params("id"), from, to)
+	if err != nil {
+		return versionError(err)
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Diff retrieved successfully",
+		Results: result,
+	})
+}
+
+// DiscardDraft deletes the unpublished changes of a flow
+func (h *FlowHandler) DiscardDraft(c *fiber.Ctx) error {
+	if err := h.Service.DiscardDraft(c.UserContext(), c.Params("id")); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Draft discarded successfully",
+		Results: nil,
+	})
+}
+
+// RunDraft runs the draft of a flow with the JSON body as its input. It is a dry run, with no
+// messages, email, webhook calls or database writes, unless the query has live=true.
+func (h *FlowHandler) RunDraft(c *fiber.Ctx) error {
+	input := map[string]interface{}{}
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), &input); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+		}
+	}
+
+	result, err := h.Service.RunDraft(c.UserContext(), c.Params("id"), input, c.QueryBool("live"))
+	if err != nil {
+		return versionError(err)
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Draft executed successfully",
+		Results: result,
+	})
+}
+
+// TestFlow dry runs a flow with sample trigger input, without sending, emailing, calling webhooks
+// or writing to databases, and returns the node trace. A run that fails is still a successful request.
+func (h *FlowHandler) TestFlow(c *fiber.Ctx) error {
+	var req flow.TestFlowRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+		}
+	}
+
+	result, err := h.Service.TestFlow(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return versionError(err)
+	}
+
+	message := "Flow test succeeded"
+	if result.Error != "" {
+		message = "Flow test failed: " + result.Error
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: result,
+	})
+}
+
+// GetExecutions lists the runs of a flow, newest first. Runs can be filtered by status and by
+// start time with RFC 3339 since and until parameters, and paged with limit and offset.
+func (h *FlowHandler) GetExecutions(c *fiber.Ctx) error {
+	filter := flow.ExecutionFilter{
+		FlowID: c.Params("id"),
+		Status: c.Query("status"),
+		Limit:  c.QueryInt("limit"),
+		Offset: c.QueryInt("offset"),
+	}
+	switch filter.Status {
+	case "", flow.ExecutionStatusRunning, flow.ExecutionStatusSuccess, flow.ExecutionStatusFailed:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid status: "+filter.Status)
+	}
+	for param, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Invalid %s, expected an RFC 3339 time: %s", param, value))
+		}
+		*target = &t
+	}
+
+	if _, err := h.Service.GetFlow(c.UserContext(), filter.FlowID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	result, err := h.Service.ListExecutions(c.UserContext(), filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Executions retrieved successfully",
+		Results: result,
+	})
+}
+
+// GetExecution returns a run of a flow with its node trace
+func (h *FlowHandler) GetExecution(c *fiber.Ctx) error {
+	result, err := h.Service.GetExecution(c.UserContext(), c.Params("id"), c.Params("executionId"))
+	if errors.Is(err, usecase.ErrExecutionNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Execution retrieved successfully",
+		Results: result,
+	})
+}
+
+// Webhook starts a flow from one of its webhook triggers. JSON and form bodies and query parameters
+// become the flow input.
+func (h *FlowHandler) Webhook(c *fiber.Ctx) error {
+	req := usecase.FlowWebhookRequest{
+		FlowID:  c.Params("id"),
+		Token:   c.Params("token"),
+		Body:    append([]byte(nil), c.Body()...),
+		Query:   map[string]interface{}{},
+		Headers: map[string]string{},
+	}
+	for k, v := range c.Queries() {
+		req.Query[k] = v
+	}
+	for k, v := range c.GetReqHeaders() {
+		if len(v) > 0 {
+			req.Headers[strings.ToLower(k)] = v[0]
+		}
+	}
+
+	payload, err := parseWebhookPayload(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	req.Payload = payload
+
+	result, err := h.Service.RunWebhook(c.UserContext(), req)
+	switch {
+	case errors.Is(err, usecase.ErrWebhookNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, usecase.ErrInvalidSignature):
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	case err != nil:
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	switch result.Mode {
+	case flow.WebhookResponseOutput:
+		if text, ok := result.Output.(string); ok {
+			return c.SendString(text)
+		}
+		return c.JSON(result.Output)
+	case flow.WebhookResponseSync:
+		return c.JSON(utils.ResponseData{
+			Status:  200,
+			Code:    "SUCCESS",
+			Message: "Flow executed successfully",
+			Results: map[string]interface{}{
+				"execution_id": result.ExecutionID,
+				"output":       result.Output,
+			},
+		})
+	default:
+		return c.Status(fiber.StatusAccepted).JSON(utils.ResponseData{
+			Status:  202,
+			Code:    "ACCEPTED",
+			Message: "Flow started",
+		})
+	}
+}
+
+// parseWebhookPayload decodes a JSON, URL-encoded or multipart body; other bodies are kept as text
+func parseWebhookPayload(c *fiber.Ctx) (interface{}, error) {
+	body := c.Body()
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.Contains(contentType, "json"):
+		var payload interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationForm):
+		fields := map[string]interface{}{}
+		c.Request().PostArgs().VisitAll(func(key, value []byte) {
+			fields[string(key)] = string(value)
+		})
+		return fields, nil
+
+	case strings.HasPrefix(contentType, fiber.MIMEMultipartForm):
+		form, err := c.MultipartForm()
+		if err != nil {
+			return nil, err
+		}
+		fields := map[string]interface{}{}
+		for key, values := range form.Value {
+			if len(values) > 0 {
+				fields[key] = values[0]
+			}
+		}
+		return fields, nil
+
+	default:
+		return string(body), nil
+	}
+}
+
+// UploadMedia stores a file to be sent by send_image and send_file nodes
+func (h *FlowHandler) UploadMedia(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "file is required")
+	}
+	if file.Size > config.WhatsappSettingMaxFileSize {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("file exceeds the maximum size of %d bytes", config.WhatsappSettingMaxFileSize))
+	}
+
+	data := helpers.MultipartFormFileHeaderToBytes(file)
+	mediaFile, err := h.Service.SaveMedia(data, file.Filename)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Media uploaded successfully",
+		Results: map[string]interface{}{
+			"media_file": mediaFile,
+			"file_name":  file.Filename,
+			"mime_type":  http.DetectContentType(data),
+			"size":       len(data),
+		},
+	})
+}
+
 // === Credential Handlers ===
 
 // GetAllCredentials returns all credentials for an agent
@@ -273,19 +687,23 @@ func (h *FlowHandler) TestDatabaseConnection(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
 	}
 
-	if config.Host == "" {
-		return fiber.NewError(fiber.StatusBadRequest, "host is required")
-	}
-	if config.Port == 0 {
-		config.Port = 5432
+	switch config.Driver {
+	case "", flow.DatabaseDriverPostgres, flow.DatabaseDriverMySQL, flow.DatabaseDriverSQLite:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "driver must be postgres, mysql or sqlite")
 	}
 	if config.Database == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "database is required")
 	}
-	if config.User == "" {
-		return fiber.NewError(fiber.StatusBadRequest, "user is required")
+	if config.Driver != flow.DatabaseDriverSQLite {
+		if config.Host == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "host is required")
+		}
+		if config.User == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "user is required")
+		}
 	}
-	if config.SSLMode == "" {
+	if config.SSLMode == "" && config.Driver != flow.DatabaseDriverMySQL {
 		config.SSLMode = "require"
 	}
 
@@ -308,6 +726,41 @@ func (h *FlowHandler) TestDatabaseConnection(c *fiber.Ctx) error {
 	})
 }
 
+// TestSMTPConnection tests an SMTP server login
+func (h *FlowHandler) TestSMTPConnection(c *fiber.Ctx) error {
+	var config flow.SMTPCredential
+	if err := c.BodyParser(&config); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	if config.Host == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "host is required")
+	}
+	switch config.Security {
+	case "", flow.SMTPSecuritySTARTTLS, flow.SMTPSecurityTLS, flow.SMTPSecurityNone:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "security must be starttls, tls or none")
+	}
+
+	if err := h.Service.TestSMTPConnection(c.UserContext(), config); err != nil {
+		return c.JSON(utils.ResponseData{
+			Status:  400,
+			Code:    "CONNECTION_FAILED",
+			Message: err.Error(),
+			Results: nil,
+		})
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "SMTP connection successful",
+		Results: map[string]interface{}{
+			"connected": true,
+		},
+	})
+}
+
 // Helper to get credential config as JSON for specific node types
 func (h *FlowHandler) GetCredentialConfig(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -331,6 +784,34 @@ func (h *FlowHandler) GetCredentialConfig(c *fiber.Ctx) error {
 		// Mask password
 		dbConfig.Password = "********"
 		config = dbConfig
+	case flow.CredentialTypeOpenAI:
+		var aiConfig flow.OpenAICredential
+		if err := json.Unmarshal([]byte(cred.Config), &aiConfig); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse config")
+		}
+		aiConfig.APIKey = "********"
+		config = aiConfig
+	case flow.CredentialTypeAnthropic:
+		var aiConfig flow.AnthropicCredential
+		if err := json.Unmarshal([]byte(cred.Config), &aiConfig); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse config")
+		}
+		aiConfig.APIKey = "********"
+		config = aiConfig
+	case flow.CredentialTypeSMTP:
+		var smtpConfig flow.SMTPCredential
+		if err := json.Unmarshal([]byte(cred.Config), &smtpConfig); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse config")
+		}
+		smtpConfig.Password = "********"
+		config = smtpConfig
+	case flow.CredentialTypeGoogleSheets:
+		var sheetsConfig flow.GoogleSheetsCredential
+		if err := json.Unmarshal([]byte(cred.Config), &sheetsConfig); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse config")
+		}
+		sheetsConfig.ServiceAccountJSON = "********"
+		config = sheetsConfig
 	default:
 		config = map[string]string{"type": cred.Type}
 	}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/basicauth"
+)
+
+func TestIsFlowWebhookPath(t *testing.T) {
+	cases := []struct {
+		basePath string
+		path     string
+		want     bool
+	}{
+		{"", "/api/flows/a/webhook/b", true},
+		{"", "/api/flows/a/webhook/b/", true},
+		{"", "/other/api/flows/a/webhook/b", false},
+		{"", "/api/flows/a/webhook/b/extra", false},
+		{"", "/api/flows/a/webhook/", false},
+		{"", "/api/flows//webhook/b", false},
+		{"", "/api/flows/a/versions", false},
+		{"/wa", "/wa/api/flows/a/webhook/b", true},
+		{"/wa", "/api/flows/a/webhook/b", false},
+		{"/wa", "/other/wa/api/flows/a/webhook/b", false},
+	}
+
+	original := config.AppBasePath
+	t.Cleanup(func() { config.AppBasePath = original })
+	for _, tc := range cases {
+		config.AppBasePath = tc.basePath
+		if got := IsFlowWebhookPath(tc.path); got != tc.want {
+			t.Errorf("IsFlowWebhookPath(%q) with base path %q = %v, want %v", tc.path, tc.basePath, got, tc.want)
+		}
+	}
+}
+
+func TestFlowWebhookSkipsBasicAuthOnlyForWebhooks(t *testing.T) {
+	original := config.AppBasePath
+	config.AppBasePath = ""
+	t.Cleanup(func() { config.AppBasePath = original })
+
+	app := fiber.New()
+	app.Use(basicauth.New(basicauth.Config{
+		Users: map[string]string{"admin": "secret"},
+		Next: func(c *fiber.Ctx) bool {
+			return IsFlowWebhookPath(c.Path())
+		},
+	}))
+	app.Post("/*", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	cases := map[string]int{
+		"/api/flows/a/webhook/b":       fiber.StatusOK,
+		"/other/api/flows/a/webhook/b": fiber.StatusUnauthorized,
+		"/api/flows/a/webhook/b/extra": fiber.StatusUnauthorized,
+	}
+	for path, want := range cases {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, path, nil))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("POST %s status = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
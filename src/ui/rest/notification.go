@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/notification"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type NotificationHandler struct {
+	Service notification.INotificationService
+}
+
+func InitRestNotification(app fiber.Router, service notification.INotificationService) NotificationHandler {
+	handler := NotificationHandler{Service: service}
+
+	app.Get("/notifications", handler.GetNotifications)
+	app.Get("/notifications/unread-count", handler.GetUnreadCount)
+	app.Post("/notifications/read-all", handler.MarkAllAsRead)
+	app.Post("/notifications/:id/read", handler.MarkAsRead)
+
+	return handler
+}
+
+// GetNotifications returns the newest notifications, optionally only unread ones
+func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	notifications, err := h.Service.GetNotifications(c.UserContext(), c.Query("user_id"), limit, c.QueryBool("unread_only", false))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Notifications retrieved",
+		Results: notifications,
+	})
+}
+
+// GetUnreadCount returns the number of unread notifications
+func (h *NotificationHandler) GetUnreadCount(c *fiber.Ctx) error {
+	count, err := h.Service.GetUnreadCount(c.UserContext(), c.Query("user_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Unread count retrieved",
+		Results: map[string]int{"count": count},
+	})
+}
+
+// MarkAsRead marks a single notification as read
+func (h *NotificationHandler) MarkAsRead(c *fiber.Ctx) error {
+	if err := h.Service.MarkAsRead(c.UserContext(), c.Params("id")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fiber.NewError(fiber.StatusNotFound, "Notification not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Notification marked as read",
+	})
+}
+
+// MarkAllAsRead marks every notification (of a user, when user_id is given) as read
+func (h *NotificationHandler) MarkAllAsRead(c *fiber.Ctx) error {
+	if err := h.Service.MarkAllAsRead(c.UserContext(), c.Query("user_id")); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "All notifications marked as read",
+	})
+}
@@ -0,0 +1,209 @@
+package rest
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/usage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/usecase"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UsageHandler struct {
+	Service         *usecase.UsageService
+	SettingsService *usecase.SettingsService
+}
+
+func InitRestUsage(app fiber.Router, service *usecase.UsageService, settingsService *usecase.SettingsService) UsageHandler {
+	handler := UsageHandler{Service: service, SettingsService: settingsService}
+
+	app.Get("/analytics/usage", handler.GetUsage)
+	app.Get("/analytics/usage/records", handler.GetRecords)
+	app.Get("/analytics/usage/pricing", handler.GetPricing)
+	app.Put("/analytics/usage/pricing", handler.SavePrice)
+	app.Delete("/analytics/usage/pricing/:model", handler.DeletePrice)
+	app.Get("/analytics/usage/budget/:agentId", handler.GetBudget)
+
+	return handler
+}
+
+// usageFilter reads the record filter from the query string. The time range comes from
+// from/to (RFC 3339 or YYYY-MM-DD) or else from period: today, 7days, 30days, month or all.
+func usageFilter(c *fiber.Ctx) (usage.Filter, error) {
+	filter := usage.Filter{
+		AgentID:         c.Query("agent_id"),
+		ConversationID:  c.Query("conversation_id"),
+		FlowID:          c.Query("flow_id"),
+		FlowExecutionID: c.Query("flow_execution_id"),
+		Operation:       c.Query("operation"),
+	}
+
+	parse := func(value string) (time.Time, error) {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		return time.ParseInLocation("2006-01-02", value, time.Local)
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parse(from)
+		if err != nil {
+			return filter, errors.New("Invalid from. Must be RFC 3339 or YYYY-MM-DD")
+		}
+		filter.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parse(to)
+		if err != nil {
+			return filter, errors.New("Invalid to. Must be RFC 3339 or YYYY-MM-DD")
+		}
+		filter.To = t
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		return filter, nil
+	}
+
+	now := time.Now()
+	switch c.Query("period", "month") {
+	case "today":
+		filter.From = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	case "7days":
+		filter.From = now.AddDate(0, 0, -7)
+	case "30days":
+		filter.From = now.AddDate(0, 0, -30)
+	case "month":
+		filter.From = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	case "all":
+	default:
+		return filter, errors.New("Invalid period. Must be: today, 7days, 30days, month, or all")
+	}
+	return filter, nil
+}
+
+// GetUsage returns token and cost totals, broken down by group_by
+// (agent, conversation, flow, model, operation or day) when given
+func (h *UsageHandler) GetUsage(c *fiber.Ctx) error {
+	filter, err := usageFilter(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	totals, err := h.Service.Summarize(c.UserContext(), filter, "")
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	result := fiber.Map{"totals": usage.Totals{}}
+	if len(totals) > 0 {
+		result["totals"] = totals[0]
+	}
+
+	if groupBy := c.Query("group_by"); groupBy != "" {
+		groups, err := h.Service.Summarize(c.UserContext(), filter, groupBy)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		result["group_by"] = groupBy
+		result["groups"] = groups
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Usage retrieved",
+		Results: result,
+	})
+}
+
+// GetRecords returns the newest individual LLM calls
+func (h *UsageHandler) GetRecords(c *fiber.Ctx) error {
+	filter, err := usageFilter(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	filter.Limit = c.QueryInt("limit", 100)
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+
+	records, err := h.Service.ListRecords(c.UserContext(), filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Usage records retrieved",
+		Results: records,
+	})
+}
+
+// GetPricing returns the effective pricing table
+func (h *UsageHandler) GetPricing(c *fiber.Ctx) error {
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Model pricing retrieved",
+		Results: h.Service.GetPrices(c.UserContext()),
+	})
+}
+
+// SavePrice creates or updates the price of a model
+func (h *UsageHandler) SavePrice(c *fiber.Ctx) error {
+	var price usage.ModelPrice
+	if err := c.BodyParser(&price); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.Service.SavePrice(c.UserContext(), &price); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Model price saved",
+		Results: price,
+	})
+}
+
+// DeletePrice removes a configured price so the built-in default applies again
+func (h *UsageHandler) DeletePrice(c *fiber.Ctx) error {
+	if err := h.Service.DeletePrice(c.UserContext(), c.Params("model")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fiber.NewError(fiber.StatusNotFound, "No configured price for this model")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Model price deleted",
+	})
+}
+
+// GetBudget returns an agent's spending this month against its budget cap
+func (h *UsageHandler) GetBudget(c *fiber.Ctx) error {
+	agentID := c.Params("agentId")
+	if h.SettingsService == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Settings service not available")
+	}
+	agentSettings, err := h.SettingsService.GetAgentSettings(c.UserContext(), agentID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	budget, err := h.Service.GetBudget(c.UserContext(), agentID, agentSettings.Budget)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Budget retrieved",
+		Results: budget,
+	})
+}
@@ -3,6 +3,7 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -26,6 +27,21 @@ var (
 	Unregister = make(chan *websocket.Conn)
 )
 
+// TryBroadcast queues a message for all clients, giving up when the hub does not take it
+// within timeout (e.g. when the hub is not running). It reports whether the message was queued.
+func TryBroadcast(ctx context.Context, message BroadcastMessage, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case Broadcast <- message:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return false
+	}
+}
+
 func handleRegister(conn *websocket.Conn) {
 	Clients[conn] = client{}
 	logrus.Println("connection registered")
@@ -3,18 +3,30 @@ package usecase
 import (
 	"context"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/calendar"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/knowledge"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/notification"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
 	agentRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/agent"
 	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/translation"
 	"github.com/sirupsen/logrus"
 )
 
 type AgentService struct {
-	repo            *agentRepo.SQLiteRepository
-	settingsService *SettingsService
+	repo             *agentRepo.SQLiteRepository
+	settingsService  *SettingsService
+	knowledgeService *KnowledgeService
+	flowService      *FlowService
+	calendarService  calendar.ICalendarService
+	notifications    *NotificationService
+	translationCache *translation.Cache
+	usageService     *UsageService
+	sendUsecase      domainSend.ISendUsecase
 }
 
 func NewAgentService(repo *agentRepo.SQLiteRepository) *AgentService {
@@ -26,6 +38,31 @@ func (s *AgentService) SetSettingsService(settingsService *SettingsService) {
 	s.settingsService = settingsService
 }
 
+// SetKnowledgeService enables knowledge retrieval and the knowledge_search tool (called after initialization)
+func (s *AgentService) SetKnowledgeService(knowledgeService *KnowledgeService) {
+	s.knowledgeService = knowledgeService
+}
+
+// SetFlowService enables the run_flow tool (called after initialization)
+func (s *AgentService) SetFlowService(flowService *FlowService) {
+	s.flowService = flowService
+}
+
+// SetCalendarService enables the calendar tools (called after initialization)
+func (s *AgentService) SetCalendarService(calendarService calendar.ICalendarService) {
+	s.calendarService = calendarService
+}
+
+// SetNotificationService enables sentiment alerts and escalation notices (called after initialization)
+func (s *AgentService) SetNotificationService(notificationService *NotificationService) {
+	s.notifications = notificationService
+}
+
+// SetUsageService enables monthly budget caps (called after initialization)
+func (s *AgentService) SetUsageService(usageService *UsageService) {
+	s.usageService = usageService
+}
+
 func maskAPIKey(key string) string {
 	if len(key) <= 8 {
 		return "****"
@@ -33,10 +70,30 @@ func maskAPIKey(key string) string {
 	return key[:4] + "..." + key[len(key)-4:]
 }
 
+// validateAgentProvider checks the provider name and the endpoint it requires
+func validateAgentProvider(provider, baseURL string) error {
+	if !aiService.IsValidProvider(provider) {
+		return fmt.Errorf("unsupported provider: %s", provider)
+	}
+	if provider == aiService.ProviderAzure && baseURL == "" {
+		return fmt.Errorf("base_url is required for the azure provider")
+	}
+	return nil
+}
+
 func (s *AgentService) CreateAgent(ctx context.Context, req agent.CreateAgentRequest) (*agent.AgentResponse, error) {
+	if req.Provider == "" {
+		req.Provider = aiService.ProviderOpenAI
+	}
+	if err := validateAgentProvider(req.Provider, req.BaseURL); err != nil {
+		return nil, err
+	}
+
 	a := &agent.Agent{
 		Name:           req.Name,
 		Description:    req.Description,
+		Provider:       req.Provider,
+		BaseURL:        req.BaseURL,
 		APIKey:         req.APIKey,
 		SerpAPIKey:     req.SerpAPIKey,
 		Model:          req.Model,
@@ -95,6 +152,12 @@ func (s *AgentService) UpdateAgent(ctx context.Context, id string, req agent.Upd
 	if req.Description != nil {
 		a.Description = *req.Description
 	}
+	if req.Provider != nil {
+		a.Provider = *req.Provider
+	}
+	if req.BaseURL != nil {
+		a.BaseURL = *req.BaseURL
+	}
 	if req.APIKey != nil {
 		a.APIKey = *req.APIKey
 	}
@@ -113,6 +176,21 @@ func (s *AgentService) UpdateAgent(ctx context.Context, id string, req agent.Upd
 	if req.IsActive != nil {
 		a.IsActive = *req.IsActive
 	}
+	if err := validateAgentProvider(a.Provider, a.BaseURL); err != nil {
+		return nil, err
+	}
+	// Documents already indexed can't be searched by a provider without embeddings
+	if req.Provider != nil && s.knowledgeService != nil {
+		if err := checkEmbeddingProvider(a.Provider); err != nil {
+			docs, docErr := s.knowledgeService.GetDocuments(ctx, a.ID)
+			if docErr != nil {
+				return nil, docErr
+			}
+			if len(docs) > 0 {
+				return nil, fmt.Errorf("%w; delete the agent's knowledge documents first", err)
+			}
+		}
+	}
 
 	if err := s.repo.Update(ctx, a); err != nil {
 		return nil, fmt.Errorf("failed to update agent: %w", err)
@@ -149,6 +227,8 @@ func (s *AgentService) agentToResponse(ctx context.Context, a *agent.Agent) (*ag
 		ID:             a.ID,
 		Name:           a.Name,
 		Description:    a.Description,
+		Provider:       a.Provider,
+		BaseURL:        a.BaseURL,
 		APIKeyMasked:   maskAPIKey(a.APIKey),
 		Model:          a.Model,
 		SystemPrompt:   a.SystemPrompt,
@@ -166,6 +246,14 @@ func (s *AgentService) agentToResponse(ctx context.Context, a *agent.Agent) (*ag
 
 // HandleIncomingMessage processes an incoming message and generates AI response
 func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integrationID, remoteJID, userMessage string) (string, error) {
+	return s.HandleIncomingMessageStream(ctx, agentID, integrationID, remoteJID, userMessage, nil, nil)
+}
+
+// HandleIncomingMessageStream is HandleIncomingMessage that also takes the message attachments
+// (images, documents) and streams the reply to the channel's stream (optional) and the live-chat
+// websocket when the agent has streaming enabled. userMessage is the text or caption and may be
+// empty when attachments are present.
+func (s *AgentService) HandleIncomingMessageStream(ctx context.Context, agentID, integrationID, remoteJID, userMessage string, attachments []agent.Attachment, stream agent.ReplyStream) (string, error) {
 	logrus.Infof("🤖 [AgentService] HandleIncomingMessage: agent=%s, integration=%s, user=%s, message=%s", agentID, integrationID, remoteJID, userMessage[:min(50, len(userMessage))])
 	
 	// Get agent
@@ -200,6 +288,10 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 		return "", fmt.Errorf("failed to get conversation: %w", err)
 	}
 	logrus.Debugf("💬 [AgentService] Conversation %s found/created", conv.ID)
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{AgentID: agentID, ConversationID: conv.ID})
+
+	// What is stored when no reply is generated: the text plus placeholders for attachments
+	storedMessage := joinMessageParts(userMessage, describeAttachments(attachments))
 
 	// Check if in manual mode (manager took over)
 	if conv.IsManualMode {
@@ -207,7 +299,7 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 		userMsg := &agent.Message{
 			ConversationID: conv.ID,
 			Role:           "user",
-			Content:        userMessage,
+			Content:        storedMessage,
 		}
 		s.repo.AddMessage(ctx, userMsg)
 		logrus.Infof("⏸️  [AgentService] Conversation %s is in manual mode, skipping AI response", conv.ID)
@@ -216,6 +308,16 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 	
 	logrus.Debugf("🔄 [AgentService] Processing message for conv %s, agent active: %v, manual mode: %v", conv.ID, a.IsActive, conv.IsManualMode)
 
+	// Message-triggered flows run first; depending on the agent's flow mode they replace the AI reply
+	if flowReply, skipAI := s.dispatchFlows(ctx, a, integration, conv, userMessage, attachments); skipAI {
+		s.repo.AddMessage(ctx, &agent.Message{ConversationID: conv.ID, Role: "user", Content: storedMessage})
+		if flowReply != "" {
+			s.repo.AddMessage(ctx, &agent.Message{ConversationID: conv.ID, Role: "assistant", Content: flowReply})
+		}
+		// Send nodes have already delivered the flow's replies
+		return "", nil
+	}
+
 	// Check Working Hours if settings service is available
 	if s.settingsService != nil {
 		isWorking, awayMessage, err := s.settingsService.IsWithinWorkingHours(ctx, agentID)
@@ -227,7 +329,7 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 			userMsg := &agent.Message{
 				ConversationID: conv.ID,
 				Role:           "user",
-				Content:        userMessage,
+				Content:        storedMessage,
 			}
 			s.repo.AddMessage(ctx, userMsg)
 			
@@ -256,75 +358,105 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 		}
 	}
 
+	// Monthly budget: once the cap is reached the agent answers with an away message
+	if agentSettings != nil && agentSettings.Budget.Enabled && s.usageService != nil {
+		budget, err := s.usageService.GetBudget(ctx, agentID, agentSettings.Budget)
+		if err != nil {
+			logrus.Warnf("⚠️  [AgentService] Failed to check budget: %v", err)
+		} else if budget.Exceeded {
+			logrus.Infof("💸 [AgentService] Agent %s reached its monthly budget ($%.2f of $%.2f), sending away message", agentID, budget.Spent, budget.MonthlyLimit)
+			awayMessage := agentSettings.Budget.AwayMessage
+			if awayMessage == "" {
+				awayMessage = settings.DefaultAgentSettings(agentID).Budget.AwayMessage
+			}
+			s.repo.AddMessage(ctx, &agent.Message{ConversationID: conv.ID, Role: "user", Content: storedMessage})
+			s.repo.AddMessage(ctx, &agent.Message{ConversationID: conv.ID, Role: "assistant", Content: awayMessage})
+			return awayMessage, nil
+		}
+	}
+
 	// Process user message: Translation and Sentiment Analysis
 	processedUserMessage := userMessage
 	
 	// Initialize AI service for translation and sentiment
-	aiSvc := aiService.NewService(a.APIKey, a.SerpAPIKey)
+	aiSvc := aiService.NewServiceForAgent(a)
 	if aiSvc == nil {
 		logrus.Errorf("❌ [AgentService] Failed to initialize AI service for agent %s", a.ID)
 		return "", fmt.Errorf("failed to initialize AI service")
 	}
 
+	// Attachments: with vision enabled images go to the model and documents are read as text
+	vision := visionSettingsOrDefault(agentSettings)
+	attachmentText := describeAttachments(attachments)
+	var images []aiService.Image
+	if vision.Enabled && len(attachments) > 0 {
+		attachmentText, images = prepareAttachments(vision, attachments)
+		logrus.Infof("🖼️  [AgentService] Prepared %d attachments for agent %s (%d images)", len(attachments), a.ID, len(images))
+	}
+
 	// Sentiment Analysis (if enabled)
-	if agentSettings != nil && agentSettings.Sentiment.Enabled {
-		sentimentScore, sentimentLabel, err := aiSvc.AnalyzeSentiment(ctx, userMessage)
+	var sentimentScore *float64
+	var sentimentLabel string
+	if agentSettings != nil && agentSettings.Sentiment.Enabled && userMessage != "" {
+		sentiment := sentimentSettingsOrDefault(agentSettings)
+		score, label, err := aiSvc.AnalyzeSentiment(ctx, userMessage)
 		if err == nil {
-			logrus.Infof("😊 [AgentService] Sentiment analysis: score=%.2f, label=%s", sentimentScore, sentimentLabel)
-			
+			logrus.Infof("😊 [AgentService] Sentiment analysis: score=%.2f, label=%s", score, label)
+			sentimentScore, sentimentLabel = &score, label
+
 			// Check if negative sentiment threshold is exceeded
-			if agentSettings.Sentiment.AlertOnNegative && sentimentScore < -agentSettings.Sentiment.NegativeThreshold {
-				logrus.Warnf("⚠️  [AgentService] Negative sentiment detected (score: %.2f, threshold: %.2f)", sentimentScore, agentSettings.Sentiment.NegativeThreshold)
-				// TODO: Send alert/notification
+			if sentiment.AlertOnNegative && score < -sentiment.NegativeThreshold {
+				logrus.Warnf("⚠️  [AgentService] Negative sentiment detected (score: %.2f, threshold: %.2f)", score, sentiment.NegativeThreshold)
+				s.notifySentiment(ctx, a, conv, sentiment, notification.TypeWarning, "Negative sentiment detected", userMessage, score, label)
 			}
-			
+
 			// Check if very negative (escalate to human)
-			if agentSettings.Sentiment.EscalateOnVeryNegative && sentimentScore < -0.7 {
-				logrus.Warnf("🚨 [AgentService] Very negative sentiment detected (score: %.2f), consider escalating to human", sentimentScore)
-				// TODO: Auto-escalate to manual mode
+			if sentiment.EscalateOnVeryNegative && score < -sentiment.EscalationThreshold {
+				logrus.Warnf("🚨 [AgentService] Very negative sentiment detected (score: %.2f), escalating conv %s to a human", score, conv.ID)
+				return s.escalateConversation(ctx, a, conv, sentiment, joinMessageParts(userMessage, attachmentText), score, label)
 			}
 		} else {
 			logrus.Warnf("⚠️  [AgentService] Failed to analyze sentiment: %v", err)
 		}
 	}
 
-	// Translation: Translate incoming message if enabled
-	if agentSettings != nil && agentSettings.Translation.Enabled && agentSettings.Translation.TranslateIncoming {
-		sourceLang := agentSettings.Translation.SourceLanguage
-		if agentSettings.Translation.AutoDetect {
-			detectedLang, err := aiSvc.DetectLanguage(ctx, userMessage)
-			if err == nil {
-				logrus.Infof("🌐 [AgentService] Detected language: %s", detectedLang)
-				if detectedLang != sourceLang {
-					translated, err := aiSvc.TranslateText(ctx, userMessage, detectedLang, sourceLang)
-					if err == nil {
-						logrus.Infof("🔄 [AgentService] Translated incoming message from %s to %s", detectedLang, sourceLang)
-						processedUserMessage = translated
-					} else {
-						logrus.Warnf("⚠️  [AgentService] Failed to translate incoming message: %v", err)
-					}
-				}
-			} else {
-				logrus.Warnf("⚠️  [AgentService] Failed to detect language: %v", err)
-			}
+	// Translation: resolve the customer's language once and reuse it for both directions
+	translationCfg := translationSettingsOrDefault(agentSettings)
+	sourceLang := translationCfg.SourceLanguage
+	var userLang string
+	if translationCfg.Enabled && (translationCfg.TranslateIncoming || translationCfg.TranslateOutgoing) {
+		userLang = s.customerLanguage(ctx, aiSvc, conv, integration, translationCfg, userMessage)
+		if userLang == "" {
+			logrus.Debugf("ℹ️  [AgentService] Translation enabled but customer language unknown, skipping translation")
+		}
+	}
+	needsTranslation := userLang != "" && userLang != sourceLang
+
+	// Translate incoming message if enabled
+	if needsTranslation && translationCfg.TranslateIncoming && userMessage != "" {
+		translated, err := s.translate(ctx, aiSvc, userMessage, userLang, sourceLang)
+		if err == nil {
+			logrus.Infof("🔄 [AgentService] Translated incoming message from %s to %s", userLang, sourceLang)
+			processedUserMessage = translated
 		} else {
-			// Assume user message is in a different language and translate to source language
-			// For now, we'll skip this if auto-detect is disabled (requires knowing user's language)
-			logrus.Debugf("ℹ️  [AgentService] Translation enabled but auto-detect disabled, skipping translation")
+			logrus.Warnf("⚠️  [AgentService] Failed to translate incoming message: %v", err)
 		}
 	}
 
-	// Store user message (original or translated)
+	// Store user message (original or translated) with the attachment text
 	userMsg := &agent.Message{
 		ConversationID: conv.ID,
 		Role:           "user",
-		Content:        processedUserMessage, // Store processed message
+		Content:        joinMessageParts(processedUserMessage, attachmentText),
+		SentimentScore: sentimentScore,
+		SentimentLabel: sentimentLabel,
 	}
 	if err := s.repo.AddMessage(ctx, userMsg); err != nil {
 		return "", fmt.Errorf("failed to store user message: %w", err)
 	}
 
 	var response string
+	var knowledgeChunkIDs []string
 
 	// Check if this is the first reply - send welcome message
 	if !conv.IsFirstReply && a.WelcomeMessage != "" {
@@ -344,51 +476,106 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 			logrus.Debugf("ℹ️  [AgentService] No SerpAPI key for agent %s", a.ID)
 		}
 
-		// Get recent messages for context (limited to 5 for efficiency)
-		recentMessages, err := s.repo.GetRecentMessages(ctx, conv.ID, 5)
+		// Build the multi-turn history window (includes the message stored above)
+		history := historySettingsOrDefault(agentSettings)
+		recentMessages, err := s.repo.GetRecentMessages(ctx, conv.ID, history.MaxMessages)
 		if err != nil {
 			return "", fmt.Errorf("failed to get recent messages: %w", err)
 		}
+		window := buildHistoryWindow(recentMessages, history.MaxContextTokens)
+		logrus.Debugf("📚 [AgentService] Using %d of %d recent messages as context for conv %s", len(window), len(recentMessages), conv.ID)
 
-		// Build context from recent messages
-		var contextBuilder strings.Builder
-		for _, msg := range recentMessages {
-			if msg.Role == "user" {
-				contextBuilder.WriteString(fmt.Sprintf("User: %s\n", msg.Content))
-			} else if msg.Role == "assistant" {
-				contextBuilder.WriteString(fmt.Sprintf("Assistant: %s\n", msg.Content))
+		// Fold turns that fell out of the window into the rolling summary
+		if history.SummarizeEnabled && len(window) > 0 {
+			s.refreshConversationSummary(ctx, aiSvc, conv, window[0].Timestamp, history.SummarizeThreshold)
+		}
+
+		systemPrompt := a.SystemPrompt
+		if conv.Summary != "" {
+			systemPrompt = fmt.Sprintf("%s\n\nSummary of the earlier conversation:\n%s", systemPrompt, conv.Summary)
+		}
+
+		// Inject knowledge base chunks relevant to this message
+		if knowledgeSettings := knowledgeSettingsOrDefault(agentSettings); s.knowledgeService != nil && knowledgeSettings.Enabled && processedUserMessage != "" {
+			relevant, err := s.knowledgeService.RetrieveContext(ctx, knowledge.ContextRequest{
+				AgentID:   a.ID,
+				Query:     processedUserMessage,
+				TopK:      knowledgeSettings.TopK,
+				MinScore:  knowledgeSettings.Threshold,
+				MaxTokens: knowledgeSettings.MaxContextTokens,
+			})
+			if err != nil {
+				logrus.Warnf("⚠️  [AgentService] Failed to retrieve knowledge context: %v", err)
+			} else if relevant.Text != "" {
+				systemPrompt = fmt.Sprintf("%s\n\n%s", systemPrompt, relevant.Text)
+				knowledgeChunkIDs = relevant.ChunkIDs()
+				logrus.Debugf("📖 [AgentService] Injected %d knowledge chunks for conv %s", len(knowledgeChunkIDs), conv.ID)
 			}
 		}
 
-		// If we have context, include it in the prompt
-		finalPrompt := userMessage
-		if contextBuilder.Len() > 0 {
-			finalPrompt = fmt.Sprintf("Previous conversation:\n%s\nCurrent message: %s", contextBuilder.String(), userMessage)
+		// Built-in tools the agent enabled
+		var tools []aiService.ToolDefinition
+		var toolCtx *toolContext
+		if agentSettings != nil && len(agentSettings.Tools.Enabled) > 0 {
+			toolCtx = &toolContext{agent: a, conv: conv, settings: agentSettings.Tools}
+			tools = s.enabledTools(ctx, toolCtx)
+		}
+
+		var toolHandler aiService.ToolHandler
+		maxRounds := 0
+		if len(tools) > 0 {
+			systemPrompt = fmt.Sprintf("%s\n\nCurrent time: %s", systemPrompt, time.Now().Format(time.RFC3339))
+			toolHandler = s.toolHandler(toolCtx)
+			maxRounds = agentSettings.Tools.MaxRounds
+		}
+
+		// Streaming is skipped when replies are translated, since the streamed text would not be what is sent
+		var onDelta aiService.StreamHandler
+		var broadcaster *replyBroadcaster
+		streaming := streamingSettingsOrDefault(agentSettings)
+		translatesOutgoing := needsTranslation && translationCfg.TranslateOutgoing
+		if streaming.Enabled && !translatesOutgoing {
+			broadcaster = newReplyBroadcaster(ctx, conv)
+			if stream != nil {
+				stream.Begin(streaming)
+			}
+			onDelta = func(delta string) {
+				if stream != nil {
+					stream.Delta(delta)
+				}
+				broadcaster.Delta(delta)
+			}
+		}
+
+		// Images only accompany the current turn; a dedicated vision model may answer it
+		model := a.Model
+		chatMessages := toChatMessages(window)
+		if n := len(chatMessages); len(images) > 0 && n > 0 && chatMessages[n-1].Role == aiService.RoleUser {
+			chatMessages[n-1].Images = images
+			if vision.Model != "" {
+				model = vision.Model
+			}
 		}
 
-		logrus.Debugf("💭 [AgentService] Generating AI response for agent %s (model: %s)", a.ID, a.Model)
-		response, err = aiSvc.GenerateResponse(ctx, finalPrompt, a.SystemPrompt, a.Model, maxTokens, temperature)
+		logrus.Debugf("💭 [AgentService] Generating AI response for agent %s (model: %s, tools: %d, images: %d, streaming: %v)", a.ID, model, len(tools), len(images), onDelta != nil)
+		response, err = aiSvc.StreamChatResponse(ctx, chatMessages, systemPrompt, model, maxTokens, temperature, tools, toolHandler, maxRounds, onDelta)
+		if broadcaster != nil {
+			broadcaster.Finish(response, err)
+		}
 		if err != nil {
 			logrus.Errorf("❌ [AgentService] Failed to generate AI response for agent %s: %v", a.ID, err)
 			return "", fmt.Errorf("failed to generate AI response: %w", err)
 		}
 		logrus.Infof("💡 [AgentService] AI response generated for agent %s: %s", a.ID, response[:min(100, len(response))])
 
-		// Translation: Translate outgoing response if enabled
-		if agentSettings != nil && agentSettings.Translation.Enabled && agentSettings.Translation.TranslateOutgoing {
-			sourceLang := agentSettings.Translation.SourceLanguage
-			// Detect user's language from original message
-			if agentSettings.Translation.AutoDetect {
-				userLang, err := aiSvc.DetectLanguage(ctx, userMessage) // Use original message
-				if err == nil && userLang != sourceLang {
-					translated, err := aiSvc.TranslateText(ctx, response, sourceLang, userLang)
-					if err == nil {
-						logrus.Infof("🔄 [AgentService] Translated outgoing response from %s to %s", sourceLang, userLang)
-						response = translated
-					} else {
-						logrus.Warnf("⚠️  [AgentService] Failed to translate outgoing response: %v", err)
-					}
-				}
+		// Translation: Translate outgoing response into the customer's language if enabled
+		if translatesOutgoing {
+			translated, err := s.translate(ctx, aiSvc, response, sourceLang, userLang)
+			if err == nil {
+				logrus.Infof("🔄 [AgentService] Translated outgoing response from %s to %s", sourceLang, userLang)
+				response = translated
+			} else {
+				logrus.Warnf("⚠️  [AgentService] Failed to translate outgoing response: %v", err)
 			}
 		}
 
@@ -401,9 +588,10 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 
 	// Store assistant response
 	assistantMsg := &agent.Message{
-		ConversationID: conv.ID,
-		Role:           "assistant",
-		Content:        response,
+		ConversationID:    conv.ID,
+		Role:              "assistant",
+		Content:           response,
+		KnowledgeChunkIDs: knowledgeChunkIDs,
 	}
 	if err := s.repo.AddMessage(ctx, assistantMsg); err != nil {
 		return "", fmt.Errorf("failed to store assistant message: %w", err)
@@ -412,6 +600,108 @@ func (s *AgentService) HandleIncomingMessage(ctx context.Context, agentID, integ
 	return response, nil
 }
 
+// maxSummaryBatch bounds how many overflow messages are folded into the summary per call
+const maxSummaryBatch = 100
+
+// historySettingsOrDefault returns the agent's history settings with defaults for unset values
+func historySettingsOrDefault(agentSettings *settings.AgentSettings) settings.HistorySettings {
+	defaults := settings.DefaultAgentSettings("").History
+	if agentSettings == nil {
+		return defaults
+	}
+	history := agentSettings.History
+	if history.MaxMessages <= 0 {
+		history.MaxMessages = defaults.MaxMessages
+	}
+	if history.MaxContextTokens <= 0 {
+		history.MaxContextTokens = defaults.MaxContextTokens
+	}
+	if history.SummarizeThreshold <= 0 {
+		history.SummarizeThreshold = defaults.SummarizeThreshold
+	}
+	return history
+}
+
+// knowledgeSettingsOrDefault returns the agent's knowledge settings with defaults for unset values
+func knowledgeSettingsOrDefault(agentSettings *settings.AgentSettings) settings.KnowledgeSettings {
+	defaults := settings.DefaultAgentSettings("").Knowledge
+	if agentSettings == nil {
+		return defaults
+	}
+	k := agentSettings.Knowledge
+	if k.TopK <= 0 {
+		k.TopK = defaults.TopK
+	}
+	if k.MaxContextTokens <= 0 {
+		k.MaxContextTokens = defaults.MaxContextTokens
+	}
+	return k
+}
+
+// buildHistoryWindow keeps the newest messages that fit into tokenBudget, in chronological order.
+// The newest message is always kept so the model sees the current turn.
+func buildHistoryWindow(messages []*agent.Message, tokenBudget int) []*agent.Message {
+	start := len(messages)
+	used := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		cost := aiService.EstimateTokens(messages[i].Content)
+		if start < len(messages) && used+cost > tokenBudget {
+			break
+		}
+		used += cost
+		start = i
+	}
+	return messages[start:]
+}
+
+// toChatMessages converts stored messages to model turns, merging consecutive turns of the same role
+func toChatMessages(messages []*agent.Message) []aiService.ChatMessage {
+	var turns []aiService.ChatMessage
+	for _, msg := range messages {
+		if msg.Role != aiService.RoleUser && msg.Role != aiService.RoleAssistant {
+			continue
+		}
+		if n := len(turns); n > 0 && turns[n-1].Role == msg.Role {
+			turns[n-1].Content += "\n" + msg.Content
+			continue
+		}
+		turns = append(turns, aiService.ChatMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return turns
+}
+
+// refreshConversationSummary folds unsummarized messages older than windowStart into the conversation summary
+func (s *AgentService) refreshConversationSummary(ctx context.Context, aiSvc *aiService.Service, conv *agent.Conversation, windowStart time.Time, threshold int) {
+	var after time.Time
+	if conv.SummarizedUntil != nil {
+		after = *conv.SummarizedUntil
+	}
+
+	pending, err := s.repo.GetMessagesInRange(ctx, conv.ID, after, windowStart, maxSummaryBatch)
+	if err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to load messages for summary: %v", err)
+		return
+	}
+	if len(pending) < threshold {
+		return
+	}
+
+	summary, err := aiSvc.SummarizeConversation(ctx, conv.Summary, toChatMessages(pending))
+	if err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to summarize conversation %s: %v", conv.ID, err)
+		return
+	}
+
+	until := pending[len(pending)-1].Timestamp
+	if err := s.repo.UpdateConversationSummary(ctx, conv.ID, summary, until); err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to store summary for conversation %s: %v", conv.ID, err)
+		return
+	}
+	conv.Summary = summary
+	conv.SummarizedUntil = &until
+	logrus.Infof("📝 [AgentService] Summarized %d older messages for conversation %s", len(pending), conv.ID)
+}
+
 // Integration management methods
 
 func (s *AgentService) GetOrCreateIntegration(ctx context.Context, agentID, integrationType string) (*agent.Integration, error) {
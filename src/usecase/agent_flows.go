@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	"github.com/sirupsen/logrus"
+)
+
+// flowSettingsOrDefault returns the agent's flow settings, falling back to the default mode
+// when it is unset or unknown
+func flowSettingsOrDefault(agentSettings *settings.AgentSettings) settings.FlowSettings {
+	defaults := settings.DefaultAgentSettings("").Flows
+	if agentSettings == nil {
+		return defaults
+	}
+	f := agentSettings.Flows
+	switch f.Mode {
+	case settings.FlowModeFlowThenAI, settings.FlowModeFlowOnly, settings.FlowModeAIOnly:
+	default:
+		f.Mode = defaults.Mode
+	}
+	return f
+}
+
+// incomingMessageType classifies a message for flow triggers: the type set by the channel,
+// else the type of the first attachment, else text
+func incomingMessageType(ctx context.Context, attachments []agent.Attachment) string {
+	if messageType := agent.MessageTypeFromContext(ctx); messageType != "" {
+		return messageType
+	}
+	if len(attachments) > 0 {
+		return attachments[0].Type
+	}
+	return agent.MessageTypeText
+}
+
+// dispatchFlows runs the agent's message-triggered flows and returns what they sent to the sender,
+// joined into one message for the conversation history. skipAI is true when the AI must not reply: always in flow_only mode, and in
+// flow_then_ai mode when a flow replied or the message was a reply a flow was waiting for.
+func (s *AgentService) dispatchFlows(ctx context.Context, a *agent.Agent, integration *agent.Integration, conv *agent.Conversation, userMessage string, attachments []agent.Attachment) (reply string, skipAI bool) {
+	var agentSettings *settings.AgentSettings
+	if s.settingsService != nil {
+		agentSettings, _ = s.settingsService.GetAgentSettings(ctx, a.ID)
+	}
+	mode := flowSettingsOrDefault(agentSettings).Mode
+	if mode == settings.FlowModeAIOnly || s.flowService == nil {
+		return "", mode == settings.FlowModeFlowOnly
+	}
+
+	result, err := s.flowService.DispatchMessage(ctx, FlowMessage{
+		AgentID:        a.ID,
+		IntegrationID:  integration.ID,
+		Channel:        integration.Type,
+		Sender:         conv.RemoteJID,
+		ConversationID: conv.ID,
+		Text:           userMessage,
+		MessageType:    incomingMessageType(ctx, attachments),
+		Attachments:    attachments,
+	})
+	if err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to dispatch flows for agent %s: %v", a.ID, err)
+		return "", mode == settings.FlowModeFlowOnly
+	}
+	if result.Matched > 0 {
+		logrus.Infof("🔀 [AgentService] Message on conv %s triggered %d flows (%d replies, mode: %s)", conv.ID, result.Matched, len(result.Replies), mode)
+	}
+
+	reply = joinMessageParts(result.Replies...)
+	return reply, mode == settings.FlowModeFlowOnly || reply != "" || result.Resumed > 0
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/notification"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	"github.com/sirupsen/logrus"
+)
+
+// sentimentSettingsOrDefault returns the agent's sentiment settings with defaults for unset thresholds
+func sentimentSettingsOrDefault(agentSettings *settings.AgentSettings) settings.SentimentSettings {
+	defaults := settings.DefaultAgentSettings("").Sentiment
+	if agentSettings == nil {
+		return defaults
+	}
+
+	sentiment := agentSettings.Sentiment
+	if sentiment.EscalationThreshold <= 0 {
+		sentiment.EscalationThreshold = defaults.EscalationThreshold
+	}
+	if sentiment.HandoffMessage == "" {
+		sentiment.HandoffMessage = defaults.HandoffMessage
+	}
+	return sentiment
+}
+
+// notifySentiment records a sentiment notification and forwards it to the configured webhook
+func (s *AgentService) notifySentiment(ctx context.Context, a *agent.Agent, conv *agent.Conversation, sentiment settings.SentimentSettings,
+	notifType, title, userMessage string, score float64, label string) {
+	if s.notifications == nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]any{
+		"conversation_id": conv.ID,
+		"integration_id":  conv.IntegrationID,
+		"remote_jid":      conv.RemoteJID,
+		"score":           score,
+		"label":           label,
+		"message":         userMessage,
+	})
+	notif := &notification.Notification{
+		Type:      notifType,
+		Title:     title,
+		Message:   fmt.Sprintf("%s (score %.2f): %s", conv.RemoteJID, score, userMessage),
+		AgentID:   a.ID,
+		AgentName: a.Name,
+		Data:      string(data),
+	}
+	if err := s.notifications.Notify(ctx, notif, sentiment.WebhookURL); err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to send sentiment notification: %v", err)
+	}
+}
+
+// escalateConversation hands the conversation over to a human: the AI is paused (manual mode),
+// the team is notified and the customer receives the hand-off message instead of an AI reply
+func (s *AgentService) escalateConversation(ctx context.Context, a *agent.Agent, conv *agent.Conversation, sentiment settings.SentimentSettings,
+	userMessage string, score float64, label string) (string, error) {
+	if err := s.repo.SetConversationManualMode(ctx, conv.ID, true); err != nil {
+		return "", fmt.Errorf("failed to escalate conversation: %w", err)
+	}
+	conv.IsManualMode = true
+
+	s.notifySentiment(ctx, a, conv, sentiment, notification.TypeError, "Conversation escalated to a human", userMessage, score, label)
+
+	userMsg := &agent.Message{
+		ConversationID: conv.ID,
+		Role:           "user",
+		Content:        userMessage,
+		SentimentScore: &score,
+		SentimentLabel: label,
+	}
+	if err := s.repo.AddMessage(ctx, userMsg); err != nil {
+		return "", fmt.Errorf("failed to store user message: %w", err)
+	}
+
+	handoffMsg := &agent.Message{
+		ConversationID: conv.ID,
+		Role:           "assistant",
+		Content:        sentiment.HandoffMessage,
+	}
+	if err := s.repo.AddMessage(ctx, handoffMsg); err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to store hand-off message: %v", err)
+	}
+	return sentiment.HandoffMessage, nil
+}
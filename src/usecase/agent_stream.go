@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Websocket event codes for streamed agent replies
+const (
+	wsCodeReplyStart = "AGENT_REPLY_START"
+	wsCodeReplyDelta = "AGENT_REPLY_DELTA"
+	wsCodeReplyDone  = "AGENT_REPLY_DONE"
+)
+
+const (
+	// replyDeltaInterval batches tokens so the hub is not hit once per token
+	replyDeltaInterval = 100 * time.Millisecond
+	// replyBroadcastTimeout drops events when no websocket hub is consuming them
+	replyBroadcastTimeout = time.Second
+)
+
+// replyStreamEvent is the websocket payload for streamed agent replies
+type replyStreamEvent struct {
+	AgentID        string `json:"agent_id"`
+	ConversationID string `json:"conversation_id"`
+	RemoteJID      string `json:"remote_jid"`
+	Delta          string `json:"delta,omitempty"`
+	Content        string `json:"content,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// replyBroadcaster publishes a reply to the live-chat websocket while it is generated
+type replyBroadcaster struct {
+	ctx       context.Context
+	event     replyStreamEvent
+	pending   strings.Builder
+	lastFlush time.Time
+	noHub     bool
+}
+
+func newReplyBroadcaster(ctx context.Context, conv *agent.Conversation) *replyBroadcaster {
+	b := &replyBroadcaster{
+		ctx:   ctx,
+		event: replyStreamEvent{AgentID: conv.AgentID, ConversationID: conv.ID, RemoteJID: conv.RemoteJID},
+	}
+	b.publish(wsCodeReplyStart, "Agent reply started", b.event)
+	b.lastFlush = time.Now()
+	return b
+}
+
+// Delta queues reply text and publishes it once replyDeltaInterval has passed
+func (b *replyBroadcaster) Delta(text string) {
+	b.pending.WriteString(text)
+	if time.Since(b.lastFlush) >= replyDeltaInterval {
+		b.flush()
+	}
+}
+
+// Finish publishes any queued text and the final reply, or the generation error
+func (b *replyBroadcaster) Finish(content string, err error) {
+	b.flush()
+	event := b.event
+	event.Content = content
+	if err != nil {
+		event.Error = err.Error()
+	}
+	b.publish(wsCodeReplyDone, "Agent reply finished", event)
+}
+
+func (b *replyBroadcaster) flush() {
+	b.lastFlush = time.Now()
+	if b.pending.Len() == 0 {
+		return
+	}
+	event := b.event
+	event.Delta = b.pending.String()
+	b.pending.Reset()
+	b.publish(wsCodeReplyDelta, "Agent reply delta", event)
+}
+
+func (b *replyBroadcaster) publish(code, message string, event replyStreamEvent) {
+	if b.noHub {
+		return
+	}
+	if !websocket.TryBroadcast(b.ctx, websocket.BroadcastMessage{Code: code, Message: message, Result: event}, replyBroadcastTimeout) {
+		b.noHub = true
+		logrus.Debugf("⏭️  [AgentService] No websocket hub, not streaming conv %s to live chat", b.event.ConversationID)
+	}
+}
+
+// streamingSettingsOrDefault returns the agent's streaming settings with defaults for unset values
+func streamingSettingsOrDefault(agentSettings *settings.AgentSettings) settings.StreamingSettings {
+	defaults := settings.DefaultAgentSettings("").Streaming
+	if agentSettings == nil {
+		return defaults
+	}
+	streaming := agentSettings.Streaming
+	if streaming.MinChunkChars <= 0 {
+		streaming.MinChunkChars = defaults.MinChunkChars
+	}
+	if streaming.EditIntervalMs <= 0 {
+		streaming.EditIntervalMs = defaults.EditIntervalMs
+	}
+	return streaming
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+)
+
+func TestBuildHistoryWindow(t *testing.T) {
+	long := strings.Repeat("a", 400) // ~101 tokens
+	messages := []*agent.Message{
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: long},
+	}
+
+	tests := []struct {
+		name   string
+		budget int
+		want   int
+	}{
+		{name: "AllFit", budget: 1000, want: 3},
+		{name: "TwoFit", budget: 250, want: 2},
+		{name: "CurrentAlwaysKept", budget: 1, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildHistoryWindow(messages, tt.budget)
+			if len(got) != tt.want {
+				t.Fatalf("buildHistoryWindow() kept %d messages, want %d", len(got), tt.want)
+			}
+			if got[len(got)-1] != messages[len(messages)-1] {
+				t.Fatalf("buildHistoryWindow() dropped the newest message")
+			}
+		})
+	}
+}
+
+func TestToChatMessages(t *testing.T) {
+	messages := []*agent.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "user", Content: "anyone there?"},
+		{Role: "system", Content: "ignored"},
+		{Role: "assistant", Content: "hello"},
+	}
+
+	got := toChatMessages(messages)
+	if len(got) != 2 {
+		t.Fatalf("toChatMessages() returned %d turns, want 2", len(got))
+	}
+	if got[0].Role != "user" || got[0].Content != "hi\nanyone there?" {
+		t.Fatalf("toChatMessages() first turn = %+v", got[0])
+	}
+	if got[1].Role != "assistant" || got[1].Content != "hello" {
+		t.Fatalf("toChatMessages() second turn = %+v", got[1])
+	}
+}
+
+func TestHistorySettingsOrDefault(t *testing.T) {
+	got := historySettingsOrDefault(&settings.AgentSettings{
+		History: settings.HistorySettings{MaxMessages: 8, SummarizeEnabled: true},
+	})
+	if got.MaxMessages != 8 {
+		t.Fatalf("MaxMessages = %d, want 8", got.MaxMessages)
+	}
+	if got.MaxContextTokens <= 0 || got.SummarizeThreshold <= 0 {
+		t.Fatalf("defaults not applied: %+v", got)
+	}
+	if !got.SummarizeEnabled {
+		t.Fatalf("SummarizeEnabled lost")
+	}
+}
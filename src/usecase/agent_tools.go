@@ -0,0 +1,414 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/calendar"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/knowledge"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+	serp "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/serp"
+	"github.com/sirupsen/logrus"
+)
+
+// maxToolResultLength caps the text fed back to the model (and stored in the audit log) per call
+const maxToolResultLength = 4000
+
+// toolContext is the conversation a tool call runs in
+type toolContext struct {
+	agent    *agent.Agent
+	conv     *agent.Conversation
+	settings settings.ToolSettings
+}
+
+// agentTool is a built-in capability exposed to the model
+type agentTool struct {
+	definition func(ctx context.Context, tc *toolContext) (aiService.ToolDefinition, bool) // false = unavailable for this agent
+	run        func(ctx context.Context, tc *toolContext, args json.RawMessage) (string, error)
+}
+
+// toolRegistry returns the built-in tools keyed by name
+func (s *AgentService) toolRegistry() map[string]agentTool {
+	return map[string]agentTool{
+		agent.ToolKnowledgeSearch:      {definition: s.knowledgeSearchDefinition, run: s.runKnowledgeSearch},
+		agent.ToolCalendarAvailability: {definition: s.calendarAvailabilityDefinition, run: s.runCalendarAvailability},
+		agent.ToolCalendarBooking:      {definition: s.calendarBookingDefinition, run: s.runCalendarBooking},
+		agent.ToolRunFlow:              {definition: s.runFlowDefinition, run: s.runFlow},
+		agent.ToolWebSearch:            {definition: webSearchDefinition, run: runWebSearch},
+		agent.ToolHandoff:              {definition: handoffDefinition, run: s.runHandoff},
+	}
+}
+
+// enabledTools returns the definitions of the tools the agent enabled and can actually use
+func (s *AgentService) enabledTools(ctx context.Context, tc *toolContext) []aiService.ToolDefinition {
+	registry := s.toolRegistry()
+	var definitions []aiService.ToolDefinition
+	for _, name := range tc.settings.Enabled {
+		tool, ok := registry[name]
+		if !ok {
+			logrus.Warnf("⚠️  [AgentService] Unknown tool %q enabled for agent %s", name, tc.agent.ID)
+			continue
+		}
+		if def, available := tool.definition(ctx, tc); available {
+			definitions = append(definitions, def)
+		}
+	}
+	return definitions
+}
+
+// toolHandler runs tool calls requested by the model and records each one on the conversation
+func (s *AgentService) toolHandler(tc *toolContext) aiService.ToolHandler {
+	registry := s.toolRegistry()
+	return func(ctx context.Context, call aiService.ToolCall) string {
+		started := time.Now()
+		invocation := &agent.ToolInvocation{
+			ConversationID: tc.conv.ID,
+			Tool:           call.Name,
+			Arguments:      call.Arguments,
+		}
+
+		var result string
+		var err error
+		tool, ok := registry[call.Name]
+		if !ok || !containsString(tc.settings.Enabled, call.Name) {
+			err = fmt.Errorf("tool %s is not enabled", call.Name)
+		} else {
+			args := json.RawMessage(call.Arguments)
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			result, err = tool.run(ctx, tc, args)
+		}
+		if err != nil {
+			invocation.Error = err.Error()
+			result = "Error: " + err.Error()
+			logrus.Warnf("⚠️  [AgentService] Tool %s failed for conv %s: %v", call.Name, tc.conv.ID, err)
+		} else {
+			logrus.Infof("🛠️ [AgentService] Tool %s completed for conv %s", call.Name, tc.conv.ID)
+		}
+
+		if len(result) > maxToolResultLength {
+			result = result[:maxToolResultLength] + "…"
+		}
+		invocation.Result = result
+		invocation.DurationMs = time.Since(started).Milliseconds()
+		if err := s.repo.AddToolInvocation(ctx, invocation); err != nil {
+			logrus.Errorf("❌ [AgentService] Failed to log tool invocation: %v", err)
+		}
+		return result
+	}
+}
+
+// GetToolInvocations returns the tool audit log of a conversation
+func (s *AgentService) GetToolInvocations(ctx context.Context, conversationID string, limit int) ([]*agent.ToolInvocation, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return s.repo.GetToolInvocations(ctx, conversationID, limit)
+}
+
+// === Knowledge search ===
+
+func (s *AgentService) knowledgeSearchDefinition(ctx context.Context, tc *toolContext) (aiService.ToolDefinition, bool) {
+	return aiService.ToolDefinition{
+		Name:        agent.ToolKnowledgeSearch,
+		Description: "Search the company knowledge base (documents, FAQs, policies) for information relevant to the customer's question.",
+		Parameters: objectSchema(map[string]interface{}{
+			"query": stringProperty("What to search for"),
+			"top_k": map[string]interface{}{"type": "integer", "description": "Number of passages to return (default 3)"},
+		}, "query"),
+	}, s.knowledgeService != nil && aiService.SupportsEmbeddings(tc.agent.Provider)
+}
+
+func (s *AgentService) runKnowledgeSearch(ctx context.Context, tc *toolContext, raw json.RawMessage) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		TopK  int    `json:"top_k"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil || args.Query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	if args.TopK <= 0 || args.TopK > 10 {
+		args.TopK = 3
+	}
+
+	results, err := s.knowledgeService.Search(ctx, knowledge.SearchRequest{AgentID: tc.agent.ID, Query: args.Query, TopK: args.TopK})
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "No matching information found in the knowledge base.", nil
+	}
+
+	var sb strings.Builder
+	for i, r := range results {
+		sb.WriteString(fmt.Sprintf("[%d] %s (score %.2f)\n%s\n\n", i+1, r.DocName, r.Score, r.Content))
+	}
+	return sb.String(), nil
+}
+
+// === Calendar ===
+
+func (s *AgentService) calendarAvailabilityDefinition(ctx context.Context, tc *toolContext) (aiService.ToolDefinition, bool) {
+	return aiService.ToolDefinition{
+		Name:        agent.ToolCalendarAvailability,
+		Description: "List free appointment slots in the business calendar between two times.",
+		Parameters: objectSchema(map[string]interface{}{
+			"start":            stringProperty("Start of the search window, RFC3339 (e.g. 2025-01-31T09:00:00+03:00)"),
+			"end":              stringProperty("End of the search window, RFC3339"),
+			"duration_minutes": map[string]interface{}{"type": "integer", "description": "Appointment length in minutes"},
+		}, "start", "end"),
+	}, s.calendarService != nil
+}
+
+func (s *AgentService) runCalendarAvailability(ctx context.Context, tc *toolContext, raw json.RawMessage) (string, error) {
+	var args struct {
+		Start    string `json:"start"`
+		End      string `json:"end"`
+		Duration int    `json:"duration_minutes"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	start, err := time.Parse(time.RFC3339, args.Start)
+	if err != nil {
+		return "", fmt.Errorf("start must be RFC3339: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, args.End)
+	if err != nil {
+		return "", fmt.Errorf("end must be RFC3339: %w", err)
+	}
+	if args.Duration <= 0 {
+		args.Duration = tc.settings.BookingDuration
+	}
+
+	slots, err := s.calendarService.GetAvailableSlots(ctx, calendar.AvailabilityRequest{
+		AgentID:   tc.agent.ID,
+		StartTime: start,
+		EndTime:   end,
+		Duration:  args.Duration,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(slots) == 0 {
+		return "No free slots in that window.", nil
+	}
+
+	const maxSlots = 10
+	var sb strings.Builder
+	sb.WriteString("Free slots:\n")
+	for i, slot := range slots {
+		if i == maxSlots {
+			sb.WriteString(fmt.Sprintf("…and %d more\n", len(slots)-maxSlots))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("- %s to %s\n", slot.StartTime.In(start.Location()).Format(time.RFC3339), slot.EndTime.In(start.Location()).Format(time.RFC3339)))
+	}
+	return sb.String(), nil
+}
+
+func (s *AgentService) calendarBookingDefinition(ctx context.Context, tc *toolContext) (aiService.ToolDefinition, bool) {
+	return aiService.ToolDefinition{
+		Name:        agent.ToolCalendarBooking,
+		Description: "Book an appointment in the business calendar. Check availability first and confirm the time with the customer.",
+		Parameters: objectSchema(map[string]interface{}{
+			"title":            stringProperty("Short appointment title"),
+			"start":            stringProperty("Start time, RFC3339"),
+			"duration_minutes": map[string]interface{}{"type": "integer", "description": "Appointment length in minutes"},
+			"attendee_email":   stringProperty("Customer email to invite (optional)"),
+			"notes":            stringProperty("Details from the conversation (optional)"),
+		}, "title", "start"),
+	}, s.calendarService != nil
+}
+
+func (s *AgentService) runCalendarBooking(ctx context.Context, tc *toolContext, raw json.RawMessage) (string, error) {
+	var args struct {
+		Title    string `json:"title"`
+		Start    string `json:"start"`
+		Duration int    `json:"duration_minutes"`
+		Email    string `json:"attendee_email"`
+		Notes    string `json:"notes"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil || args.Title == "" {
+		return "", fmt.Errorf("title and start are required")
+	}
+	start, err := time.Parse(time.RFC3339, args.Start)
+	if err != nil {
+		return "", fmt.Errorf("start must be RFC3339: %w", err)
+	}
+	if args.Duration <= 0 {
+		args.Duration = tc.settings.BookingDuration
+	}
+
+	req := calendar.CreateEventRequest{
+		AgentID:     tc.agent.ID,
+		Title:       args.Title,
+		Description: strings.TrimSpace(fmt.Sprintf("%s\n\nBooked by %s for %s", args.Notes, tc.agent.Name, tc.conv.RemoteJID)),
+		StartTime:   start,
+		EndTime:     start.Add(time.Duration(args.Duration) * time.Minute),
+	}
+	if args.Email != "" {
+		req.Attendees = []string{args.Email}
+	}
+
+	event, err := s.calendarService.CreateEvent(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Booked %q from %s to %s (event ID %s).", event.Title, event.StartTime.Format(time.RFC3339), event.EndTime.Format(time.RFC3339), event.ID), nil
+}
+
+// === Flows ===
+
+func (s *AgentService) runFlowDefinition(ctx context.Context, tc *toolContext) (aiService.ToolDefinition, bool) {
+	if s.flowService == nil || len(tc.settings.FlowIDs) == 0 {
+		return aiService.ToolDefinition{}, false
+	}
+
+	var flowIDs []interface{}
+	var sb strings.Builder
+	sb.WriteString("Run one of the business automations and get its output. Available flows:")
+	for _, id := range tc.settings.FlowIDs {
+		f, err := s.flowService.repo.GetFlowByID(ctx, id)
+		if err != nil || !f.IsActive || f.AgentID != tc.agent.ID {
+			continue
+		}
+		flowIDs = append(flowIDs, f.ID)
+		sb.WriteString(fmt.Sprintf("\n- %s: %s", f.ID, f.Name))
+		if f.Description != "" {
+			sb.WriteString(" — " + f.Description)
+		}
+	}
+	if len(flowIDs) == 0 {
+		return aiService.ToolDefinition{}, false
+	}
+
+	return aiService.ToolDefinition{
+		Name:        agent.ToolRunFlow,
+		Description: sb.String(),
+		Parameters: objectSchema(map[string]interface{}{
+			"flow_id": map[string]interface{}{"type": "string", "enum": flowIDs, "description": "ID of the flow to run"},
+			"input":   map[string]interface{}{"type": "object", "description": "Input variables for the flow"},
+		}, "flow_id"),
+	}, true
+}
+
+func (s *AgentService) runFlow(ctx context.Context, tc *toolContext, raw json.RawMessage) (string, error) {
+	var args struct {
+		FlowID string                 `json:"flow_id"`
+		Input  map[string]interface{} `json:"input"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil || args.FlowID == "" {
+		return "", fmt.Errorf("flow_id is required")
+	}
+	if !containsString(tc.settings.FlowIDs, args.FlowID) {
+		return "", fmt.Errorf("flow %s is not allowed for this agent", args.FlowID)
+	}
+
+	output, err := s.flowService.ExecuteFlow(ctx, args.FlowID, flowToolInput(tc, args.Input))
+	if err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode flow output: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// flowToolInput merges the model's input with the conversation identifiers. The identifiers are
+// set last so a prompt-injected tool call can't point the flow at another contact or conversation.
+func flowToolInput(tc *toolContext, modelInput map[string]interface{}) map[string]interface{} {
+	input := make(map[string]interface{}, len(modelInput)+3)
+	for k, v := range modelInput {
+		input[k] = v
+	}
+	input["agent_id"] = tc.agent.ID
+	input["conversation_id"] = tc.conv.ID
+	input["remote_jid"] = tc.conv.RemoteJID
+	return input
+}
+
+// === Web search ===
+
+func webSearchDefinition(ctx context.Context, tc *toolContext) (aiService.ToolDefinition, bool) {
+	return aiService.ToolDefinition{
+		Name:        agent.ToolWebSearch,
+		Description: "Search the internet for current information such as news, prices, weather or exchange rates.",
+		Parameters: objectSchema(map[string]interface{}{
+			"query": stringProperty("Search query"),
+		}, "query"),
+	}, tc.agent.SerpAPIKey != ""
+}
+
+func runWebSearch(ctx context.Context, tc *toolContext, raw json.RawMessage) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil || args.Query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	svc := serp.NewService(tc.agent.SerpAPIKey)
+	if svc == nil {
+		return "", fmt.Errorf("web search is not configured")
+	}
+	return svc.Search(args.Query)
+}
+
+// === Handoff ===
+
+func handoffDefinition(ctx context.Context, tc *toolContext) (aiService.ToolDefinition, bool) {
+	return aiService.ToolDefinition{
+		Name:        agent.ToolHandoff,
+		Description: "Hand the conversation over to a human team member when the customer asks for a person or you cannot help. The AI stops replying afterwards.",
+		Parameters: objectSchema(map[string]interface{}{
+			"reason": stringProperty("Why a human is needed"),
+		}, "reason"),
+	}, true
+}
+
+func (s *AgentService) runHandoff(ctx context.Context, tc *toolContext, raw json.RawMessage) (string, error) {
+	var args struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(raw, &args)
+
+	if err := s.repo.SetConversationManualMode(ctx, tc.conv.ID, true); err != nil {
+		return "", fmt.Errorf("failed to switch to manual mode: %w", err)
+	}
+	tc.conv.IsManualMode = true
+	logrus.Infof("🙋 [AgentService] Conversation %s handed off to a human: %s", tc.conv.ID, args.Reason)
+	return "The conversation is now assigned to a human team member. Tell the customer briefly that someone will reply soon.", nil
+}
+
+// === Helpers ===
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+)
+
+func TestFlowToolInputKeepsConversationIdentifiers(t *testing.T) {
+	tc := &toolContext{
+		agent: &agent.Agent{ID: "agent-1"},
+		conv:  &agent.Conversation{ID: "conv-1", RemoteJID: "628111@s.whatsapp.net"},
+	}
+
+	got := flowToolInput(tc, map[string]interface{}{
+		"remote_jid":      "628999@s.whatsapp.net",
+		"conversation_id": "conv-other",
+		"agent_id":        "agent-other",
+		"order_id":        "A-42",
+	})
+
+	if got["remote_jid"] != "628111@s.whatsapp.net" {
+		t.Fatalf("remote_jid = %v, want the conversation's contact", got["remote_jid"])
+	}
+	if got["conversation_id"] != "conv-1" || got["agent_id"] != "agent-1" {
+		t.Fatalf("identifiers = %v / %v, want conv-1 / agent-1", got["conversation_id"], got["agent_id"])
+	}
+	if got["order_id"] != "A-42" {
+		t.Fatalf("order_id = %v, want the model's value", got["order_id"])
+	}
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/translation"
+	"github.com/sirupsen/logrus"
+)
+
+// SetTranslationCache enables caching of translated messages (called after initialization)
+func (s *AgentService) SetTranslationCache(cache *translation.Cache) {
+	s.translationCache = cache
+}
+
+// translationSettingsOrDefault returns the agent's translation settings with defaults for unset values
+func translationSettingsOrDefault(agentSettings *settings.AgentSettings) settings.TranslationSettings {
+	defaults := settings.DefaultAgentSettings("").Translation
+	if agentSettings == nil {
+		return defaults
+	}
+
+	cfg := agentSettings.Translation
+	if cfg.SourceLanguage == "" {
+		cfg.SourceLanguage = defaults.SourceLanguage
+	}
+	if cfg.MinConfidence <= 0 {
+		cfg.MinConfidence = defaults.MinConfidence
+	}
+	return cfg
+}
+
+// normalizeLanguage validates an ISO 639-1 code and returns it in lower case
+func normalizeLanguage(language string) (string, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if len(language) != 2 || language[0] < 'a' || language[0] > 'z' || language[1] < 'a' || language[1] > 'z' {
+		return "", fmt.Errorf("invalid language code %q, expected ISO 639-1 (e.g. en, es)", language)
+	}
+	return language, nil
+}
+
+// customerLanguage resolves the language the customer writes in. A language set from the live chat
+// always wins; otherwise the integration's fixed language is used when auto-detect is off. With
+// auto-detect the stored detection is reused once it is confident enough, so most messages need
+// no detection call at all. Returns "" when the language is unknown.
+func (s *AgentService) customerLanguage(ctx context.Context, aiSvc *aiService.Service, conv *agent.Conversation,
+	integration *agent.Integration, cfg settings.TranslationSettings, text string) string {
+	if conv.LanguageManual && conv.Language != "" {
+		return conv.Language
+	}
+	if !cfg.AutoDetect {
+		return integration.CustomerLanguage
+	}
+	if conv.Language != "" && conv.LanguageConfidence >= cfg.MinConfidence {
+		return conv.Language
+	}
+	if strings.TrimSpace(text) == "" {
+		return conv.Language
+	}
+
+	language, confidence, err := aiSvc.DetectLanguageWithConfidence(ctx, text)
+	if err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to detect language: %v", err)
+		return conv.Language
+	}
+	if language == "" {
+		return conv.Language
+	}
+	logrus.Infof("🌐 [AgentService] Detected language: %s (confidence %.2f)", language, confidence)
+
+	// Keep the most confident detection so a short "ok" does not flip the conversation language
+	if conv.Language == "" || confidence >= conv.LanguageConfidence {
+		if err := s.repo.UpdateConversationLanguage(ctx, conv.ID, language, confidence, false); err != nil {
+			logrus.Warnf("⚠️  [AgentService] Failed to store conversation language: %v", err)
+		}
+		conv.Language, conv.LanguageConfidence = language, confidence
+	}
+	return conv.Language
+}
+
+// translate translates text, reusing cached translations of the same text and language pair
+func (s *AgentService) translate(ctx context.Context, aiSvc *aiService.Service, text, sourceLang, targetLang string) (string, error) {
+	if s.translationCache != nil {
+		if translated, ok := s.translationCache.Get(ctx, text, sourceLang, targetLang); ok {
+			logrus.Debugf("🗃️  [AgentService] Translation cache hit (%s → %s)", sourceLang, targetLang)
+			return translated, nil
+		}
+	}
+
+	translated, err := aiSvc.TranslateText(ctx, text, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+	if s.translationCache != nil {
+		if err := s.translationCache.Put(ctx, text, sourceLang, targetLang, translated); err != nil {
+			logrus.Warnf("⚠️  [AgentService] Failed to cache translation: %v", err)
+		}
+	}
+	return translated, nil
+}
+
+// SetConversationLanguage overrides the customer language of a conversation from the live chat.
+// An empty language removes the override and lets auto-detection take over again.
+func (s *AgentService) SetConversationLanguage(ctx context.Context, conversationID, language string) error {
+	if language == "" {
+		return s.repo.UpdateConversationLanguage(ctx, conversationID, "", 0, false)
+	}
+	language, err := normalizeLanguage(language)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpdateConversationLanguage(ctx, conversationID, language, 1, true)
+}
+
+// SetIntegrationLanguage sets the fixed customer language used when translation auto-detect is off
+func (s *AgentService) SetIntegrationLanguage(ctx context.Context, integrationID, language string) error {
+	if language != "" {
+		var err error
+		if language, err = normalizeLanguage(language); err != nil {
+			return err
+		}
+	}
+	integration, err := s.repo.GetIntegrationByID(ctx, integrationID)
+	if err != nil {
+		return err
+	}
+	integration.CustomerLanguage = language
+	return s.repo.UpdateIntegration(ctx, integration)
+}
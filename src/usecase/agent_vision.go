@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// visionSettingsOrDefault returns the agent's vision settings with defaults for unset values
+func visionSettingsOrDefault(agentSettings *settings.AgentSettings) settings.VisionSettings {
+	defaults := settings.DefaultAgentSettings("").Vision
+	if agentSettings == nil {
+		return defaults
+	}
+	v := agentSettings.Vision
+	if v.MaxImages <= 0 {
+		v.MaxImages = defaults.MaxImages
+	}
+	if v.MaxDocumentPages <= 0 {
+		v.MaxDocumentPages = defaults.MaxDocumentPages
+	}
+	if v.MaxDocumentTokens <= 0 {
+		v.MaxDocumentTokens = defaults.MaxDocumentTokens
+	}
+	return v
+}
+
+// joinMessageParts joins the non-empty parts of a message with blank lines
+func joinMessageParts(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
+
+// attachmentLabel is the placeholder stored in the conversation for an attachment
+func attachmentLabel(att agent.Attachment, note string) string {
+	label := "Image"
+	if att.Type == agent.AttachmentTypeDocument {
+		label = "Document"
+	}
+	if att.FileName != "" {
+		label += ": " + att.FileName
+	}
+	if note != "" {
+		label += " (" + note + ")"
+	}
+	return "[" + label + "]"
+}
+
+// describeAttachments returns placeholders for attachments the model does not get to see
+func describeAttachments(attachments []agent.Attachment) string {
+	labels := make([]string, 0, len(attachments))
+	for _, att := range attachments {
+		labels = append(labels, attachmentLabel(att, ""))
+	}
+	return strings.Join(labels, "\n")
+}
+
+// attachmentMIMEType returns the declared MIME type without parameters, sniffing the content when unknown
+func attachmentMIMEType(att agent.Attachment) string {
+	mimeType := att.MIMEType
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(att.Data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// prepareAttachments converts attachments for the model. Images within the size limit are passed
+// as image parts; PDF and text documents are converted to text appended to the message.
+// Attachments without data (too large or failed to download) are only mentioned.
+func prepareAttachments(cfg settings.VisionSettings, attachments []agent.Attachment) (string, []aiService.Image) {
+	var parts []string
+	var images []aiService.Image
+	for _, att := range attachments {
+		mimeType := attachmentMIMEType(att)
+
+		switch {
+		case len(att.Data) == 0:
+			parts = append(parts, attachmentLabel(att, "not available"))
+
+		case strings.HasPrefix(mimeType, "image/"):
+			switch {
+			case !aiService.IsSupportedImageType(mimeType):
+				parts = append(parts, attachmentLabel(att, "unsupported format"))
+			case int64(len(att.Data)) > config.WhatsappSettingMaxImageSize:
+				parts = append(parts, attachmentLabel(att, "too large to view"))
+			case len(images) >= cfg.MaxImages:
+				parts = append(parts, attachmentLabel(att, "not viewed, too many images"))
+			default:
+				images = append(images, aiService.Image{MIMEType: mimeType, Data: att.Data})
+				parts = append(parts, attachmentLabel(att, ""))
+			}
+
+		case mimeType == "application/pdf" || strings.EqualFold(filepath.Ext(att.FileName), ".pdf"):
+			parts = append(parts, pdfAttachmentText(cfg, att))
+
+		case strings.HasPrefix(mimeType, "text/") && utf8.Valid(att.Data):
+			text := truncateToTokens(strings.TrimSpace(string(att.Data)), cfg.MaxDocumentTokens)
+			parts = append(parts, attachmentLabel(att, "")+"\n"+text)
+
+		default:
+			parts = append(parts, attachmentLabel(att, "unsupported format"))
+		}
+	}
+	return strings.Join(parts, "\n\n"), images
+}
+
+// pdfAttachmentText extracts the text of a PDF, page by page
+func pdfAttachmentText(cfg settings.VisionSettings, att agent.Attachment) string {
+	if int64(len(att.Data)) > config.WhatsappSettingMaxFileSize {
+		return attachmentLabel(att, "too large to read")
+	}
+	pages, err := utils.ExtractPDFText(att.Data, cfg.MaxDocumentPages)
+	if err != nil {
+		logrus.Warnf("⚠️  [AgentService] Failed to read PDF %q: %v", att.FileName, err)
+		return attachmentLabel(att, "could not be read")
+	}
+
+	var sb strings.Builder
+	for i, page := range pages {
+		if page == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "--- Page %d ---\n%s\n", i+1, page)
+	}
+	if sb.Len() == 0 {
+		return attachmentLabel(att, "no text found, it may be a scanned document")
+	}
+	return attachmentLabel(att, "") + "\n" + truncateToTokens(strings.TrimSpace(sb.String()), cfg.MaxDocumentTokens)
+}
+
+// truncateToTokens shortens text to about maxTokens tokens
+func truncateToTokens(text string, maxTokens int) string {
+	if aiService.EstimateTokens(text) <= maxTokens {
+		return text
+	}
+	runes := []rune(text)
+	if limit := maxTokens * 4; limit < len(runes) {
+		runes = runes[:limit]
+	}
+	return string(runes) + "\n[... truncated]"
+}
@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+)
+
+func TestPrepareAttachments(t *testing.T) {
+	cfg := visionSettingsOrDefault(&settings.AgentSettings{Vision: settings.VisionSettings{Enabled: true, MaxImages: 1}})
+	png := []byte("\x89PNG\r\n\x1a\n rest of the image")
+	attachments := []agent.Attachment{
+		{Type: agent.AttachmentTypeImage, MIMEType: "image/jpeg; charset=binary", Data: []byte("jpeg")},
+		{Type: agent.AttachmentTypeImage, Data: png}, // Over MaxImages
+		{Type: agent.AttachmentTypeImage, MIMEType: "image/tiff", Data: []byte("tiff")},
+		{Type: agent.AttachmentTypeDocument, MIMEType: "text/plain", FileName: "notes.txt", Data: []byte("size 42, blue")},
+		{Type: agent.AttachmentTypeDocument, MIMEType: "application/pdf", FileName: "broken.pdf", Data: []byte("not a pdf")},
+		{Type: agent.AttachmentTypeImage, FileName: "big.jpg"}, // Not downloaded
+	}
+
+	text, images := prepareAttachments(cfg, attachments)
+	if len(images) != 1 || images[0].MIMEType != "image/jpeg" {
+		t.Fatalf("images = %+v, want the first JPEG only", images)
+	}
+	for _, want := range []string{
+		"[Image]",
+		"[Image (not viewed, too many images)]",
+		"[Image (unsupported format)]",
+		"[Document: notes.txt]\nsize 42, blue",
+		"[Document: broken.pdf (could not be read)]",
+		"[Image: big.jpg (not available)]",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("attachment text missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	if got := truncateToTokens("short", 10); got != "short" {
+		t.Fatalf("truncateToTokens() = %q", got)
+	}
+	got := truncateToTokens(strings.Repeat("a", 100), 5)
+	if !strings.HasPrefix(got, strings.Repeat("a", 20)+"\n") || !strings.HasSuffix(got, "[... truncated]") {
+		t.Fatalf("truncateToTokens() = %q", got)
+	}
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	fiberUtils "github.com/gofiber/fiber/v2/utils"
+)
+
+// voiceFFMpeg runs ffmpeg for voice replies; tests replace it
+var voiceFFMpeg = runFFMpeg
+
+// errVoiceReplyTooLong is returned for replies over VoiceReplySettings.MaxChars; they are sent as text
+var errVoiceReplyTooLong = errors.New("reply is too long to send as voice")
+
+// SetSendUsecase enables WhatsApp voice replies (called after initialization)
+func (s *AgentService) SetSendUsecase(sendUsecase domainSend.ISendUsecase) {
+	s.sendUsecase = sendUsecase
+}
+
+// voiceReplySettingsOrDefault returns the agent's voice reply settings with defaults for unset values
+func voiceReplySettingsOrDefault(agentSettings *settings.AgentSettings) settings.VoiceReplySettings {
+	defaults := settings.DefaultAgentSettings("").VoiceReply
+	if agentSettings == nil {
+		return defaults
+	}
+	v := agentSettings.VoiceReply
+	if v.Voice == "" {
+		v.Voice = defaults.Voice
+	}
+	if v.Model == "" {
+		v.Model = defaults.Model
+	}
+	return v
+}
+
+// VoiceRepliesEnabled reports whether the agent answers voice notes with a voice note
+func (s *AgentService) VoiceRepliesEnabled(ctx context.Context, agentID string) bool {
+	if s.settingsService == nil {
+		return false
+	}
+	agentSettings, err := s.settingsService.GetAgentSettings(ctx, agentID)
+	if err != nil {
+		return false
+	}
+	return agentSettings.VoiceReply.Enabled
+}
+
+// SynthesizeVoiceReply speaks text with the agent's text-to-speech settings and returns
+// OGG/Opus audio, the format WhatsApp and Telegram play as voice notes
+func (s *AgentService) SynthesizeVoiceReply(ctx context.Context, agentID, text string) ([]byte, error) {
+	var agentSettings *settings.AgentSettings
+	if s.settingsService != nil {
+		agentSettings, _ = s.settingsService.GetAgentSettings(ctx, agentID)
+	}
+	cfg := voiceReplySettingsOrDefault(agentSettings)
+	if cfg.MaxChars > 0 && utf8.RuneCountInString(text) > cfg.MaxChars {
+		return nil, errVoiceReplyTooLong
+	}
+
+	ag, err := s.repo.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("agent not found: %w", err)
+	}
+	aiSvc := aiService.NewServiceForAgent(ag)
+	if aiSvc == nil {
+		return nil, fmt.Errorf("AI service not configured for agent %s", agentID)
+	}
+
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{AgentID: agentID})
+	speech, err := aiSvc.SynthesizeSpeech(ctx, text, cfg.Voice, cfg.Model)
+	if err != nil {
+		return nil, err
+	}
+	return convertToOpus(speech.Audio, speech.Format)
+}
+
+// convertToOpus re-encodes audio as mono OGG/Opus with ffmpeg
+func convertToOpus(audio []byte, format string) ([]byte, error) {
+	base := filepath.Join(config.PathSendItems, "voice_reply_"+fiberUtils.UUIDv4())
+	inputPath, outputPath := base+"."+format, base+".ogg"
+	if err := os.WriteFile(inputPath, audio, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write speech audio: %w", err)
+	}
+	defer os.Remove(inputPath)
+	defer os.Remove(outputPath)
+
+	if _, err := voiceFFMpeg(
+		"-y",
+		"-i", inputPath,
+		"-c:a", "libopus",
+		"-b:a", "32k",
+		"-ac", "1",
+		"-ar", "48000",
+		"-application", "voip",
+		outputPath,
+	); err != nil {
+		return nil, fmt.Errorf("failed to convert speech to opus: %w", err)
+	}
+	return os.ReadFile(outputPath)
+}
+
+// SendWhatsAppVoiceReply speaks text and sends it to phone as a WhatsApp voice note.
+// The WhatsApp client is taken from ctx, as for any other send.
+func (s *AgentService) SendWhatsAppVoiceReply(ctx context.Context, agentID, phone, text string) error {
+	if s.sendUsecase == nil {
+		return fmt.Errorf("send usecase not configured")
+	}
+	audio, err := s.SynthesizeVoiceReply(ctx, agentID, text)
+	if err != nil {
+		return err
+	}
+	fileHeader, err := utils.NewFileHeader("audio", "voice-reply.ogg", "audio/ogg", audio)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.sendUsecase.SendAudio(ctx, domainSend.AudioRequest{
+		BaseRequest: domainSend.BaseRequest{Phone: phone},
+		Audio:       fileHeader,
+		PTT:         true,
+	})
+	return err
+}
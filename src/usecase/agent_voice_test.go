@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	agentRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/agent"
+	settingsRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/settings"
+)
+
+func TestVoiceReplySettingsOrDefault(t *testing.T) {
+	got := voiceReplySettingsOrDefault(&settings.AgentSettings{VoiceReply: settings.VoiceReplySettings{Enabled: true, Voice: "nova"}})
+	if !got.Enabled || got.Voice != "nova" || got.Model != "tts-1" || got.MaxChars != 0 {
+		t.Fatalf("voiceReplySettingsOrDefault() = %+v", got)
+	}
+	if got := voiceReplySettingsOrDefault(nil); got.Enabled || got.Voice != "alloy" {
+		t.Fatalf("voiceReplySettingsOrDefault(nil) = %+v", got)
+	}
+}
+
+// audioSender records the voice notes sent through the send usecase
+type audioSender struct {
+	domainSend.ISendUsecase
+	requests []domainSend.AudioRequest
+}
+
+func (s *audioSender) SendAudio(ctx context.Context, request domainSend.AudioRequest) (domainSend.GenericResponse, error) {
+	s.requests = append(s.requests, request)
+	return domainSend.GenericResponse{}, nil
+}
+
+// newVoiceTestService returns an agent service whose agent speaks through a stub TTS server,
+// with voice replies limited to maxChars characters
+func newVoiceTestService(t *testing.T, maxChars int) (*AgentService, *audioSender, string, *int) {
+	t.Helper()
+	ctx := context.Background()
+
+	speechCalls := 0
+	tts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		speechCalls++
+		if r.URL.Path != "/v1/audio/speech" {
+			t.Errorf("TTS request path = %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3speech"))
+	}))
+	t.Cleanup(tts.Close)
+
+	repo, err := agentRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "agents.db"))
+	if err != nil {
+		t.Fatalf("agent NewSQLiteRepository() error = %v", err)
+	}
+	a := &agent.Agent{Name: "voice", Provider: "openai", BaseURL: tts.URL + "/v1", APIKey: "key", Model: "m", IsActive: true}
+	if err := repo.Create(ctx, a); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	sRepo, err := settingsRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "settings.db"))
+	if err != nil {
+		t.Fatalf("settings NewSQLiteRepository() error = %v", err)
+	}
+	settingsService := NewSettingsService(sRepo)
+	agentSettings := settings.DefaultAgentSettings(a.ID)
+	agentSettings.VoiceReply = settings.VoiceReplySettings{Enabled: true, MaxChars: maxChars}
+	if err := settingsService.UpdateAgentSettings(ctx, agentSettings); err != nil {
+		t.Fatalf("UpdateAgentSettings() error = %v", err)
+	}
+
+	sender := &audioSender{}
+	service := NewAgentService(repo)
+	service.SetSettingsService(settingsService)
+	service.SetSendUsecase(sender)
+	return service, sender, a.ID, &speechCalls
+}
+
+// fakeFFMpeg replaces ffmpeg for the test; it writes "OggS" + the input audio to the output file,
+// or fails with err
+func fakeFFMpeg(t *testing.T, err error) {
+	t.Helper()
+	previousRunner, previousPath := voiceFFMpeg, config.PathSendItems
+	t.Cleanup(func() { voiceFFMpeg, config.PathSendItems = previousRunner, previousPath })
+
+	config.PathSendItems = t.TempDir()
+	voiceFFMpeg = func(args ...string) ([]byte, error) {
+		if err != nil {
+			return nil, err
+		}
+		var input string
+		for i, arg := range args[:len(args)-1] {
+			if arg == "-i" {
+				input = args[i+1]
+			}
+		}
+		audio, readErr := os.ReadFile(input)
+		if readErr != nil {
+			return nil, readErr
+		}
+		return nil, os.WriteFile(args[len(args)-1], append([]byte("OggS"), audio...), 0644)
+	}
+}
+
+func TestSendWhatsAppVoiceReply(t *testing.T) {
+	service, sender, agentID, _ := newVoiceTestService(t, 0)
+	fakeFFMpeg(t, nil)
+
+	if err := service.SendWhatsAppVoiceReply(context.Background(), agentID, "628111@s.whatsapp.net", "We open at 9."); err != nil {
+		t.Fatalf("SendWhatsAppVoiceReply() error = %v", err)
+	}
+	if len(sender.requests) != 1 {
+		t.Fatalf("SendAudio called %d times, want 1", len(sender.requests))
+	}
+	req := sender.requests[0]
+	if req.Phone != "628111@s.whatsapp.net" || !req.PTT || req.Audio == nil {
+		t.Fatalf("SendAudio request = %+v", req)
+	}
+	if req.Audio.Header.Get("Content-Type") != "audio/ogg" || !strings.HasSuffix(req.Audio.Filename, ".ogg") {
+		t.Fatalf("audio file = %s (%s), want an audio/ogg file", req.Audio.Filename, req.Audio.Header.Get("Content-Type"))
+	}
+	file, err := req.Audio.Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer file.Close()
+	if audio, _ := io.ReadAll(file); string(audio) != "OggSID3speech" {
+		t.Fatalf("audio = %q, want the converted speech", audio)
+	}
+}
+
+func TestSynthesizeVoiceReplyTooLong(t *testing.T) {
+	service, sender, agentID, speechCalls := newVoiceTestService(t, 10)
+	fakeFFMpeg(t, nil)
+
+	err := service.SendWhatsAppVoiceReply(context.Background(), agentID, "628111@s.whatsapp.net", "This reply is longer than ten characters.")
+	if !errors.Is(err, errVoiceReplyTooLong) {
+		t.Fatalf("SendWhatsAppVoiceReply() error = %v, want errVoiceReplyTooLong", err)
+	}
+	if *speechCalls != 0 || len(sender.requests) != 0 {
+		t.Fatalf("long reply was synthesized (%d calls) or sent (%d)", *speechCalls, len(sender.requests))
+	}
+}
+
+func TestSynthesizeVoiceReplyConversionError(t *testing.T) {
+	service, sender, agentID, _ := newVoiceTestService(t, 0)
+	fakeFFMpeg(t, errors.New("ffmpeg not found"))
+
+	_, err := service.SynthesizeVoiceReply(context.Background(), agentID, "We open at 9.")
+	if err == nil || !strings.Contains(err.Error(), "failed to convert speech to opus") {
+		t.Fatalf("SynthesizeVoiceReply() error = %v, want a conversion error", err)
+	}
+	// The caller sends the reply as text when the voice note fails
+	if err := service.SendWhatsAppVoiceReply(context.Background(), agentID, "628111@s.whatsapp.net", "We open at 9."); err == nil {
+		t.Fatalf("SendWhatsAppVoiceReply() succeeded without a converted voice note")
+	}
+	if len(sender.requests) != 0 {
+		t.Fatalf("SendAudio called %d times after a conversion error", len(sender.requests))
+	}
+}
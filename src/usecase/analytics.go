@@ -33,3 +33,8 @@ func (s *AnalyticsService) GetRecentActivity(ctx context.Context, limit int) ([]
 func (s *AnalyticsService) GetAgentStats(ctx context.Context, period string) ([]analytics.AgentStats, error) {
 	return s.repo.GetAgentStats(ctx, period)
 }
+
+// GetSentimentStats returns the sentiment breakdown of incoming messages per agent
+func (s *AnalyticsService) GetSentimentStats(ctx context.Context, period string) ([]analytics.SentimentStats, error) {
+	return s.repo.GetSentimentStats(ctx, period)
+}
@@ -0,0 +1,445 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/calendar"
+)
+
+const (
+	googleCalendarAPIURL = "https://www.googleapis.com/calendar/v3"
+	googleOAuthAuthURL   = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleOAuthTokenURL  = "https://oauth2.googleapis.com/token"
+	googleCalendarScope  = "https://www.googleapis.com/auth/calendar"
+
+	// oauthStateTTL is how long a consent URL can be completed after it was generated
+	oauthStateTTL = 10 * time.Minute
+)
+
+// oauthState is a pending consent request waiting for its callback
+type oauthState struct {
+	agentID   string
+	expiresAt time.Time
+}
+
+// CalendarService implements calendar.ICalendarService on top of the Google Calendar REST API
+type CalendarService struct {
+	repo       calendar.ICalendarRepository
+	apiURL     string
+	tokenURL   string
+	httpClient *http.Client
+
+	statesMu sync.Mutex
+	states   map[string]oauthState // Random OAuth state -> agent that requested the consent URL
+}
+
+func NewCalendarService(repo calendar.ICalendarRepository) *CalendarService {
+	return &CalendarService{
+		repo:       repo,
+		apiURL:     googleCalendarAPIURL,
+		tokenURL:   googleOAuthTokenURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		states:     make(map[string]oauthState),
+	}
+}
+
+// === Credential management ===
+
+func (s *CalendarService) SaveCredential(ctx context.Context, cred *calendar.CalendarCredential) error {
+	if cred.CalendarID == "" {
+		cred.CalendarID = "primary"
+	}
+	existing, err := s.repo.GetCredentialByAgentID(ctx, cred.AgentID)
+	if err == nil && existing != nil {
+		cred.ID = existing.ID
+		return s.repo.UpdateCredential(ctx, cred)
+	}
+	return s.repo.CreateCredential(ctx, cred)
+}
+
+func (s *CalendarService) GetCredential(ctx context.Context, agentID string) (*calendar.CalendarCredential, error) {
+	return s.repo.GetCredentialByAgentID(ctx, agentID)
+}
+
+func (s *CalendarService) DeleteCredential(ctx context.Context, id string) error {
+	return s.repo.DeleteCredential(ctx, id)
+}
+
+// === OAuth flow ===
+
+func (s *CalendarService) GetAuthURL(ctx context.Context, agentID string) (string, error) {
+	if config.CalendarOAuthRedirectURL == "" {
+		return "", fmt.Errorf("calendar OAuth redirect URL is not configured")
+	}
+	cred, err := s.repo.GetCredentialByAgentID(ctx, agentID)
+	if err != nil {
+		return "", fmt.Errorf("calendar credential not found: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("client_id", cred.ClientID)
+	params.Set("redirect_uri", config.CalendarOAuthRedirectURL)
+	params.Set("response_type", "code")
+	params.Set("scope", googleCalendarScope)
+	params.Set("access_type", "offline")
+	params.Set("prompt", "consent")
+	state, err := s.issueOAuthState(agentID)
+	if err != nil {
+		return "", err
+	}
+	params.Set("state", state)
+	return googleOAuthAuthURL + "?" + params.Encode(), nil
+}
+
+// HandleCallback completes the consent started by GetAuthURL. The state must be one this
+// server issued and not yet used, so a crafted callback can't bind another Google account.
+func (s *CalendarService) HandleCallback(ctx context.Context, state string, code string) error {
+	agentID, ok := s.consumeOAuthState(state)
+	if !ok {
+		return fmt.Errorf("invalid or expired OAuth state")
+	}
+	cred, err := s.repo.GetCredentialByAgentID(ctx, agentID)
+	if err != nil {
+		return fmt.Errorf("calendar credential not found: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", config.CalendarOAuthRedirectURL)
+	if err := s.exchangeToken(ctx, cred, form); err != nil {
+		return err
+	}
+	cred.IsConnected = true
+	return s.repo.UpdateCredential(ctx, cred)
+}
+
+// issueOAuthState returns a random state for agentID and drops expired ones
+func (s *CalendarService) issueOAuthState(agentID string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate OAuth state: %w", err)
+	}
+	state := hex.EncodeToString(buf)
+
+	s.statesMu.Lock()
+	defer s.statesMu.Unlock()
+	now := time.Now()
+	for key, pending := range s.states {
+		if now.After(pending.expiresAt) {
+			delete(s.states, key)
+		}
+	}
+	s.states[state] = oauthState{agentID: agentID, expiresAt: now.Add(oauthStateTTL)}
+	return state, nil
+}
+
+// consumeOAuthState returns the agent of a pending state and removes it, so each state works once
+func (s *CalendarService) consumeOAuthState(state string) (string, bool) {
+	s.statesMu.Lock()
+	defer s.statesMu.Unlock()
+	pending, ok := s.states[state]
+	if !ok {
+		return "", false
+	}
+	delete(s.states, state)
+	if time.Now().After(pending.expiresAt) {
+		return "", false
+	}
+	return pending.agentID, true
+}
+
+type googleTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// exchangeToken posts to the token endpoint and stores the returned tokens on cred
+func (s *CalendarService) exchangeToken(ctx context.Context, cred *calendar.CalendarCredential, form url.Values) error {
+	form.Set("client_id", cred.ClientID)
+	form.Set("client_secret", cred.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token googleTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if token.Error != "" || token.AccessToken == "" {
+		return fmt.Errorf("token request rejected: %s %s", token.Error, token.ErrorDesc)
+	}
+
+	cred.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		cred.RefreshToken = token.RefreshToken
+	}
+	cred.TokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return nil
+}
+
+// connectedCredential loads the agent's credential and refreshes the access token when it is about to expire
+func (s *CalendarService) connectedCredential(ctx context.Context, agentID string) (*calendar.CalendarCredential, error) {
+	cred, err := s.repo.GetCredentialByAgentID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("calendar not connected: %w", err)
+	}
+	if !cred.IsConnected || (cred.RefreshToken == "" && cred.AccessToken == "") {
+		return nil, fmt.Errorf("calendar not connected")
+	}
+
+	if cred.RefreshToken != "" && time.Until(cred.TokenExpiry) < time.Minute {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", cred.RefreshToken)
+		if err := s.exchangeToken(ctx, cred, form); err != nil {
+			return nil, fmt.Errorf("failed to refresh calendar token: %w", err)
+		}
+		if err := s.repo.UpdateCredential(ctx, cred); err != nil {
+			return nil, fmt.Errorf("failed to store calendar token: %w", err)
+		}
+	}
+	return cred, nil
+}
+
+// doAPI performs an authenticated Calendar API request and decodes the JSON response into out (if not nil)
+func (s *CalendarService) doAPI(ctx context.Context, cred *calendar.CalendarCredential, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cred.AccessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calendar request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("calendar API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse calendar response: %w", err)
+		}
+	}
+	return nil
+}
+
+// === Calendar operations ===
+
+type googleEventTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+type googleEvent struct {
+	ID          string          `json:"id,omitempty"`
+	Summary     string          `json:"summary"`
+	Description string          `json:"description,omitempty"`
+	Location    string          `json:"location,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Start       googleEventTime `json:"start"`
+	End         googleEventTime `json:"end"`
+	Attendees   []struct {
+		Email string `json:"email"`
+	} `json:"attendees,omitempty"`
+}
+
+func (e googleEvent) toEvent() *calendar.CalendarEvent {
+	event := &calendar.CalendarEvent{
+		ID:          e.ID,
+		Title:       e.Summary,
+		Description: e.Description,
+		Location:    e.Location,
+		Status:      e.Status,
+	}
+	if e.Start.Date != "" {
+		event.AllDay = true
+		event.StartTime, _ = time.Parse("2006-01-02", e.Start.Date)
+		event.EndTime, _ = time.Parse("2006-01-02", e.End.Date)
+	} else {
+		event.StartTime, _ = time.Parse(time.RFC3339, e.Start.DateTime)
+		event.EndTime, _ = time.Parse(time.RFC3339, e.End.DateTime)
+	}
+	for _, a := range e.Attendees {
+		event.Attendees = append(event.Attendees, a.Email)
+	}
+	return event
+}
+
+func (s *CalendarService) CreateEvent(ctx context.Context, req calendar.CreateEventRequest) (*calendar.CalendarEvent, error) {
+	if !req.EndTime.After(req.StartTime) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+	cred, err := s.connectedCredential(ctx, req.AgentID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := googleEvent{
+		Summary:     req.Title,
+		Description: req.Description,
+		Location:    req.Location,
+	}
+	if req.AllDay {
+		body.Start.Date = req.StartTime.Format("2006-01-02")
+		body.End.Date = req.EndTime.Format("2006-01-02")
+	} else {
+		body.Start.DateTime = req.StartTime.Format(time.RFC3339)
+		body.End.DateTime = req.EndTime.Format(time.RFC3339)
+	}
+	for _, email := range req.Attendees {
+		body.Attendees = append(body.Attendees, struct {
+			Email string `json:"email"`
+		}{Email: email})
+	}
+
+	var created googleEvent
+	path := "/calendars/" + url.PathEscape(cred.CalendarID) + "/events"
+	if err := s.doAPI(ctx, cred, http.MethodPost, path, body, &created); err != nil {
+		return nil, err
+	}
+	return created.toEvent(), nil
+}
+
+func (s *CalendarService) GetEvents(ctx context.Context, req calendar.GetEventsRequest) ([]*calendar.CalendarEvent, error) {
+	cred, err := s.connectedCredential(ctx, req.AgentID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("timeMin", req.StartTime.Format(time.RFC3339))
+	params.Set("timeMax", req.EndTime.Format(time.RFC3339))
+	params.Set("singleEvents", "true")
+	params.Set("orderBy", "startTime")
+	if req.Query != "" {
+		params.Set("q", req.Query)
+	}
+
+	var result struct {
+		Items []googleEvent `json:"items"`
+	}
+	path := "/calendars/" + url.PathEscape(cred.CalendarID) + "/events?" + params.Encode()
+	if err := s.doAPI(ctx, cred, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+
+	events := make([]*calendar.CalendarEvent, 0, len(result.Items))
+	for _, item := range result.Items {
+		events = append(events, item.toEvent())
+	}
+	return events, nil
+}
+
+func (s *CalendarService) GetAvailableSlots(ctx context.Context, req calendar.AvailabilityRequest) ([]calendar.TimeSlot, error) {
+	cred, err := s.connectedCredential(ctx, req.AgentID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"timeMin": req.StartTime.Format(time.RFC3339),
+		"timeMax": req.EndTime.Format(time.RFC3339),
+		"items":   []map[string]string{{"id": cred.CalendarID}},
+	}
+	var result struct {
+		Calendars map[string]struct {
+			Busy []struct {
+				Start time.Time `json:"start"`
+				End   time.Time `json:"end"`
+			} `json:"busy"`
+		} `json:"calendars"`
+	}
+	if err := s.doAPI(ctx, cred, http.MethodPost, "/freeBusy", body, &result); err != nil {
+		return nil, err
+	}
+
+	var busy []calendar.TimeSlot
+	for _, b := range result.Calendars[cred.CalendarID].Busy {
+		busy = append(busy, calendar.TimeSlot{StartTime: b.Start, EndTime: b.End})
+	}
+
+	duration := time.Duration(req.Duration) * time.Minute
+	if duration <= 0 {
+		duration = 30 * time.Minute
+	}
+	return freeSlots(req.StartTime, req.EndTime, busy, duration), nil
+}
+
+func (s *CalendarService) CancelEvent(ctx context.Context, agentID string, eventID string) error {
+	cred, err := s.connectedCredential(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	path := "/calendars/" + url.PathEscape(cred.CalendarID) + "/events/" + url.PathEscape(eventID)
+	return s.doAPI(ctx, cred, http.MethodDelete, path, nil, nil)
+}
+
+// freeSlots splits the gaps between busy intervals into back-to-back slots of the given duration
+func freeSlots(start, end time.Time, busy []calendar.TimeSlot, duration time.Duration) []calendar.TimeSlot {
+	sort.Slice(busy, func(i, j int) bool { return busy[i].StartTime.Before(busy[j].StartTime) })
+
+	var slots []calendar.TimeSlot
+	cursor := start
+	addUntil := func(limit time.Time) {
+		for !cursor.Add(duration).After(limit) {
+			slots = append(slots, calendar.TimeSlot{StartTime: cursor, EndTime: cursor.Add(duration)})
+			cursor = cursor.Add(duration)
+		}
+	}
+
+	for _, b := range busy {
+		if b.EndTime.Before(cursor) || b.EndTime.Equal(cursor) {
+			continue
+		}
+		if b.StartTime.After(cursor) {
+			addUntil(b.StartTime)
+		}
+		if b.EndTime.After(cursor) {
+			cursor = b.EndTime
+		}
+	}
+	addUntil(end)
+	return slots
+}
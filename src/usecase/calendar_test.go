@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/calendar"
+)
+
+func TestFreeSlots(t *testing.T) {
+	day := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+
+	busy := []calendar.TimeSlot{
+		{StartTime: at(1, 0), EndTime: at(2, 0)},
+		{StartTime: at(0, 30), EndTime: at(0, 45)},
+	}
+	slots := freeSlots(at(0, 0), at(3, 0), busy, 30*time.Minute)
+
+	want := []time.Time{at(0, 0), at(2, 0), at(2, 30)}
+	if len(slots) != len(want) {
+		t.Fatalf("got %d slots, want %d: %+v", len(slots), len(want), slots)
+	}
+	for i, s := range slots {
+		if !s.StartTime.Equal(want[i]) {
+			t.Errorf("slot %d starts at %s, want %s", i, s.StartTime, want[i])
+		}
+	}
+}
+
+func TestOAuthStateIsSingleUse(t *testing.T) {
+	s := NewCalendarService(nil)
+
+	state, err := s.issueOAuthState("agent-1")
+	if err != nil {
+		t.Fatalf("issueOAuthState() error = %v", err)
+	}
+	if state == "agent-1" || len(state) != 64 {
+		t.Fatalf("state = %q, want a random value", state)
+	}
+
+	if agentID, ok := s.consumeOAuthState(state); !ok || agentID != "agent-1" {
+		t.Fatalf("consumeOAuthState() = %q, %v, want agent-1, true", agentID, ok)
+	}
+	if _, ok := s.consumeOAuthState(state); ok {
+		t.Fatalf("consumeOAuthState() accepted a state twice")
+	}
+
+	// A callback carrying only the agent ID is rejected before any token exchange
+	if err := s.HandleCallback(context.Background(), "agent-1", "code"); err == nil {
+		t.Fatalf("HandleCallback() accepted an unknown state")
+	}
+
+	expired, _ := s.issueOAuthState("agent-2")
+	s.states[expired] = oauthState{agentID: "agent-2", expiresAt: time.Now().Add(-time.Second)}
+	if _, ok := s.consumeOAuthState(expired); ok {
+		t.Fatalf("consumeOAuthState() accepted an expired state")
+	}
+}
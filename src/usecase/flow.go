@@ -2,23 +2,43 @@ package usecase
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
 	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
-	_ "github.com/lib/pq"
 )
 
 type FlowService struct {
-	repo *flowRepo.SQLiteRepository
+	repo     *flowRepo.SQLiteRepository
+	executor *flowRepo.FlowExecutor
 }
 
 func NewFlowService(repo *flowRepo.SQLiteRepository) *FlowService {
 	return &FlowService{repo: repo}
 }
 
+// SetExecutor sets the flow executor (called after initialization)
+func (s *FlowService) SetExecutor(executor *flowRepo.FlowExecutor) {
+	s.executor = executor
+}
+
+// ExecuteFlow runs an active flow with the given input and returns its output
+func (s *FlowService) ExecuteFlow(ctx context.Context, id string, input map[string]interface{}) (map[string]interface{}, error) {
+	if s.executor == nil {
+		return nil, fmt.Errorf("flow executor not initialized")
+	}
+	f, err := s.repo.GetFlowByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("flow not found: %w", err)
+	}
+	if !f.IsActive {
+		return nil, fmt.Errorf("flow %s is not active", f.Name)
+	}
+	return s.executor.Execute(ctx, f, input)
+}
+
 // === Flow Operations ===
 
 func (s *FlowService) CreateFlow(ctx context.Context, req flow.CreateFlowRequest) (*flow.FlowResponse, error) {
@@ -38,6 +58,12 @@ func (s *FlowService) CreateFlow(ctx context.Context, req flow.CreateFlowRequest
 	if f.Edges == nil {
 		f.Edges = []flow.Edge{}
 	}
+	if err := restoreWebhookSecrets(f.Nodes, nil); err != nil {
+		return nil, err
+	}
+	if err := validateDefinition(f); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.CreateFlow(ctx, f); err != nil {
 		return nil, fmt.Errorf("failed to create flow: %w", err)
@@ -51,7 +77,13 @@ func (s *FlowService) GetFlow(ctx context.Context, id string) (*flow.FlowRespons
 	if err != nil {
 		return nil, err
 	}
-	return s.flowToResponse(f), nil
+	draft, err := s.repo.GetFlowDraft(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+	response := s.flowToResponse(f)
+	response.Draft = maskDraft(draft)
+	return response, nil
 }
 
 func (s *FlowService) GetFlowsByAgent(ctx context.Context, agentID string) ([]*flow.FlowResponse, error) {
@@ -67,55 +99,112 @@ func (s *FlowService) GetFlowsByAgent(ctx context.Context, agentID string) ([]*f
 	return responses, nil
 }
 
+// UpdateFlow applies name, description and active state changes at once, and saves node, edge
+// and variable changes to the draft of the flow, leaving the published version running
 func (s *FlowService) UpdateFlow(ctx context.Context, id string, req flow.UpdateFlowRequest) (*flow.FlowResponse, error) {
 	f, err := s.repo.GetFlowByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-
-	if req.Name != nil {
-		f.Name = *req.Name
-	}
-	if req.Description != nil {
-		f.Description = *req.Description
-	}
-	if req.IsActive != nil {
-		f.IsActive = *req.IsActive
-	}
-	if req.Nodes != nil {
-		f.Nodes = req.Nodes
-	}
-	if req.Edges != nil {
-		f.Edges = req.Edges
+	draft, err := s.repo.GetFlowDraft(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
 	}
-	if req.Variables != nil {
-		f.Variables = req.Variables
+
+	if req.Nodes != nil || req.Edges != nil || req.Variables != nil {
+		if req.Nodes != nil {
+			stored := f.Nodes
+			if draft != nil {
+				stored = draft.Nodes
+			}
+			if err := restoreWebhookSecrets(req.Nodes, stored); err != nil {
+				return nil, err
+			}
+		}
+		if draft == nil {
+			draft = &flow.FlowDraft{Nodes: f.Nodes, Edges: f.Edges, Variables: f.Variables}
+		}
+		if req.Nodes != nil {
+			draft.Nodes = req.Nodes
+		}
+		if req.Edges != nil {
+			draft.Edges = req.Edges
+		}
+		if req.Variables != nil {
+			draft.Variables = req.Variables
+		}
+		edited := *f
+		edited.Nodes, edited.Edges, edited.Variables = draft.Nodes, draft.Edges, draft.Variables
+		if err := validateDefinition(&edited); err != nil {
+			return nil, err
+		}
+		// Changes undone in the editor leave nothing to publish
+		if sameJSON(draft.Nodes, f.Nodes) && sameJSON(draft.Edges, f.Edges) && sameJSON(nonNilVariables(draft.Variables), nonNilVariables(f.Variables)) {
+			if err := s.repo.DeleteFlowDraft(ctx, id); err != nil {
+				return nil, fmt.Errorf("failed to save draft: %w", err)
+			}
+			draft = nil
+		} else if err := s.repo.SaveFlowDraft(ctx, id, draft); err != nil {
+			return nil, fmt.Errorf("failed to save draft: %w", err)
+		}
 	}
 
-	if err := s.repo.UpdateFlow(ctx, f); err != nil {
-		return nil, fmt.Errorf("failed to update flow: %w", err)
+	if req.Name != nil || req.Description != nil || req.IsActive != nil {
+		if req.Name != nil {
+			f.Name = *req.Name
+		}
+		if req.Description != nil {
+			f.Description = *req.Description
+		}
+		if req.IsActive != nil {
+			f.IsActive = *req.IsActive
+		}
+		if err := s.repo.UpdateFlowDetails(ctx, f); err != nil {
+			return nil, fmt.Errorf("failed to update flow: %w", err)
+		}
 	}
 
-	return s.flowToResponse(f), nil
+	response := s.flowToResponse(f)
+	response.Draft = maskDraft(draft)
+	return response, nil
 }
 
 func (s *FlowService) DeleteFlow(ctx context.Context, id string) error {
 	return s.repo.DeleteFlow(ctx, id)
 }
 
+// SaveMedia stores a file for send_image and send_file nodes and returns the ID they reference it by
+func (s *FlowService) SaveMedia(data []byte, fileName string) (string, error) {
+	return flowRepo.SaveMedia(data, fileName)
+}
+
 func (s *FlowService) flowToResponse(f *flow.Flow) *flow.FlowResponse {
+	schedules, nextRunAt := scheduleStatuses(f, time.Now())
 	return &flow.FlowResponse{
 		ID:          f.ID,
 		AgentID:     f.AgentID,
 		Name:        f.Name,
 		Description: f.Description,
 		IsActive:    f.IsActive,
-		Nodes:       f.Nodes,
+		Nodes:       maskWebhookSecrets(f.Nodes),
 		Edges:       f.Edges,
 		Variables:   f.Variables,
+		Version:     f.Version,
 		CreatedAt:   f.CreatedAt,
 		UpdatedAt:   f.UpdatedAt,
+		NextRunAt:   nextRunAt,
+		Schedules:   schedules,
+	}
+}
+
+// maskDraft returns a copy of draft with webhook signing secrets masked
+func maskDraft(draft *flow.FlowDraft) *flow.FlowDraft {
+	if draft == nil {
+		return nil
 	}
+	masked := *draft
+	masked.Nodes = maskWebhookSecrets(draft.Nodes)
+	return &masked
 }
 
 // === Credential Operations ===
@@ -195,14 +284,9 @@ func (s *FlowService) credentialToResponse(c *flow.Credential) *flow.CredentialR
 	}
 }
 
-// TestDatabaseConnection tests a PostgreSQL/Supabase connection
+// TestDatabaseConnection tests a PostgreSQL/Supabase, MySQL or SQLite connection
 func (s *FlowService) TestDatabaseConnection(ctx context.Context, config flow.DatabaseCredential) error {
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.Database, config.SSLMode,
-	)
-
-	db, err := sql.Open("postgres", connStr)
+	db, err := flowRepo.OpenDatabase(config)
 	if err != nil {
 		return fmt.Errorf("failed to open connection: %w", err)
 	}
@@ -215,6 +299,11 @@ func (s *FlowService) TestDatabaseConnection(ctx context.Context, config flow.Da
 	return nil
 }
 
+// TestSMTPConnection tests that an SMTP server accepts a login
+func (s *FlowService) TestSMTPConnection(ctx context.Context, config flow.SMTPCredential) error {
+	return flowRepo.TestSMTP(ctx, config)
+}
+
 // GetCredentialConfig returns parsed credential config
 func (s *FlowService) GetDatabaseCredential(ctx context.Context, credentialID string) (*flow.DatabaseCredential, error) {
 	c, err := s.repo.GetCredentialByID(ctx, credentialID)
@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+)
+
+// validateCode rejects code nodes whose JavaScript does not compile
+func validateCode(nodes []flow.Node) error {
+	for _, node := range nodes {
+		if node.Type != flow.NodeTypeCode {
+			continue
+		}
+		code, _ := node.Data["code"].(string)
+		if err := flowRepo.CompileJavaScript(code); err != nil {
+			return fmt.Errorf("%w: code node %q: %v", ErrInvalidFlow, node.Label, err)
+		}
+	}
+	return nil
+}
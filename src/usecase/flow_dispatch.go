@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+	"github.com/sirupsen/logrus"
+)
+
+// FlowMessage is an incoming channel message offered to message-triggered flows
+type FlowMessage struct {
+	AgentID        string
+	IntegrationID  string
+	Channel        string // Integration type: whatsapp, telegram or instagram
+	Sender         string // Remote JID, tg_<user id> or Instagram user ID
+	ConversationID string
+	Text           string
+	MessageType    string // text, image, audio or document
+	Attachments    []agent.Attachment
+
+	received []receivedMedia
+}
+
+// receivedMedia is an attachment of a message saved as flow media
+type receivedMedia struct {
+	Type      string
+	MIMEType  string
+	FileName  string
+	MediaFile string
+}
+
+// saveAttachments stores the attachments of the message so that nodes such as email can use them
+func (m *FlowMessage) saveAttachments() {
+	for _, a := range m.Attachments {
+		id, err := flowRepo.SaveReceivedMedia(a.Data, a.FileName)
+		if err != nil {
+			logrus.Warnf("⚠️  [Flow] Failed to save attachment from %s: %v", m.Sender, err)
+			continue
+		}
+		m.received = append(m.received, receivedMedia{Type: a.Type, MIMEType: a.MIMEType, FileName: a.FileName, MediaFile: id})
+	}
+}
+
+// input is what the flow receives as its initial variables
+func (m FlowMessage) input() map[string]interface{} {
+	attachments := make([]interface{}, len(m.received))
+	for i, media := range m.received {
+		attachments[i] = map[string]interface{}{
+			"type":       media.Type,
+			"mime_type":  media.MIMEType,
+			"file_name":  media.FileName,
+			"media_file": media.MediaFile,
+		}
+	}
+	return map[string]interface{}{
+		"attachments":     attachments,
+		"message":         m.Text,
+		"text":            m.Text,
+		"message_type":    m.MessageType,
+		"sender":          m.Sender,
+		"remote_jid":      m.Sender,
+		"channel":         m.Channel,
+		"integration_id":  m.IntegrationID,
+		"agent_id":        m.AgentID,
+		"conversation_id": m.ConversationID,
+	}
+}
+
+// FlowDispatchResult reports what the flows triggered by a message did
+type FlowDispatchResult struct {
+	Matched int      // Flows whose trigger matched, including runs that failed
+	Resumed int      // Runs that were waiting for this reply, including runs that failed
+	Replies []string // Messages the flows addressed to the sender, in order
+}
+
+// messageTriggerTypes maps integration types to the trigger node listening on them
+var messageTriggerTypes = map[string]string{
+	agent.IntegrationTypeWhatsApp:  flow.NodeTypeTriggerWhatsApp,
+	agent.IntegrationTypeTelegram:  flow.NodeTypeTriggerTelegram,
+	agent.IntegrationTypeInstagram: flow.NodeTypeTriggerInstagram,
+}
+
+// triggerNodeData decodes the configuration of a trigger node
+func triggerNodeData(node flow.Node) flow.TriggerNodeData {
+	var data flow.TriggerNodeData
+	if raw, err := json.Marshal(node.Data); err == nil {
+		json.Unmarshal(raw, &data)
+	}
+	return data
+}
+
+// matchesTrigger reports whether node is a trigger for msg. Unset integration, message type and
+// keyword filters match any message; keywords match case-insensitively anywhere in the text.
+func matchesTrigger(node flow.Node, msg FlowMessage) bool {
+	triggerType, ok := messageTriggerTypes[msg.Channel]
+	if !ok || node.Type != triggerType {
+		return false
+	}
+
+	data := triggerNodeData(node)
+	if data.IntegrationID != "" && data.IntegrationID != msg.IntegrationID {
+		return false
+	}
+	if len(data.MessageTypes) > 0 {
+		matched := false
+		for _, messageType := range data.MessageTypes {
+			if strings.EqualFold(strings.TrimSpace(messageType), msg.MessageType) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	text := strings.ToLower(msg.Text)
+	filtered := false
+	for _, keyword := range data.FilterKeywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" {
+			continue
+		}
+		if strings.Contains(text, keyword) {
+			return true
+		}
+		filtered = true
+	}
+	return !filtered
+}
+
+// DispatchMessage runs the agent's active flows with a trigger matching msg, oldest flow first.
+// Each flow runs at most once per message, from its first matching trigger. A failing flow is
+// logged and does not stop the others. A message that runs were waiting for from the sender
+// resumes them instead, and triggers no flows.
+func (s *FlowService) DispatchMessage(ctx context.Context, msg FlowMessage) (*FlowDispatchResult, error) {
+	if s.executor == nil {
+		return nil, fmt.Errorf("flow executor not initialized")
+	}
+
+	flows, err := s.repo.GetFlowsByAgentID(ctx, msg.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flows: %w", err)
+	}
+	// Attachments are kept only for agents with flows that may use them
+	for _, f := range flows {
+		if f.IsActive {
+			msg.saveAttachments()
+			break
+		}
+	}
+
+	result := &FlowDispatchResult{}
+	resumed, replies, err := s.executor.ResumeReply(ctx, msg.IntegrationID, msg.Sender, msg.input())
+	if err != nil {
+		logrus.Warnf("⚠️  [Flow] Failed to resume runs waiting for %s: %v", msg.Sender, err)
+	}
+	if resumed > 0 {
+		logrus.Infof("🔀 [Flow] Message from %s resumed %d waiting runs", msg.Sender, resumed)
+		result.Resumed = resumed
+		result.Replies = replies
+		return result, nil
+	}
+
+	// Flows are listed newest first
+	for i := len(flows) - 1; i >= 0; i-- {
+		f := flows[i]
+		if !f.IsActive {
+			continue
+		}
+		for _, node := range f.Nodes {
+			if !matchesTrigger(node, msg) {
+				continue
+			}
+			result.Matched++
+			logrus.Infof("🔀 [Flow] Message from %s triggered flow %s (%s)", msg.Sender, f.ID, f.Name)
+			run, err := s.executor.Run(ctx, f, node.ID, msg.input())
+			if err != nil {
+				logrus.Warnf("⚠️  [Flow] Flow %s (%s) failed on message from %s: %v", f.ID, f.Name, msg.Sender, err)
+				break
+			}
+			result.Replies = append(result.Replies, run.Replies...)
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/agent"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+)
+
+func TestMatchesTrigger(t *testing.T) {
+	msg := FlowMessage{Channel: agent.IntegrationTypeWhatsApp, IntegrationID: "wa-1", Text: "I want a REFUND please", MessageType: agent.MessageTypeText}
+
+	tests := []struct {
+		name string
+		node flow.Node
+		want bool
+	}{
+		{name: "AnyMessage", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp}, want: true},
+		{name: "OtherChannel", node: flow.Node{Type: flow.NodeTypeTriggerTelegram}, want: false},
+		{name: "NotATrigger", node: flow.Node{Type: flow.NodeTypeSendMessage}, want: false},
+		{name: "SameIntegration", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp, Data: map[string]interface{}{"integration_id": "wa-1"}}, want: true},
+		{name: "OtherIntegration", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp, Data: map[string]interface{}{"integration_id": "wa-2"}}, want: false},
+		{name: "MessageType", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp, Data: map[string]interface{}{"message_types": []interface{}{"image", "Text"}}}, want: true},
+		{name: "OtherMessageType", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp, Data: map[string]interface{}{"message_types": []interface{}{"audio"}}}, want: false},
+		{name: "Keyword", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp, Data: map[string]interface{}{"filter_keywords": []interface{}{"order", "refund"}}}, want: true},
+		{name: "NoKeyword", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp, Data: map[string]interface{}{"filter_keywords": []interface{}{"order"}}}, want: false},
+		{name: "BlankKeywords", node: flow.Node{Type: flow.NodeTypeTriggerWhatsApp, Data: map[string]interface{}{"filter_keywords": []interface{}{" "}}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesTrigger(tt.node, msg); got != tt.want {
+				t.Fatalf("matchesTrigger() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingSender is a flow.IMessageSender that records messages instead of delivering them
+type recordingSender struct {
+	sent []flow.OutgoingMessage
+}
+
+func (r *recordingSender) Send(ctx context.Context, msg flow.OutgoingMessage) (*flow.SentMessage, error) {
+	r.sent = append(r.sent, msg)
+	return &flow.SentMessage{MessageID: "msg-1", Channel: agent.IntegrationTypeTelegram}, nil
+}
+
+func TestDispatchMessage(t *testing.T) {
+	repo, err := flowRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	service := NewFlowService(repo)
+	executor := flowRepo.NewFlowExecutor(repo)
+	sender := &recordingSender{}
+	executor.SetMessageSender(sender)
+	service.SetExecutor(executor)
+	ctx := context.Background()
+
+	replyFlow := func(name, keyword string, active bool) *flow.Flow {
+		return &flow.Flow{
+			AgentID:  "agent-1",
+			Name:     name,
+			IsActive: active,
+			Nodes: []flow.Node{
+				{ID: "webhook", Type: flow.NodeTypeTriggerWebhook},
+				{ID: "trigger", Type: flow.NodeTypeTriggerTelegram, Data: map[string]interface{}{"filter_keywords": []interface{}{keyword}}},
+				{ID: "reply", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"message": name + ": {{message}}", "reply_to_trigger": true}},
+			},
+			Edges: []flow.Edge{{ID: "e1", Source: "trigger", Target: "reply"}},
+		}
+	}
+	for _, f := range []*flow.Flow{
+		replyFlow("orders", "order", true),
+		replyFlow("inactive", "order", false),
+		replyFlow("refunds", "refund", true),
+	} {
+		if err := repo.CreateFlow(ctx, f); err != nil {
+			t.Fatalf("CreateFlow() error = %v", err)
+		}
+	}
+
+	result, err := service.DispatchMessage(ctx, FlowMessage{
+		AgentID: "agent-1", IntegrationID: "tg-1", Channel: agent.IntegrationTypeTelegram, Sender: "tg_1", Text: "where is my order", MessageType: agent.MessageTypeText,
+	})
+	if err != nil {
+		t.Fatalf("DispatchMessage() error = %v", err)
+	}
+	if result.Matched != 1 || len(result.Replies) != 1 || result.Replies[0] != "orders: where is my order" {
+		t.Fatalf("DispatchMessage() = %+v", result)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].IntegrationID != "tg-1" || sender.sent[0].Recipient != "tg_1" || sender.sent[0].AgentID != "agent-1" {
+		t.Fatalf("sent = %+v", sender.sent)
+	}
+
+	result, err = service.DispatchMessage(ctx, FlowMessage{AgentID: "agent-1", Channel: agent.IntegrationTypeWhatsApp, Text: "order"})
+	if err != nil || result.Matched != 0 {
+		t.Fatalf("DispatchMessage() on another channel = %+v, %v", result, err)
+	}
+}
+
+func TestDispatchMessageResumesWaitingRun(t *testing.T) {
+	repo, err := flowRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	service := NewFlowService(repo)
+	executor := flowRepo.NewFlowExecutor(repo)
+	sender := &recordingSender{}
+	executor.SetMessageSender(sender)
+	service.SetExecutor(executor)
+	ctx := context.Background()
+
+	f := &flow.Flow{
+		AgentID:  "agent-1",
+		Name:     "name",
+		IsActive: true,
+		Nodes: []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerTelegram},
+			{ID: "ask", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"message": "What is your name?", "reply_to_trigger": true}},
+			{ID: "wait", Type: flow.NodeTypeWaitForReply, Data: map[string]interface{}{"timeout": float64(10), "unit": "minutes"}},
+			{ID: "thank", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"message": "Thanks {{reply}}", "reply_to_trigger": true}},
+		},
+		Edges: []flow.Edge{
+			{ID: "e1", Source: "trigger", Target: "ask"},
+			{ID: "e2", Source: "ask", Target: "wait"},
+			{ID: "e3", Source: "wait", Target: "thank", SourceHandle: flow.WaitHandleReply},
+		},
+	}
+	if err := repo.CreateFlow(ctx, f); err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+
+	msg := FlowMessage{AgentID: "agent-1", IntegrationID: "tg-1", Channel: agent.IntegrationTypeTelegram, Sender: "tg_1", Text: "hi", MessageType: agent.MessageTypeText}
+	result, err := service.DispatchMessage(ctx, msg)
+	if err != nil || result.Matched != 1 || len(result.Replies) != 1 {
+		t.Fatalf("DispatchMessage() = %+v, %v", result, err)
+	}
+
+	// The reply resumes the run instead of triggering the flow again
+	msg.Text = "Ana"
+	result, err = service.DispatchMessage(ctx, msg)
+	if err != nil {
+		t.Fatalf("DispatchMessage() error = %v", err)
+	}
+	if result.Matched != 0 || result.Resumed != 1 || len(result.Replies) != 1 || result.Replies[0] != "Thanks Ana" {
+		t.Fatalf("DispatchMessage() with the reply = %+v", result)
+	}
+	if len(sender.sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(sender.sent))
+	}
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// TestFlow dry runs a flow with sample trigger input. Inactive flows can be tested, and nodes that
+// would send messages or email, call webhooks or write to databases and sheets are stubbed.
+func (s *FlowService) TestFlow(ctx context.Context, id string, req flow.TestFlowRequest) (*flow.TestFlowResponse, error) {
+	if s.executor == nil {
+		return nil, fmt.Errorf("flow executor not initialized")
+	}
+	f, err := s.repo.GetFlowByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("flow not found: %w", err)
+	}
+
+	ref := req.Version
+	if ref == "" {
+		ref = fmt.Sprint(f.Version)
+		if draft, err := s.repo.GetFlowDraft(ctx, id); err != nil {
+			return nil, fmt.Errorf("failed to get draft: %w", err)
+		} else if draft != nil {
+			ref = draftRef
+		}
+	}
+	def, err := s.definition(ctx, id, ref)
+	if err != nil {
+		return nil, err
+	}
+	f.Nodes, f.Edges, f.Variables = def.Nodes, def.Edges, def.Variables
+	if err := validateGraph(f); err != nil {
+		return nil, err
+	}
+
+	input := req.Input
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+	result, runErr := s.executor.DryRun(ctx, f, req.TriggerID, input, req.Reply)
+	// Without a result the run could not start, for want of its trigger
+	if result == nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFlow, runErr)
+	}
+
+	response := &flow.TestFlowResponse{
+		Version:    ref,
+		Status:     flow.ExecutionStatusSuccess,
+		Trace:      result.Trace,
+		Variables:  result.Variables,
+		Output:     result.Output,
+		Replies:    result.Replies,
+		Stubbed:    []string{},
+		DurationMs: result.Duration.Milliseconds(),
+	}
+	if runErr != nil {
+		response.Status = flow.ExecutionStatusFailed
+		response.Error = runErr.Error()
+	}
+	if response.Trace == nil {
+		response.Trace = []flow.NodeTrace{}
+	}
+	if response.Replies == nil {
+		response.Replies = []string{}
+	}
+	response.Stubbed = append(response.Stubbed, stubbedNodes(response.Trace)...)
+	return response, nil
+}
+
+// stubbedNodes lists the nodes of a dry run trace whose side effects were skipped
+func stubbedNodes(trace []flow.NodeTrace) []string {
+	var stubbed []string
+	for _, t := range trace {
+		if t.Stubbed {
+			stubbed = append(stubbed, t.NodeID)
+		}
+	}
+	return stubbed
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+)
+
+func TestTestFlow(t *testing.T) {
+	repo, err := flowRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	service := NewFlowService(repo)
+	service.SetExecutor(flowRepo.NewFlowExecutor(repo))
+	ctx := context.Background()
+
+	nodes := func(message string) []flow.Node {
+		return []flow.Node{
+			{ID: "trigger", Type: flow.NodeTypeTriggerWhatsApp},
+			{ID: "reply", Type: flow.NodeTypeSendMessage, Data: map[string]interface{}{"message": message}},
+		}
+	}
+	edges := []flow.Edge{{ID: "e1", Source: "trigger", Target: "reply"}}
+	f, err := service.CreateFlow(ctx, flow.CreateFlowRequest{AgentID: "agent-1", Name: "support", Nodes: nodes("Hello {{sender}}"), Edges: edges})
+	if err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+	input := map[string]interface{}{"integration_id": "wa-1", "sender": "628999"}
+
+	// No message sender is configured, so only a dry run can get past the send node
+	got, err := service.TestFlow(ctx, f.ID, flow.TestFlowRequest{Input: input})
+	if err != nil {
+		t.Fatalf("TestFlow() error = %v", err)
+	}
+	if got.Status != flow.ExecutionStatusSuccess || got.Version != "1" || len(got.Stubbed) != 1 || got.Stubbed[0] != "reply" || len(got.Replies) != 1 || got.Replies[0] != "Hello 628999" {
+		t.Fatalf("TestFlow() = %+v", got)
+	}
+
+	// The draft is tested when there is one
+	if _, err := service.UpdateFlow(ctx, f.ID, flow.UpdateFlowRequest{Nodes: nodes("   ")}); err != nil {
+		t.Fatalf("UpdateFlow() error = %v", err)
+	}
+	got, err = service.TestFlow(ctx, f.ID, flow.TestFlowRequest{Input: input})
+	if err != nil {
+		t.Fatalf("TestFlow() error = %v", err)
+	}
+	if got.Version != draftRef || got.Status != flow.ExecutionStatusFailed || got.Error == "" || len(got.Trace) != 2 || got.Trace[1].Error == "" {
+		t.Fatalf("TestFlow() of the draft = %+v", got)
+	}
+	if got, err := service.TestFlow(ctx, f.ID, flow.TestFlowRequest{Input: input, Version: "1"}); err != nil || got.Status != flow.ExecutionStatusSuccess {
+		t.Fatalf("TestFlow() of version 1 = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+const (
+	defaultExecutionLimit = 50
+	maxExecutionLimit     = 200
+)
+
+// ErrExecutionNotFound is returned when a flow has no execution with the requested ID
+var ErrExecutionNotFound = errors.New("execution not found")
+
+// ListExecutions returns the runs of a flow matching filter, newest first and without node traces
+func (s *FlowService) ListExecutions(ctx context.Context, filter flow.ExecutionFilter) (*flow.ExecutionList, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultExecutionLimit
+	}
+	if filter.Limit > maxExecutionLimit {
+		filter.Limit = maxExecutionLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	executions, total, err := s.repo.ListExecutions(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list executions: %w", err)
+	}
+	if executions == nil {
+		executions = []*flow.Execution{}
+	}
+	return &flow.ExecutionList{Executions: executions, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// GetExecution returns a run of a flow with its node trace
+func (s *FlowService) GetExecution(ctx context.Context, flowID, executionID string) (*flow.Execution, error) {
+	execution, err := s.repo.GetExecution(ctx, executionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExecutionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if execution.FlowID != flowID {
+		return nil, ErrExecutionNotFound
+	}
+	return execution, nil
+}
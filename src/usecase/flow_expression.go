@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+)
+
+// expressionFields hold a variable path or an expression without {{ }}
+var expressionFields = map[string]bool{"field": true, "items": true, "expression": true}
+
+// validateExpressions rejects nodes with an expression that does not compile, in a {{ }} of any
+// text field or in a field that takes an expression
+func validateExpressions(nodes []flow.Node) error {
+	for _, node := range nodes {
+		for key, value := range node.Data {
+			if node.Type == flow.NodeTypeCode && key == "code" {
+				continue
+			}
+			if err := compileExpressions(key, value); err != nil {
+				return fmt.Errorf("%w: %s %q, %s: %v", ErrInvalidFlow, node.Type, node.Label, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func compileExpressions(key string, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		if expressionFields[key] {
+			return flowRepo.CompileExpression(v)
+		}
+		return flowRepo.CompileTemplate(v)
+	case map[string]interface{}:
+		for k, item := range v {
+			if err := compileExpressions(k, item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := compileExpressions(key, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
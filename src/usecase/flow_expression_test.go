@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestValidateExpressions(t *testing.T) {
+	nodes := []flow.Node{
+		{ID: "reply", Type: flow.NodeTypeSendMessage, Label: "Reply", Data: map[string]interface{}{"message": "Total {{ price * qty }} for {{name}}"}},
+		{ID: "check", Type: flow.NodeTypeCondition, Label: "Check", Data: map[string]interface{}{
+			"expression": "total > 100",
+			"conditions": []interface{}{map[string]interface{}{"field": "order.status", "operator": "eq", "value": "paid"}},
+		}},
+		{ID: "code", Type: flow.NodeTypeCode, Label: "Code", Data: map[string]interface{}{"code": "return { a: '{{ not an expression' };"}},
+	}
+	if err := validateExpressions(nodes); err != nil {
+		t.Fatalf("validateExpressions() error = %v", err)
+	}
+
+	nodes[1].Data["conditions"] = []interface{}{map[string]interface{}{"field": "total >", "operator": "eq"}}
+	err := validateExpressions(nodes)
+	if !errors.Is(err, ErrInvalidFlow) || !strings.Contains(err.Error(), `"Check"`) {
+		t.Fatalf("validateExpressions() error = %v, want ErrInvalidFlow naming the node", err)
+	}
+
+	nodes[1].Data["conditions"] = nil
+	nodes[0].Data["message"] = "Total {{ price * }}"
+	if err := validateExpressions(nodes); !errors.Is(err, ErrInvalidFlow) {
+		t.Fatalf("validateExpressions() error = %v, want ErrInvalidFlow", err)
+	}
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+)
+
+// validateGraph rejects flows the engine cannot run, such as flows that loop other than through a
+// for each node or that take more than the step limit
+func validateGraph(f *flow.Flow) error {
+	if err := flowRepo.ValidateGraph(f.Nodes, f.Edges, config.FlowMaxSteps); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFlow, err)
+	}
+	return nil
+}
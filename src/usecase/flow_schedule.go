@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+)
+
+// ErrInvalidFlow is returned when a flow cannot be saved as given
+var ErrInvalidFlow = errors.New("invalid flow")
+
+// validateSchedules rejects schedule triggers with a missing or invalid cron expression or timezone
+func validateSchedules(nodes []flow.Node) error {
+	for _, node := range nodes {
+		if node.Type != flow.NodeTypeTriggerSchedule {
+			continue
+		}
+		data := triggerNodeData(node)
+		if _, _, err := flowRepo.ParseSchedule(data.Cron, data.Timezone); err != nil {
+			return fmt.Errorf("%w: schedule trigger %q: %v", ErrInvalidFlow, node.Label, err)
+		}
+	}
+	return nil
+}
+
+// scheduleStatuses returns the schedule triggers of f with their next run after now, and the
+// earliest of those runs. Inactive flows have no next runs.
+func scheduleStatuses(f *flow.Flow, now time.Time) ([]flow.ScheduleStatus, *time.Time) {
+	var statuses []flow.ScheduleStatus
+	var earliest *time.Time
+	for _, node := range f.Nodes {
+		if node.Type != flow.NodeTypeTriggerSchedule {
+			continue
+		}
+		data := triggerNodeData(node)
+		status := flow.ScheduleStatus{NodeID: node.ID, Cron: data.Cron, Timezone: data.Timezone}
+		if status.Timezone == "" {
+			status.Timezone = time.UTC.String()
+		}
+
+		schedule, loc, err := flowRepo.ParseSchedule(data.Cron, data.Timezone)
+		switch {
+		case err != nil:
+			status.Error = err.Error()
+		case f.IsActive:
+			if next := schedule.Next(now.In(loc)); !next.IsZero() {
+				status.NextRunAt = &next
+				if earliest == nil || next.Before(*earliest) {
+					earliest = &next
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, earliest
+}
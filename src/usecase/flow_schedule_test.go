@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+func TestScheduleStatuses(t *testing.T) {
+	f := &flow.Flow{
+		IsActive: true,
+		Nodes: []flow.Node{
+			{ID: "daily", Type: flow.NodeTypeTriggerSchedule, Data: map[string]interface{}{"cron": "0 9 * * *", "timezone": "Asia/Jakarta"}},
+			{ID: "hourly", Type: flow.NodeTypeTriggerSchedule, Data: map[string]interface{}{"cron": "@hourly"}},
+			{ID: "broken", Type: flow.NodeTypeTriggerSchedule, Data: map[string]interface{}{"cron": "0 9 * *"}},
+			{ID: "hook", Type: flow.NodeTypeTriggerWebhook},
+		},
+	}
+	now := time.Date(2025, 3, 14, 1, 30, 0, 0, time.UTC) // 08:30 in Jakarta
+
+	statuses, next := scheduleStatuses(f, now)
+	if len(statuses) != 3 {
+		t.Fatalf("scheduleStatuses() = %+v", statuses)
+	}
+	if got := statuses[0].NextRunAt; got == nil || !got.Equal(time.Date(2025, 3, 14, 2, 0, 0, 0, time.UTC)) || statuses[0].Timezone != "Asia/Jakarta" {
+		t.Fatalf("daily = %+v", statuses[0])
+	}
+	if statuses[1].Timezone != "UTC" || statuses[2].Error == "" || statuses[2].NextRunAt != nil {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	if next == nil || !next.Equal(time.Date(2025, 3, 14, 2, 0, 0, 0, time.UTC)) {
+		t.Fatalf("next run = %v", next)
+	}
+
+	f.IsActive = false
+	if _, next := scheduleStatuses(f, now); next != nil {
+		t.Fatalf("next run of an inactive flow = %v", next)
+	}
+
+	if err := validateSchedules(f.Nodes); !errors.Is(err, ErrInvalidFlow) {
+		t.Fatalf("validateSchedules() error = %v, want ErrInvalidFlow", err)
+	}
+	if err := validateSchedules(f.Nodes[:2]); err != nil {
+		t.Fatalf("validateSchedules() error = %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+)
+
+// draftRef names the draft of a flow in place of a version number
+const draftRef = "draft"
+
+var (
+	// ErrNoDraft is returned when a flow has no unpublished changes
+	ErrNoDraft = errors.New("flow has no unpublished changes")
+	// ErrVersionNotFound is returned when a flow has no version with the requested number
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// validateDefinition checks a definition of f before it is saved as a draft or published
+func validateDefinition(f *flow.Flow) error {
+	if err := validateSchedules(f.Nodes); err != nil {
+		return err
+	}
+	if err := validateCode(f.Nodes); err != nil {
+		return err
+	}
+	if err := validateExpressions(f.Nodes); err != nil {
+		return err
+	}
+	if err := validateGraph(f); err != nil {
+		return err
+	}
+	return ensureWebhookTokens(f.Nodes)
+}
+
+// PublishFlow makes the draft of a flow its next version, which runs from then on
+func (s *FlowService) PublishFlow(ctx context.Context, id string, req flow.PublishFlowRequest) (*flow.FlowResponse, error) {
+	f, err := s.repo.GetFlowByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	draft, err := s.repo.GetFlowDraft(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+	if draft == nil {
+		return nil, ErrNoDraft
+	}
+
+	// Validation may have changed since the draft was saved
+	f.Nodes, f.Edges, f.Variables = draft.Nodes, draft.Edges, draft.Variables
+	if err := validateDefinition(f); err != nil {
+		return nil, err
+	}
+	v := &flow.FlowVersion{FlowID: id, Nodes: f.Nodes, Edges: f.Edges, Variables: f.Variables, Note: req.Note}
+	if err := s.repo.PublishFlowVersion(ctx, v, true); err != nil {
+		return nil, fmt.Errorf("failed to publish flow: %w", err)
+	}
+	f.Version, f.UpdatedAt = v.Version, v.CreatedAt
+	return s.flowToResponse(f), nil
+}
+
+// RollbackFlow publishes an earlier version of a flow again as its next version. The draft is
+// kept.
+func (s *FlowService) RollbackFlow(ctx context.Context, id string, req flow.RollbackFlowRequest) (*flow.FlowResponse, error) {
+	f, err := s.repo.GetFlowByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	old, err := s.flowVersion(ctx, id, req.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Older versions may predate checks that published versions must pass now
+	f.Nodes, f.Edges, f.Variables = old.Nodes, old.Edges, old.Variables
+	if err := validateDefinition(f); err != nil {
+		return nil, fmt.Errorf("version %d can't be published again: %w", old.Version, err)
+	}
+
+	note := req.Note
+	if note == "" {
+		note = fmt.Sprintf("Rollback to version %d", old.Version)
+	}
+	v := &flow.FlowVersion{FlowID: id, Nodes: f.Nodes, Edges: f.Edges, Variables: f.Variables, Note: note}
+	if err := s.repo.PublishFlowVersion(ctx, v, false); err != nil {
+		return nil, fmt.Errorf("failed to roll back flow: %w", err)
+	}
+	f.Version, f.UpdatedAt = v.Version, v.CreatedAt
+	return s.flowToResponse(f), nil
+}
+
+// DiscardDraft deletes the unpublished changes of a flow
+func (s *FlowService) DiscardDraft(ctx context.Context, id string) error {
+	return s.repo.DeleteFlowDraft(ctx, id)
+}
+
+// RunDraft runs the draft of a flow from its first trigger with the given input, and the published
+// version keeps serving triggers meanwhile. Unless live is set it is a dry run, which skips sending,
+// emailing, calling webhooks and writing to databases. Live runs do all of that and are recorded
+// with version 0.
+func (s *FlowService) RunDraft(ctx context.Context, id string, input map[string]interface{}, live bool) (*flow.DraftRunResponse, error) {
+	if s.executor == nil {
+		return nil, fmt.Errorf("flow executor not initialized")
+	}
+	f, err := s.repo.GetFlowByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("flow not found: %w", err)
+	}
+	draft, err := s.repo.GetFlowDraft(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+	if draft == nil {
+		return nil, ErrNoDraft
+	}
+	f.Nodes, f.Edges, f.Variables, f.Version = draft.Nodes, draft.Edges, draft.Variables, 0
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+
+	if !live {
+		result, err := s.executor.DryRun(ctx, f, "", input, "")
+		if err != nil {
+			return nil, err
+		}
+		return &flow.DraftRunResponse{Output: result.Output, DryRun: true, Stubbed: stubbedNodes(result.Trace)}, nil
+	}
+	result, err := s.executor.Run(ctx, f, "", input)
+	if err != nil {
+		return nil, err
+	}
+	return &flow.DraftRunResponse{ExecutionID: result.ExecutionID, Output: result.Output, Waiting: result.Waiting}, nil
+}
+
+// GetFlowVersions lists the published versions of a flow, newest first
+func (s *FlowService) GetFlowVersions(ctx context.Context, id string) ([]*flow.FlowVersion, error) {
+	if _, err := s.repo.GetFlowByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetFlowVersions(ctx, id)
+}
+
+// GetFlowVersion returns a published version of a flow with its definition, webhook signing
+// secrets masked
+func (s *FlowService) GetFlowVersion(ctx context.Context, id string, version int) (*flow.FlowVersion, error) {
+	v, err := s.flowVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	v.Nodes = maskWebhookSecrets(v.Nodes)
+	return v, nil
+}
+
+// flowVersion returns a published version of a flow as stored
+func (s *FlowService) flowVersion(ctx context.Context, id string, version int) (*flow.FlowVersion, error) {
+	v, err := s.repo.GetFlowVersion(ctx, id, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+	}
+	return v, err
+}
+
+// DiffFlowVersions lists the changes from one definition of a flow to another, each named by its
+// version number or as draft
+func (s *FlowService) DiffFlowVersions(ctx context.Context, id, from, to string) (*flow.FlowDiff, error) {
+	fromDef, err := s.definition(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toDef, err := s.definition(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := diffDefinitions(fromDef, toDef)
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+// definition returns the draft of a flow for ref draft, or the version ref numbers
+func (s *FlowService) definition(ctx context.Context, id, ref string) (*flow.FlowDraft, error) {
+	if ref == draftRef {
+		draft, err := s.repo.GetFlowDraft(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get draft: %w", err)
+		}
+		if draft == nil {
+			return nil, ErrNoDraft
+		}
+		return draft, nil
+	}
+	version, err := strconv.Atoi(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not a version number or %s", ErrVersionNotFound, ref, draftRef)
+	}
+	v, err := s.flowVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	return &flow.FlowDraft{Nodes: v.Nodes, Edges: v.Edges, Variables: v.Variables, UpdatedAt: v.CreatedAt}, nil
+}
+
+// diffDefinitions compares nodes by ID and edges by their ends, handles and label; edge IDs are
+// generated by the editor and do not change what a flow does
+func diffDefinitions(from, to *flow.FlowDraft) *flow.FlowDiff {
+	diff := &flow.FlowDiff{
+		AddedNodes:   []flow.Node{},
+		RemovedNodes: []flow.Node{},
+		ChangedNodes: []flow.NodeChange{},
+		AddedEdges:   []flow.Edge{},
+		RemovedEdges: []flow.Edge{},
+	}
+
+	fromNodes := make(map[string]flow.Node, len(from.Nodes))
+	for _, node := range from.Nodes {
+		fromNodes[node.ID] = node
+	}
+	toNodes := make(map[string]bool, len(to.Nodes))
+	for _, node := range to.Nodes {
+		toNodes[node.ID] = true
+		old, ok := fromNodes[node.ID]
+		if !ok {
+			diff.AddedNodes = append(diff.AddedNodes, node)
+			continue
+		}
+		if fields := changedFields(old, node); len(fields) > 0 {
+			diff.ChangedNodes = append(diff.ChangedNodes, flow.NodeChange{NodeID: node.ID, Label: node.Label, Fields: fields})
+		}
+	}
+	for _, node := range from.Nodes {
+		if !toNodes[node.ID] {
+			diff.RemovedNodes = append(diff.RemovedNodes, node)
+		}
+	}
+
+	edgeKey := func(e flow.Edge) flow.Edge {
+		e.ID = ""
+		return e
+	}
+	fromEdges := make(map[flow.Edge]bool, len(from.Edges))
+	for _, e := range from.Edges {
+		fromEdges[edgeKey(e)] = true
+	}
+	toEdges := make(map[flow.Edge]bool, len(to.Edges))
+	for _, e := range to.Edges {
+		toEdges[edgeKey(e)] = true
+		if !fromEdges[edgeKey(e)] {
+			diff.AddedEdges = append(diff.AddedEdges, e)
+		}
+	}
+	for _, e := range from.Edges {
+		if !toEdges[edgeKey(e)] {
+			diff.RemovedEdges = append(diff.RemovedEdges, e)
+		}
+	}
+
+	diff.Variables = !sameJSON(nonNilVariables(from.Variables), nonNilVariables(to.Variables))
+	return diff
+}
+
+// changedFields lists the fields that differ between two definitions of a node
+func changedFields(from, to flow.Node) []string {
+	var fields []string
+	if from.Type != to.Type {
+		fields = append(fields, "type")
+	}
+	if from.Label != to.Label {
+		fields = append(fields, "label")
+	}
+	if from.Position != to.Position {
+		fields = append(fields, "position")
+	}
+	var keys []string
+	for key, value := range to.Data {
+		if old, ok := from.Data[key]; !ok || !sameJSON(old, value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range from.Data {
+		if _, ok := to.Data[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fields = append(fields, "data."+key)
+	}
+	return fields
+}
+
+// sameJSON reports whether two values encode to the same JSON, so numbers compare equal whatever
+// their Go type
+func sameJSON(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
+
+func nonNilVariables(variables []flow.Variable) []flow.Variable {
+	if variables == nil {
+		return []flow.Variable{}
+	}
+	return variables
+}
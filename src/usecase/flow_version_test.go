@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+)
+
+func TestFlowVersions(t *testing.T) {
+	repo, err := flowRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	service := NewFlowService(repo)
+	service.SetExecutor(flowRepo.NewFlowExecutor(repo))
+	ctx := context.Background()
+
+	trigger := flow.Node{ID: "start", Type: flow.NodeTypeTriggerWebhook}
+	setStatus := func(value string) []flow.Node {
+		return []flow.Node{
+			trigger,
+			{ID: "status", Type: flow.NodeTypeSetVariable, Label: "Status", Data: map[string]interface{}{"name": "status", "value": value}},
+		}
+	}
+	edges := []flow.Edge{{ID: "e1", Source: "start", Target: "status"}}
+	f, err := service.CreateFlow(ctx, flow.CreateFlowRequest{AgentID: "agent-1", Name: "orders", Nodes: setStatus("v1"), Edges: edges})
+	if err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+	if f.Version != 1 {
+		t.Fatalf("CreateFlow() version = %d, want 1", f.Version)
+	}
+	trigger = f.Nodes[0] // With its webhook token
+	output := func() string {
+		t.Helper()
+		out, err := service.ExecuteFlow(ctx, f.ID, nil)
+		if err != nil {
+			t.Fatalf("ExecuteFlow() error = %v", err)
+		}
+		status, _ := out["status"].(string)
+		return status
+	}
+
+	// Saving changes the draft only
+	if _, err := service.UpdateFlow(ctx, f.ID, flow.UpdateFlowRequest{Nodes: setStatus("v2")}); err != nil {
+		t.Fatalf("UpdateFlow() error = %v", err)
+	}
+	if got := output(); got != "v1" {
+		t.Fatalf("published flow ran with status %q after saving a draft, want v1", got)
+	}
+	got, err := service.GetFlow(ctx, f.ID)
+	if err != nil || got.Draft == nil || got.Draft.Nodes[1].Data["value"] != "v2" {
+		t.Fatalf("GetFlow() draft = %+v, %v", got.Draft, err)
+	}
+
+	// Draft runs are dry unless asked to be live
+	draftRun, err := service.RunDraft(ctx, f.ID, nil, false)
+	if err != nil || draftRun.Output["status"] != "v2" || !draftRun.DryRun || draftRun.ExecutionID != "" {
+		t.Fatalf("RunDraft() = %+v, %v; want a dry run", draftRun, err)
+	}
+	draftRun, err = service.RunDraft(ctx, f.ID, nil, true)
+	if err != nil || draftRun.Output["status"] != "v2" || draftRun.DryRun {
+		t.Fatalf("RunDraft(live) = %+v, %v", draftRun, err)
+	}
+	if execution, err := service.GetExecution(ctx, f.ID, draftRun.ExecutionID); err != nil || execution.FlowVersion != 0 {
+		t.Fatalf("draft execution = %+v, %v; want version 0", execution, err)
+	}
+
+	diff, err := service.DiffFlowVersions(ctx, f.ID, "1", "draft")
+	if err != nil {
+		t.Fatalf("DiffFlowVersions() error = %v", err)
+	}
+	wantChange := []flow.NodeChange{{NodeID: "status", Label: "Status", Fields: []string{"data.value"}}}
+	if !reflect.DeepEqual(diff.ChangedNodes, wantChange) || len(diff.AddedNodes)+len(diff.RemovedNodes)+len(diff.AddedEdges)+len(diff.RemovedEdges) != 0 || diff.Variables {
+		t.Fatalf("DiffFlowVersions() = %+v", diff)
+	}
+
+	published, err := service.PublishFlow(ctx, f.ID, flow.PublishFlowRequest{Note: "New status"})
+	if err != nil || published.Version != 2 {
+		t.Fatalf("PublishFlow() = %+v, %v; want version 2", published, err)
+	}
+	if got := output(); got != "v2" {
+		t.Fatalf("flow ran with status %q after publishing, want v2", got)
+	}
+	if _, err := service.PublishFlow(ctx, f.ID, flow.PublishFlowRequest{}); !errors.Is(err, ErrNoDraft) {
+		t.Fatalf("PublishFlow() without a draft error = %v, want ErrNoDraft", err)
+	}
+
+	rolledBack, err := service.RollbackFlow(ctx, f.ID, flow.RollbackFlowRequest{Version: 1})
+	if err != nil || rolledBack.Version != 3 {
+		t.Fatalf("RollbackFlow() = %+v, %v; want version 3", rolledBack, err)
+	}
+	if got := output(); got != "v1" {
+		t.Fatalf("flow ran with status %q after rolling back, want v1", got)
+	}
+	if _, err := service.RollbackFlow(ctx, f.ID, flow.RollbackFlowRequest{Version: 9}); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("RollbackFlow() to a missing version error = %v, want ErrVersionNotFound", err)
+	}
+
+	versions, err := service.GetFlowVersions(ctx, f.ID)
+	if err != nil || len(versions) != 3 || versions[0].Version != 3 || versions[0].Note != "Rollback to version 1" || versions[1].Note != "New status" {
+		t.Fatalf("GetFlowVersions() = %+v, %v", versions, err)
+	}
+	list, err := service.ListExecutions(ctx, flow.ExecutionFilter{FlowID: f.ID})
+	if err != nil || list.Executions[0].FlowVersion != 3 {
+		t.Fatalf("ListExecutions() = %+v, %v; want the last run on version 3", list, err)
+	}
+}
+
+func TestRollbackFlowValidatesOldVersion(t *testing.T) {
+	repo, err := flowRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	service := NewFlowService(repo)
+	ctx := context.Background()
+
+	nodes := []flow.Node{
+		{ID: "start", Type: flow.NodeTypeTriggerWebhook},
+		{ID: "status", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "status", "value": "ok"}},
+	}
+	edges := []flow.Edge{{ID: "e1", Source: "start", Target: "status"}}
+	f, err := service.CreateFlow(ctx, flow.CreateFlowRequest{AgentID: "agent-1", Name: "orders", Nodes: nodes, Edges: edges})
+	if err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+
+	// A version saved before expressions were checked, followed by a valid one
+	invalid := []flow.Node{
+		f.Nodes[0],
+		{ID: "status", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "status", "value": "{{ total + }}"}},
+	}
+	for _, v := range []*flow.FlowVersion{
+		{FlowID: f.ID, Nodes: invalid, Edges: edges},
+		{FlowID: f.ID, Nodes: f.Nodes, Edges: edges},
+	} {
+		if err := repo.PublishFlowVersion(ctx, v, false); err != nil {
+			t.Fatalf("PublishFlowVersion() error = %v", err)
+		}
+	}
+
+	if _, err := service.RollbackFlow(ctx, f.ID, flow.RollbackFlowRequest{Version: 2}); err == nil {
+		t.Fatalf("RollbackFlow() to an invalid version succeeded")
+	}
+	versions, err := service.GetFlowVersions(ctx, f.ID)
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("GetFlowVersions() = %d versions, %v; want 3", len(versions), err)
+	}
+}
+
+func TestDiffDefinitions(t *testing.T) {
+	from := &flow.FlowDraft{
+		Nodes: []flow.Node{
+			{ID: "a", Type: flow.NodeTypeTriggerWebhook},
+			{ID: "b", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "x", "value": float64(1)}},
+			{ID: "c", Type: flow.NodeTypeSetVariable},
+		},
+		Edges: []flow.Edge{{ID: "e1", Source: "a", Target: "b"}, {ID: "e2", Source: "b", Target: "c"}},
+	}
+	to := &flow.FlowDraft{
+		Nodes: []flow.Node{
+			{ID: "a", Type: flow.NodeTypeTriggerWebhook, Position: flow.Position{X: 10}},
+			{ID: "b", Type: flow.NodeTypeSetVariable, Label: "B", Data: map[string]interface{}{"value": 1, "extra": true}},
+			{ID: "d", Type: flow.NodeTypeSetVariable},
+		},
+		Edges:     []flow.Edge{{ID: "new-id", Source: "a", Target: "b"}, {ID: "e3", Source: "b", Target: "d"}},
+		Variables: []flow.Variable{{Name: "x", Value: 1, Type: "number"}},
+	}
+
+	diff := diffDefinitions(from, to)
+	wantChanged := []flow.NodeChange{
+		{NodeID: "a", Fields: []string{"position"}},
+		{NodeID: "b", Label: "B", Fields: []string{"label", "data.extra", "data.name"}},
+	}
+	if !reflect.DeepEqual(diff.ChangedNodes, wantChanged) {
+		t.Errorf("ChangedNodes = %+v, want %+v", diff.ChangedNodes, wantChanged)
+	}
+	if len(diff.AddedNodes) != 1 || diff.AddedNodes[0].ID != "d" || len(diff.RemovedNodes) != 1 || diff.RemovedNodes[0].ID != "c" {
+		t.Errorf("AddedNodes = %+v, RemovedNodes = %+v", diff.AddedNodes, diff.RemovedNodes)
+	}
+	if len(diff.AddedEdges) != 1 || diff.AddedEdges[0].ID != "e3" || len(diff.RemovedEdges) != 1 || diff.RemovedEdges[0].ID != "e2" {
+		t.Errorf("AddedEdges = %+v, RemovedEdges = %+v", diff.AddedEdges, diff.RemovedEdges)
+	}
+	if !diff.Variables {
+		t.Errorf("Variables = false, want true")
+	}
+}
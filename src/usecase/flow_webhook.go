@@ -0,0 +1,248 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrWebhookNotFound is returned when no active webhook trigger matches the flow and token
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrInvalidSignature is returned when a signed webhook request has a missing or wrong signature
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// FlowWebhookRequest is an HTTP request to a flow's webhook trigger
+type FlowWebhookRequest struct {
+	FlowID  string
+	Token   string
+	Body    []byte                 // Raw body, for signature verification
+	Payload interface{}            // Parsed JSON or form body; nil when empty
+	Query   map[string]interface{} // Query parameters
+	Headers map[string]string      // Request headers, lower-cased names
+}
+
+// input is what the flow receives as its initial variables: query parameters and the fields of an
+// object body at the top level (body fields win), plus the body, query and headers as they came
+func (r FlowWebhookRequest) input() map[string]interface{} {
+	input := make(map[string]interface{}, len(r.Query)+3)
+	for k, v := range r.Query {
+		input[k] = v
+	}
+	if fields, ok := r.Payload.(map[string]interface{}); ok {
+		for k, v := range fields {
+			input[k] = v
+		}
+	}
+	input["body"] = r.Payload
+	input["query"] = r.Query
+	input["headers"] = r.Headers
+	return input
+}
+
+// FlowWebhookResult is what a webhook call responds with
+type FlowWebhookResult struct {
+	Mode        string      // One of the flow.WebhookResponse* modes
+	ExecutionID string      // Empty in async mode
+	Output      interface{} // The flow's output, or its response variable in output mode
+}
+
+// generateWebhookToken returns a random token for a webhook URL
+func generateWebhookToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ensureWebhookTokens gives every webhook trigger without a token a new one
+func ensureWebhookTokens(nodes []flow.Node) error {
+	for i := range nodes {
+		if nodes[i].Type != flow.NodeTypeTriggerWebhook {
+			continue
+		}
+		if token, _ := nodes[i].Data["webhook_token"].(string); token != "" {
+			continue
+		}
+		token, err := generateWebhookToken()
+		if err != nil {
+			return fmt.Errorf("failed to generate webhook token: %w", err)
+		}
+		if nodes[i].Data == nil {
+			nodes[i].Data = map[string]interface{}{}
+		}
+		nodes[i].Data["webhook_token"] = token
+	}
+	return nil
+}
+
+// maskedWebhookSecret replaces signing secrets in flow responses. The editor sends it back
+// unchanged to keep the stored secret.
+const maskedWebhookSecret = "********"
+
+// maskWebhookSecrets returns nodes with the signing secrets of webhook triggers masked, leaving
+// nodes itself untouched
+func maskWebhookSecrets(nodes []flow.Node) []flow.Node {
+	if nodes == nil {
+		return nil
+	}
+	masked := make([]flow.Node, len(nodes))
+	copy(masked, nodes)
+	for i := range masked {
+		if masked[i].Type != flow.NodeTypeTriggerWebhook {
+			continue
+		}
+		if secret, _ := masked[i].Data["webhook_secret"].(string); secret == "" {
+			continue
+		}
+		data := make(map[string]interface{}, len(masked[i].Data))
+		for k, v := range masked[i].Data {
+			data[k] = v
+		}
+		data["webhook_secret"] = maskedWebhookSecret
+		masked[i].Data = data
+	}
+	return masked
+}
+
+// restoreWebhookSecrets puts the stored secrets back into webhook triggers that still carry the
+// mask, matching nodes by ID
+func restoreWebhookSecrets(nodes, stored []flow.Node) error {
+	for i := range nodes {
+		if nodes[i].Type != flow.NodeTypeTriggerWebhook {
+			continue
+		}
+		if secret, _ := nodes[i].Data["webhook_secret"].(string); secret != maskedWebhookSecret {
+			continue
+		}
+		restored := ""
+		for _, old := range stored {
+			if old.ID == nodes[i].ID && old.Type == flow.NodeTypeTriggerWebhook {
+				restored, _ = old.Data["webhook_secret"].(string)
+				break
+			}
+		}
+		if restored == "" {
+			return fmt.Errorf("%w: webhook trigger %q has a masked signing secret, enter it again", ErrInvalidFlow, nodes[i].Label)
+		}
+		nodes[i].Data["webhook_secret"] = restored
+	}
+	return nil
+}
+
+// findWebhookTrigger returns the webhook trigger of f with the given token
+func findWebhookTrigger(f *flow.Flow, token string) (*flow.Node, flow.TriggerNodeData, bool) {
+	for i := range f.Nodes {
+		if f.Nodes[i].Type != flow.NodeTypeTriggerWebhook {
+			continue
+		}
+		data := triggerNodeData(f.Nodes[i])
+		if data.WebhookToken != "" && subtle.ConstantTimeCompare([]byte(data.WebhookToken), []byte(token)) == 1 {
+			return &f.Nodes[i], data, true
+		}
+	}
+	return nil, flow.TriggerNodeData{}, false
+}
+
+// verifyWebhookSignature checks the hex HMAC-SHA256 of body in the trigger's signature header,
+// optionally prefixed with "sha256=" as GitHub and most payment providers send it
+func verifyWebhookSignature(data flow.TriggerNodeData, body []byte, headers map[string]string) error {
+	if data.WebhookSecret == "" {
+		return nil
+	}
+	header := data.SignatureHeader
+	if header == "" {
+		header = flow.DefaultSignatureHeader
+	}
+	signature := strings.TrimSpace(headers[strings.ToLower(header)])
+	signature = strings.ToLower(strings.TrimPrefix(signature, "sha256="))
+	if signature == "" {
+		return ErrInvalidSignature
+	}
+
+	expected, err := utils.GetMessageDigestOrSignature(body, []byte(data.WebhookSecret))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// webhookResponseMode returns the trigger's response mode, async when unset or unknown
+func webhookResponseMode(data flow.TriggerNodeData) string {
+	switch data.ResponseMode {
+	case flow.WebhookResponseSync, flow.WebhookResponseOutput:
+		return data.ResponseMode
+	default:
+		return flow.WebhookResponseAsync
+	}
+}
+
+// RunWebhook runs an active flow from the webhook trigger holding req.Token. In async mode the flow
+// runs in the background and its errors are only logged.
+func (s *FlowService) RunWebhook(ctx context.Context, req FlowWebhookRequest) (*FlowWebhookResult, error) {
+	if s.executor == nil {
+		return nil, fmt.Errorf("flow executor not initialized")
+	}
+	f, err := s.repo.GetFlowByID(ctx, req.FlowID)
+	if err != nil || !f.IsActive {
+		return nil, ErrWebhookNotFound
+	}
+	trigger, data, ok := findWebhookTrigger(f, req.Token)
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+	if err := verifyWebhookSignature(data, req.Body, req.Headers); err != nil {
+		logrus.Warnf("⚠️  [Flow] Rejected webhook call to flow %s (%s): %v", f.ID, f.Name, err)
+		return nil, err
+	}
+
+	mode := webhookResponseMode(data)
+	logrus.Infof("🔀 [Flow] Webhook triggered flow %s (%s), mode: %s", f.ID, f.Name, mode)
+
+	if mode == flow.WebhookResponseAsync {
+		go func() {
+			if _, err := s.executor.Run(context.Background(), f, trigger.ID, req.input()); err != nil {
+				logrus.Warnf("⚠️  [Flow] Flow %s (%s) failed on webhook call: %v", f.ID, f.Name, err)
+			}
+		}()
+		return &FlowWebhookResult{Mode: mode}, nil
+	}
+
+	run, err := s.executor.Run(ctx, f, trigger.ID, req.input())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FlowWebhookResult{Mode: mode, ExecutionID: run.ExecutionID, Output: run.Output}
+	if mode == flow.WebhookResponseOutput && data.ResponseVariable != "" {
+		result.Output = lookupPath(run.Output, data.ResponseVariable)
+	}
+	return result, nil
+}
+
+// lookupPath returns the value at a dotted path such as "order.status" in data
+func lookupPath(data map[string]interface{}, path string) interface{} {
+	var current interface{} = data
+	for _, part := range strings.Split(strings.Trim(path, "{} "), ".") {
+		fields, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = fields[part]
+	}
+	return current
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/flow"
+	flowRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/flow"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"order_id":"A1"}`)
+	signature, _ := utils.GetMessageDigestOrSignature(body, []byte("s3cret"))
+	data := flow.TriggerNodeData{WebhookSecret: "s3cret", SignatureHeader: "X-Hub-Signature-256"}
+
+	tests := []struct {
+		name    string
+		data    flow.TriggerNodeData
+		headers map[string]string
+		wantErr bool
+	}{
+		{name: "Unsigned", data: flow.TriggerNodeData{}, headers: map[string]string{}},
+		{name: "Valid", data: data, headers: map[string]string{"x-hub-signature-256": signature}},
+		{name: "Prefixed", data: data, headers: map[string]string{"x-hub-signature-256": "sha256=" + signature}},
+		{name: "DefaultHeader", data: flow.TriggerNodeData{WebhookSecret: "s3cret"}, headers: map[string]string{"x-signature": signature}},
+		{name: "Missing", data: data, headers: map[string]string{}, wantErr: true},
+		{name: "Wrong", data: data, headers: map[string]string{"x-hub-signature-256": "sha256=00"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyWebhookSignature(tt.data, body, tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyWebhookSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunWebhook(t *testing.T) {
+	repo, err := flowRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	service := NewFlowService(repo)
+	service.SetExecutor(flowRepo.NewFlowExecutor(repo))
+	ctx := context.Background()
+
+	created, err := service.CreateFlow(ctx, flow.CreateFlowRequest{
+		AgentID: "agent-1",
+		Name:    "payments",
+		Nodes: []flow.Node{
+			{ID: "hook", Type: flow.NodeTypeTriggerWebhook, Data: map[string]interface{}{"response_mode": "output", "response_variable": "status"}},
+			{ID: "status", Type: flow.NodeTypeSetVariable, Data: map[string]interface{}{"name": "status", "value": "paid {{order_id}} via {{source}}"}},
+		},
+		Edges: []flow.Edge{{ID: "e1", Source: "hook", Target: "status"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+	token, _ := created.Nodes[0].Data["webhook_token"].(string)
+	if len(token) != 32 {
+		t.Fatalf("webhook_token = %q, want a generated token", token)
+	}
+
+	req := FlowWebhookRequest{
+		FlowID:  created.ID,
+		Token:   token,
+		Payload: map[string]interface{}{"order_id": "A1"},
+		Query:   map[string]interface{}{"source": "stripe", "order_id": "ignored"},
+	}
+	result, err := service.RunWebhook(ctx, req)
+	if err != nil {
+		t.Fatalf("RunWebhook() error = %v", err)
+	}
+	if result.Mode != flow.WebhookResponseOutput || result.Output != "paid A1 via stripe" || result.ExecutionID == "" {
+		t.Fatalf("RunWebhook() = %+v", result)
+	}
+
+	req.Token = "wrong"
+	if _, err := service.RunWebhook(ctx, req); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("RunWebhook() with a wrong token error = %v, want ErrWebhookNotFound", err)
+	}
+
+	// Saving again keeps the token
+	updated, err := service.UpdateFlow(ctx, created.ID, flow.UpdateFlowRequest{Nodes: created.Nodes})
+	if err != nil {
+		t.Fatalf("UpdateFlow() error = %v", err)
+	}
+	if got := updated.Nodes[0].Data["webhook_token"]; got != token {
+		t.Fatalf("webhook_token after update = %v, want %s", got, token)
+	}
+}
+
+func TestWebhookSecretIsMasked(t *testing.T) {
+	repo, err := flowRepo.NewSQLiteRepository(filepath.Join(t.TempDir(), "flows.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	service := NewFlowService(repo)
+	ctx := context.Background()
+
+	created, err := service.CreateFlow(ctx, flow.CreateFlowRequest{
+		AgentID: "agent-1",
+		Name:    "payments",
+		Nodes:   []flow.Node{{ID: "hook", Type: flow.NodeTypeTriggerWebhook, Data: map[string]interface{}{"webhook_secret": "s3cret"}}},
+	})
+	if err != nil {
+		t.Fatalf("CreateFlow() error = %v", err)
+	}
+	if secret := created.Nodes[0].Data["webhook_secret"]; secret != maskedWebhookSecret {
+		t.Fatalf("CreateFlow() webhook_secret = %v, want it masked", secret)
+	}
+
+	got, err := service.GetFlow(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetFlow() error = %v", err)
+	}
+	if secret := got.Nodes[0].Data["webhook_secret"]; secret != maskedWebhookSecret {
+		t.Fatalf("GetFlow() webhook_secret = %v, want it masked", secret)
+	}
+
+	// Saving the flow as the editor got it keeps the stored secret
+	got.Nodes[0].Label = "Payment hook"
+	updated, err := service.UpdateFlow(ctx, created.ID, flow.UpdateFlowRequest{Nodes: got.Nodes})
+	if err != nil {
+		t.Fatalf("UpdateFlow() error = %v", err)
+	}
+	if secret := updated.Draft.Nodes[0].Data["webhook_secret"]; secret != maskedWebhookSecret {
+		t.Fatalf("UpdateFlow() draft webhook_secret = %v, want it masked", secret)
+	}
+	draft, err := repo.GetFlowDraft(ctx, created.ID)
+	if err != nil || draft == nil {
+		t.Fatalf("GetFlowDraft() = %v, %v", draft, err)
+	}
+	if secret := draft.Nodes[0].Data["webhook_secret"]; secret != "s3cret" {
+		t.Fatalf("stored draft webhook_secret = %v, want s3cret", secret)
+	}
+
+	version, err := service.GetFlowVersion(ctx, created.ID, 1)
+	if err != nil {
+		t.Fatalf("GetFlowVersion() error = %v", err)
+	}
+	if secret := version.Nodes[0].Data["webhook_secret"]; secret != maskedWebhookSecret {
+		t.Fatalf("GetFlowVersion() webhook_secret = %v, want it masked", secret)
+	}
+
+	// A mask with no stored secret behind it can't be saved
+	_, err = service.CreateFlow(ctx, flow.CreateFlowRequest{
+		AgentID: "agent-1",
+		Name:    "copy",
+		Nodes:   []flow.Node{{ID: "hook", Type: flow.NodeTypeTriggerWebhook, Data: map[string]interface{}{"webhook_secret": maskedWebhookSecret}}},
+	})
+	if !errors.Is(err, ErrInvalidFlow) {
+		t.Fatalf("CreateFlow() with a masked secret error = %v, want ErrInvalidFlow", err)
+	}
+}
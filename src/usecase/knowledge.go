@@ -6,8 +6,9 @@ import (
 	"strings"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/knowledge"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
 	knowledgeRepo "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/knowledge"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
 )
 
 type KnowledgeService struct {
@@ -22,7 +23,23 @@ func NewKnowledgeService(repo *knowledgeRepo.SQLiteRepository, agentService *Age
 	}
 }
 
+// checkEmbeddingProvider rejects providers that can't embed documents and queries
+func checkEmbeddingProvider(provider string) error {
+	if !aiService.SupportsEmbeddings(provider) {
+		return fmt.Errorf("the %s provider cannot create embeddings, so it can't be used with a knowledge base", provider)
+	}
+	return nil
+}
+
 func (s *KnowledgeService) UploadDocument(ctx context.Context, req knowledge.CreateDocumentRequest) (*knowledge.Document, error) {
+	agent, err := s.agentService.GetAgentInternal(ctx, req.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEmbeddingProvider(agent.Provider); err != nil {
+		return nil, err
+	}
+
 	doc := &knowledge.Document{
 		AgentID: req.AgentID,
 		Name:    req.Name,
@@ -55,22 +72,18 @@ func (s *KnowledgeService) processDocument(ctx context.Context, doc *knowledge.D
 	// Split content into chunks (simple chunking by paragraphs)
 	chunks := s.splitIntoChunks(doc.Content, 500) // ~500 tokens per chunk
 
-	// Create OpenAI client for embeddings
-	client := openai.NewClient(agent.APIKey)
+	// Use the agent's provider for embeddings
+	aiSvc := aiService.NewServiceForAgent(agent)
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{AgentID: agent.ID})
 
 	for _, chunkContent := range chunks {
-		// Get embedding from OpenAI
-		embResp, err := client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-			Model: openai.AdaEmbeddingV2,
-			Input: []string{chunkContent},
-		})
-
+		// Get embedding from the provider
 		var embedding []float64
-		if err == nil && len(embResp.Data) > 0 {
-			embedding = make([]float64, len(embResp.Data[0].Embedding))
-			for i, v := range embResp.Data[0].Embedding {
-				embedding[i] = float64(v)
-			}
+		embeddings, err := aiSvc.CreateEmbeddings(ctx, []string{chunkContent})
+		if err == nil {
+			embedding = embeddings[0]
+		} else {
+			logrus.Warnf("⚠️ [KnowledgeService] Failed to embed chunk of document %s: %v", doc.ID, err)
 		}
 
 		chunk := &knowledge.Chunk{
@@ -151,23 +164,12 @@ func (s *KnowledgeService) Search(ctx context.Context, req knowledge.SearchReque
 	}
 
 	// Get query embedding
-	client := openai.NewClient(agent.APIKey)
-	embResp, err := client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-		Model: openai.AdaEmbeddingV2,
-		Input: []string{req.Query},
-	})
+	ctx = aiService.WithUsageScope(ctx, aiService.UsageScope{AgentID: agent.ID})
+	embeddings, err := aiService.NewServiceForAgent(agent).CreateEmbeddings(ctx, []string{req.Query})
 	if err != nil {
 		return nil, err
 	}
-
-	if len(embResp.Data) == 0 {
-		return nil, fmt.Errorf("no embedding returned")
-	}
-
-	queryEmbedding := make([]float64, len(embResp.Data[0].Embedding))
-	for i, v := range embResp.Data[0].Embedding {
-		queryEmbedding[i] = float64(v)
-	}
+	queryEmbedding := embeddings[0]
 
 	topK := req.TopK
 	if topK <= 0 {
@@ -178,32 +180,77 @@ func (s *KnowledgeService) Search(ctx context.Context, req knowledge.SearchReque
 }
 
 func (s *KnowledgeService) GetRelevantContext(ctx context.Context, agentID string, query string) (string, error) {
-	results, err := s.Search(ctx, knowledge.SearchRequest{
-		AgentID: agentID,
-		Query:   query,
-		TopK:    3,
+	relevant, err := s.RetrieveContext(ctx, knowledge.ContextRequest{
+		AgentID:  agentID,
+		Query:    query,
+		TopK:     3,
+		MinScore: 0.7,
 	})
 	if err != nil {
 		return "", err
 	}
+	return relevant.Text, nil
+}
+
+// RetrieveContext searches the agent's knowledge base and formats the chunks above the score
+// threshold, with their source document, within the token budget
+func (s *KnowledgeService) RetrieveContext(ctx context.Context, req knowledge.ContextRequest) (*knowledge.RelevantContext, error) {
+	// Skip the embedding call for agents without any processed documents
+	docs, err := s.repo.GetDocumentsByAgentID(ctx, req.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	hasReady := false
+	for _, doc := range docs {
+		if doc.Status == "ready" {
+			hasReady = true
+			break
+		}
+	}
+	if !hasReady {
+		return &knowledge.RelevantContext{}, nil
+	}
 
-	if len(results) == 0 {
-		return "", nil
+	topK := req.TopK
+	if topK <= 0 {
+		topK = 3
 	}
+	results, err := s.Search(ctx, knowledge.SearchRequest{
+		AgentID: req.AgentID,
+		Query:   req.Query,
+		TopK:    topK,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buildRelevantContext(results, req.MinScore, req.MaxTokens), nil
+}
+
+// buildRelevantContext formats search results for the prompt, numbering each chunk with its
+// source document. Chunks below minScore or past the token budget are left out.
+func buildRelevantContext(results []knowledge.SearchResult, minScore float64, maxTokens int) *knowledge.RelevantContext {
+	relevant := &knowledge.RelevantContext{}
 
 	var contextBuilder strings.Builder
-	contextBuilder.WriteString("Relevant information from knowledge base:\n\n")
-	
-	for i, result := range results {
-		if result.Score < 0.7 { // Only include relevant results
+	used := 0
+	for _, result := range results {
+		if result.Score < minScore {
 			continue
 		}
-		contextBuilder.WriteString(fmt.Sprintf("--- From %s ---\n%s\n\n", result.DocName, result.Content))
-		if i >= 2 { // Max 3 chunks
-			break
+		entry := fmt.Sprintf("[%d] Source: %s\n%s\n\n", len(relevant.Sources)+1, result.DocName, strings.TrimSpace(result.Content))
+		cost := aiService.EstimateTokens(entry)
+		if maxTokens > 0 && used+cost > maxTokens {
+			continue
 		}
+		used += cost
+		contextBuilder.WriteString(entry)
+		relevant.Sources = append(relevant.Sources, result)
 	}
 
-	return contextBuilder.String(), nil
+	if len(relevant.Sources) == 0 {
+		return relevant
+	}
+	relevant.Text = "Relevant information from knowledge base (mention the source when you rely on it):\n\n" + strings.TrimSpace(contextBuilder.String())
+	return relevant
 }
-
@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/knowledge"
+)
+
+func TestBuildRelevantContext(t *testing.T) {
+	results := []knowledge.SearchResult{
+		{ChunkID: "c1", DocName: "pricing.pdf", Content: "Basic plan costs $10.", Score: 0.92},
+		{ChunkID: "c2", DocName: "faq.md", Content: strings.Repeat("long answer ", 100), Score: 0.85},
+		{ChunkID: "c3", DocName: "faq.md", Content: "We ship worldwide.", Score: 0.8},
+		{ChunkID: "c4", DocName: "old.txt", Content: "Unrelated.", Score: 0.4},
+	}
+
+	tests := []struct {
+		name      string
+		minScore  float64
+		maxTokens int
+		want      []string
+	}{
+		{name: "Unlimited", minScore: 0.7, maxTokens: 0, want: []string{"c1", "c2", "c3"}},
+		{name: "BudgetSkipsLargeChunk", minScore: 0.7, maxTokens: 60, want: []string{"c1", "c3"}},
+		{name: "ThresholdFiltersAll", minScore: 0.95, maxTokens: 0, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildRelevantContext(results, tt.minScore, tt.maxTokens)
+			ids := got.ChunkIDs()
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("chunk IDs = %v, want %v", ids, tt.want)
+			}
+			if len(tt.want) == 0 && got.Text != "" {
+				t.Fatalf("expected empty text, got %q", got.Text)
+			}
+		})
+	}
+
+	got := buildRelevantContext(results, 0.7, 60)
+	if !strings.Contains(got.Text, "[1] Source: pricing.pdf") || !strings.Contains(got.Text, "[2] Source: faq.md") {
+		t.Fatalf("missing source attribution in %q", got.Text)
+	}
+}
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/notification"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// notificationWebhookEvent is the event name used when forwarding notifications to a webhook
+const notificationWebhookEvent = "agent.notification"
+
+type NotificationService struct {
+	repo       notification.INotificationRepository
+	httpClient *http.Client
+}
+
+func NewNotificationService(repo notification.INotificationRepository) *NotificationService {
+	return &NotificationService{
+		repo:       repo,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Create stores a notification and pushes it to connected websocket clients
+func (s *NotificationService) Create(ctx context.Context, notif *notification.Notification) error {
+	if notif.Type == "" {
+		notif.Type = notification.TypeInfo
+	}
+	if err := s.repo.Create(ctx, notif); err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	websocket.TryBroadcast(ctx, websocket.BroadcastMessage{
+		Code:    "NOTIFICATION",
+		Message: notif.Title,
+		Result:  notif,
+	}, time.Second)
+	return nil
+}
+
+// Notify creates a notification and, when webhookURL is set, forwards it there in the background
+func (s *NotificationService) Notify(ctx context.Context, notif *notification.Notification, webhookURL string) error {
+	if err := s.Create(ctx, notif); err != nil {
+		return err
+	}
+	if webhookURL != "" {
+		go func() {
+			if err := s.sendWebhook(context.Background(), webhookURL, notif); err != nil {
+				logrus.Warnf("⚠️  [Notification] Failed to forward notification %s to webhook: %v", notif.ID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// sendWebhook posts the notification signed like the WhatsApp webhooks (X-Hub-Signature-256)
+func (s *NotificationService) sendWebhook(ctx context.Context, url string, notif *notification.Notification) error {
+	body, err := json.Marshal(map[string]any{
+		"event":   notificationWebhookEvent,
+		"payload": notif,
+	})
+	if err != nil {
+		return err
+	}
+
+	signature, err := utils.GetMessageDigestOrSignature(body, []byte(config.WhatsappWebhookSecret))
+	if err != nil {
+		return fmt.Errorf("failed to sign webhook: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+signature)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *NotificationService) GetNotifications(ctx context.Context, userID string, limit int, unreadOnly bool) ([]*notification.Notification, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return s.repo.GetByUserID(ctx, userID, limit, unreadOnly)
+}
+
+func (s *NotificationService) MarkAsRead(ctx context.Context, id string) error {
+	return s.repo.MarkAsRead(ctx, id)
+}
+
+func (s *NotificationService) MarkAllAsRead(ctx context.Context, userID string) error {
+	return s.repo.MarkAllAsRead(ctx, userID)
+}
+
+func (s *NotificationService) GetUnreadCount(ctx context.Context, userID string) (int, error) {
+	return s.repo.GetUnreadCount(ctx, userID)
+}
@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/notification"
+)
+
+func TestNotificationSendWebhook(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Hub-Signature-256")
+	}))
+	defer server.Close()
+
+	service := NewNotificationService(nil)
+	notif := &notification.Notification{ID: "n1", Type: notification.TypeWarning, Title: "Negative sentiment detected"}
+	if err := service.sendWebhook(context.Background(), server.URL, notif); err != nil {
+		t.Fatalf("sendWebhook: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(config.WhatsappWebhookSecret))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+
+	var payload struct {
+		Event   string                    `json:"event"`
+		Payload notification.Notification `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if payload.Event != notificationWebhookEvent || payload.Payload.ID != "n1" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+}
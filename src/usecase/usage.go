@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/settings"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/usage"
+	aiService "github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/ai"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultModelPrices are list prices in USD used until a price is configured at runtime
+var defaultModelPrices = []usage.ModelPrice{
+	{Model: "gpt-4o", PromptPerMillion: 2.50, CompletionPerMillion: 10.00},
+	{Model: "gpt-4o-mini", PromptPerMillion: 0.15, CompletionPerMillion: 0.60},
+	{Model: "gpt-4-turbo", PromptPerMillion: 10.00, CompletionPerMillion: 30.00},
+	{Model: "gpt-4", PromptPerMillion: 30.00, CompletionPerMillion: 60.00},
+	{Model: "gpt-3.5-turbo", PromptPerMillion: 0.50, CompletionPerMillion: 1.50},
+	{Model: "o1", PromptPerMillion: 15.00, CompletionPerMillion: 60.00},
+	{Model: "o1-mini", PromptPerMillion: 3.00, CompletionPerMillion: 12.00},
+	{Model: "text-embedding-ada-002", PromptPerMillion: 0.10},
+	{Model: "text-embedding-3-small", PromptPerMillion: 0.02},
+	{Model: "text-embedding-3-large", PromptPerMillion: 0.13},
+	{Model: "whisper-1", AudioPerMinute: 0.006},
+	{Model: "tts-1", PromptPerMillion: 15.00}, // Speech is billed per character, recorded as prompt tokens
+	{Model: "tts-1-hd", PromptPerMillion: 30.00},
+	{Model: "claude-3-5-sonnet", PromptPerMillion: 3.00, CompletionPerMillion: 15.00},
+	{Model: "claude-3-5-haiku", PromptPerMillion: 0.80, CompletionPerMillion: 4.00},
+	{Model: "claude-3-opus", PromptPerMillion: 15.00, CompletionPerMillion: 75.00},
+	{Model: "claude-3-haiku", PromptPerMillion: 0.25, CompletionPerMillion: 1.25},
+}
+
+type UsageService struct {
+	repo usage.IUsageRepository
+
+	mu     sync.RWMutex
+	prices map[string]usage.ModelPrice // defaults overlaid with configured prices
+}
+
+func NewUsageService(repo usage.IUsageRepository) *UsageService {
+	s := &UsageService{repo: repo}
+	if err := s.reloadPrices(context.Background()); err != nil {
+		logrus.Warnf("⚠️  [Usage] Failed to load model prices, using defaults: %v", err)
+	}
+	return s
+}
+
+func (s *UsageService) reloadPrices(ctx context.Context) error {
+	prices := make(map[string]usage.ModelPrice, len(defaultModelPrices))
+	for _, p := range defaultModelPrices {
+		prices[p.Model] = p
+	}
+	configured, err := s.repo.GetPrices(ctx)
+	for _, p := range configured {
+		prices[p.Model] = p
+	}
+
+	s.mu.Lock()
+	s.prices = prices
+	s.mu.Unlock()
+	return err
+}
+
+// priceFor finds the price of a model: an exact entry, else the longest entry prefixing the name
+func priceFor(prices map[string]usage.ModelPrice, model string) (usage.ModelPrice, bool) {
+	model = strings.ToLower(model)
+	if p, ok := prices[model]; ok {
+		return p, true
+	}
+	var best usage.ModelPrice
+	found := false
+	for name, p := range prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best.Model) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// estimateCost prices a call in USD
+func estimateCost(price usage.ModelPrice, promptTokens, completionTokens int, audioSeconds float64) float64 {
+	return float64(promptTokens)*price.PromptPerMillion/1e6 +
+		float64(completionTokens)*price.CompletionPerMillion/1e6 +
+		audioSeconds/60*price.AudioPerMinute
+}
+
+// Record stores a provider call reported by the AI service. It is installed with
+// ai.SetUsageRecorder and writes in the background so replies are not delayed.
+func (s *UsageService) Record(ctx context.Context, event aiService.UsageEvent) {
+	s.mu.RLock()
+	price, ok := priceFor(s.prices, event.Model)
+	s.mu.RUnlock()
+
+	rec := &usage.Record{
+		AgentID:          event.AgentID,
+		ConversationID:   event.ConversationID,
+		FlowID:           event.FlowID,
+		FlowExecutionID:  event.FlowExecutionID,
+		Operation:        event.Operation,
+		Provider:         event.Provider,
+		Model:            event.Model,
+		PromptTokens:     event.PromptTokens,
+		CompletionTokens: event.CompletionTokens,
+		AudioSeconds:     event.AudioSeconds,
+		LatencyMs:        event.Latency.Milliseconds(),
+		CreatedAt:        time.Now(),
+	}
+	if ok {
+		rec.Cost = estimateCost(price, event.PromptTokens, event.CompletionTokens, event.AudioSeconds)
+	}
+	if event.Err != nil {
+		rec.Error = event.Err.Error()
+	}
+
+	go func() {
+		if err := s.repo.AddRecord(context.Background(), rec); err != nil {
+			logrus.Warnf("⚠️  [Usage] Failed to record %s usage: %v", rec.Operation, err)
+		}
+	}()
+}
+
+// GetPrices returns the effective pricing table
+func (s *UsageService) GetPrices(ctx context.Context) []usage.ModelPrice {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prices := make([]usage.ModelPrice, 0, len(s.prices))
+	for _, p := range s.prices {
+		prices = append(prices, p)
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Model < prices[j].Model })
+	return prices
+}
+
+// SavePrice sets the price of a model; it applies to calls recorded from now on
+func (s *UsageService) SavePrice(ctx context.Context, price *usage.ModelPrice) error {
+	price.Model = strings.ToLower(strings.TrimSpace(price.Model))
+	if price.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if price.PromptPerMillion < 0 || price.CompletionPerMillion < 0 || price.AudioPerMinute < 0 {
+		return fmt.Errorf("prices must not be negative")
+	}
+	if err := s.repo.SavePrice(ctx, price); err != nil {
+		return err
+	}
+	return s.reloadPrices(ctx)
+}
+
+// DeletePrice removes a configured price; built-in defaults apply again
+func (s *UsageService) DeletePrice(ctx context.Context, model string) error {
+	if err := s.repo.DeletePrice(ctx, strings.ToLower(model)); err != nil {
+		return err
+	}
+	return s.reloadPrices(ctx)
+}
+
+// Summarize aggregates usage, per group when groupBy is set
+func (s *UsageService) Summarize(ctx context.Context, filter usage.Filter, groupBy string) ([]usage.Totals, error) {
+	return s.repo.Summarize(ctx, filter, groupBy)
+}
+
+// ListRecords returns the newest usage records matching the filter
+func (s *UsageService) ListRecords(ctx context.Context, filter usage.Filter) ([]*usage.Record, error) {
+	return s.repo.ListRecords(ctx, filter)
+}
+
+// monthStart returns the first instant of the month containing t
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// GetBudget reports an agent's spending this month against its budget settings
+func (s *UsageService) GetBudget(ctx context.Context, agentID string, cfg settings.BudgetSettings) (*usage.Budget, error) {
+	start := monthStart(time.Now())
+	spent, err := s.repo.TotalCost(ctx, agentID, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent spending: %w", err)
+	}
+
+	budget := &usage.Budget{
+		AgentID:      agentID,
+		Enabled:      cfg.Enabled,
+		MonthlyLimit: cfg.MonthlyLimit,
+		Spent:        spent,
+		PeriodStart:  start,
+	}
+	if cfg.Enabled && cfg.MonthlyLimit > 0 {
+		budget.Remaining = max(cfg.MonthlyLimit-spent, 0)
+		budget.Exceeded = spent >= cfg.MonthlyLimit
+	}
+	return budget, nil
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/usage"
+)
+
+func TestPriceForLongestPrefix(t *testing.T) {
+	prices := map[string]usage.ModelPrice{}
+	for _, p := range defaultModelPrices {
+		prices[p.Model] = p
+	}
+
+	tests := []struct {
+		model string
+		want  string
+		found bool
+	}{
+		{"gpt-4o", "gpt-4o", true},
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini", true},
+		{"GPT-4o-2024-08-06", "gpt-4o", true},
+		{"claude-3-5-sonnet-20241022", "claude-3-5-sonnet", true},
+		{"llama-3-70b", "", false},
+	}
+	for _, tt := range tests {
+		price, ok := priceFor(prices, tt.model)
+		if ok != tt.found || price.Model != tt.want {
+			t.Errorf("priceFor(%q) = %q, %v; want %q, %v", tt.model, price.Model, ok, tt.want, tt.found)
+		}
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	price := usage.ModelPrice{PromptPerMillion: 2.5, CompletionPerMillion: 10, AudioPerMinute: 0.006}
+
+	if got := estimateCost(price, 1000, 500, 0); math.Abs(got-0.0075) > 1e-9 {
+		t.Errorf("token cost = %v, want 0.0075", got)
+	}
+	if got := estimateCost(price, 0, 0, 90); math.Abs(got-0.009) > 1e-9 {
+		t.Errorf("audio cost = %v, want 0.009", got)
+	}
+}
@@ -16,6 +16,8 @@ const FlowBuilder = {
                 <input v-model="flowName" type="text" 
                        class="bg-transparent text-white text-lg font-semibold focus:outline-none border-b border-transparent hover:border-dark-border focus:border-primary-500"
                        placeholder="Flow name">
+                <span v-if="flowId && version" class="px-2 py-0.5 bg-dark-bg rounded text-xs text-dark-muted">v{{ version }}</span>
+                <span v-if="hasDraft" class="px-2 py-0.5 bg-yellow-900/50 rounded text-xs text-yellow-300" title="Saved changes that are not published yet">Draft</span>
             </div>
             <div class="flex items-center gap-3">
                 <!-- Zoom Controls -->
@@ -35,17 +37,43 @@ const FlowBuilder = {
                         Reset
                     </button>
                 </div>
+                <button v-if="flowId" @click="toggleExecutions"
+                        class="px-3 py-2 rounded-lg text-sm transition-colors"
+                        :class="showExecutions ? 'bg-dark-border text-white' : 'text-dark-muted hover:bg-dark-border hover:text-white'">
+                    Executions
+                </button>
+                <button v-if="flowId" @click="toggleVersions"
+                        class="px-3 py-2 rounded-lg text-sm transition-colors"
+                        :class="showVersions ? 'bg-dark-border text-white' : 'text-dark-muted hover:bg-dark-border hover:text-white'">
+                    Versions
+                </button>
+                <button v-if="flowId" @click="toggleTest"
+                        class="px-3 py-2 rounded-lg text-sm transition-colors"
+                        :class="showTest ? 'bg-dark-border text-white' : 'text-dark-muted hover:bg-dark-border hover:text-white'">
+                    Test
+                </button>
                 <label class="flex items-center gap-2 text-sm text-dark-muted">
                     <input type="checkbox" v-model="isActive" class="rounded">
                     Active
                 </label>
                 <button @click="saveFlow" :disabled="saving" 
                         class="px-4 py-2 bg-primary-600 hover:bg-primary-500 text-white rounded-lg transition-colors disabled:opacity-50">
-                    {{ saving ? 'Saving...' : 'Save' }}
+                    {{ saving ? 'Saving...' : (flowId ? 'Save Draft' : 'Save') }}
+                </button>
+                <button v-if="flowId" @click="publishFlow" :disabled="saving || publishing"
+                        class="px-4 py-2 bg-green-600 hover:bg-green-500 text-white rounded-lg transition-colors disabled:opacity-50"
+                        title="Save and make these changes the version that runs">
+                    {{ publishing ? 'Publishing...' : 'Publish' }}
                 </button>
             </div>
         </div>
 
+        <!-- Save Error -->
+        <div v-if="saveError" class="absolute top-16 left-1/2 -translate-x-1/2 z-50 max-w-xl flex items-start gap-3 px-4 py-2 bg-red-900/90 border border-red-700 rounded-lg text-sm text-red-100">
+            <span class="flex-1">{{ saveError }}</span>
+            <button @click="saveError = ''" class="text-red-300 hover:text-white">✕</button>
+        </div>
+
         <div class="flex h-[calc(100vh-3.5rem)]">
             <!-- Sidebar - Node Palette -->
             <div class="w-56 bg-dark-card border-r border-dark-border overflow-y-auto">
@@ -145,7 +173,8 @@ const FlowBuilder = {
                         
                         <div :class="['w-56 rounded-xl border-2 transition-all cursor-move select-none', 
                                       selectedNode?.id === node.id ? 'border-primary-500 shadow-lg shadow-primary-500/30' : 'border-dark-border',
-                                      getNodeBgClass(node.type)]">
+                                      getNodeBgClass(node.type)]"
+                             :style="{ minHeight: getNodeMinHeight(node) + 'px' }">
                             <!-- Node Header -->
                             <div class="flex items-center gap-2 p-2.5 border-b border-dark-border/50">
                                 <span class="text-base">{{ getNodeIcon(node.type) }}</span>
@@ -170,7 +199,7 @@ const FlowBuilder = {
                             </div>
                             
                             <!-- Output Connection Point (right) -->
-                            <div v-if="node.type !== 'condition'"
+                            <div v-if="node.type !== 'condition' && node.type !== 'wait_for_reply' && !getNamedOutputs(node).length"
                                  class="absolute -right-3 top-1/2 -translate-y-1/2 w-5 h-5 bg-dark-border rounded-full border-2 border-dark-bg cursor-pointer hover:bg-primary-500 hover:border-primary-400 transition-colors flex items-center justify-center"
                                  @mousedown.stop="onConnectStart($event, node)">
                                 <div class="w-2 h-2 bg-dark-bg rounded-full"></div>
@@ -183,6 +212,20 @@ const FlowBuilder = {
                                 <div class="absolute -right-3 top-2/3 -translate-y-1/2 w-5 h-5 bg-red-500 rounded-full border-2 border-dark-bg cursor-pointer hover:bg-red-400 transition-colors flex items-center justify-center text-[8px] text-white font-bold"
                                      @mousedown.stop="onConnectStart($event, node, 'false')">✗</div>
                             </template>
+
+                            <!-- Wait for reply outputs (reply/timeout) -->
+                            <template v-if="node.type === 'wait_for_reply'">
+                                <div class="absolute -right-3 top-1/3 -translate-y-1/2 w-5 h-5 bg-green-500 rounded-full border-2 border-dark-bg cursor-pointer hover:bg-green-400 transition-colors flex items-center justify-center text-[8px] text-white font-bold"
+                                     title="Reply" @mousedown.stop="onConnectStart($event, node, 'reply')">💬</div>
+                                <div class="absolute -right-3 top-2/3 -translate-y-1/2 w-5 h-5 bg-red-500 rounded-full border-2 border-dark-bg cursor-pointer hover:bg-red-400 transition-colors flex items-center justify-center text-[8px] text-white font-bold"
+                                     title="Timeout" @mousedown.stop="onConnectStart($event, node, 'timeout')">⌛</div>
+                            </template>
+
+                            <!-- Named outputs (switch cases, for each body/done, parallel branch/done) -->
+                            <div v-for="output in getNamedOutputs(node)" :key="output"
+                                 class="absolute -right-3 -translate-y-1/2 h-5 px-1.5 bg-blue-500 rounded-full border-2 border-dark-bg cursor-pointer hover:bg-blue-400 transition-colors flex items-center text-[9px] text-white font-bold"
+                                 :style="{ top: getHandleY(node, output) + 'px' }"
+                                 :title="output" @mousedown.stop="onConnectStart($event, node, output)">{{ output }}</div>
                         </div>
                     </div>
                 </div>
@@ -201,6 +244,120 @@ const FlowBuilder = {
                                    class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
                         </div>
 
+                        <!-- Message Trigger Properties -->
+                        <template v-if="isMessageTrigger(selectedNode.type)">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Integration</label>
+                                <select v-model="selectedNode.data.integration_id"
+                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                    <option value="">Any</option>
+                                    <option v-for="integration in integrationsFor(selectedNode.type)" :key="integration.id" :value="integration.id">
+                                        {{ integration.details || integration.id }}
+                                    </option>
+                                </select>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Message Types</label>
+                                <div class="flex flex-wrap gap-3">
+                                    <label v-for="type in messageTypes" :key="type" class="flex items-center gap-1 text-sm text-dark-muted">
+                                        <input type="checkbox" class="rounded"
+                                               :checked="(selectedNode.data.message_types || []).includes(type)"
+                                               @change="toggleMessageType(type)">
+                                        {{ type }}
+                                    </label>
+                                </div>
+                                <p class="mt-1 text-xs text-dark-muted">None checked = any type</p>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Keywords</label>
+                                <input type="text" :value="(selectedNode.data.filter_keywords || []).join(', ')"
+                                       @change="selectedNode.data.filter_keywords = $event.target.value.split(',').map(k => k.trim()).filter(Boolean)"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="order, refund">
+                                <p class="mt-1 text-xs text-dark-muted">Comma separated; runs when the message contains any of them</p>
+                            </div>
+                        </template>
+
+                        <!-- Webhook Trigger Properties -->
+                        <template v-if="selectedNode.type === 'trigger_webhook'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Webhook URL</label>
+                                <template v-if="webhookUrl(selectedNode)">
+                                    <input type="text" readonly :value="webhookUrl(selectedNode)" @focus="$event.target.select()"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none font-mono text-xs">
+                                    <button @click="selectedNode.data.webhook_token = ''" class="mt-1 text-xs text-red-400 hover:text-red-300">
+                                        Regenerate on save
+                                    </button>
+                                </template>
+                                <p v-else class="text-xs text-dark-muted">Save the flow to generate the URL</p>
+                                <p class="mt-1 text-xs text-dark-muted">POST JSON or form data; fields and query parameters become variables</p>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Response</label>
+                                <select v-model="selectedNode.data.response_mode"
+                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                    <option value="async">Accept immediately (202)</option>
+                                    <option value="sync">Wait and return execution result</option>
+                                    <option value="output">Wait and return flow output as body</option>
+                                </select>
+                            </div>
+                            <div v-if="selectedNode.data.response_mode === 'output'">
+                                <label class="block text-sm text-dark-muted mb-1">Response Variable</label>
+                                <input v-model="selectedNode.data.response_variable" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="ai_response (empty = whole output)">
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Signing Secret</label>
+                                <input v-model="selectedNode.data.webhook_secret" type="password"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="Optional">
+                                <p class="mt-1 text-xs text-dark-muted">Requires a hex HMAC-SHA256 signature of the body</p>
+                            </div>
+                            <div v-if="selectedNode.data.webhook_secret">
+                                <label class="block text-sm text-dark-muted mb-1">Signature Header</label>
+                                <input v-model="selectedNode.data.signature_header" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="X-Signature">
+                            </div>
+                        </template>
+
+                        <!-- Schedule Trigger Properties -->
+                        <template v-if="selectedNode.type === 'trigger_schedule'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Cron Expression</label>
+                                <input v-model="selectedNode.data.cron" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none font-mono"
+                                       placeholder="0 9 * * MON-FRI">
+                                <div class="mt-1 flex flex-wrap gap-1">
+                                    <button v-for="preset in cronPresets" :key="preset.cron" @click="selectedNode.data.cron = preset.cron"
+                                            class="px-2 py-0.5 text-xs bg-dark-bg border border-dark-border rounded text-dark-muted hover:text-white">
+                                        {{ preset.label }}
+                                    </button>
+                                </div>
+                                <p class="mt-1 text-xs text-dark-muted">minute hour day month weekday</p>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Timezone</label>
+                                <input v-model="selectedNode.data.timezone" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="Asia/Jakarta">
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Missed Runs</label>
+                                <select v-model="selectedNode.data.missed_runs"
+                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                    <option value="skip">Skip, wait for the next time</option>
+                                    <option value="catch_up">Run once when back up</option>
+                                </select>
+                                <p class="mt-1 text-xs text-dark-muted">When the server was down at the scheduled time</p>
+                            </div>
+                            <p v-if="scheduleStatus(selectedNode)?.error" class="text-xs text-red-400">{{ scheduleStatus(selectedNode).error }}</p>
+                            <p v-else-if="scheduleStatus(selectedNode)?.next_run_at" class="text-xs text-dark-muted">
+                                Next run: {{ new Date(scheduleStatus(selectedNode).next_run_at).toLocaleString() }}
+                            </p>
+                        </template>
+
                         <!-- AI Agent Properties -->
                         <template v-if="selectedNode.type === 'ai_agent'">
                             <div>
@@ -252,6 +409,7 @@ const FlowBuilder = {
                                     <option value="DELETE">DELETE</option>
                                     <option value="PATCH">PATCH</option>
                                 </select>
+                                <p class="mt-1 text-xs text-dark-muted">Test runs only send GET requests; other methods are recorded instead</p>
                             </div>
                             <div>
                                 <label class="block text-sm text-dark-muted mb-1">URL</label>
@@ -309,13 +467,162 @@ const FlowBuilder = {
                                 <label class="block text-sm text-dark-muted mb-1">SQL Query</label>
                                 <textarea v-model="selectedNode.data.query" rows="4"
                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm font-mono focus:border-primary-500 focus:outline-none resize-none"
-                                          placeholder="SELECT * FROM users WHERE id = $1"></textarea>
+                                          placeholder="SELECT * FROM users WHERE id = {{user_id}}"></textarea>
                             </div>
                             <div v-if="selectedNode.data.operation === 'select' || selectedNode.data.operation === 'update' || selectedNode.data.operation === 'delete'">
                                 <label class="block text-sm text-dark-muted mb-1">WHERE</label>
                                 <input v-model="selectedNode.data.where" type="text"
                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm font-mono focus:border-primary-500 focus:outline-none"
-                                       placeholder="id = 1">
+                                       placeholder="phone = {{sender}}">
+                            </div>
+                            <p class="text-xs text-dark-muted" v-pre>Values such as {{sender}} are sent as query parameters, never as SQL.</p>
+                        </template>
+
+                        <!-- Email Properties -->
+                        <template v-if="selectedNode.type === 'email'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">SMTP Server</label>
+                                <select v-model="selectedNode.data.credential_id"
+                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                    <option value="">Select server...</option>
+                                    <option v-for="cred in credentials.filter(c => c.type === 'smtp')" :key="cred.id" :value="cred.id">
+                                        {{ cred.name }}
+                                    </option>
+                                </select>
+                                <button @click="showSMTPCredentialModal = true" class="mt-2 text-xs text-primary-400 hover:text-primary-300">
+                                    + Add SMTP server
+                                </button>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">To</label>
+                                <input v-model="selectedNode.data.to" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="ana@example.com, {{email}}">
+                            </div>
+                            <div class="flex gap-2">
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">CC</label>
+                                    <input v-model="selectedNode.data.cc" type="text"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                </div>
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">BCC</label>
+                                    <input v-model="selectedNode.data.bcc" type="text"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                </div>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Subject</label>
+                                <input v-model="selectedNode.data.subject" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="Order {{order.id}} confirmed">
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Text Body</label>
+                                <textarea v-model="selectedNode.data.body" rows="4"
+                                          class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none resize-none"></textarea>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">HTML Body (optional)</label>
+                                <textarea v-model="selectedNode.data.html" rows="4"
+                                          class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none font-mono resize-none"
+                                          placeholder="<p>Hello {{name}}</p>"></textarea>
+                                <p class="text-xs text-dark-muted mt-1">Values are HTML-escaped. With both bodies, mail apps pick the one they can show.</p>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Attachments</label>
+                                <div v-for="(attachment, index) in (selectedNode.data.attachments || [])" :key="index"
+                                     class="mb-2 p-2 bg-dark-bg border border-dark-border rounded-lg space-y-2">
+                                    <input v-model="attachment.variable" type="text"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                           placeholder="Variable, e.g. attachments">
+                                    <input v-model="attachment.url" type="text"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                           placeholder="or URL">
+                                    <div class="flex gap-2">
+                                        <input v-model="attachment.file_name" type="text"
+                                               class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                               placeholder="File name (optional)">
+                                        <button @click="selectedNode.data.attachments.splice(index, 1)"
+                                                class="px-2 text-red-400 hover:text-red-300">✕</button>
+                                    </div>
+                                </div>
+                                <button @click="addEmailAttachment"
+                                        class="text-xs text-primary-400 hover:text-primary-300">
+                                    + Add attachment
+                                </button>
+                                <p class="text-xs text-dark-muted mt-1" v-pre>Media received by a WhatsApp trigger is in {{attachments}}.</p>
+                            </div>
+                        </template>
+
+                        <!-- Google Sheets Properties -->
+                        <template v-if="selectedNode.type === 'google_sheets'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Service Account</label>
+                                <select v-model="selectedNode.data.credential_id"
+                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                    <option value="">Select account...</option>
+                                    <option v-for="cred in credentials.filter(c => c.type === 'google_sheets')" :key="cred.id" :value="cred.id">
+                                        {{ cred.name }}
+                                    </option>
+                                </select>
+                                <button @click="showSheetsCredentialModal = true" class="mt-2 text-xs text-primary-400 hover:text-primary-300">
+                                    + Add service account
+                                </button>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Spreadsheet ID</label>
+                                <input v-model="selectedNode.data.spreadsheet_id" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none font-mono"
+                                       placeholder="From the sheet URL: /d/<ID>/edit">
+                            </div>
+                            <div class="flex gap-2">
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">Sheet</label>
+                                    <input v-model="selectedNode.data.sheet" type="text"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                           placeholder="Sheet1">
+                                </div>
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">Operation</label>
+                                    <select v-model="selectedNode.data.operation"
+                                            class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                        <option value="append">Append Row</option>
+                                        <option value="lookup">Lookup Row</option>
+                                        <option value="update">Update Row</option>
+                                        <option value="read">Read Range</option>
+                                    </select>
+                                </div>
+                            </div>
+                            <template v-if="selectedNode.data.operation === 'lookup' || selectedNode.data.operation === 'update'">
+                                <div class="flex gap-2">
+                                    <div class="flex-1">
+                                        <label class="block text-sm text-dark-muted mb-1">Lookup Column</label>
+                                        <input v-model="selectedNode.data.lookup_column" type="text"
+                                               class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                               placeholder="Phone">
+                                    </div>
+                                    <div class="flex-1">
+                                        <label class="block text-sm text-dark-muted mb-1">Lookup Value</label>
+                                        <input v-model="selectedNode.data.lookup_value" type="text"
+                                               class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                               placeholder="{{sender}}">
+                                    </div>
+                                </div>
+                            </template>
+                            <div v-if="selectedNode.data.operation === 'read'">
+                                <label class="block text-sm text-dark-muted mb-1">Range</label>
+                                <input v-model="selectedNode.data.range" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none font-mono"
+                                       placeholder="A1:D50 (whole sheet when empty)">
+                            </div>
+                            <div v-if="selectedNode.data.operation === 'append' || selectedNode.data.operation === 'update'">
+                                <label class="block text-sm text-dark-muted mb-1">Values by Column</label>
+                                <textarea :value="JSON.stringify(selectedNode.data.values || {}, null, 2)" rows="4"
+                                          @change="setSheetValues($event.target.value)"
+                                          class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none font-mono resize-none"
+                                          placeholder='{"Name": "{{name}}", "Phone": "{{sender}}"}'></textarea>
+                                <p class="text-xs text-dark-muted mt-1">Columns are the headers in the first row of the sheet.</p>
                             </div>
                         </template>
 
@@ -340,6 +647,7 @@ const FlowBuilder = {
                                     <option value="lt">Less Than (<)</option>
                                     <option value="empty">Is Empty</option>
                                     <option value="not_empty">Is Not Empty</option>
+                                    <option value="matches">Matches Regex</option>
                                 </select>
                             </div>
                             <div>
@@ -348,6 +656,82 @@ const FlowBuilder = {
                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
                                        placeholder="hello">
                             </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Expression (optional)</label>
+                                <input v-model="selectedNode.data.expression" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm font-mono focus:border-primary-500 focus:outline-none"
+                                       placeholder="order.total * qty >= 100">
+                                <p class="mt-1 text-xs text-dark-muted">Must also be true. Field accepts expressions too, such as <code>items.length</code>.</p>
+                            </div>
+                        </template>
+
+                        <!-- Switch Properties -->
+                        <template v-if="selectedNode.type === 'switch'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Field</label>
+                                <input v-model="selectedNode.data.field" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="order.status">
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Cases</label>
+                                <div v-for="(item, index) in selectedNode.data.cases" :key="index" class="mb-2 p-2 bg-dark-bg border border-dark-border rounded-lg space-y-1">
+                                    <div class="flex gap-1">
+                                        <input v-model="item.name" type="text" placeholder="Output name"
+                                               class="flex-1 min-w-0 px-2 py-1 bg-dark-card border border-dark-border rounded text-white text-xs focus:border-primary-500 focus:outline-none">
+                                        <button @click="selectedNode.data.cases.splice(index, 1)" class="px-2 text-dark-muted hover:text-red-400">✕</button>
+                                    </div>
+                                    <div class="flex gap-1">
+                                        <select v-model="item.operator"
+                                                class="w-24 px-1 py-1 bg-dark-card border border-dark-border rounded text-white text-xs focus:border-primary-500 focus:outline-none">
+                                            <option value="eq">==</option>
+                                            <option value="ne">!=</option>
+                                            <option value="contains">contains</option>
+                                            <option value="starts_with">starts with</option>
+                                            <option value="ends_with">ends with</option>
+                                            <option value="gt">&gt;</option>
+                                            <option value="lt">&lt;</option>
+                                            <option value="empty">is empty</option>
+                                        </select>
+                                        <input v-model="item.value" type="text" placeholder="Value"
+                                               class="flex-1 min-w-0 px-2 py-1 bg-dark-card border border-dark-border rounded text-white text-xs focus:border-primary-500 focus:outline-none">
+                                    </div>
+                                </div>
+                                <button @click="selectedNode.data.cases.push({ name: '', operator: 'eq', value: '' })"
+                                        class="w-full px-3 py-1.5 border border-dashed border-dark-border rounded-lg text-xs text-dark-muted hover:text-white hover:border-primary-500">
+                                    + Add case
+                                </button>
+                                <p class="mt-1 text-xs text-dark-muted">The first matching case picks the output; otherwise the run continues on <code>default</code>.</p>
+                            </div>
+                        </template>
+
+                        <!-- For Each Properties -->
+                        <template v-if="selectedNode.type === 'for_each'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Items</label>
+                                <input v-model="selectedNode.data.items" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="orders">
+                                <p class="mt-1 text-xs text-dark-muted">Variable holding an array</p>
+                            </div>
+                            <div class="flex gap-2">
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">Item variable</label>
+                                    <input v-model="selectedNode.data.item_variable" type="text" placeholder="item"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                </div>
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">Index variable</label>
+                                    <input v-model="selectedNode.data.index_variable" type="text" placeholder="index"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                </div>
+                            </div>
+                            <p class="text-xs text-dark-muted">Runs the nodes on <code>body</code> once per item, then continues on <code>done</code>. Connect the last body node back here to mark the end of the body.</p>
+                        </template>
+
+                        <!-- Parallel Properties -->
+                        <template v-if="selectedNode.type === 'parallel'">
+                            <p class="text-xs text-dark-muted">Every node connected to <code>branch</code> starts a branch. Branches run at the same time with their own copy of the variables; once all finished, their changes are merged and the run continues on <code>done</code>. Branches cannot contain delays or waits for a reply.</p>
                         </template>
 
                         <!-- Send Message Properties -->
@@ -357,12 +741,94 @@ const FlowBuilder = {
                                 <textarea v-model="selectedNode.data.message" rows="4"
                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none resize-none"
                                           placeholder="Hello! {{ai_response}}"></textarea>
-                                <p class="mt-1 text-xs text-dark-muted">Use {{variable}} for dynamic content</p>
+                                <p class="mt-1 text-xs text-dark-muted" v-pre>Use {{variable}} or expressions such as {{ total * 2 }}, {{ name ?? "friend" }} or {{ formatDate(created_at, "DD/MM/YYYY") }}</p>
                             </div>
                             <div class="flex items-center gap-2">
                                 <input type="checkbox" v-model="selectedNode.data.reply_to_trigger" id="replyToTrigger" class="rounded">
                                 <label for="replyToTrigger" class="text-sm text-dark-muted">Reply to trigger message</label>
                             </div>
+                            <template v-if="!selectedNode.data.reply_to_trigger">
+                                <div>
+                                    <label class="block text-sm text-dark-muted mb-1">Integration</label>
+                                    <select v-model="selectedNode.data.integration_id"
+                                            class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                        <option value="">Same as trigger</option>
+                                        <option v-for="integration in integrations" :key="integration.id" :value="integration.id">
+                                            {{ integration.type }}: {{ integration.details || integration.id }}
+                                        </option>
+                                    </select>
+                                </div>
+                                <div>
+                                    <label class="block text-sm text-dark-muted mb-1">Recipient</label>
+                                    <input v-model="selectedNode.data.recipient" type="text"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                           placeholder="628123456789 or {{customer_phone}}">
+                                    <p class="mt-1 text-xs text-dark-muted">Phone/JID, Telegram chat ID or Instagram user ID</p>
+                                </div>
+                            </template>
+                        </template>
+
+                        <!-- Send Image / File Properties -->
+                        <template v-if="selectedNode.type === 'send_image' || selectedNode.type === 'send_file'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Media URL</label>
+                                <input v-model="selectedNode.data.media_url" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="https://example.com/receipt.png">
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Or Variable</label>
+                                <input v-model="selectedNode.data.media_variable" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="http_response.body.image">
+                                <p class="mt-1 text-xs text-dark-muted">Holding a URL, data URL or base64 content</p>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Or Upload</label>
+                                <input type="file" @change="uploadMedia" :disabled="uploadingMedia"
+                                       :accept="selectedNode.type === 'send_image' ? 'image/jpeg,image/png' : ''"
+                                       class="w-full text-sm text-dark-muted">
+                                <p v-if="uploadingMedia" class="mt-1 text-xs text-dark-muted">Uploading...</p>
+                                <p v-else-if="selectedNode.data.media_file" class="mt-1 text-xs text-dark-muted">
+                                    Uploaded: {{ selectedNode.data.file_name || selectedNode.data.media_file }}
+                                    <button @click="selectedNode.data.media_file = ''" class="ml-1 text-red-400 hover:text-red-300">Remove</button>
+                                </p>
+                            </div>
+                            <div v-if="selectedNode.type === 'send_file'">
+                                <label class="block text-sm text-dark-muted mb-1">File Name</label>
+                                <input v-model="selectedNode.data.file_name" type="text"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                       placeholder="invoice-{{order_id}}.pdf">
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Caption</label>
+                                <textarea v-model="selectedNode.data.caption" rows="2"
+                                          class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none resize-none"
+                                          placeholder="Your receipt for {{order_id}}"></textarea>
+                            </div>
+                            <div class="flex items-center gap-2">
+                                <input type="checkbox" v-model="selectedNode.data.reply_to_trigger" id="mediaReplyToTrigger" class="rounded">
+                                <label for="mediaReplyToTrigger" class="text-sm text-dark-muted">Reply to trigger message</label>
+                            </div>
+                            <template v-if="!selectedNode.data.reply_to_trigger">
+                                <div>
+                                    <label class="block text-sm text-dark-muted mb-1">Integration</label>
+                                    <select v-model="selectedNode.data.integration_id"
+                                            class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                        <option value="">Same as trigger</option>
+                                        <option v-for="integration in integrations" :key="integration.id" :value="integration.id">
+                                            {{ integration.type }}: {{ integration.details || integration.id }}
+                                        </option>
+                                    </select>
+                                </div>
+                                <div>
+                                    <label class="block text-sm text-dark-muted mb-1">Recipient</label>
+                                    <input v-model="selectedNode.data.recipient" type="text"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none"
+                                           placeholder="628123456789 or {{customer_phone}}">
+                                    <p class="mt-1 text-xs text-dark-muted">Phone/JID, Telegram chat ID or Instagram user ID</p>
+                                </div>
+                            </template>
                         </template>
 
                         <!-- Delay Properties -->
@@ -379,13 +845,232 @@ const FlowBuilder = {
                                             class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
                                         <option value="seconds">Seconds</option>
                                         <option value="minutes">Minutes</option>
+                                        <option value="hours">Hours</option>
+                                        <option value="days">Days</option>
                                     </select>
                                 </div>
                             </div>
+                            <p class="text-xs text-dark-muted">The run is saved and continues after the delay, also after a restart.</p>
+                        </template>
+
+                        <!-- Wait for Reply Properties -->
+                        <template v-if="selectedNode.type === 'wait_for_reply'">
+                            <div class="flex gap-2">
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">Timeout</label>
+                                    <input v-model.number="selectedNode.data.timeout" type="number" min="1"
+                                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                </div>
+                                <div class="flex-1">
+                                    <label class="block text-sm text-dark-muted mb-1">Unit</label>
+                                    <select v-model="selectedNode.data.unit"
+                                            class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                        <option value="minutes">Minutes</option>
+                                        <option value="hours">Hours</option>
+                                        <option value="days">Days</option>
+                                    </select>
+                                </div>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Integration</label>
+                                <select v-model="selectedNode.data.integration_id"
+                                        class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                                    <option value="">Same as trigger</option>
+                                    <option v-for="integration in integrations" :key="integration.id" :value="integration.id">
+                                        {{ integration.type }}: {{ integration.details || integration.id }}
+                                    </option>
+                                </select>
+                            </div>
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">Contact</label>
+                                <input v-model="selectedNode.data.recipient" type="text" placeholder="Sender of the trigger message"
+                                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                            </div>
+                            <p class="text-xs text-dark-muted">Continues on ✓ reply with the contact's next message in <code v-pre>{{reply}}</code>, or on ✗ timeout when none arrives in time.</p>
+                        </template>
+
+                        <!-- Code Properties -->
+                        <template v-if="selectedNode.type === 'code'">
+                            <div>
+                                <label class="block text-sm text-dark-muted mb-1">JavaScript</label>
+                                <textarea v-model="selectedNode.data.code" rows="10" spellcheck="false"
+                                          class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm font-mono focus:border-primary-500 focus:outline-none"
+                                          placeholder="return { total: vars.items.length };"></textarea>
+                            </div>
+                            <p class="text-xs text-dark-muted">Read the flow variables from <code>vars</code> and the trigger data from <code>input</code>. The returned object is merged into the variables; <code>console.log</code> lines are kept in <code>_logs</code>. Scripts have no network or file access and are stopped after 2 seconds or 64 MB.</p>
                         </template>
                     </div>
                 </div>
             </div>
+
+            <!-- Executions Panel -->
+            <div v-if="showExecutions" class="w-80 bg-dark-card border-l border-dark-border overflow-y-auto">
+                <div class="p-4">
+                    <template v-if="!selectedExecution">
+                        <div class="flex items-center justify-between mb-3">
+                            <h3 class="text-lg font-semibold text-white">Executions</h3>
+                            <button @click="loadExecutions" class="text-xs text-primary-400 hover:text-primary-300">Refresh</button>
+                        </div>
+                        <select v-model="executionStatus" @change="loadExecutions"
+                                class="w-full mb-3 px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                            <option value="">All statuses</option>
+                            <option value="success">Success</option>
+                            <option value="failed">Failed</option>
+                            <option value="running">Running</option>
+                        </select>
+                        <p v-if="loadingExecutions" class="text-sm text-dark-muted">Loading...</p>
+                        <p v-else-if="executions.length === 0" class="text-sm text-dark-muted">No executions yet</p>
+                        <div class="space-y-1.5">
+                            <div v-for="execution in executions" :key="execution.id" @click="openExecution(execution)"
+                                 class="p-2 bg-dark-bg rounded-lg cursor-pointer hover:bg-dark-border transition-colors">
+                                <div class="flex items-center justify-between text-sm">
+                                    <span :class="executionStatusClass(execution.status)">{{ execution.status }}</span>
+                                    <span class="text-xs text-dark-muted">{{ execution.duration_ms }} ms</span>
+                                </div>
+                                <div class="text-xs text-dark-muted">{{ new Date(execution.started_at).toLocaleString() }} · {{ execution.trigger_type }}<template v-if="execution.flow_version"> · v{{ execution.flow_version }}</template></div>
+                                <div v-if="execution.error" class="text-xs text-red-400 truncate">{{ execution.error }}</div>
+                            </div>
+                        </div>
+                        <p v-if="executionTotal > executions.length" class="text-xs text-dark-muted mt-2">Showing {{ executions.length }} of {{ executionTotal }}</p>
+                    </template>
+
+                    <template v-else>
+                        <button @click="selectedExecution = null" class="text-xs text-primary-400 hover:text-primary-300 mb-2">&larr; Executions</button>
+                        <h3 class="text-lg font-semibold text-white">
+                            <span :class="executionStatusClass(selectedExecution.status)">{{ selectedExecution.status }}</span>
+                        </h3>
+                        <p class="text-xs text-dark-muted mb-2">{{ new Date(selectedExecution.started_at).toLocaleString() }} · {{ selectedExecution.duration_ms }} ms · {{ selectedExecution.flow_version ? 'v' + selectedExecution.flow_version : 'unversioned' }}</p>
+                        <p v-if="selectedExecution.error" class="text-xs text-red-400 mb-2">{{ selectedExecution.error }}</p>
+                        <details class="mb-3">
+                            <summary class="text-sm text-dark-muted cursor-pointer">Input</summary>
+                            <pre class="text-xs text-white bg-dark-bg rounded p-2 overflow-x-auto">{{ JSON.stringify(selectedExecution.input, null, 2) }}</pre>
+                        </details>
+                        <div class="space-y-2">
+                            <div v-for="(step, i) in selectedExecution.trace || []" :key="i" class="p-2 bg-dark-bg rounded-lg">
+                                <div class="flex items-center justify-between text-sm">
+                                    <span class="text-white">{{ getNodeIcon(step.node_type) }} {{ step.label || step.node_type }}</span>
+                                    <span class="text-xs text-dark-muted">{{ step.duration_ms }} ms</span>
+                                </div>
+                                <div v-if="step.handle" class="text-xs text-dark-muted">Branch: {{ step.handle }}</div>
+                                <div v-if="step.error" class="text-xs text-red-400">{{ step.error }}</div>
+                                <details>
+                                    <summary class="text-xs text-dark-muted cursor-pointer">Input</summary>
+                                    <pre class="text-xs text-white overflow-x-auto">{{ JSON.stringify(step.input, null, 2) }}</pre>
+                                </details>
+                                <details>
+                                    <summary class="text-xs text-dark-muted cursor-pointer">Output</summary>
+                                    <pre class="text-xs text-white overflow-x-auto">{{ JSON.stringify(step.output, null, 2) }}</pre>
+                                </details>
+                            </div>
+                        </div>
+                    </template>
+                </div>
+            </div>
+
+            <!-- Test Panel -->
+            <div v-if="showTest" class="w-80 bg-dark-card border-l border-dark-border overflow-y-auto">
+                <div class="p-4">
+                    <h3 class="text-lg font-semibold text-white mb-1">Test Run</h3>
+                    <p class="text-xs text-dark-muted mb-3">Runs the editor's flow, saved as the draft, without sending messages or email, calling webhooks or writing to databases and sheets. GET requests and AI calls still go out.</p>
+                    <label class="block text-sm text-dark-muted mb-1">Trigger Input (JSON)</label>
+                    <textarea v-model="testInput" rows="6"
+                              class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-xs font-mono focus:border-primary-500 focus:outline-none"></textarea>
+                    <label class="block text-sm text-dark-muted mt-2 mb-1">Reply for Wait for Reply</label>
+                    <input v-model="testReply" type="text"
+                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
+                           placeholder="Empty to time out">
+                    <button @click="runTest" :disabled="testing"
+                            class="w-full mt-3 px-4 py-2 bg-primary-600 hover:bg-primary-500 text-white rounded-lg text-sm transition-colors disabled:opacity-50">
+                        {{ testing ? 'Running...' : 'Run Test' }}
+                    </button>
+                    <p v-if="testError" class="text-xs text-red-400 mt-2">{{ testError }}</p>
+
+                    <template v-if="testResult">
+                        <h4 class="text-sm font-semibold mt-4">
+                            <span :class="executionStatusClass(testResult.status)">{{ testResult.status }}</span>
+                            <span class="text-xs text-dark-muted font-normal"> · {{ diffLabel(testResult.version) }} · {{ testResult.duration_ms }} ms</span>
+                        </h4>
+                        <p v-if="testResult.error" class="text-xs text-red-400 mb-2">{{ testResult.error }}</p>
+                        <div v-if="testResult.replies.length" class="mb-2">
+                            <div class="text-xs text-dark-muted">Replies</div>
+                            <div v-for="(reply, i) in testResult.replies" :key="i" class="text-xs text-white bg-dark-bg rounded p-1.5 mt-1 whitespace-pre-wrap">{{ reply }}</div>
+                        </div>
+                        <details class="mb-3">
+                            <summary class="text-sm text-dark-muted cursor-pointer">Variables</summary>
+                            <pre class="text-xs text-white bg-dark-bg rounded p-2 overflow-x-auto">{{ JSON.stringify(testResult.variables, null, 2) }}</pre>
+                        </details>
+                        <div class="space-y-2">
+                            <div v-for="(step, i) in testResult.trace" :key="i" class="p-2 bg-dark-bg rounded-lg">
+                                <div class="flex items-center justify-between text-sm">
+                                    <span class="text-white">{{ getNodeIcon(step.node_type) }} {{ step.label || step.node_type }}</span>
+                                    <span class="text-xs text-dark-muted">{{ step.duration_ms }} ms</span>
+                                </div>
+                                <div v-if="step.stubbed" class="text-xs text-yellow-300">Stubbed: recorded, not performed</div>
+                                <div v-if="step.handle" class="text-xs text-dark-muted">Branch: {{ step.handle }}</div>
+                                <div v-if="step.error" class="text-xs text-red-400">{{ step.error }}</div>
+                                <details>
+                                    <summary class="text-xs text-dark-muted cursor-pointer">Output</summary>
+                                    <pre class="text-xs text-white overflow-x-auto">{{ JSON.stringify(step.output, null, 2) }}</pre>
+                                </details>
+                            </div>
+                        </div>
+                    </template>
+                </div>
+            </div>
+
+            <!-- Versions Panel -->
+            <div v-if="showVersions" class="w-80 bg-dark-card border-l border-dark-border overflow-y-auto">
+                <div class="p-4">
+                    <div class="flex items-center justify-between mb-3">
+                        <h3 class="text-lg font-semibold text-white">Versions</h3>
+                        <button @click="loadVersions" class="text-xs text-primary-400 hover:text-primary-300">Refresh</button>
+                    </div>
+                    <div v-if="hasDraft" class="p-2 mb-3 bg-dark-bg rounded-lg">
+                        <div class="text-sm text-yellow-300">Unpublished draft</div>
+                        <div class="flex gap-3 mt-1">
+                            <button @click="showDiff(String(version), 'draft')" class="text-xs text-primary-400 hover:text-primary-300">Changes</button>
+                            <button @click="discardDraft" class="text-xs text-red-400 hover:text-red-300">Discard</button>
+                        </div>
+                    </div>
+                    <input v-model="publishNote" type="text"
+                           class="w-full mb-3 px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
+                           placeholder="Note for the next publish (optional)">
+                    <p v-if="loadingVersions" class="text-sm text-dark-muted">Loading...</p>
+                    <div class="space-y-1.5">
+                        <div v-for="v in versions" :key="v.version" class="p-2 bg-dark-bg rounded-lg">
+                            <div class="flex items-center justify-between text-sm">
+                                <span class="text-white">v{{ v.version }}</span>
+                                <span v-if="v.version === version" class="text-xs text-green-400">Published</span>
+                            </div>
+                            <div class="text-xs text-dark-muted">{{ new Date(v.created_at).toLocaleString() }}</div>
+                            <div v-if="v.note" class="text-xs text-dark-muted truncate">{{ v.note }}</div>
+                            <div class="flex gap-3 mt-1">
+                                <button v-if="v.version > 1" @click="showDiff(String(v.version - 1), String(v.version))" class="text-xs text-primary-400 hover:text-primary-300">Changes</button>
+                                <button v-if="v.version !== version" @click="rollbackFlow(v.version)" class="text-xs text-primary-400 hover:text-primary-300">Roll back</button>
+                            </div>
+                        </div>
+                    </div>
+
+                    <div v-if="versionDiff" class="mt-4">
+                        <div class="flex items-center justify-between mb-2">
+                            <h4 class="text-sm font-semibold text-white">{{ diffLabel(versionDiff.from) }} &rarr; {{ diffLabel(versionDiff.to) }}</h4>
+                            <button @click="versionDiff = null" class="text-xs text-dark-muted hover:text-white">✕</button>
+                        </div>
+                        <div class="space-y-1 text-xs">
+                            <div v-for="node in versionDiff.added_nodes" :key="'a' + node.id" class="text-green-400">+ {{ getNodeIcon(node.type) }} {{ node.label || node.type }}</div>
+                            <div v-for="node in versionDiff.removed_nodes" :key="'r' + node.id" class="text-red-400">− {{ getNodeIcon(node.type) }} {{ node.label || node.type }}</div>
+                            <div v-for="change in versionDiff.changed_nodes" :key="'c' + change.node_id" class="text-yellow-300">
+                                ~ {{ change.label || change.node_id }}: {{ change.fields.join(', ') }}
+                            </div>
+                            <div v-if="versionDiff.added_edges.length" class="text-green-400">+ {{ versionDiff.added_edges.length }} connection(s)</div>
+                            <div v-if="versionDiff.removed_edges.length" class="text-red-400">− {{ versionDiff.removed_edges.length }} connection(s)</div>
+                            <div v-if="versionDiff.variables_changed" class="text-yellow-300">~ Variables</div>
+                            <p v-if="!versionDiff.added_nodes.length && !versionDiff.removed_nodes.length && !versionDiff.changed_nodes.length && !versionDiff.added_edges.length && !versionDiff.removed_edges.length && !versionDiff.variables_changed"
+                               class="text-dark-muted">No changes</p>
+                        </div>
+                    </div>
+                </div>
+            </div>
         </div>
 
         <!-- Database Credential Modal -->
@@ -400,6 +1085,23 @@ const FlowBuilder = {
                                class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
                                placeholder="My Supabase DB">
                     </div>
+                    <div>
+                        <label class="block text-sm text-dark-muted mb-1">Driver</label>
+                        <select v-model="newCredential.driver" @change="onDriverChange"
+                                class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm">
+                            <option value="postgres">PostgreSQL / Supabase</option>
+                            <option value="mysql">MySQL</option>
+                            <option value="sqlite">SQLite</option>
+                        </select>
+                    </div>
+                    <div v-if="newCredential.driver === 'sqlite'">
+                        <label class="block text-sm text-dark-muted mb-1">Database File</label>
+                        <input v-model="newCredential.database" type="text"
+                               class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
+                               placeholder="shop.db">
+                        <p class="text-xs text-dark-muted mt-1">A file in the storages/databases directory</p>
+                    </div>
+                    <template v-else>
                     <div>
                         <label class="block text-sm text-dark-muted mb-1">Host</label>
                         <input v-model="newCredential.host" type="text"
@@ -441,6 +1143,17 @@ const FlowBuilder = {
                             <option value="verify-full">Verify Full</option>
                         </select>
                     </div>
+                    </template>
+                    <div>
+                        <label class="block text-sm text-dark-muted mb-1">Allowed Tables</label>
+                        <input v-model="newCredential.tables" type="text"
+                               class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
+                               placeholder="customers, orders (empty for any table)">
+                    </div>
+                    <label class="flex items-center gap-2 text-sm text-dark-muted">
+                        <input v-model="newCredential.read_only" type="checkbox" class="rounded">
+                        Read-only (SELECT queries only)
+                    </label>
                 </div>
 
                 <div class="flex gap-3 mt-6">
@@ -456,6 +1169,120 @@ const FlowBuilder = {
             </div>
         </div>
 
+        <!-- SMTP Credential Modal -->
+        <div v-if="showSMTPCredentialModal" class="fixed inset-0 z-[60] flex items-center justify-center bg-black/70 backdrop-blur-sm">
+            <div class="bg-dark-card rounded-2xl p-6 max-w-md w-full mx-4 border border-dark-border">
+                <h3 class="text-xl font-bold text-white mb-4">Add SMTP Server</h3>
+
+                <div class="space-y-4">
+                    <div>
+                        <label class="block text-sm text-dark-muted mb-1">Name</label>
+                        <input v-model="newSMTPCredential.name" type="text"
+                               class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
+                               placeholder="Company mail">
+                    </div>
+                    <div class="flex gap-2">
+                        <div class="flex-1">
+                            <label class="block text-sm text-dark-muted mb-1">Host</label>
+                            <input v-model="newSMTPCredential.host" type="text"
+                                   class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
+                                   placeholder="smtp.example.com">
+                        </div>
+                        <div class="w-24">
+                            <label class="block text-sm text-dark-muted mb-1">Port</label>
+                            <input v-model.number="newSMTPCredential.port" type="number"
+                                   class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm">
+                        </div>
+                    </div>
+                    <div>
+                        <label class="block text-sm text-dark-muted mb-1">Security</label>
+                        <select v-model="newSMTPCredential.security"
+                                class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm">
+                            <option value="starttls">STARTTLS (port 587)</option>
+                            <option value="tls">TLS (port 465)</option>
+                            <option value="none">None</option>
+                        </select>
+                    </div>
+                    <div class="flex gap-2">
+                        <div class="flex-1">
+                            <label class="block text-sm text-dark-muted mb-1">User</label>
+                            <input v-model="newSMTPCredential.user" type="text"
+                                   class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm">
+                        </div>
+                        <div class="flex-1">
+                            <label class="block text-sm text-dark-muted mb-1">Password</label>
+                            <input v-model="newSMTPCredential.password" type="password"
+                                   class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm">
+                        </div>
+                    </div>
+                    <div class="flex gap-2">
+                        <div class="flex-1">
+                            <label class="block text-sm text-dark-muted mb-1">From Name</label>
+                            <input v-model="newSMTPCredential.from_name" type="text"
+                                   class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm">
+                        </div>
+                        <div class="flex-1">
+                            <label class="block text-sm text-dark-muted mb-1">From Email</label>
+                            <input v-model="newSMTPCredential.from_email" type="email"
+                                   class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm">
+                        </div>
+                    </div>
+                    <p v-if="smtpTestResult" class="text-sm" :class="smtpTestResult.ok ? 'text-emerald-400' : 'text-red-400'">
+                        {{ smtpTestResult.message }}
+                    </p>
+                </div>
+
+                <div class="flex gap-3 mt-6">
+                    <button @click="showSMTPCredentialModal = false"
+                            class="flex-1 py-2 bg-dark-border hover:bg-dark-muted/20 text-white rounded-lg">
+                        Cancel
+                    </button>
+                    <button @click="testSMTPCredential" :disabled="testingSMTP"
+                            class="flex-1 py-2 bg-dark-border hover:bg-dark-muted/20 text-white rounded-lg">
+                        {{ testingSMTP ? 'Testing...' : 'Test' }}
+                    </button>
+                    <button @click="saveSMTPCredential" :disabled="savingCredential"
+                            class="flex-1 py-2 bg-primary-600 hover:bg-primary-500 text-white rounded-lg">
+                        {{ savingCredential ? 'Saving...' : 'Save' }}
+                    </button>
+                </div>
+            </div>
+        </div>
+
+        <!-- Google Sheets Credential Modal -->
+        <div v-if="showSheetsCredentialModal" class="fixed inset-0 z-[60] flex items-center justify-center bg-black/70 backdrop-blur-sm">
+            <div class="bg-dark-card rounded-2xl p-6 max-w-md w-full mx-4 border border-dark-border">
+                <h3 class="text-xl font-bold text-white mb-4">Add Google Service Account</h3>
+
+                <div class="space-y-4">
+                    <div>
+                        <label class="block text-sm text-dark-muted mb-1">Name</label>
+                        <input v-model="newSheetsCredential.name" type="text"
+                               class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm"
+                               placeholder="Sales sheets">
+                    </div>
+                    <div>
+                        <label class="block text-sm text-dark-muted mb-1">Service Account Key (JSON)</label>
+                        <textarea v-model="newSheetsCredential.service_account_json" rows="6"
+                                  class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-xs font-mono resize-none"
+                                  placeholder='{"type": "service_account", "client_email": "...", "private_key": "..."}'></textarea>
+                        <p class="text-xs text-dark-muted mt-1">Share each spreadsheet with the client_email of the account.</p>
+                    </div>
+                </div>
+
+                <div class="flex gap-3 mt-6">
+                    <button @click="showSheetsCredentialModal = false"
+                            class="flex-1 py-2 bg-dark-border hover:bg-dark-muted/20 text-white rounded-lg">
+                        Cancel
+                    </button>
+                    <button @click="saveSheetsCredential" :disabled="savingCredential"
+                            class="flex-1 py-2 bg-primary-600 hover:bg-primary-500 text-white rounded-lg">
+                        {{ savingCredential ? 'Saving...' : 'Save' }}
+                    </button>
+                </div>
+            </div>
+        </div>
+
         <!-- OpenAI Credential Modal -->
         <div v-if="showOpenAICredentialModal" class="fixed inset-0 z-[60] flex items-center justify-center bg-black/70 backdrop-blur-sm">
             <div class="bg-dark-card rounded-2xl p-6 max-w-md w-full mx-4 border border-dark-border">
@@ -498,10 +1325,34 @@ const FlowBuilder = {
         const flowName = ref('New Flow');
         const isActive = ref(true);
         const saving = ref(false);
+        const saveError = ref('');
         const nodes = ref([]);
         const edges = ref([]);
         const selectedNode = ref(null);
         const credentials = ref([]);
+        const integrations = ref([]);
+        const uploadingMedia = ref(false);
+        const schedules = ref([]);
+        const showExecutions = ref(false);
+        const executions = ref([]);
+        const executionTotal = ref(0);
+        const executionStatus = ref('');
+        const loadingExecutions = ref(false);
+        const selectedExecution = ref(null);
+        const version = ref(0);
+        const hasDraft = ref(false);
+        const publishing = ref(false);
+        const publishNote = ref('');
+        const showVersions = ref(false);
+        const versions = ref([]);
+        const loadingVersions = ref(false);
+        const versionDiff = ref(null);
+        const showTest = ref(false);
+        const testInput = ref(JSON.stringify({ message: 'Hello', sender: '628123456789', integration_id: '' }, null, 2));
+        const testReply = ref('');
+        const testing = ref(false);
+        const testError = ref('');
+        const testResult = ref(null);
         const canvas = ref(null);
 
         // Zoom and pan
@@ -525,17 +1376,38 @@ const FlowBuilder = {
         const savingCredential = ref(false);
         const newCredential = reactive({
             name: '',
+            driver: 'postgres',
             host: '',
             port: 5432,
             database: 'postgres',
             user: 'postgres',
             password: '',
-            ssl_mode: 'require'
+            ssl_mode: 'require',
+            read_only: false,
+            tables: ''
         });
         const newOpenAICredential = reactive({
             name: '',
             api_key: ''
         });
+        const showSheetsCredentialModal = ref(false);
+        const newSheetsCredential = reactive({
+            name: '',
+            service_account_json: ''
+        });
+        const showSMTPCredentialModal = ref(false);
+        const testingSMTP = ref(false);
+        const smtpTestResult = ref(null);
+        const newSMTPCredential = reactive({
+            name: '',
+            host: '',
+            port: 587,
+            security: 'starttls',
+            user: '',
+            password: '',
+            from_name: '',
+            from_email: ''
+        });
 
         // Node definitions
         const triggerNodes = [
@@ -543,21 +1415,31 @@ const FlowBuilder = {
             { type: 'trigger_telegram', label: 'Telegram', icon: '✈️' },
             { type: 'trigger_instagram', label: 'Instagram', icon: '📷' },
             { type: 'trigger_webhook', label: 'Webhook', icon: '🪝' },
+            { type: 'trigger_schedule', label: 'Schedule', icon: '⏰' },
         ];
 
         const aiNodes = [
             { type: 'ai_agent', label: 'AI Agent', icon: '🤖' },
             { type: 'condition', label: 'Condition', icon: '⚡' },
             { type: 'delay', label: 'Delay', icon: '⏱️' },
+            { type: 'wait_for_reply', label: 'Wait for Reply', icon: '💬' },
+            { type: 'code', label: 'Code', icon: '🧩' },
+            { type: 'switch', label: 'Switch', icon: '🔀' },
+            { type: 'for_each', label: 'For Each', icon: '🔁' },
+            { type: 'parallel', label: 'Parallel', icon: '🛤️' },
         ];
 
         const integrationNodes = [
             { type: 'http_request', label: 'HTTP Request', icon: '🌐' },
             { type: 'database', label: 'Database', icon: '🗄️' },
+            { type: 'email', label: 'Email', icon: '📧' },
+            { type: 'google_sheets', label: 'Google Sheets', icon: '📊' },
         ];
 
         const actionNodes = [
             { type: 'send_message', label: 'Send Message', icon: '💬' },
+            { type: 'send_image', label: 'Send Image', icon: '🖼️' },
+            { type: 'send_file', label: 'Send File', icon: '📎' },
         ];
 
         // Methods
@@ -590,18 +1472,42 @@ const FlowBuilder = {
 
         const getDefaultNodeData = (type) => {
             switch (type) {
+                case 'trigger_whatsapp':
+                case 'trigger_telegram':
+                case 'trigger_instagram':
+                    return { integration_id: '', message_types: [], filter_keywords: [] };
+                case 'trigger_schedule':
+                    return { cron: '0 9 * * *', timezone: Intl.DateTimeFormat().resolvedOptions().timeZone || 'UTC', missed_runs: 'skip' };
+                case 'trigger_webhook':
+                    return { response_mode: 'async', response_variable: '', webhook_secret: '', signature_header: 'X-Signature' };
                 case 'ai_agent':
                     return { model: 'gpt-4o-mini', system_prompt: '', api_key: '', credential_id: '' };
                 case 'http_request':
                     return { method: 'GET', url: '', headers_json: '', body: '' };
                 case 'database':
                     return { credential_id: '', operation: 'select', table: '', query: '', where: '' };
+                case 'email':
+                    return { credential_id: '', to: '', cc: '', bcc: '', subject: '', body: '', html: '', attachments: [] };
+                case 'google_sheets':
+                    return { credential_id: '', spreadsheet_id: '', sheet: 'Sheet1', operation: 'append', values: {}, lookup_column: '', lookup_value: '', range: '' };
                 case 'condition':
                     return { field: '', operator: 'eq', value: '' };
                 case 'send_message':
-                    return { message: '{{ai_response}}', reply_to_trigger: true };
+                    return { message: '{{ai_response}}', reply_to_trigger: true, integration_id: '', recipient: '' };
+                case 'send_image':
+                    return { media_url: '', media_variable: '', media_file: '', caption: '', reply_to_trigger: true, integration_id: '', recipient: '' };
+                case 'send_file':
+                    return { media_url: '', media_variable: '', media_file: '', file_name: '', caption: '', reply_to_trigger: true, integration_id: '', recipient: '' };
                 case 'delay':
                     return { duration: 1, unit: 'seconds' };
+                case 'wait_for_reply':
+                    return { timeout: 1, unit: 'hours', integration_id: '', recipient: '' };
+                case 'switch':
+                    return { field: '', cases: [{ name: 'case1', operator: 'eq', value: '' }] };
+                case 'for_each':
+                    return { items: '', item_variable: 'item', index_variable: 'index' };
+                case 'code':
+                    return { language: 'javascript', code: 'return { greeting: "Hello " + (vars.name || "there") };' };
                 default:
                     return {};
             }
@@ -663,9 +1569,7 @@ const FlowBuilder = {
             edgeStart.node = node;
             edgeStart.handle = handle;
             edgeStart.x = node.position.x + 224;
-            edgeStart.y = node.position.y + 40;
-            if (handle === 'true') edgeStart.y = node.position.y + 27;
-            if (handle === 'false') edgeStart.y = node.position.y + 53;
+            edgeStart.y = node.position.y + getHandleY(node, handle);
             
             const rect = canvas.value.getBoundingClientRect();
             edgeEnd.x = (event.clientX - rect.left - pan.x) / zoom.value;
@@ -692,16 +1596,41 @@ const FlowBuilder = {
             edgeStart.node = null;
         };
 
+        // Outputs of switch, for each and parallel nodes, top to bottom
+        const getNamedOutputs = (node) => {
+            switch (node.type) {
+                case 'switch':
+                    return [...(node.data.cases || []).map(c => c.name).filter(Boolean), 'default'];
+                case 'for_each':
+                    return ['body', 'done'];
+                case 'parallel':
+                    return ['branch', 'done'];
+                default:
+                    return [];
+            }
+        };
+
+        // Vertical offset of an output handle within its node
+        const getHandleY = (node, handle) => {
+            if (handle === 'true' || handle === 'reply') return 27;
+            if (handle === 'false' || handle === 'timeout') return 53;
+            const outputs = getNamedOutputs(node);
+            const index = outputs.indexOf(handle);
+            if (index < 0) return 40;
+            if (outputs.length <= 2) return index === 0 ? 27 : 53;
+            return 20 + index * 24;
+        };
+
+        // Nodes grow to fit their outputs
+        const getNodeMinHeight = (node) => Math.max(80, getNamedOutputs(node).length * 24 + 16);
+
         const getEdgePath = (edge) => {
             const sourceNode = nodes.value.find(n => n.id === edge.source);
             const targetNode = nodes.value.find(n => n.id === edge.target);
             if (!sourceNode || !targetNode) return '';
 
             const sx = sourceNode.position.x + 224;
-            let sy = sourceNode.position.y + 40;
-            
-            if (edge.source_handle === 'true') sy = sourceNode.position.y + 27;
-            if (edge.source_handle === 'false') sy = sourceNode.position.y + 53;
+            const sy = sourceNode.position.y + getHandleY(sourceNode, edge.source_handle);
 
             const tx = targetNode.position.x;
             const ty = targetNode.position.y + 40;
@@ -738,9 +1667,9 @@ const FlowBuilder = {
         const getNodeBgClass = (type) => {
             if (type.startsWith('trigger_')) return 'bg-emerald-900/50';
             if (type === 'ai_agent') return 'bg-purple-900/50';
-            if (type === 'condition') return 'bg-yellow-900/50';
-            if (type === 'http_request' || type === 'database') return 'bg-blue-900/50';
-            if (type === 'send_message') return 'bg-pink-900/50';
+            if (['condition', 'switch', 'for_each', 'parallel', 'wait_for_reply'].includes(type)) return 'bg-yellow-900/50';
+            if (['http_request', 'database', 'email', 'google_sheets'].includes(type)) return 'bg-blue-900/50';
+            if (type.startsWith('send_')) return 'bg-pink-900/50';
             return 'bg-dark-card';
         };
 
@@ -752,12 +1681,31 @@ const FlowBuilder = {
                     return `${node.data.method || 'GET'} ${node.data.url || 'Set URL'}`;
                 case 'database':
                     return `${node.data.operation?.toUpperCase() || 'SELECT'} ${node.data.table || '...'}`;
+                case 'email':
+                    return node.data.to ? `To ${node.data.to}` : 'Set recipients';
+                case 'google_sheets':
+                    return `${node.data.operation || 'append'} ${node.data.sheet || 'Sheet1'}`;
                 case 'condition':
                     return `${node.data.field || '...'} ${node.data.operator || '=='} ${node.data.value || '...'}`;
                 case 'send_message':
                     return (node.data.message?.substring(0, 25) || 'Set message') + '...';
+                case 'send_image':
+                case 'send_file':
+                    return node.data.file_name || node.data.media_url || (node.data.media_variable && `{{${node.data.media_variable}}}`) || 'Set media';
                 case 'delay':
                     return `Wait ${node.data.duration || 0} ${node.data.unit || 'seconds'}`;
+                case 'wait_for_reply':
+                    return `Reply within ${node.data.timeout || 0} ${node.data.unit || 'hours'}`;
+                case 'switch':
+                    return `${node.data.field || '...'}: ${(node.data.cases || []).length} cases`;
+                case 'for_each':
+                    return `Each ${node.data.item_variable || 'item'} in ${node.data.items || '...'}`;
+                case 'parallel':
+                    return `${edges.value.filter(e => e.source === node.id && e.source_handle === 'branch').length} branches`;
+                case 'code':
+                    return (node.data.code?.substring(0, 25) || 'Write code') + '...';
+                case 'trigger_schedule':
+                    return node.data.cron || 'Set schedule';
                 default:
                     return 'Trigger';
             }
@@ -786,16 +1734,28 @@ const FlowBuilder = {
             }
         };
 
+        const onDriverChange = () => {
+            const defaults = {
+                postgres: { port: 5432, database: 'postgres', user: 'postgres', ssl_mode: 'require' },
+                mysql: { port: 3306, database: '', user: 'root', ssl_mode: 'disable' },
+                sqlite: { port: 0, database: '', user: '', ssl_mode: 'disable' }
+            };
+            Object.assign(newCredential, defaults[newCredential.driver]);
+        };
+
         const saveCredential = async (type) => {
             savingCredential.value = true;
             try {
                 const config = JSON.stringify({
+                    driver: newCredential.driver,
                     host: newCredential.host,
                     port: newCredential.port,
                     database: newCredential.database,
                     user: newCredential.user,
                     password: newCredential.password,
-                    ssl_mode: newCredential.ssl_mode
+                    ssl_mode: newCredential.ssl_mode,
+                    read_only: newCredential.read_only,
+                    tables: newCredential.tables.split(',').map(t => t.trim()).filter(Boolean)
                 });
 
                 const response = await axios.post('/api/credentials', {
@@ -809,8 +1769,8 @@ const FlowBuilder = {
                 showCredentialModal.value = false;
                 
                 Object.assign(newCredential, {
-                    name: '', host: '', port: 5432, database: 'postgres',
-                    user: 'postgres', password: '', ssl_mode: 'require'
+                    name: '', driver: 'postgres', host: '', port: 5432, database: 'postgres',
+                    user: 'postgres', password: '', ssl_mode: 'require', read_only: false, tables: ''
                 });
             } catch (error) {
                 console.error('Failed to save credential:', error);
@@ -845,6 +1805,87 @@ const FlowBuilder = {
             }
         };
 
+        const saveSheetsCredential = async () => {
+            savingCredential.value = true;
+            try {
+                const response = await axios.post('/api/credentials', {
+                    agent_id: props.agentId,
+                    name: newSheetsCredential.name,
+                    type: 'google_sheets',
+                    config: JSON.stringify({ service_account_json: newSheetsCredential.service_account_json })
+                });
+
+                credentials.value.push(response.data.results);
+                showSheetsCredentialModal.value = false;
+
+                newSheetsCredential.name = '';
+                newSheetsCredential.service_account_json = '';
+            } catch (error) {
+                console.error('Failed to save credential:', error);
+            } finally {
+                savingCredential.value = false;
+            }
+        };
+
+        const setSheetValues = (text) => {
+            try {
+                const values = JSON.parse(text || '{}');
+                if (values && typeof values === 'object' && !Array.isArray(values)) {
+                    selectedNode.value.data.values = values;
+                }
+            } catch (error) {
+                console.error('Invalid values JSON:', error);
+            }
+        };
+
+        const addEmailAttachment = () => {
+            if (!selectedNode.value.data.attachments) selectedNode.value.data.attachments = [];
+            selectedNode.value.data.attachments.push({ variable: '', url: '', file_name: '' });
+        };
+
+        const smtpConfig = () => {
+            const { name, ...config } = newSMTPCredential;
+            return config;
+        };
+
+        const testSMTPCredential = async () => {
+            testingSMTP.value = true;
+            smtpTestResult.value = null;
+            try {
+                const response = await axios.post('/api/credentials/test-smtp', smtpConfig());
+                smtpTestResult.value = { ok: response.data.code === 'SUCCESS', message: response.data.message };
+            } catch (error) {
+                smtpTestResult.value = { ok: false, message: error.response?.data?.message || error.response?.data || error.message };
+            } finally {
+                testingSMTP.value = false;
+            }
+        };
+
+        const saveSMTPCredential = async () => {
+            savingCredential.value = true;
+            try {
+                const response = await axios.post('/api/credentials', {
+                    agent_id: props.agentId,
+                    name: newSMTPCredential.name,
+                    type: 'smtp',
+                    config: JSON.stringify(smtpConfig())
+                });
+
+                credentials.value.push(response.data.results);
+                showSMTPCredentialModal.value = false;
+                smtpTestResult.value = null;
+
+                Object.assign(newSMTPCredential, {
+                    name: '', host: '', port: 587, security: 'starttls',
+                    user: '', password: '', from_name: '', from_email: ''
+                });
+            } catch (error) {
+                console.error('Failed to save credential:', error);
+            } finally {
+                savingCredential.value = false;
+            }
+        };
+
         const loadFlow = async () => {
             if (!props.flowId) return;
             try {
@@ -852,13 +1893,225 @@ const FlowBuilder = {
                 const flow = response.data.results;
                 flowName.value = flow.name;
                 isActive.value = flow.is_active;
-                nodes.value = flow.nodes || [];
-                edges.value = flow.edges || [];
+                // Edit the unpublished changes when there are any
+                nodes.value = (flow.draft || flow).nodes || [];
+                edges.value = (flow.draft || flow).edges || [];
+                version.value = flow.version || 0;
+                hasDraft.value = !!flow.draft;
+                schedules.value = flow.schedules || [];
             } catch (error) {
                 console.error('Failed to load flow:', error);
             }
         };
 
+        // Message triggers: the integration type each listens on
+        const messageTriggerTypes = {
+            trigger_whatsapp: 'whatsapp',
+            trigger_telegram: 'telegram',
+            trigger_instagram: 'instagram'
+        };
+        const messageTypes = ['text', 'image', 'audio', 'document'];
+
+        const isMessageTrigger = (type) => type in messageTriggerTypes;
+
+        const integrationsFor = (type) => integrations.value.filter(i => i.type === messageTriggerTypes[type]);
+
+        const toggleMessageType = (type) => {
+            const current = selectedNode.value.data.message_types || [];
+            selectedNode.value.data.message_types = current.includes(type)
+                ? current.filter(t => t !== type)
+                : [...current, type];
+        };
+
+        const uploadMedia = async (event) => {
+            const file = event.target.files?.[0];
+            if (!file) return;
+            const node = selectedNode.value;
+            uploadingMedia.value = true;
+            try {
+                const formData = new FormData();
+                formData.append('file', file);
+                const response = await axios.post('/api/flows/media', formData);
+                node.data.media_file = response.data.results.media_file;
+                node.data.file_name = response.data.results.file_name;
+            } catch (error) {
+                console.error('Failed to upload media:', error);
+            } finally {
+                uploadingMedia.value = false;
+                event.target.value = '';
+            }
+        };
+
+        const cronPresets = [
+            { label: 'Hourly', cron: '0 * * * *' },
+            { label: 'Daily 9:00', cron: '0 9 * * *' },
+            { label: 'Weekdays 9:00', cron: '0 9 * * MON-FRI' },
+            { label: 'Mondays 9:00', cron: '0 9 * * MON' },
+            { label: 'Monthly', cron: '0 9 1 * *' },
+        ];
+
+        // Next run as computed by the server when the flow was loaded
+        const scheduleStatus = (node) => schedules.value.find(s => s.node_id === node.id && s.cron === node.data.cron && s.timezone === (node.data.timezone || 'UTC'));
+
+        const webhookUrl = (node) => {
+            if (!props.flowId || !node.data.webhook_token) return '';
+            return `${window.location.origin}/api/flows/${props.flowId}/webhook/${node.data.webhook_token}`;
+        };
+
+        const loadExecutions = async () => {
+            if (!props.flowId) return;
+            loadingExecutions.value = true;
+            try {
+                const params = executionStatus.value ? { status: executionStatus.value } : {};
+                const response = await axios.get(`/api/flows/${props.flowId}/executions`, { params });
+                executions.value = response.data.results?.executions || [];
+                executionTotal.value = response.data.results?.total || 0;
+            } catch (error) {
+                console.error('Failed to load executions:', error);
+                executions.value = [];
+            } finally {
+                loadingExecutions.value = false;
+            }
+        };
+
+        const openExecution = async (execution) => {
+            try {
+                const response = await axios.get(`/api/flows/${props.flowId}/executions/${execution.id}`);
+                selectedExecution.value = response.data.results;
+            } catch (error) {
+                console.error('Failed to load execution:', error);
+            }
+        };
+
+        const toggleExecutions = () => {
+            showExecutions.value = !showExecutions.value;
+            selectedExecution.value = null;
+            if (showExecutions.value) {
+                showVersions.value = false;
+                showTest.value = false;
+                loadExecutions();
+            }
+        };
+
+        const loadVersions = async () => {
+            if (!props.flowId) return;
+            loadingVersions.value = true;
+            try {
+                const response = await axios.get(`/api/flows/${props.flowId}/versions`);
+                versions.value = response.data.results || [];
+            } catch (error) {
+                console.error('Failed to load versions:', error);
+                versions.value = [];
+            } finally {
+                loadingVersions.value = false;
+            }
+        };
+
+        const toggleVersions = () => {
+            showVersions.value = !showVersions.value;
+            versionDiff.value = null;
+            if (showVersions.value) {
+                showExecutions.value = false;
+                showTest.value = false;
+                loadVersions();
+            }
+        };
+
+        const toggleTest = () => {
+            showTest.value = !showTest.value;
+            if (showTest.value) {
+                showExecutions.value = false;
+                showVersions.value = false;
+            }
+        };
+
+        // Saves the editor to the draft, which is what the test runs
+        const runTest = async () => {
+            testError.value = '';
+            let input;
+            try {
+                input = testInput.value.trim() ? JSON.parse(testInput.value) : {};
+            } catch (error) {
+                testError.value = 'Trigger input is not valid JSON: ' + error.message;
+                return;
+            }
+            testing.value = true;
+            try {
+                const saved = await axios.put(`/api/flows/${props.flowId}`, { nodes: nodes.value, edges: edges.value });
+                hasDraft.value = !!saved.data.results?.draft;
+                const response = await axios.post(`/api/flows/${props.flowId}/test`, { input, reply: testReply.value });
+                testResult.value = response.data.results;
+            } catch (error) {
+                console.error('Failed to test flow:', error);
+                const data = error.response?.data;
+                testError.value = (typeof data === 'string' ? data : data?.message) || 'Failed to test flow';
+            } finally {
+                testing.value = false;
+            }
+        };
+
+        const showDiff = async (from, to) => {
+            try {
+                const response = await axios.get(`/api/flows/${props.flowId}/versions/diff`, { params: { from, to } });
+                versionDiff.value = response.data.results;
+            } catch (error) {
+                console.error('Failed to load changes:', error);
+            }
+        };
+
+        const diffLabel = (ref) => ref === 'draft' ? 'Draft' : `v${ref}`;
+
+        const showRequestError = (error, fallback) => {
+            const data = error.response?.data;
+            saveError.value = (typeof data === 'string' ? data : data?.message) || fallback;
+        };
+
+        const rollbackFlow = async (target) => {
+            if (!confirm(`Publish version ${target} again? Triggers run it from now on; your draft is kept.`)) return;
+            try {
+                const response = await axios.post(`/api/flows/${props.flowId}/rollback`, { version: target, note: publishNote.value });
+                version.value = response.data.results.version;
+                publishNote.value = '';
+                if (!hasDraft.value) {
+                    nodes.value = response.data.results.nodes || [];
+                    edges.value = response.data.results.edges || [];
+                }
+                loadVersions();
+            } catch (error) {
+                console.error('Failed to roll back flow:', error);
+                showRequestError(error, 'Failed to roll back flow');
+            }
+        };
+
+        const discardDraft = async () => {
+            if (!confirm('Discard the unpublished changes?')) return;
+            try {
+                await axios.delete(`/api/flows/${props.flowId}/draft`);
+                selectedNode.value = null;
+                versionDiff.value = null;
+                await loadFlow();
+            } catch (error) {
+                console.error('Failed to discard draft:', error);
+                showRequestError(error, 'Failed to discard draft');
+            }
+        };
+
+        const executionStatusClass = (status) => ({
+            success: 'text-green-400',
+            failed: 'text-red-400',
+            running: 'text-yellow-400'
+        })[status] || 'text-dark-muted';
+
+        const loadIntegrations = async () => {
+            try {
+                const response = await axios.get(`/api/agents/${props.agentId}`);
+                integrations.value = response.data.results?.integrations || [];
+            } catch (error) {
+                console.error('Failed to load integrations:', error);
+                integrations.value = [];
+            }
+        };
+
         const loadCredentials = async () => {
             try {
                 const response = await axios.get(`/api/credentials?agent_id=${props.agentId}`);
@@ -871,6 +2124,7 @@ const FlowBuilder = {
 
         const saveFlow = async () => {
             saving.value = true;
+            saveError.value = '';
             try {
                 const flowData = {
                     agent_id: props.agentId,
@@ -890,14 +2144,38 @@ const FlowBuilder = {
                 emit('close');
             } catch (error) {
                 console.error('Failed to save flow:', error);
+                showRequestError(error, 'Failed to save flow');
             } finally {
                 saving.value = false;
             }
         };
 
+        // Saves the editor to the draft and publishes it
+        const publishFlow = async () => {
+            publishing.value = true;
+            saveError.value = '';
+            try {
+                await axios.put(`/api/flows/${props.flowId}`, {
+                    name: flowName.value,
+                    is_active: isActive.value,
+                    nodes: nodes.value,
+                    edges: edges.value
+                });
+                await axios.post(`/api/flows/${props.flowId}/publish`, { note: publishNote.value });
+                emit('save');
+                emit('close');
+            } catch (error) {
+                console.error('Failed to publish flow:', error);
+                showRequestError(error, 'Failed to publish flow');
+            } finally {
+                publishing.value = false;
+            }
+        };
+
         onMounted(() => {
             loadFlow();
             loadCredentials();
+            loadIntegrations();
             
             // Global mouse events for smooth dragging
             window.addEventListener('mousemove', onMouseMove);
@@ -910,15 +2188,25 @@ const FlowBuilder = {
         });
 
         return {
-            flowName, isActive, saving, nodes, edges, selectedNode, credentials, canvas,
+            flowName, isActive, saving, saveError, nodes, edges, selectedNode, credentials, canvas,
             zoom, pan,
             draggingNode, drawingEdge,
             showCredentialModal, showOpenAICredentialModal, savingCredential, newCredential, newOpenAICredential,
+            showSMTPCredentialModal, newSMTPCredential, testingSMTP, smtpTestResult, testSMTPCredential, saveSMTPCredential, addEmailAttachment,
+            showSheetsCredentialModal, newSheetsCredential, saveSheetsCredential, setSheetValues,
             triggerNodes, aiNodes, integrationNodes, actionNodes,
             onDragStart, onDrop, onNodeMouseDown, onCanvasMouseDown, onConnectStart, onConnectEnd,
             getEdgePath, getDrawingEdgePath, deleteNode, getNodeIcon, getNodeBgClass, getNodePreview,
+            getNamedOutputs, getHandleY, getNodeMinHeight,
             zoomIn, zoomOut, resetZoom, onWheel,
-            saveCredential, saveOpenAICredential, loadCredentials, saveFlow
+            saveCredential, onDriverChange, saveOpenAICredential, loadCredentials, saveFlow,
+            messageTypes, isMessageTrigger, integrationsFor, toggleMessageType,
+            integrations, uploadingMedia, uploadMedia, webhookUrl, cronPresets, scheduleStatus,
+            showExecutions, executions, executionTotal, executionStatus, loadingExecutions, selectedExecution,
+            loadExecutions, openExecution, toggleExecutions, executionStatusClass,
+            version, hasDraft, publishing, publishNote, publishFlow, showVersions, versions, loadingVersions, versionDiff,
+            loadVersions, toggleVersions, showDiff, diffLabel, rollbackFlow, discardDraft,
+            showTest, testInput, testReply, testing, testError, testResult, toggleTest, runTest
         };
     }
 };
@@ -146,6 +146,17 @@ const LiveChat = {
                           class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm resize-none h-24 focus:border-primary-500 focus:outline-none"></textarea>
             </div>
 
+            <div class="mt-4 pt-4 border-t border-dark-border">
+                <h3 class="text-sm font-semibold text-dark-muted uppercase tracking-wider mb-3">Language</h3>
+                <input v-model="conversationLanguage" @change="saveLanguage"
+                       placeholder="Auto-detect (e.g. en, es)" maxlength="2"
+                       class="w-full px-3 py-2 bg-dark-bg border border-dark-border rounded-lg text-white text-sm focus:border-primary-500 focus:outline-none">
+                <p class="text-xs text-dark-muted mt-1">
+                    {{ selectedConversation.language_manual ? 'Set manually' :
+                       (selectedConversation.language ? 'Detected (' + Math.round((selectedConversation.language_confidence || 0) * 100) + '% confidence)' : 'Not detected yet') }}
+                </p>
+            </div>
+
             <div class="mt-4 pt-4 border-t border-dark-border">
                 <h3 class="text-sm font-semibold text-dark-muted uppercase tracking-wider mb-3">Quick Actions</h3>
                 <div class="space-y-2">
@@ -170,6 +181,7 @@ const LiveChat = {
         const isManualMode = ref(false);
         const messagesContainer = ref(null);
         const conversationNotes = ref('');
+        const conversationLanguage = ref('');
 
         const filteredConversations = computed(() => {
             if (!searchQuery.value) return conversations.value;
@@ -198,6 +210,7 @@ const LiveChat = {
             selectedConversation.value = conv;
             isManualMode.value = conv.is_manual_mode || false;
             conversationNotes.value = conv.notes || '';
+            conversationLanguage.value = conv.language || '';
             await loadMessages(conv.id);
         };
 
@@ -271,6 +284,18 @@ const LiveChat = {
             }
         };
 
+        const saveLanguage = async () => {
+            if (!selectedConversation.value) return;
+            const language = conversationLanguage.value.trim().toLowerCase();
+            try {
+                await axios.put(`/api/conversations/${selectedConversation.value.id}/language`, { language });
+                selectedConversation.value.language = language;
+                selectedConversation.value.language_manual = language !== '';
+            } catch (error) {
+                console.error('Failed to save language:', error);
+            }
+        };
+
         const exportChat = () => {
             if (!selectedConversation.value) return;
             // Download CSV file
@@ -316,10 +341,10 @@ const LiveChat = {
 
         return {
             conversations, selectedConversation, messages, searchQuery,
-            newMessage, sending, isManualMode, messagesContainer, conversationNotes,
+            newMessage, sending, isManualMode, messagesContainer, conversationNotes, conversationLanguage,
             filteredConversations,
             loadConversations, selectConversation, sendMessage,
-            takeOver, releaseControl, saveNotes, exportChat,
+            takeOver, releaseControl, saveNotes, saveLanguage, exportChat,
             formatJID, formatTime, formatMessageTime, formatDate
         };
     }
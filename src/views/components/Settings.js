@@ -243,6 +243,137 @@ const AgentSettings = {
                            class="rounded bg-dark-border text-primary-500 focus:ring-primary-500">
                     <span class="text-white text-sm">Auto-escalate very negative messages to human</span>
                 </label>
+                <div v-if="settings.sentiment.escalate_on_very_negative">
+                    <label class="block text-sm font-medium text-dark-text mb-2">Hand-off Message</label>
+                    <input type="text" v-model="settings.sentiment.handoff_message"
+                           class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                </div>
+                <div>
+                    <label class="block text-sm font-medium text-dark-text mb-2">Alert Webhook URL (optional)</label>
+                    <input type="text" v-model="settings.sentiment.webhook_url" placeholder="https://..."
+                           class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                </div>
+            </div>
+
+            <div class="flex items-center justify-between p-4 bg-dark-bg rounded-xl">
+                <div>
+                    <p class="text-white font-medium">Image & Document Understanding</p>
+                    <p class="text-sm text-dark-muted">Show customer photos to the model and read PDFs</p>
+                </div>
+                <label class="relative inline-flex items-center cursor-pointer">
+                    <input type="checkbox" v-model="settings.vision.enabled" class="sr-only peer">
+                    <div class="w-11 h-6 bg-dark-border rounded-full peer peer-checked:bg-primary-500 
+                                after:content-[''] after:absolute after:top-[2px] after:left-[2px] 
+                                after:bg-white after:rounded-full after:h-5 after:w-5 after:transition-all 
+                                peer-checked:after:translate-x-full"></div>
+                </label>
+            </div>
+
+            <div v-if="settings.vision.enabled" class="space-y-4 pl-4">
+                <div>
+                    <label class="block text-sm font-medium text-dark-text mb-2">Vision Model (optional)</label>
+                    <input type="text" v-model="settings.vision.model" placeholder="Agent model"
+                           class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                    <p class="text-xs text-dark-muted mt-1">Used for messages with images; must support image input</p>
+                </div>
+                <div class="grid grid-cols-2 gap-4">
+                    <div>
+                        <label class="block text-sm font-medium text-dark-text mb-2">Max Images per Message</label>
+                        <input type="number" v-model.number="settings.vision.max_images" min="1" max="10"
+                               class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                    </div>
+                    <div>
+                        <label class="block text-sm font-medium text-dark-text mb-2">Max PDF Pages</label>
+                        <input type="number" v-model.number="settings.vision.max_document_pages" min="1" max="100"
+                               class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                    </div>
+                </div>
+            </div>
+
+            <div class="flex items-center justify-between p-4 bg-dark-bg rounded-xl">
+                <div>
+                    <p class="text-white font-medium">Voice Replies</p>
+                    <p class="text-sm text-dark-muted">Reply with a voice note when the customer sent one</p>
+                </div>
+                <label class="relative inline-flex items-center cursor-pointer">
+                    <input type="checkbox" v-model="settings.voice_reply.enabled" class="sr-only peer">
+                    <div class="w-11 h-6 bg-dark-border rounded-full peer peer-checked:bg-primary-500 
+                                after:content-[''] after:absolute after:top-[2px] after:left-[2px] 
+                                after:bg-white after:rounded-full after:h-5 after:w-5 after:transition-all 
+                                peer-checked:after:translate-x-full"></div>
+                </label>
+            </div>
+
+            <div v-if="settings.voice_reply.enabled" class="space-y-4 pl-4">
+                <div class="grid grid-cols-2 gap-4">
+                    <div>
+                        <label class="block text-sm font-medium text-dark-text mb-2">Voice</label>
+                        <select v-model="settings.voice_reply.voice"
+                                class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                            <option value="alloy">Alloy</option>
+                            <option value="echo">Echo</option>
+                            <option value="fable">Fable</option>
+                            <option value="onyx">Onyx</option>
+                            <option value="nova">Nova</option>
+                            <option value="shimmer">Shimmer</option>
+                        </select>
+                    </div>
+                    <div>
+                        <label class="block text-sm font-medium text-dark-text mb-2">Speech Model</label>
+                        <select v-model="settings.voice_reply.model"
+                                class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                            <option value="tts-1">tts-1</option>
+                            <option value="tts-1-hd">tts-1-hd</option>
+                        </select>
+                    </div>
+                </div>
+                <div>
+                    <label class="block text-sm font-medium text-dark-text mb-2">Max Characters</label>
+                    <input type="number" v-model.number="settings.voice_reply.max_chars" min="0" max="4096"
+                           class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                    <p class="text-xs text-dark-muted mt-1">Longer replies are sent as text (0 = no limit). Requires an OpenAI-compatible provider and ffmpeg</p>
+                </div>
+            </div>
+
+            <div class="p-4 bg-dark-bg rounded-xl">
+                <p class="text-white font-medium">Flows</p>
+                <p class="text-sm text-dark-muted mb-3">How messages are shared between flows with a WhatsApp, Telegram or Instagram trigger and the AI</p>
+                <select v-model="settings.flows.mode"
+                        class="w-full px-4 py-2 bg-dark-card border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                    <option value="flow_then_ai">Flows first, AI replies when no flow did</option>
+                    <option value="flow_only">Flows only, the AI never replies</option>
+                    <option value="ai_only">AI only, messages do not trigger flows</option>
+                </select>
+            </div>
+
+            <div class="flex items-center justify-between p-4 bg-dark-bg rounded-xl">
+                <div>
+                    <p class="text-white font-medium">Monthly Budget</p>
+                    <p class="text-sm text-dark-muted">Pause AI replies once the monthly spend is reached</p>
+                </div>
+                <label class="relative inline-flex items-center cursor-pointer">
+                    <input type="checkbox" v-model="settings.budget.enabled" class="sr-only peer">
+                    <div class="w-11 h-6 bg-dark-border rounded-full peer peer-checked:bg-primary-500 
+                                after:content-[''] after:absolute after:top-[2px] after:left-[2px] 
+                                after:bg-white after:rounded-full after:h-5 after:w-5 after:transition-all 
+                                peer-checked:after:translate-x-full"></div>
+                </label>
+            </div>
+
+            <div v-if="settings.budget.enabled" class="space-y-4 pl-4">
+                <div>
+                    <label class="block text-sm font-medium text-dark-text mb-2">Monthly Limit (USD)</label>
+                    <input type="number" v-model.number="settings.budget.monthly_limit" min="0" step="1"
+                           class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                    <p v-if="budget" class="text-xs text-dark-muted mt-1">
+                        Spent this month: \${{ budget.spent.toFixed(2) }}
+                    </p>
+                </div>
+                <div>
+                    <label class="block text-sm font-medium text-dark-text mb-2">Away Message</label>
+                    <input type="text" v-model="settings.budget.away_message"
+                           class="w-full px-4 py-2 bg-dark-bg border border-dark-border rounded-lg text-white focus:border-primary-500 focus:outline-none">
+                </div>
             </div>
         </div>
 
@@ -261,6 +392,7 @@ const AgentSettings = {
 
         const activeTab = ref('hours');
         const saving = ref(false);
+        const budget = ref(null);
         
         const tabs = [
             { id: 'hours', label: 'Working Hours', icon: '🕐' },
@@ -289,7 +421,8 @@ const AgentSettings = {
                 source_language: 'en',
                 auto_detect: true,
                 translate_incoming: true,
-                translate_outgoing: true
+                translate_outgoing: true,
+                min_confidence: 0.8
             },
             follow_up: {
                 enabled: false,
@@ -302,7 +435,31 @@ const AgentSettings = {
                 enabled: false,
                 alert_on_negative: true,
                 negative_threshold: 0.3,
-                escalate_on_very_negative: false
+                escalate_on_very_negative: false,
+                escalation_threshold: 0.7,
+                handoff_message: '',
+                webhook_url: ''
+            },
+            budget: {
+                enabled: false,
+                monthly_limit: 50,
+                away_message: ''
+            },
+            vision: {
+                enabled: false,
+                model: '',
+                max_images: 4,
+                max_document_pages: 10,
+                max_document_tokens: 4000
+            },
+            voice_reply: {
+                enabled: false,
+                voice: 'alloy',
+                model: 'tts-1',
+                max_chars: 1000
+            },
+            flows: {
+                mode: 'flow_then_ai'
             },
             max_tokens_per_msg: 500,
             temperature: 0.7
@@ -320,6 +477,12 @@ const AgentSettings = {
             } catch (error) {
                 console.error('Failed to load settings:', error);
             }
+            try {
+                const response = await axios.get(`/api/analytics/usage/budget/${props.agentId}`);
+                budget.value = response.data.results;
+            } catch (error) {
+                // Usage tracking is optional
+            }
         };
 
         const saveSettings = async () => {
@@ -345,7 +508,7 @@ const AgentSettings = {
         onMounted(loadSettings);
 
         return {
-            activeTab, tabs, settings, saving,
+            activeTab, tabs, settings, saving, budget,
             getDayName, saveSettings, addFollowUpMessage, removeFollowUpMessage
         };
     }